@@ -0,0 +1,349 @@
+package accountdeletion
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"go-aprendizaje/apikeys"
+	"go-aprendizaje/config"
+	"go-aprendizaje/core"
+	"go-aprendizaje/database"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/loginhistory"
+	"go-aprendizaje/media"
+	"go-aprendizaje/models"
+	"go-aprendizaje/oauth"
+	"go-aprendizaje/outbox"
+	"go-aprendizaje/security"
+	"go-aprendizaje/sessions"
+	"go-aprendizaje/utils"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Métodos de borrado (ACCOUNT_DELETION_MODE)
+const (
+	MethodHardDelete = "hard_delete" // Se borra la fila/documento del usuario
+	MethodAnonymize  = "anonymize"   // Se conservan la fila/documento pero sin datos personales
+)
+
+// RequestedBySelf es el 'requestedBy' de un borrado que pidió el propio usuario (los de un admin son "admin:<id>")
+const RequestedBySelf = "self"
+
+// ErrUserNotFound se devuelve si el usuario no existe
+var ErrUserNotFound = errors.New("usuario no encontrado")
+
+// ErrScheduledByAdmin se devuelve si el usuario intenta cancelar (o volver a pedir) un borrado que programó un administrador
+var ErrScheduledByAdmin = errors.New("el borrado de la cuenta lo programó un administrador")
+
+// BlocksLogin indica si un borrado programado impide iniciar sesión: el que programa un administrador bloquea
+// la cuenta; con el que pidió el propio usuario se puede entrar (para cancelarlo)
+func BlocksLogin(scheduledAt *time.Time, requestedBy string) bool {
+	return scheduledAt != nil && requestedBy != RequestedBySelf
+}
+
+// GracePeriod es el tiempo que pasa entre la petición y el borrado real (para poder arrepentirse)
+func GracePeriod() time.Duration {
+	grace, err := time.ParseDuration(config.GetEnv("ACCOUNT_DELETION_GRACE", "720h"))
+	if err != nil {
+		return 30 * 24 * time.Hour
+	}
+	return grace
+}
+
+// Schedule programa el borrado de una cuenta. 'requestedBy' es "self" o "admin:<id>".
+// Si 'immediate' es true (solo admins) se borra ya, sin periodo de gracia.
+// El usuario no puede pisar un borrado que programó un administrador (se convertiría en uno que puede cancelar).
+// En cualquier caso se revocan los tokens: el usuario tendrá que volver a iniciar sesión (por ejemplo, para cancelar).
+func Schedule(store string, userID string, requestedBy string, immediate bool) (time.Time, error) {
+	scheduledAt := time.Now().Add(GracePeriod())
+	if immediate {
+		scheduledAt = time.Now()
+	}
+
+	var email, locale string
+	if store == "mongo" {
+		user, err := core.MongoUserRepo.GetUserByID(userID)
+		if err != nil {
+			return time.Time{}, ErrUserNotFound
+		}
+		if requestedBy == RequestedBySelf && BlocksLogin(user.DeletionScheduledAt, user.DeletionRequestedBy) {
+			return time.Time{}, ErrScheduledByAdmin
+		}
+		if err := core.MongoUserRepo.ScheduleDeletion(user.ID, scheduledAt, requestedBy); err != nil {
+			return time.Time{}, err
+		}
+		email, locale = user.Email, user.Locale
+	} else {
+		var user models.User
+		if err := database.DB.First(&user, userID).Error; err != nil {
+			return time.Time{}, ErrUserNotFound
+		}
+		if requestedBy == RequestedBySelf && BlocksLogin(user.DeletionScheduledAt, user.DeletionRequestedBy) {
+			return time.Time{}, ErrScheduledByAdmin
+		}
+		now := time.Now()
+		err := database.DB.Model(&user).Updates(map[string]interface{}{
+			"deletion_scheduled_at": scheduledAt,
+			"deletion_requested_at": now,
+			"deletion_requested_by": requestedBy,
+		}).Error
+		if err != nil {
+			return time.Time{}, err
+		}
+		email, locale = user.Email, user.Locale
+	}
+
+	if err := security.RevokeUserTokens(store, userID); err != nil {
+		logging.Log.Errorf("No se pudieron revocar los tokens de %s/%s: %v", store, userID, err)
+	}
+
+	if immediate {
+		ProcessDue()
+	} else {
+		outbox.Send(utils.AccountDeletionScheduledEmail(locale, email, scheduledAt))
+	}
+
+	return scheduledAt, nil
+}
+
+// Cancel anula un borrado programado (solo mientras dure el periodo de gracia).
+// Con 'bySelf' (lo cancela el propio usuario) solo se anulan los borrados que pidió él: los de un administrador
+// solo los puede cancelar un administrador.
+func Cancel(store string, userID string, bySelf bool) error {
+	if store == "mongo" {
+		user, err := core.MongoUserRepo.GetUserByID(userID)
+		if err != nil {
+			return ErrUserNotFound
+		}
+		if bySelf && BlocksLogin(user.DeletionScheduledAt, user.DeletionRequestedBy) {
+			return ErrScheduledByAdmin
+		}
+		return core.MongoUserRepo.CancelDeletion(user.ID)
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return ErrUserNotFound
+	}
+	if bySelf && BlocksLogin(user.DeletionScheduledAt, user.DeletionRequestedBy) {
+		return ErrScheduledByAdmin
+	}
+	return database.DB.Model(&user).Updates(map[string]interface{}{
+		"deletion_scheduled_at": nil,
+		"deletion_requested_at": nil,
+		"deletion_requested_by": "",
+	}).Error
+}
+
+// StartWorker lanza una goroutine que ejecuta los borrados pendientes cada cierto tiempo
+func StartWorker(every time.Duration) {
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for range ticker.C {
+			ProcessDue()
+		}
+	}()
+}
+
+// ProcessDue borra (o anonimiza) todas las cuentas cuyo periodo de gracia terminó.
+// Devuelve cuántas cuentas se procesaron.
+func ProcessDue() int {
+	method := config.GetEnv("ACCOUNT_DELETION_MODE", MethodAnonymize)
+	if method != MethodHardDelete {
+		method = MethodAnonymize
+	}
+	now := time.Now()
+	processed := 0
+
+	// 1. Usuarios de Postgres
+	var users []models.User
+	database.DB.Where("deletion_scheduled_at <= ?", now).Find(&users)
+	for _, user := range users {
+		if err := deletePostgresUser(user, method); err != nil {
+			logging.Log.Errorf("Error al borrar el usuario %d (Postgres): %v", user.ID, err)
+			continue
+		}
+		processed++
+	}
+
+	// 2. Usuarios de Mongo
+	mongoUsers, err := core.MongoUserRepo.GetDueDeletions(now)
+	if err != nil {
+		logging.Log.Errorf("Error al buscar borrados pendientes en Mongo: %v", err)
+	}
+	for _, user := range mongoUsers {
+		if err := deleteMongoUser(user, method); err != nil {
+			logging.Log.Errorf("Error al borrar el usuario %s (Mongo): %v", user.ID.Hex(), err)
+			continue
+		}
+		processed++
+	}
+
+	if processed > 0 {
+		logging.Log.Infof("Borrado de cuentas: %d cuentas procesadas (%s)", processed, method)
+	}
+	return processed
+}
+
+// deletePostgresUser borra o anonimiza un usuario de Postgres y todo lo que cuelga de él
+func deletePostgresUser(user models.User, method string) error {
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.PasswordHistory{}).Error; err != nil {
+			return err
+		}
+
+		if method == MethodHardDelete {
+			// Unscoped: borrado real, no el "soft delete" de gorm.Model
+			if err := tx.Unscoped().Delete(&user).Error; err != nil {
+				return err
+			}
+		} else {
+			err := tx.Model(&user).Updates(map[string]interface{}{
+				"email":                 anonymousEmail(userID),
+				"password":              "!", // Ningún hash coincide nunca con "!"
+				"profile_image_path":    nil,
+				"deletion_scheduled_at": nil,
+				"deletion_requested_at": nil,
+				"deletion_requested_by": "",
+				// El email ya no es real: la marca de supresión no significa nada
+				"email_suppressed_at":      nil,
+				"email_suppression_reason": "",
+			}).Error
+			if err != nil {
+				return err
+			}
+			// Además lo marcamos como borrado (soft delete) para que no aparezca en consultas normales
+			if err := tx.Delete(&user).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(tombstone("postgres", userID, user.Email, method, user.DeletionRequestedBy, user.DeletionRequestedAt)).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	removeProfileImage(user.ProfileImagePath)
+	cleanup("postgres", userID, user.Email)
+	outbox.Send(utils.AccountDeletedEmail(user.Locale, user.Email))
+	return nil
+}
+
+// deleteMongoUser borra o anonimiza un usuario de Mongo.
+// Mongo y Postgres no comparten transacción: el orden permite repetir el borrado si algo falla a medias.
+// Lo que se puede repetir sin efectos (lápida única, borrados de archivos, sesiones...) va primero, y el cambio
+// del documento, que lo saca de la lista de borrados pendientes, al final: si falla cualquier paso,
+// el siguiente ProcessDue vuelve a empezar con el documento intacto.
+func deleteMongoUser(user models.MongoUser, method string) error {
+	userID := user.ID.Hex()
+
+	// 1. La lápida (una sola aunque se repita el borrado)
+	entry := tombstone("mongo", userID, user.Email, method, user.DeletionRequestedBy, user.DeletionRequestedAt)
+	err := database.DB.Where(models.DeletionTombstone{UserStore: "mongo", UserID: userID}).FirstOrCreate(entry).Error
+	if err != nil {
+		return err
+	}
+
+	// 2. Archivos, tokens, sesiones y el resto de datos en Postgres
+	removeProfileImage(user.ProfileImagePath)
+	cleanup("mongo", userID, user.Email)
+
+	// 3. El documento del usuario
+	if method == MethodHardDelete {
+		err = core.MongoUserRepo.DeleteUser(user.ID)
+	} else {
+		err = core.MongoUserRepo.AnonymizeUser(user.ID, anonymousEmail(userID))
+	}
+	if err != nil {
+		return err
+	}
+
+	outbox.Send(utils.AccountDeletedEmail(user.Locale, user.Email))
+	return nil
+}
+
+// cleanup hace lo común a ambas BD: revocar tokens y borrar sesiones, exportaciones y archivos.
+// Se puede repetir sin problema (lo que ya no existe simplemente no se borra).
+func cleanup(store string, userID string, email string) {
+	if err := security.RevokeUserTokens(store, userID); err != nil {
+		logging.Log.Errorf("No se pudieron revocar los tokens de %s/%s: %v", store, userID, err)
+	}
+
+	// Las sesiones guardan la IP y el dispositivo del usuario
+	if err := sessions.DeleteUserSessions(store, userID); err != nil {
+		logging.Log.Errorf("No se pudieron borrar las sesiones de %s/%s: %v", store, userID, err)
+	}
+
+	// Las API keys no son tokens JWT (revocar los tokens no les afecta): se borran
+	if err := apikeys.DeleteUserKeys(store, userID); err != nil {
+		logging.Log.Errorf("No se pudieron borrar las API keys de %s/%s: %v", store, userID, err)
+	}
+
+	// Las aplicaciones OAuth a las que dio acceso: sus tokens dejan de valer
+	if err := oauth.DeleteUserData(store, userID); err != nil {
+		logging.Log.Errorf("No se pudieron borrar los datos de OAuth de %s/%s: %v", store, userID, err)
+	}
+
+	// El historial de inicios de sesión también guarda IPs, dispositivos y ubicaciones
+	if err := loginhistory.DeleteUserHistory(store, userID, email); err != nil {
+		logging.Log.Errorf("No se pudo borrar el historial de inicios de sesión de %s/%s: %v", store, userID, err)
+	}
+
+	// Las exportaciones de datos (RGPD) contienen datos personales: también se borran
+	var exports []models.DataExport
+	database.DB.Where("user_store = ? AND user_id = ?", store, userID).Find(&exports)
+	for _, export := range exports {
+		if export.FilePath != "" {
+			os.Remove(export.FilePath)
+		}
+	}
+	database.DB.Where("user_store = ? AND user_id = ?", store, userID).Delete(&models.DataExport{})
+
+	// Archivos del usuario (fotos y archivos privados)
+	if err := media.DeleteUserFiles(context.Background(), core.FileStorage, store, userID); err != nil {
+		logging.Log.Errorf("No se pudieron borrar los archivos de %s/%s: %v", store, userID, err)
+	}
+}
+
+// removeProfileImage borra la foto de perfil (y sus miniaturas) del backend de almacenamiento
+func removeProfileImage(key string) {
+	if key == "" {
+		return
+	}
+	if err := media.DeleteWithVariants(context.Background(), core.FileStorage, key); err != nil {
+		logging.Log.Errorf("No se pudo borrar la foto de perfil %s: %v", key, err)
+	}
+}
+
+// tombstone construye la "lápida" del borrado (sin datos personales)
+func tombstone(store string, userID string, email string, method string, requestedBy string, requestedAt *time.Time) *models.DeletionTombstone {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+
+	entry := &models.DeletionTombstone{
+		UserStore:   store,
+		UserID:      userID,
+		EmailHash:   hex.EncodeToString(sum[:]),
+		Method:      method,
+		RequestedBy: requestedBy,
+		DeletedAt:   time.Now(),
+	}
+	if requestedAt != nil {
+		entry.RequestedAt = *requestedAt
+	}
+	return entry
+}
+
+// anonymousEmail genera un email único que no identifica a nadie (el dominio .invalid nunca existe)
+func anonymousEmail(userID string) string {
+	return "deleted-" + userID + "@deleted.invalid"
+}
@@ -0,0 +1,27 @@
+package accountdeletion
+
+import (
+	"testing"
+	"time"
+)
+
+// Solo el borrado que programa un administrador bloquea el inicio de sesión
+func TestBlocksLogin(t *testing.T) {
+	scheduledAt := time.Now().Add(time.Hour)
+
+	cases := []struct {
+		name        string
+		scheduledAt *time.Time
+		requestedBy string
+		blocks      bool
+	}{
+		{"sin borrado", nil, "", false},
+		{"pedido por el usuario", &scheduledAt, RequestedBySelf, false},
+		{"programado por un admin", &scheduledAt, "admin:7", true},
+	}
+	for _, tc := range cases {
+		if blocks := BlocksLogin(tc.scheduledAt, tc.requestedBy); blocks != tc.blocks {
+			t.Errorf("%s: BlocksLogin = %v, se esperaba %v", tc.name, blocks, tc.blocks)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package apikeys
+
+import (
+	"archive/zip"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"go-aprendizaje/config"
+	"go-aprendizaje/core"
+	"go-aprendizaje/database"
+	"go-aprendizaje/dataexport"
+	"go-aprendizaje/models"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// API keys para scripts y otros clientes automáticos. Formato: "ak_<prefijo>_<secreto>".
+// El prefijo (8 caracteres hexadecimales) identifica la key en la BD y en los listados;
+// del secreto solo se guarda el hash SHA-256 (es aleatorio y largo: no hace falta un hash lento).
+
+const keyPrefix = "ak_"
+
+var (
+	// ErrInvalid se devuelve si la key no existe, no coincide, caducó o se revocó
+	ErrInvalid = errors.New("API key inválida, caducada o revocada")
+	// ErrNotFound se devuelve si la key no existe o no es del usuario
+	ErrNotFound = errors.New("API key no encontrada")
+	// ErrInvalidScope se devuelve si se pide un permiso que no existe o que el usuario no tiene
+	ErrInvalidScope = errors.New("permiso (scope) inválido")
+	// ErrInvalidExpiry se devuelve si la caducidad ya pasó o supera API_KEY_MAX_TTL
+	ErrInvalidExpiry = errors.New("caducidad inválida")
+	// ErrTooManyKeys se devuelve si el usuario ya tiene API_KEY_MAX_PER_USER keys activas
+	ErrTooManyKeys = errors.New("demasiadas API keys activas")
+	// ErrOwnerDisabled se devuelve si el dueño de la key ya no existe o tiene el borrado programado
+	ErrOwnerDisabled = errors.New("el usuario de la API key no está activo")
+)
+
+// Owner es el usuario al que pertenece una key
+type Owner struct {
+	TokenID interface{} // El mismo "userID" que llevaría su token JWT: número en Postgres, string en Mongo
+	Role    string
+}
+
+// Create genera una key para el usuario. 'ownerRole' decide qué permisos puede tener (ScopeAdmin solo los admins).
+// 'expiresAt' cero = API_KEY_DEFAULT_TTL. Devuelve la key completa: es la única vez que se puede ver.
+func Create(store string, userID string, ownerRole string, name string, scopes []string, expiresAt time.Time, createdBy string) (string, *models.APIKey, error) {
+	// 1. Validar los permisos (sin repetidos) y la caducidad
+	granted := []string{}
+	for _, scope := range scopes {
+		if !slices.Contains(models.APIKeyScopes, scope) || (scope == models.ScopeAdmin && ownerRole != "admin") {
+			return "", nil, ErrInvalidScope
+		}
+		if !slices.Contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	if len(granted) == 0 {
+		return "", nil, ErrInvalidScope
+	}
+	if expiresAt.IsZero() {
+		expiresAt = time.Now().Add(durationFromEnv("API_KEY_DEFAULT_TTL", 90*24*time.Hour))
+	}
+	if !expiresAt.After(time.Now()) || expiresAt.After(time.Now().Add(durationFromEnv("API_KEY_MAX_TTL", 365*24*time.Hour))) {
+		return "", nil, ErrInvalidExpiry
+	}
+
+	// 2. Límite de keys activas por usuario
+	var active int64
+	database.DB.Model(&models.APIKey{}).
+		Where("user_store = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", store, userID, time.Now()).
+		Count(&active)
+	if active >= int64(config.GetEnvInt("API_KEY_MAX_PER_USER", 20)) {
+		return "", nil, ErrTooManyKeys
+	}
+
+	// 3. Generar la key y guardar solo su hash
+	prefixBytes := make([]byte, 4)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", nil, err
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", nil, err
+	}
+	prefix := keyPrefix + hex.EncodeToString(prefixBytes)
+	key := prefix + "_" + base64.RawURLEncoding.EncodeToString(secretBytes)
+
+	apiKey := &models.APIKey{
+		Name:       strings.TrimSpace(name),
+		Prefix:     prefix,
+		SecretHash: hashKey(key),
+		UserStore:  store,
+		UserID:     userID,
+		Scopes:     strings.Join(granted, " "),
+		ExpiresAt:  expiresAt,
+		CreatedBy:  createdBy,
+	}
+	if err := database.DB.Create(apiKey).Error; err != nil {
+		return "", nil, err
+	}
+	return key, apiKey, nil
+}
+
+// Authenticate comprueba una key y apunta cuándo y desde qué IP se usó por última vez
+// (como mucho una escritura por minuto, para no escribir en cada petición)
+func Authenticate(key string, ip string) (*models.APIKey, error) {
+	// "ak_" + 8 hexadecimales + "_" + secreto
+	prefixLength := len(keyPrefix) + 8
+	if len(key) <= prefixLength+1 || !strings.HasPrefix(key, keyPrefix) || key[prefixLength] != '_' {
+		return nil, ErrInvalid
+	}
+
+	var apiKey models.APIKey
+	if err := database.DB.Where("prefix = ?", key[:prefixLength]).First(&apiKey).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalid
+		}
+		return nil, err
+	}
+	if !hmac.Equal([]byte(hashKey(key)), []byte(apiKey.SecretHash)) {
+		return nil, ErrInvalid
+	}
+	if apiKey.RevokedAt != nil || time.Now().After(apiKey.ExpiresAt) {
+		return nil, ErrInvalid
+	}
+
+	if apiKey.LastUsedAt == nil || time.Since(*apiKey.LastUsedAt) > time.Minute || apiKey.LastUsedIP != ip {
+		now := time.Now()
+		database.DB.Model(&apiKey).Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip})
+	}
+	return &apiKey, nil
+}
+
+// FindOwner busca al dueño de la key (su rol puede haber cambiado desde que se creó).
+// Devuelve ErrOwnerDisabled si ya no existe o tiene el borrado de la cuenta programado.
+func FindOwner(apiKey *models.APIKey) (*Owner, error) {
+	if apiKey.UserStore == "mongo" {
+		user, err := core.MongoUserRepo.GetUserByID(apiKey.UserID)
+		if err != nil || user.DeletionScheduledAt != nil {
+			return nil, ErrOwnerDisabled
+		}
+		return &Owner{TokenID: user.ID.Hex(), Role: user.Role}, nil
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, apiKey.UserID).Error; err != nil || user.DeletionScheduledAt != nil {
+		return nil, ErrOwnerDisabled
+	}
+	// Como en el token JWT, el ID de Postgres es un número float64
+	return &Owner{TokenID: float64(user.ID), Role: user.Role}, nil
+}
+
+// List devuelve las keys de un usuario (también las caducadas y revocadas, las más nuevas primero)
+func List(store string, userID string) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := database.DB.Where("user_store = ? AND user_id = ?", store, userID).Order("created_at desc").Find(&keys).Error
+	return keys, err
+}
+
+// Revoke anula una key del usuario (deja de funcionar al momento; se conserva para el historial)
+func Revoke(store string, userID string, id string) error {
+	result := database.DB.Model(&models.APIKey{}).
+		Where("id = ? AND user_store = ? AND user_id = ? AND revoked_at IS NULL", id, store, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteUserKeys borra las keys de un usuario (al borrar su cuenta)
+func DeleteUserKeys(store string, userID string) error {
+	return database.DB.Where("user_store = ? AND user_id = ?", store, userID).Delete(&models.APIKey{}).Error
+}
+
+// ExportSection añade las keys del usuario (sin hashes) a su exportación de datos personales (RGPD)
+func ExportSection(zipWriter *zip.Writer, export *models.DataExport) error {
+	keys, _ := List(export.UserStore, export.UserID)
+	return dataexport.WriteJSON(zipWriter, "api_keys.json", map[string]interface{}{"api_keys": keys})
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(config.GetEnv(key, ""))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,120 @@
+package authcookies
+
+import (
+	"go-aprendizaje/config"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Modo cookie (para el frontend Nuxt con SSR): en vez de devolver el token en el JSON, el login lo deja en
+// cookies HttpOnly que el JavaScript de la página no puede leer (un XSS no puede robarlas).
+// Se activa con AUTH_COOKIES=true y el cliente lo pide enviando "X-Auth-Transport: cookie" al iniciar sesión.
+//   - access_token: el JWT de siempre, de vida corta (AUTH_ACCESS_TTL). Va a toda la API.
+//   - refresh_token: renueva el access_token (AUTH_REFRESH_TTL). Solo viaja a /api/users/token.
+//   - csrf_token: legible por el frontend, que lo repite en la cabecera X-CSRF-Token (ver security.NewCSRFToken).
+
+const (
+	AccessCookie    = "access_token"
+	RefreshCookie   = "refresh_token"
+	CSRFCookie      = "csrf_token"
+	CSRFHeader      = "X-CSRF-Token"
+	TransportHeader = "X-Auth-Transport"
+
+	accessPath  = "/api"
+	refreshPath = "/api/users/token"
+)
+
+// Enabled indica si el modo cookie está activado (AUTH_COOKIES)
+func Enabled() bool {
+	return config.GetEnvBool("AUTH_COOKIES", false)
+}
+
+// Requested indica si el cliente pide el modo cookie en esta petición de login
+func Requested(c *gin.Context) bool {
+	return Enabled() && strings.EqualFold(c.GetHeader(TransportHeader), "cookie")
+}
+
+// AccessTTL es la vida del token de acceso en modo cookie (corta: se renueva con el refresh token)
+func AccessTTL() time.Duration {
+	return durationFromEnv("AUTH_ACCESS_TTL", 15*time.Minute)
+}
+
+// RefreshTTL es la vida de la sesión en modo cookie: después hay que volver a iniciar sesión
+func RefreshTTL() time.Duration {
+	return durationFromEnv("AUTH_REFRESH_TTL", 30*24*time.Hour)
+}
+
+// Set guarda los tres tokens en cookies
+func Set(c *gin.Context, accessToken string, accessExpires time.Time, refreshToken string, refreshExpires time.Time, csrfToken string) {
+	setCookie(c, AccessCookie, accessToken, accessPath, accessExpires, true)
+	setCookie(c, RefreshCookie, refreshToken, refreshPath, refreshExpires, true)
+	// Mismo tiempo que la sesión: el frontend lo necesita mientras pueda renovar el acceso
+	setCookie(c, CSRFCookie, csrfToken, "/", refreshExpires, false)
+}
+
+// Clear borra las cookies (cerrar sesión)
+func Clear(c *gin.Context) {
+	past := time.Unix(0, 0)
+	setCookie(c, AccessCookie, "", accessPath, past, true)
+	setCookie(c, RefreshCookie, "", refreshPath, past, true)
+	setCookie(c, CSRFCookie, "", "/", past, false)
+}
+
+// SafeMethod indica si el método no cambia nada (no necesita token CSRF)
+func SafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// CSRFMatches comprueba el "double submit": la cabecera X-CSRF-Token debe ser igual a la cookie csrf_token.
+// (La firma del token se comprueba aparte, con security.ValidCSRFToken.)
+func CSRFMatches(c *gin.Context) (string, bool) {
+	header := c.GetHeader(CSRFHeader)
+	cookie, err := c.Cookie(CSRFCookie)
+	if err != nil || header == "" || header != cookie {
+		return "", false
+	}
+	return header, true
+}
+
+func setCookie(c *gin.Context, name string, value string, path string, expires time.Time, httpOnly bool) {
+	sameSite := sameSiteMode()
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   config.GetEnv("AUTH_COOKIE_DOMAIN", ""),
+		Expires:  expires,
+		HttpOnly: httpOnly,
+		// Secure salvo COOKIE_INSECURE_DEV en desarrollo, y aun así los navegadores rechazan SameSite=None sin Secure
+		Secure:   config.SecureCookies() || sameSite == http.SameSiteNoneMode,
+		SameSite: sameSite,
+	}
+	if value == "" {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(c.Writer, cookie)
+}
+
+// sameSiteMode lee AUTH_COOKIE_SAMESITE: "lax" (por defecto), "strict" o "none"
+// ("none" solo si el frontend está en otro dominio: entonces el CSRF depende solo del token)
+func sameSiteMode() http.SameSite {
+	switch strings.ToLower(config.GetEnv("AUTH_COOKIE_SAMESITE", "lax")) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(config.GetEnv(key, ""))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
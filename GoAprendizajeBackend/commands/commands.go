@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+)
+
+// command es un subcomando de la línea de comandos (ej: ./main import-users ...)
+type command struct {
+	description string
+	run         func(args []string) int // Devuelve el código de salida del proceso
+}
+
+// registry contiene todos los subcomandos disponibles
+var registry = map[string]command{
+	"import-users":      {description: "Importa usuarios desde un CSV/JSONL de otro sistema", run: importUsers},
+	"export-users":      {description: "Exporta usuarios a CSV, JSONL o XLSX", run: exportUsers},
+	"migrate-storage":   {description: "Copia los archivos entre backends de almacenamiento (local ↔ s3)", run: migrateStorage},
+	"gc-uploads":        {description: "Borra las fotos de perfil que no usa ningún usuario (con -dry-run para probar)", run: gcUploads},
+	"process-deletions": {description: "Ejecuta los borrados de cuentas cuyo periodo de gracia terminó", run: processDeletions},
+	"scan-files":        {description: "Analiza con el antivirus los archivos que siguen en cuarentena", run: scanFiles},
+	"process-bounce":    {description: "Procesa emails de rebote (RFC 3464) desde archivos o la entrada estándar", run: processBounce},
+}
+
+// Run ejecuta el subcomando indicado en 'args[0]' y devuelve el código de salida
+func Run(args []string) int {
+	cmd, ok := registry[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Comando desconocido: %s\n\nComandos disponibles:\n", args[0])
+		for name, cmd := range registry {
+			fmt.Fprintf(os.Stderr, "  %-20s %s\n", name, cmd.description)
+		}
+		return 2
+	}
+
+	return cmd.run(args[1:])
+}
@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"go-aprendizaje/exporter"
+	"io"
+	"os"
+)
+
+// exportUsers: ./main export-users [-format csv|jsonl|xlsx] [-store postgres|mongo] [-columns id,email] [-role admin] [-search texto] [-created-after AAAA-MM-DD] [-created-before AAAA-MM-DD] [-out fichero]
+func exportUsers(args []string) int {
+	flags := flag.NewFlagSet("export-users", flag.ContinueOnError)
+	format := flags.String("format", "csv", "Formato: csv, jsonl o xlsx")
+	store := flags.String("store", "postgres", "Origen de los usuarios: postgres o mongo")
+	columnsFlag := flags.String("columns", "", "Columnas separadas por comas (por defecto, todas)")
+	role := flags.String("role", "", "Filtrar por rol")
+	search := flags.String("search", "", "Filtrar por emails que contengan este texto")
+	createdAfterFlag := flags.String("created-after", "", "Filtrar por usuarios creados desde esta fecha (AAAA-MM-DD)")
+	createdBeforeFlag := flags.String("created-before", "", "Filtrar por usuarios creados antes de esta fecha (AAAA-MM-DD)")
+	out := flags.String("out", "", "Fichero de salida (por defecto, la salida estándar)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	columns, err := exporter.ParseColumns(*columnsFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	createdAfter, err := exporter.ParseDate(*createdAfterFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	createdBefore, err := exporter.ParseDate(*createdBeforeFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	var writer io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "No se pudo crear el fichero de salida: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		writer = f
+	}
+
+	count, err := exporter.Export(writer, exporter.Options{
+		Format:        *format,
+		Store:         *store,
+		Columns:       columns,
+		Role:          *role,
+		Search:        *search,
+		CreatedAfter:  createdAfter,
+		CreatedBefore: createdBefore,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error en la exportación tras %d usuarios: %v\n", count, err)
+		return 1
+	}
+
+	// El resumen va a stderr para no mezclarse con los datos si se exporta a stdout
+	fmt.Fprintf(os.Stderr, "Exportados %d usuarios\n", count)
+	return 0
+}
@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"go-aprendizaje/core"
+	"go-aprendizaje/media"
+	"os"
+	"time"
+)
+
+// gcUploads: ./main gc-uploads [-dry-run] [-min-age 1h]
+// Borra las fotos de perfil que no usa ningún usuario (Postgres ni Mongo)
+func gcUploads(args []string) int {
+	flags := flag.NewFlagSet("gc-uploads", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "Solo mostrar lo que se borraría, sin borrar nada")
+	minAge := flags.Duration("min-age", time.Hour, "No tocar archivos más recientes que esto")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	report, err := media.CollectGarbage(context.Background(), core.FileStorage, *minAge, *dryRun)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error en el recolector:", err)
+		return 1
+	}
+
+	action := "Borrado"
+	if *dryRun {
+		action = "Se borraría"
+	}
+	for _, key := range report.Deleted {
+		fmt.Printf("%s: %s\n", action, key)
+	}
+	for _, key := range report.Failed {
+		fmt.Fprintln(os.Stderr, "  Error:", key)
+	}
+	fmt.Printf("Revisados: %d, en uso: %d, huérfanos: %d (%d bytes), con errores: %d\n",
+		report.Scanned, report.Kept, len(report.Deleted), report.DeletedBytes, len(report.Failed))
+
+	if len(report.Failed) > 0 {
+		return 1
+	}
+	return 0
+}
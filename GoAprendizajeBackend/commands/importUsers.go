@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go-aprendizaje/importer"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// importUsers: ./main import-users -file usuarios.csv [-format csv|jsonl] [-store postgres|mongo]
+func importUsers(args []string) int {
+	flags := flag.NewFlagSet("import-users", flag.ContinueOnError)
+	file := flags.String("file", "", "Ruta del fichero a importar (obligatorio)")
+	format := flags.String("format", "", "Formato: csv o jsonl (por defecto, según la extensión del fichero)")
+	store := flags.String("store", "postgres", "Dónde guardar los usuarios: postgres o mongo")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *file == "" {
+		flags.Usage()
+		return 2
+	}
+
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*file)), ".")
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "No se pudo abrir el fichero: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+
+	report, err := importer.ImportUsers(f, *format, *store)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error en la importación: %v\n", err)
+		if report == nil {
+			return 1
+		}
+	}
+
+	// El informe se imprime en JSON para poder guardarlo o procesarlo
+	out, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(out))
+
+	if err != nil || len(report.Failed) > 0 {
+		return 1
+	}
+	return 0
+}
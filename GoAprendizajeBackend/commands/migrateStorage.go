@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"go-aprendizaje/database"
+	"go-aprendizaje/models"
+	"go-aprendizaje/storage"
+	"os"
+
+	"gorm.io/gorm"
+)
+
+// migrateStorage: ./main migrate-storage -from local -to s3 [-dry-run] [-delete-source]
+// Copia los archivos entre backends y normaliza las rutas antiguas de la BD ("/static/<archivo>" → clave)
+func migrateStorage(args []string) int {
+	flags := flag.NewFlagSet("migrate-storage", flag.ContinueOnError)
+	fromFlag := flags.String("from", "local", "Backend de origen: local o s3")
+	toFlag := flags.String("to", "s3", "Backend de destino: local o s3")
+	dryRun := flags.Bool("dry-run", false, "Solo mostrar lo que se copiaría, sin copiar nada")
+	deleteSource := flags.Bool("delete-source", false, "Borrar cada archivo del origen después de copiarlo")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *fromFlag == *toFlag {
+		fmt.Fprintln(os.Stderr, "El origen y el destino no pueden ser el mismo backend")
+		return 2
+	}
+
+	// 1. Crear los dos backends (ambos se configuran con las mismas variables del .env)
+	from, err := storage.New(*fromFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	to, err := storage.New(*toFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	// 2. Copiar los archivos
+	report, err := storage.Migrate(context.Background(), from, to, *dryRun, *deleteSource)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error en la migración:", err)
+		return 1
+	}
+
+	// 3. Normalizar las rutas antiguas de la BD (antes se guardaba "/static/<archivo>", ahora solo la clave)
+	var normalized int64
+	if !*dryRun {
+		result := database.DB.Model(&models.User{}).
+			Where("profile_image_path LIKE ?", "/static/%").
+			Update("profile_image_path", gorm.Expr("substring(profile_image_path from 9)"))
+		if result.Error != nil {
+			fmt.Fprintln(os.Stderr, "Error al normalizar las rutas de la BD:", result.Error)
+			return 1
+		}
+		normalized = result.RowsAffected
+	}
+
+	fmt.Printf("Copiados: %d, ya existían: %d, con errores: %d, rutas normalizadas en la BD: %d\n",
+		report.Copied, report.Skipped, len(report.Failed), normalized)
+	for _, key := range report.Failed {
+		fmt.Fprintln(os.Stderr, "  Error:", key)
+	}
+
+	if len(report.Failed) > 0 {
+		return 1
+	}
+	return 0
+}
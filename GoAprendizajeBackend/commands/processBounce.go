@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"go-aprendizaje/suppression"
+	"io"
+	"os"
+)
+
+// processBounce: ./main process-bounce [rebote.eml ...]
+// Procesa emails de rebote (RFC 3464) y suprime las direcciones con rebote permanente.
+// Sin archivos lee un email de la entrada estándar, para que el servidor de correo se lo pase
+// directamente (ej: en /etc/aliases: bounces: "|/app/main process-bounce").
+func processBounce(args []string) int {
+	if len(args) == 0 {
+		return processBounceMessage("stdin", os.Stdin)
+	}
+
+	exitCode := 0
+	for _, path := range args {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "No se pudo abrir %s: %v\n", path, err)
+			exitCode = 1
+			continue
+		}
+		if code := processBounceMessage(path, file); code != 0 {
+			exitCode = code
+		}
+		file.Close()
+	}
+	return exitCode
+}
+
+func processBounceMessage(name string, r io.Reader) int {
+	bounces, err := suppression.ParseDSN(r)
+	if errors.Is(err, suppression.ErrNotDSN) {
+		// No es un error: al buzón de rebotes también llegan respuestas automáticas, spam...
+		fmt.Printf("%s: no es un informe de entrega, se ignora\n", name)
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: no se pudo leer el mensaje: %v\n", name, err)
+		return 1
+	}
+
+	for _, bounce := range bounces {
+		suppressed, err := suppression.Apply(bounce.Event(), "dsn")
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "%s: %s: %v\n", name, bounce.Recipient, err)
+		case suppressed:
+			fmt.Printf("%s: %s suprimido (%s %s)\n", name, bounce.Recipient, bounce.Status, bounce.Action)
+		default:
+			fmt.Printf("%s: %s rebote temporal, no se suprime (%s %s)\n", name, bounce.Recipient, bounce.Status, bounce.Action)
+		}
+	}
+	return 0
+}
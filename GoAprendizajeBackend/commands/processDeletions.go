@@ -0,0 +1,14 @@
+package commands
+
+import (
+	"fmt"
+	"go-aprendizaje/accountdeletion"
+)
+
+// processDeletions: ./main process-deletions
+// Ejecuta ya los borrados de cuentas pendientes (útil desde un cron si el servidor no está levantado)
+func processDeletions(args []string) int {
+	processed := accountdeletion.ProcessDue()
+	fmt.Printf("Cuentas procesadas: %d\n", processed)
+	return 0
+}
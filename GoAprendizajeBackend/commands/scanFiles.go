@@ -0,0 +1,21 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"go-aprendizaje/core"
+	"go-aprendizaje/media"
+	"os"
+)
+
+// scanFiles: ./main scan-files
+// Analiza ya los archivos que siguen en cuarentena (ej: después de que clamd estuviera caído)
+func scanFiles(args []string) int {
+	if core.FileScanner == nil {
+		fmt.Fprintln(os.Stderr, "El antivirus está desactivado (SCANNER_BACKEND=none)")
+		return 1
+	}
+	scanned := media.ScanPending(context.Background())
+	fmt.Printf("Archivos analizados: %d\n", scanned)
+	return 0
+}
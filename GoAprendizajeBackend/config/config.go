@@ -3,7 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
 
+	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
 
@@ -23,3 +25,31 @@ func GetEnv(key string, defaultValue string) string {
 	}
 	return value
 }
+
+// GetEnvInt lee una variable de entorno como entero (o devuelve el valor por defecto si no existe o es inválida)
+func GetEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(GetEnv(key, strconv.Itoa(defaultValue)))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// GetEnvBool lee una variable de entorno como booleano ("true", "false", "1", "0"...)
+func GetEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(GetEnv(key, strconv.FormatBool(defaultValue)))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// SecureCookies indica si las cookies llevan el atributo Secure (solo viajan por HTTPS).
+// Siempre, salvo que se desactive a propósito para desarrollar con http:// (COOKIE_INSECURE_DEV=true),
+// y eso no vale en modo release (GIN_MODE=release): en producción nunca se envían sin HTTPS.
+func SecureCookies() bool {
+	if !GetEnvBool("COOKIE_INSECURE_DEV", false) {
+		return true
+	}
+	return gin.Mode() == gin.ReleaseMode
+}
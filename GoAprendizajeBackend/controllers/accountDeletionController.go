@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"errors"
+	"go-aprendizaje/accountdeletion"
+	"go-aprendizaje/core"
+	"go-aprendizaje/database"
+	"go-aprendizaje/loginhistory"
+	"go-aprendizaje/models"
+	"go-aprendizaje/security"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deleteAccountInput es el cuerpo que esperamos al pedir el borrado de la propia cuenta
+type deleteAccountInput struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// DeleteMyAccount programa el borrado de la cuenta del usuario (con periodo de gracia).
+// Pide la contraseña para confirmar y cierra todas las sesiones en el momento.
+func DeleteMyAccount(c *gin.Context) {
+	// 1. Saber quién es el usuario
+	store, userID, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return
+	}
+
+	// 2. Bindear el JSON
+	var input deleteAccountInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos: " + err.Error()})
+		return
+	}
+
+	// 3. Comprobar la contraseña (Postgres o Mongo)
+	var passwordHash string
+	if store == "mongo" {
+		user, err := core.MongoUserRepo.GetUserByID(userID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado (Mongo)"})
+			return
+		}
+		passwordHash = user.Password
+	} else {
+		var user models.User
+		if err := database.DB.First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+			return
+		}
+		passwordHash = user.Password
+	}
+	if !security.Passwords.Matches(input.Password, passwordHash) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "La contraseña no es correcta"})
+		return
+	}
+
+	// 4. Programar el borrado
+	scheduledAt, err := accountdeletion.Schedule(store, userID, accountdeletion.RequestedBySelf, false)
+	if errors.Is(err, accountdeletion.ErrScheduledByAdmin) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Un administrador ya programó el borrado de tu cuenta"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo programar el borrado de la cuenta"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":      "Tu cuenta se eliminará en la fecha indicada. Inicia sesión antes si quieres cancelarlo",
+		"scheduled_at": scheduledAt,
+	})
+}
+
+// CancelMyAccountDeletion anula el borrado programado de la propia cuenta
+// (solo si lo pidió el usuario: el que programa un administrador solo lo cancela un administrador)
+func CancelMyAccountDeletion(c *gin.Context) {
+	store, userID, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return
+	}
+
+	err := accountdeletion.Cancel(store, userID, true)
+	if errors.Is(err, accountdeletion.ErrScheduledByAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "El borrado de tu cuenta lo programó un administrador: no puedes cancelarlo"})
+		return
+	}
+	if errors.Is(err, accountdeletion.ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo cancelar el borrado"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Borrado de la cuenta cancelado"})
+}
+
+// AdminDeleteUser programa el borrado de cualquier cuenta (solo admin).
+// Con "?immediate=true" se borra en el momento, sin periodo de gracia.
+func AdminDeleteUser(c *gin.Context) {
+	store := c.Param("store")
+	if store != "postgres" && store != "mongo" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "store inválido (usa 'postgres' o 'mongo')"})
+		return
+	}
+
+	_, adminID, _ := currentUser(c)
+	immediate := c.Query("immediate") == "true"
+
+	scheduledAt, err := accountdeletion.Schedule(store, c.Param("id"), "admin:"+adminID, immediate)
+	if errors.Is(err, accountdeletion.ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo programar el borrado de la cuenta"})
+		return
+	}
+
+	if immediate {
+		c.JSON(http.StatusOK, gin.H{"message": "Cuenta eliminada"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Borrado de la cuenta programado", "scheduled_at": scheduledAt})
+}
+
+// AdminCancelUserDeletion anula el borrado programado de cualquier cuenta (solo admin)
+func AdminCancelUserDeletion(c *gin.Context) {
+	store := c.Param("store")
+	if store != "postgres" && store != "mongo" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "store inválido (usa 'postgres' o 'mongo')"})
+		return
+	}
+
+	err := accountdeletion.Cancel(store, c.Param("id"), false)
+	if errors.Is(err, accountdeletion.ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo cancelar el borrado"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Borrado de la cuenta cancelado"})
+}
+
+// deletionBlocksLogin comprueba el borrado programado de una cuenta que ya demostró quién es (contraseña,
+// enlace o código). Si lo programó un administrador la cuenta está bloqueada: registra el intento fallido,
+// responde 403 y devuelve true. Si lo pidió el propio usuario puede entrar, para poder cancelarlo.
+func deletionBlocksLogin(c *gin.Context, attempt loginhistory.Attempt, locale string, scheduledAt *time.Time, requestedBy string) bool {
+	if !accountdeletion.BlocksLogin(scheduledAt, requestedBy) {
+		return false
+	}
+	attempt.Failure = models.LoginFailureAccountLocked
+	recordLogin(c, attempt, locale)
+	c.JSON(http.StatusForbidden, gin.H{"error": "La cuenta está bloqueada: tiene el borrado programado"})
+	return true
+}
+
+// withPendingDeletion añade a la respuesta del login la fecha del borrado que pidió el usuario
+// (el cliente puede ofrecerle cancelarlo con DELETE /api/users/me/deletion)
+func withPendingDeletion(response gin.H, scheduledAt *time.Time) gin.H {
+	if scheduledAt != nil {
+		response["deletion_scheduled_at"] = scheduledAt
+	}
+	return response
+}
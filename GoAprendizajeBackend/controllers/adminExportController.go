@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"go-aprendizaje/exporter"
+	"go-aprendizaje/logging"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportUsers exporta usuarios en CSV, JSONL o XLSX (solo admin)
+// Query: ?format=csv|jsonl|xlsx&store=postgres|mongo&columns=id,email&role=admin&search=gmail&created_after=2024-01-01&created_before=2025-01-01
+// La respuesta se envía "en streaming": se escribe a medida que se leen los usuarios de la BD.
+func ExportUsers(c *gin.Context) {
+	// 1. Leer y validar las opciones
+	format := c.DefaultQuery("format", "csv")
+	contentType, ok := exporter.ContentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Formato inválido (usa csv, jsonl o xlsx)"})
+		return
+	}
+
+	columns, err := exporter.ParseColumns(c.Query("columns"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	createdAfter, err := exporter.ParseDate(c.Query("created_after"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	createdBefore, err := exporter.ParseDate(c.Query("created_before"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	store := c.DefaultQuery("store", "postgres")
+	if store != "postgres" && store != "mongo" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Store inválido (usa postgres o mongo)"})
+		return
+	}
+
+	// 2. Cabeceras de descarga (a partir de aquí ya no podemos cambiar el código de estado)
+	filename := "usuarios-" + store + "-" + time.Now().Format("20060102-150405") + "." + format
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	// 3. Escribir directamente en la respuesta
+	count, err := exporter.Export(c.Writer, exporter.Options{
+		Format:        format,
+		Store:         store,
+		Columns:       columns,
+		Role:          c.Query("role"),
+		Search:        c.Query("search"),
+		CreatedAfter:  createdAfter,
+		CreatedBefore: createdBefore,
+	})
+	if err != nil {
+		// La respuesta ya empezó: solo podemos registrar el error (el fichero quedará incompleto)
+		logging.Log.Errorf("Error al exportar usuarios (%s, %s) tras %d filas: %v", store, format, count, err)
+		return
+	}
+
+	logging.Log.Infof("Exportados %d usuarios (%s, %s)", count, store, format)
+}
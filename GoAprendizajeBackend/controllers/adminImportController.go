@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"go-aprendizaje/importer"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImportUsers importa usuarios de otro sistema (solo admin)
+// Recibe el fichero en el campo "file" (multipart) y acepta ?store=postgres|mongo y ?format=csv|jsonl
+func ImportUsers(c *gin.Context) {
+	// 1. Obtener el fichero del formulario
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No se proporcionó ningún archivo"})
+		return
+	}
+
+	// 2. Formato: el de la query o, si no, el de la extensión del fichero
+	format := c.Query("format")
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(fileHeader.Filename)), ".")
+	}
+	store := c.DefaultQuery("store", "postgres")
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo leer el archivo"})
+		return
+	}
+	defer file.Close()
+
+	// 3. Importar (las filas con errores vienen en el informe, no cortan la importación)
+	report, err := importer.ImportUsers(file, format, store)
+	if err != nil {
+		status := http.StatusBadRequest
+		if report != nil {
+			// Error a mitad de lectura: devolvemos lo que se llegó a importar
+			c.JSON(status, gin.H{"error": err.Error(), "report": report})
+			return
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Importación finalizada",
+		"report":  report,
+	})
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"errors"
+	"go-aprendizaje/apikeys"
+	"go-aprendizaje/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyInput es el cuerpo para crear una API key
+type apiKeyInput struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required"` // Ver models.APIKeyScopes
+	ExpiresAt *time.Time `json:"expires_at"`                // Opcional (RFC 3339). Por defecto, API_KEY_DEFAULT_TTL
+}
+
+// CreateAPIKey crea una API key del usuario. La key solo se devuelve en esta respuesta.
+func CreateAPIKey(c *gin.Context) {
+	store, userID, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return
+	}
+	role, _ := c.Get("role")
+	roleString, _ := role.(string)
+	createAPIKey(c, store, userID, roleString, "self")
+}
+
+// ListAPIKeys lista las API keys del usuario (sin la key: solo el prefijo para reconocerlas)
+func ListAPIKeys(c *gin.Context) {
+	store, userID, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return
+	}
+	listAPIKeys(c, store, userID)
+}
+
+// RevokeAPIKey anula una API key del usuario
+func RevokeAPIKey(c *gin.Context) {
+	store, userID, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return
+	}
+	revokeAPIKey(c, store, userID)
+}
+
+// AdminCreateUserAPIKey crea una API key para cualquier usuario (por ejemplo, una cuenta de servicio)
+func AdminCreateUserAPIKey(c *gin.Context) {
+	store := c.Param("store")
+	if store != "postgres" && store != "mongo" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "store inválido (usa 'postgres' o 'mongo')"})
+		return
+	}
+	owner, err := magicUserByID(store, c.Param("id"))
+	if err != nil || owner == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+		return
+	}
+	_, adminID, _ := currentUser(c)
+	createAPIKey(c, store, owner.id, owner.role, "admin:"+adminID)
+}
+
+// AdminListUserAPIKeys lista las API keys de cualquier usuario
+func AdminListUserAPIKeys(c *gin.Context) {
+	store := c.Param("store")
+	if store != "postgres" && store != "mongo" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "store inválido (usa 'postgres' o 'mongo')"})
+		return
+	}
+	listAPIKeys(c, store, c.Param("id"))
+}
+
+// AdminRevokeUserAPIKey anula una API key de cualquier usuario
+func AdminRevokeUserAPIKey(c *gin.Context) {
+	store := c.Param("store")
+	if store != "postgres" && store != "mongo" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "store inválido (usa 'postgres' o 'mongo')"})
+		return
+	}
+	revokeAPIKey(c, store, c.Param("id"))
+}
+
+func createAPIKey(c *gin.Context, store string, userID string, ownerRole string, createdBy string) {
+	var input apiKeyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos: " + err.Error()})
+		return
+	}
+	var expiresAt time.Time
+	if input.ExpiresAt != nil {
+		expiresAt = *input.ExpiresAt
+	}
+
+	key, apiKey, err := apikeys.Create(store, userID, ownerRole, input.Name, input.Scopes, expiresAt, createdBy)
+	switch {
+	case errors.Is(err, apikeys.ErrInvalidScope):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "allowed_scopes": models.APIKeyScopes})
+		return
+	case errors.Is(err, apikeys.ErrInvalidExpiry):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, apikeys.ErrTooManyKeys):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo crear la API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "API key creada. Guárdala ahora: no se volverá a mostrar",
+		"key":     key,
+		"api_key": apiKey,
+	})
+}
+
+func listAPIKeys(c *gin.Context, store string, userID string) {
+	keys, err := apikeys.List(store, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer las API keys"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+func revokeAPIKey(c *gin.Context, store string, userID string) {
+	err := apikeys.Revoke(store, userID, c.Param("keyID"))
+	if errors.Is(err, apikeys.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo revocar la API key"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API key revocada"})
+}
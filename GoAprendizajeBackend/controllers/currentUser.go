@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"go-aprendizaje/models"
+	"go-aprendizaje/security"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currentUser devuelve en qué BD vive el usuario del token ("postgres" o "mongo") y su ID como string
+func currentUser(c *gin.Context) (store string, id string, ok bool) {
+	userID_any, exists := c.Get("userID")
+	if !exists {
+		return "", "", false
+	}
+	return security.UserRef(userID_any)
+}
+
+// actsAsAdmin indica si la petición puede actuar como admin: el usuario tiene el rol "admin" y,
+// si llega con una API key, la key tiene además el permiso ScopeAdmin (una key "files:read" de un admin
+// solo da acceso a sus propios archivos)
+func actsAsAdmin(c *gin.Context) bool {
+	if c.GetString("role") != "admin" {
+		return false
+	}
+	if apiKey, exists := c.Get("apiKey"); exists {
+		key, _ := apiKey.(*models.APIKey)
+		return key != nil && key.HasScope(models.ScopeAdmin)
+	}
+	return true
+}
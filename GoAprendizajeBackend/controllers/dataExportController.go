@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"errors"
+	"go-aprendizaje/core"
+	"go-aprendizaje/database"
+	"go-aprendizaje/dataexport"
+	"go-aprendizaje/models"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestDataExport inicia la exportación de los datos personales del usuario ("descargar mis datos").
+// El ZIP se genera en segundo plano y el enlace de descarga llega por email.
+func RequestDataExport(c *gin.Context) {
+	// 1. Saber quién es el usuario y buscar su email e idioma (Postgres o Mongo)
+	store, userID, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return
+	}
+
+	var email, locale string
+	if store == "mongo" {
+		user, err := core.MongoUserRepo.GetUserByID(userID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado (Mongo)"})
+			return
+		}
+		email, locale = user.Email, user.Locale
+	} else {
+		var user models.User
+		if err := database.DB.First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+			return
+		}
+		email, locale = user.Email, user.Locale
+	}
+
+	// 2. Crear la petición (el ZIP se genera en una goroutine)
+	export, err := dataexport.Request(store, userID, email, locale)
+	if errors.Is(err, dataexport.ErrExportInProgress) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ya tienes una exportación en curso"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo iniciar la exportación"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":   "Estamos preparando tus datos. Recibirás un email con el enlace de descarga",
+		"export_id": export.ID,
+	})
+}
+
+// DownloadDataExport descarga el ZIP con el enlace recibido por email
+// (no requiere el token JWT: el enlace ya lleva su propio token de un solo uso y con caducidad)
+func DownloadDataExport(c *gin.Context) {
+	export, err := dataexport.FindDownload(c.Param("id"), c.Query("token"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Enlace de descarga inválido o caducado"})
+		return
+	}
+
+	c.FileAttachment(export.FilePath, filepath.Base(export.FilePath))
+}
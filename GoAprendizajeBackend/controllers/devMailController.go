@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"go-aprendizaje/core"
+	"go-aprendizaje/mailer"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Endpoints de desarrollo para ver los emails "enviados" con MAIL_TRANSPORT=capture
+// (solo se registran con DEV_ENDPOINTS=true; nunca en producción)
+
+// captureMailer devuelve el transporte de captura, o responde 404 si se usa otro transporte
+func captureMailer(c *gin.Context) (*mailer.CaptureMailer, bool) {
+	capture, ok := core.Mailer.(*mailer.CaptureMailer)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Los emails solo se capturan con MAIL_TRANSPORT=capture"})
+		return nil, false
+	}
+	return capture, true
+}
+
+// DevListMail lista los emails capturados (del más reciente al más antiguo)
+func DevListMail(c *gin.Context) {
+	capture, ok := captureMailer(c)
+	if !ok {
+		return
+	}
+
+	messages := capture.Messages()
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	// ?to=... filtra por destinatario (útil en los tests: "¿le llegó el email a este usuario?")
+	if to := c.Query("to"); to != "" {
+		filtered := messages[:0]
+		for _, message := range messages {
+			if message.To == to {
+				filtered = append(filtered, message)
+			}
+		}
+		messages = filtered
+	}
+
+	c.JSON(http.StatusOK, gin.H{"messages": messages})
+}
+
+// DevGetMail devuelve un email capturado. Con ?format=html (o text) devuelve esa parte tal cual para verla en el navegador.
+func DevGetMail(c *gin.Context) {
+	capture, ok := captureMailer(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	message, found := capture.Get(id)
+	if err != nil || !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email no encontrado"})
+		return
+	}
+
+	if c.Query("format") == "html" {
+		// Sin scripts ni peticiones a otros sitios: es HTML que no hemos escrito a mano
+		c.Header("Content-Security-Policy", "default-src 'none'; img-src * data:; style-src 'unsafe-inline'; sandbox")
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(message.HTML))
+		return
+	}
+	if c.Query("format") == "text" {
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(message.Text))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// DevClearMail borra los emails capturados
+func DevClearMail(c *gin.Context) {
+	capture, ok := captureMailer(c)
+	if !ok {
+		return
+	}
+	capture.Clear()
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"go-aprendizaje/emails"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminListEmailTemplates lista las plantillas de email y los idiomas en los que existen
+func AdminListEmailTemplates(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"templates":      emails.Templates(),
+		"locales":        emails.Locales(),
+		"default_locale": emails.DefaultLocale(),
+	})
+}
+
+// AdminPreviewEmailTemplate genera una plantilla con datos de ejemplo
+// (GET /api/admin/email-templates/welcome/preview?locale=en&format=html).
+// Sin 'format' devuelve el asunto, el HTML y el texto en JSON; con "html" o "text" solo esa parte, para verla en el navegador.
+func AdminPreviewEmailTemplate(c *gin.Context) {
+	rendered, err := emails.Preview(c.Param("name"), c.Query("locale"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Plantilla no encontrada"})
+		return
+	}
+
+	switch c.Query("format") {
+	case "html":
+		c.Header("Content-Security-Policy", "default-src 'none'; img-src * data:; style-src 'unsafe-inline'; sandbox")
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(rendered.HTML))
+	case "text":
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(rendered.Text))
+	default:
+		c.JSON(http.StatusOK, gin.H{"template": c.Param("name"), "preview": rendered})
+	}
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"go-aprendizaje/config"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/suppression"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Webhooks del proveedor de correo: avisos de rebotes y quejas de spam que alimentan la lista de supresión.
+// Se protegen con un secreto compartido (EMAIL_WEBHOOK_SECRET) en la cabecera X-Webhook-Token o en ?token=
+// (muchos proveedores solo permiten configurar una URL). Sin secreto configurado, los webhooks están desactivados.
+
+// emailWebhookMaxBytes es el tamaño máximo de una notificación (un rebote puede incluir el email original)
+const emailWebhookMaxBytes = 5 << 20
+
+// EmailEventsWebhook recibe eventos en JSON: un evento, una lista, o {"events": [...]}, con el formato
+// {"type": "bounce"|"complaint", "email": "...", "bounce_type": "hard"|"soft", "detail": "..."}
+func EmailEventsWebhook(c *gin.Context) {
+	if !checkWebhookToken(c) {
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, emailWebhookMaxBytes))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Notificación demasiado grande"})
+		return
+	}
+	events, err := decodeEmailEvents(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido: " + err.Error()})
+		return
+	}
+
+	suppressed, ignored := applyEmailEvents(events, "webhook")
+	c.JSON(http.StatusOK, gin.H{"suppressed": suppressed, "ignored": ignored})
+}
+
+// EmailDSNWebhook recibe un email de rebote completo (RFC 3464), tal cual llegó al buzón de rebotes
+// (ej: reenviado por el servidor de correo o por un script que lee el buzón)
+func EmailDSNWebhook(c *gin.Context) {
+	if !checkWebhookToken(c) {
+		return
+	}
+
+	bounces, err := suppression.ParseDSN(http.MaxBytesReader(c.Writer, c.Request.Body, emailWebhookMaxBytes))
+	if errors.Is(err, suppression.ErrNotDSN) {
+		// No es un rebote (ej: una respuesta automática de "fuera de la oficina"): no hay nada que hacer
+		c.JSON(http.StatusOK, gin.H{"suppressed": 0, "ignored": 0, "message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No se pudo leer el mensaje: " + err.Error()})
+		return
+	}
+
+	events := make([]suppression.Event, len(bounces))
+	for i, bounce := range bounces {
+		events[i] = bounce.Event()
+	}
+	suppressed, ignored := applyEmailEvents(events, "dsn")
+	c.JSON(http.StatusOK, gin.H{"suppressed": suppressed, "ignored": ignored})
+}
+
+// applyEmailEvents procesa los eventos y cuenta cuántos suprimieron una dirección y cuántos no
+// (rebotes temporales o eventos inválidos, que se loguean pero no hacen fallar el resto)
+func applyEmailEvents(events []suppression.Event, source string) (int, int) {
+	suppressed, ignored := 0, 0
+	for _, event := range events {
+		added, err := suppression.Apply(event, source)
+		if err != nil {
+			logging.Log.Warnf("Evento de email ignorado (%s, %q, %q): %v", source, event.Type, event.Email, err)
+		}
+		if added && err == nil {
+			suppressed++
+		} else {
+			ignored++
+		}
+	}
+	return suppressed, ignored
+}
+
+// decodeEmailEvents acepta un evento suelto, una lista de eventos o un objeto con "events"
+func decodeEmailEvents(body []byte) ([]suppression.Event, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var events []suppression.Event
+		err := json.Unmarshal(body, &events)
+		return events, err
+	}
+
+	var wrapper struct {
+		Events []suppression.Event `json:"events"`
+		suppression.Event
+	}
+	if err := json.Unmarshal(body, &wrapper); err != nil {
+		return nil, err
+	}
+	if wrapper.Events != nil {
+		return wrapper.Events, nil
+	}
+	return []suppression.Event{wrapper.Event}, nil
+}
+
+// checkWebhookToken comprueba el secreto compartido con el proveedor de correo
+func checkWebhookToken(c *gin.Context) bool {
+	secret := config.GetEnv("EMAIL_WEBHOOK_SECRET", "")
+	if secret == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook desactivado"})
+		return false
+	}
+
+	token := c.GetHeader("X-Webhook-Token")
+	if token == "" {
+		token = c.Query("token")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token del webhook inválido"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"errors"
+	"go-aprendizaje/core"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/media"
+	"go-aprendizaje/storage"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServeFile sirve un archivo público (GET /static/<clave>) desde el backend de almacenamiento.
+// Funciona igual con disco local que con S3, así todas las réplicas sirven los mismos archivos.
+func ServeFile(c *gin.Context) {
+	// 1. Validar la clave (evita "../" y similares). Solo se sirven las fotos de perfil:
+	// los archivos privados, la cuarentena del antivirus, etc. nunca salen por aquí
+	key, err := storage.CleanKey(c.Param("key"))
+	if err != nil || !media.IsPublicKey(key) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Archivo no encontrado"})
+		return
+	}
+
+	// 2. Abrir el archivo
+	file, info, err := core.FileStorage.Open(c.Request.Context(), key)
+
+	// Si es una miniatura que aún no existe (ej: foto subida antes de que hubiera miniaturas), se genera ahora
+	// y se suma a la cuota del dueño de la original
+	if errors.Is(err, storage.ErrNotFound) {
+		if original, size, webp, ok := media.ParseVariantKey(key); ok {
+			generated, genErr := media.GenerateVariant(c.Request.Context(), core.FileStorage, original, size, webp)
+			if genErr == nil {
+				if err := media.RecordVariant(original, generated); err != nil {
+					logging.Log.Errorf("Error al sumar la miniatura %s a la cuota: %v", key, err)
+				}
+				file, info, err = core.FileStorage.Open(c.Request.Context(), key)
+			} else if !errors.Is(genErr, storage.ErrNotFound) {
+				logging.Log.Errorf("Error al generar la miniatura %s: %v", key, genErr)
+			}
+		}
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Archivo no encontrado"})
+		return
+	}
+	if err != nil {
+		logging.Log.Errorf("Error al abrir el archivo %s: %v", key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo leer el archivo"})
+		return
+	}
+	defer file.Close()
+
+	// 3. Las claves son únicas (UUID) y nunca se sobrescriben: se pueden cachear para siempre
+	writeObject(c, file, info, "public, max-age=31536000, immutable")
+}
+
+// uploadError es un fallo al procesar una subida, con la respuesta HTTP que le corresponde
+// (así la misma lógica sirve para la subida normal y para la reanudable)
+type uploadError struct {
+	status int
+	body   gin.H
+}
+
+// writeObject envía un archivo abierto del almacenamiento con las cabeceras de seguridad y de caché
+func writeObject(c *gin.Context, file io.Reader, info *storage.ObjectInfo, cacheControl string) {
+	c.Header("Cache-Control", cacheControl)
+	// Aunque se hubiera colado un HTML (ej: subidas antiguas, sin validar), el navegador no ejecutará nada
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "default-src 'none'; sandbox")
+	if info.ContentType != "" {
+		c.Header("Content-Type", info.ContentType)
+	}
+
+	// Si se puede hacer "seek" (disco local y S3) usamos ServeContent: soporta If-Modified-Since y rangos (Range)
+	if seeker, ok := file.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, "", info.ModTime, seeker)
+		return
+	}
+
+	c.Header("Content-Length", strconv.FormatInt(info.Size, 10))
+	c.Status(http.StatusOK)
+	if c.Request.Method != http.MethodHead {
+		io.Copy(c.Writer, file)
+	}
+}
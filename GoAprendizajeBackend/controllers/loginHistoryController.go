@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"go-aprendizaje/config"
+	"go-aprendizaje/geoip"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/loginhistory"
+	"go-aprendizaje/outbox"
+	"go-aprendizaje/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetLoginHistory devuelve los intentos de inicio de sesión en la cuenta del usuario
+// (GET /api/users/login-history?limit=50&offset=0), correctos y fallidos
+func GetLoginHistory(c *gin.Context) {
+	store, userID, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return
+	}
+	listLoginHistory(c, loginhistory.Filter{Store: store, UserID: userID})
+}
+
+// AdminGetUserLoginHistory devuelve el historial de inicios de sesión de cualquier usuario
+func AdminGetUserLoginHistory(c *gin.Context) {
+	store := c.Param("store")
+	if store != "postgres" && store != "mongo" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "store inválido (usa 'postgres' o 'mongo')"})
+		return
+	}
+	listLoginHistory(c, loginhistory.Filter{Store: store, UserID: c.Param("id")})
+}
+
+// AdminSearchLoginHistory busca en todo el historial (GET /api/admin/login-history?email=&ip=&success=false),
+// incluidos los intentos con emails que no existen (ej: para investigar un ataque de fuerza bruta)
+func AdminSearchLoginHistory(c *gin.Context) {
+	filter := loginhistory.Filter{Email: c.Query("email"), IP: c.Query("ip")}
+	if value := c.Query("success"); value != "" {
+		success, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "success inválido (usa 'true' o 'false')"})
+			return
+		}
+		filter.Success = &success
+	}
+	listLoginHistory(c, filter)
+}
+
+func listLoginHistory(c *gin.Context, filter loginhistory.Filter) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	attempts, total, err := loginhistory.List(filter, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer el historial de inicios de sesión"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"login_history": attempts, "total": total, "limit": limit, "offset": offset})
+}
+
+// recordLogin guarda el intento en el historial (la IP y el dispositivo salen de la petición) y, si es un
+// login correcto desde un dispositivo nuevo o desde un sitio lejos de los habituales, avisa al usuario por email.
+// Si algo falla solo se registra en el log: el historial nunca impide iniciar sesión.
+func recordLogin(c *gin.Context, attempt loginhistory.Attempt, locale string) {
+	attempt.IP = c.ClientIP()
+	attempt.UserAgent = c.Request.UserAgent()
+	entry, err := loginhistory.Record(attempt)
+	if err != nil {
+		logging.Log.Errorf("No se pudo guardar el intento de inicio de sesión de %s: %v", attempt.Email, err)
+		return
+	}
+	if !config.GetEnvBool("LOGIN_ALERTS", true) || (!entry.NewDevice && !entry.UnusualLocation) {
+		return
+	}
+
+	event := utils.SecurityEventNewDevice
+	if entry.UnusualLocation {
+		event = utils.SecurityEventUnusualLocation
+	}
+	location := geoip.Location{Country: entry.Country, City: entry.City}.String()
+	outbox.Send(utils.LoginAlertEmail(locale, attempt.Email, event, entry.CreatedAt, entry.IP, entry.Browser+" / "+entry.OS, location))
+}
@@ -0,0 +1,277 @@
+package controllers
+
+import (
+	"errors"
+	"go-aprendizaje/accountdeletion"
+	"go-aprendizaje/config"
+	"go-aprendizaje/core"
+	"go-aprendizaje/database"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/loginhistory"
+	"go-aprendizaje/models"
+	"go-aprendizaje/outbox"
+	"go-aprendizaje/passwordless"
+	"go-aprendizaje/utils"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Inicio de sesión sin contraseña (enlace o código por email), para usuarios de Postgres y de Mongo.
+// La cookie "magic_login_device" liga la petición al navegador: solo ese navegador puede canjear el enlace o el código.
+
+const (
+	magicDeviceCookie = "magic_login_device"
+	magicCookiePath   = "/api/users/login/magic"
+)
+
+// MagicLoginAcceptedMessage es la respuesta de la petición, exista o no el usuario (no permite enumerar cuentas)
+const MagicLoginAcceptedMessage = "Si el email está registrado, recibirás un email para iniciar sesión"
+
+// magicUser es lo que necesitamos del usuario, venga de Postgres o de Mongo
+type magicUser struct {
+	store   string
+	id      string
+	tokenID interface{} // El "userID" del token: número en Postgres, string en Mongo
+	email   string
+	locale  string
+	role    string
+
+	// Borrado programado de la cuenta (ver deletionBlocksLogin)
+	deletionScheduledAt *time.Time
+	deletionRequestedBy string
+}
+
+// RequestMagicLogin envía por email un enlace (mode "link", por defecto) o un código de 6 dígitos (mode "code")
+func RequestMagicLogin(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+		Store string `json:"store"` // "postgres" (por defecto) o "mongo"
+		Mode  string `json:"mode"`  // "link" (por defecto) o "code"
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos: " + err.Error()})
+		return
+	}
+	if input.Store == "" {
+		input.Store = "postgres"
+	}
+	if input.Mode == "" {
+		input.Mode = models.MagicLoginLink
+	}
+	if input.Store != "postgres" && input.Store != "mongo" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "store inválido (usa 'postgres' o 'mongo')"})
+		return
+	}
+	if input.Mode != models.MagicLoginLink && input.Mode != models.MagicLoginCode {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode inválido (usa 'link' o 'code')"})
+		return
+	}
+
+	// 1. Secreto del navegador: se reutiliza el de la cookie si ya tiene uno
+	deviceSecret, err := c.Cookie(magicDeviceCookie)
+	if err != nil || len(deviceSecret) < 43 {
+		if deviceSecret, err = passwordless.RandomSecret(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo iniciar la petición"})
+			return
+		}
+	}
+	setMagicDeviceCookie(c, deviceSecret, int(passwordless.TTL().Seconds()))
+
+	// 2. Si el usuario existe, crear la petición y enviar el email
+	// (si no existe, tiene demasiadas peticiones pendientes o está bloqueado, no se envía nada pero se responde igual)
+	user, err := findMagicUser(input.Store, input.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al contactar la base de datos"})
+		return
+	}
+	if user != nil && !accountdeletion.BlocksLogin(user.deletionScheduledAt, user.deletionRequestedBy) {
+		secret, login, err := passwordless.Request(user.store, user.id, input.Mode, deviceSecret, c.ClientIP())
+		switch {
+		case errors.Is(err, passwordless.ErrTooManyRequests):
+			logging.Log.Warnf("Inicio de sesión sin contraseña de %s/%s rechazado: %v", user.store, user.id, err)
+		case err != nil:
+			logging.Log.Errorf("Error al crear el inicio de sesión sin contraseña de %s/%s: %v", user.store, user.id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo iniciar la petición"})
+			return
+		case input.Mode == models.MagicLoginCode:
+			outbox.Send(utils.MagicLinkEmail(user.locale, user.email, "", secret, login.ExpiresAt))
+		default:
+			outbox.Send(utils.MagicLinkEmail(user.locale, user.email, magicLinkURL(secret), "", login.ExpiresAt))
+		}
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": MagicLoginAcceptedMessage, "mode": input.Mode})
+}
+
+// VerifyMagicLogin canjea el enlace ({"token"}) o el código ({"email", "store", "code"}) por el token JWT,
+// el mismo que devuelve el login con contraseña
+func VerifyMagicLogin(c *gin.Context) {
+	var input struct {
+		Token string `json:"token"`
+		Email string `json:"email"`
+		Store string `json:"store"`
+		Code  string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos: " + err.Error()})
+		return
+	}
+	if input.Store == "" {
+		input.Store = "postgres"
+	}
+	deviceSecret, _ := c.Cookie(magicDeviceCookie)
+
+	// 1. Canjear el enlace o el código
+	// (en el historial, un enlace que falla no tiene usuario: solo se sabe la IP y el dispositivo)
+	var login *models.MagicLogin
+	var err error
+	var attempt loginhistory.Attempt
+	switch {
+	case input.Token != "":
+		attempt.Method = models.SessionMethodMagicLink
+		login, err = passwordless.ExchangeLink(input.Token, deviceSecret)
+	case input.Code != "" && input.Email != "":
+		attempt = loginhistory.Attempt{Store: input.Store, Email: input.Email, Method: models.SessionMethodMagicCode}
+		user, findErr := findMagicUser(input.Store, input.Email)
+		if findErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al contactar la base de datos"})
+			return
+		}
+		if user == nil {
+			err = passwordless.ErrInvalid
+		} else {
+			attempt.UserID = user.id
+			login, err = passwordless.ExchangeCode(user.store, user.id, input.Code, deviceSecret)
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Envía 'token' (enlace) o 'email' y 'code' (código)"})
+		return
+	}
+
+	switch {
+	case errors.Is(err, passwordless.ErrDeviceMismatch):
+		attempt.Failure = models.LoginFailureDeviceMismatch
+		recordLogin(c, attempt, "")
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, passwordless.ErrInvalid):
+		attempt.Failure = models.LoginFailureInvalidCode
+		recordLogin(c, attempt, "")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo iniciar sesión"})
+		return
+	}
+
+	// 2. Generar el mismo token que el login con contraseña
+	user, err := magicUserByID(login.UserStore, login.UserID)
+	if err != nil || user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": passwordless.ErrInvalid.Error()})
+		return
+	}
+	method := models.SessionMethodMagicLink
+	if login.Mode == models.MagicLoginCode {
+		method = models.SessionMethodMagicCode
+	}
+	attempt = loginhistory.Attempt{Store: user.store, UserID: user.id, Email: user.email, Method: method}
+	if deletionBlocksLogin(c, attempt, user.locale, user.deletionScheduledAt, user.deletionRequestedBy) {
+		return
+	}
+	response, err := issueToken(c, user.tokenID, user.role, method)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al generar el token"})
+		return
+	}
+
+	recordLogin(c, attempt, user.locale)
+
+	// La petición ya se usó: la cookie del navegador ya no hace falta
+	setMagicDeviceCookie(c, "", -1)
+	c.JSON(http.StatusOK, withPendingDeletion(response, user.deletionScheduledAt))
+}
+
+// findMagicUser busca al usuario por email en su BD (nil si no existe)
+func findMagicUser(store string, email string) (*magicUser, error) {
+	if store == "mongo" {
+		user, err := core.MongoUserRepo.GetUserByEmail(email)
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return mongoMagicUser(user), nil
+	}
+
+	var users []models.User
+	if err := database.DB.Where("email = ? AND service_account = ?", email, false).Limit(1).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, nil
+	}
+	return pgMagicUser(&users[0]), nil
+}
+
+// magicUserByID busca al usuario de una petición ya canjeada (nil si ya no existe)
+func magicUserByID(store string, userID string) (*magicUser, error) {
+	if store == "mongo" {
+		user, err := core.MongoUserRepo.GetUserByID(userID)
+		if err != nil {
+			return nil, err
+		}
+		return mongoMagicUser(user), nil
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	return pgMagicUser(&user), nil
+}
+
+func pgMagicUser(user *models.User) *magicUser {
+	return &magicUser{
+		store:               "postgres",
+		id:                  strconv.FormatUint(uint64(user.ID), 10),
+		tokenID:             user.ID,
+		email:               user.Email,
+		locale:              user.Locale,
+		role:                user.Role,
+		deletionScheduledAt: user.DeletionScheduledAt,
+		deletionRequestedBy: user.DeletionRequestedBy,
+	}
+}
+
+func mongoMagicUser(user *models.MongoUser) *magicUser {
+	return &magicUser{
+		store:               "mongo",
+		id:                  user.ID.Hex(),
+		tokenID:             user.ID.Hex(),
+		email:               user.Email,
+		locale:              user.Locale,
+		role:                user.Role,
+		deletionScheduledAt: user.DeletionScheduledAt,
+		deletionRequestedBy: user.DeletionRequestedBy,
+	}
+}
+
+// magicLinkURL es el enlace del email: una página del frontend que envía el token a VerifyMagicLogin
+// (desde el navegador, para que vaya la cookie)
+func magicLinkURL(token string) string {
+	base := config.GetEnv("MAGIC_LINK_URL", config.GetEnv("FRONTEND_URL", "http://localhost:3000")+"/login/magic")
+	return base + "?token=" + url.QueryEscape(token)
+}
+
+// setMagicDeviceCookie guarda (o borra, con maxAge -1) el secreto del navegador.
+// HttpOnly: el JavaScript de la página no lo puede leer; solo viaja a las rutas de este login.
+func setMagicDeviceCookie(c *gin.Context, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(magicDeviceCookie, value, maxAge, magicCookiePath, "", config.SecureCookies(), true)
+}
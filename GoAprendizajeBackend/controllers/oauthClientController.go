@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"errors"
+	"go-aprendizaje/oauth"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oauthClientInput es el cuerpo para registrar un cliente OAuth
+type oauthClientInput struct {
+	Name         string   `json:"name" binding:"required,max=100"`
+	Public       bool     `json:"public"`        // SPA o app nativa (sin secreto)
+	RedirectURIs []string `json:"redirect_uris"` // https, http://localhost o esquema propio de app nativa
+	Scopes       []string `json:"scopes"`        // Vacío = todos (ver oauth.Scopes)
+	GrantTypes   []string `json:"grant_types"`   // Vacío = authorization_code y refresh_token
+	SkipConsent  bool     `json:"skip_consent"`  // Solo para aplicaciones propias de confianza
+}
+
+// AdminCreateOAuthClient registra una aplicación cliente. El secreto (si es confidencial) solo se devuelve aquí.
+func AdminCreateOAuthClient(c *gin.Context) {
+	var input oauthClientInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos: " + err.Error()})
+		return
+	}
+	_, adminID, _ := currentUser(c)
+
+	client, secret, err := oauth.CreateClient(oauth.ClientInput{
+		Name:         input.Name,
+		Public:       input.Public,
+		RedirectURIs: input.RedirectURIs,
+		Scopes:       input.Scopes,
+		GrantTypes:   input.GrantTypes,
+		SkipConsent:  input.SkipConsent,
+	}, "admin:"+adminID)
+	if errors.Is(err, oauth.ErrInvalidClientConfig) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo registrar el cliente OAuth"})
+		return
+	}
+
+	response := gin.H{"message": "Cliente OAuth registrado", "client": client}
+	if secret != "" {
+		response["message"] = "Cliente OAuth registrado. Guarda el secreto ahora: no se volverá a mostrar"
+		response["client_secret"] = secret
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// AdminListOAuthClients lista las aplicaciones cliente registradas
+func AdminListOAuthClients(c *gin.Context) {
+	clients, err := oauth.ListClients()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer los clientes OAuth"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"clients": clients})
+}
+
+// AdminDeleteOAuthClient borra una aplicación cliente: todos sus tokens dejan de valer
+func AdminDeleteOAuthClient(c *gin.Context) {
+	err := oauth.DeleteClient(c.Param("clientID"))
+	if errors.Is(err, oauth.ErrClientNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo borrar el cliente OAuth"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Cliente OAuth borrado"})
+}
+
+// AdminRotateOAuthClientSecret genera un secreto nuevo para un cliente confidencial (el anterior deja de valer)
+func AdminRotateOAuthClientSecret(c *gin.Context) {
+	secret, err := oauth.RotateSecret(c.Param("clientID"))
+	switch {
+	case errors.Is(err, oauth.ErrClientNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, oauth.ErrInvalidClientConfig):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo rotar el secreto"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Secreto rotado. Guárdalo ahora: no se volverá a mostrar",
+		"client_secret": secret,
+	})
+}
@@ -0,0 +1,293 @@
+package controllers
+
+import (
+	"errors"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/models"
+	"go-aprendizaje/oauth"
+	"go-aprendizaje/sessions"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Endpoints del servidor OAuth 2.1 / OpenID Connect (ver el paquete oauth).
+// Los de la especificación responden los errores en su formato ({"error": "<código>", "error_description"}),
+// no en el del resto de la API: las librerías cliente de OAuth esperan ese.
+
+// OpenIDConfiguration devuelve los metadatos del servidor (GET /.well-known/openid-configuration)
+func OpenIDConfiguration(c *gin.Context) {
+	c.JSON(http.StatusOK, oauth.Discovery())
+}
+
+// OAuthJWKS devuelve las claves públicas con las que se comprueban los tokens (GET /api/oauth/jwks)
+func OAuthJWKS(c *gin.Context) {
+	c.JSON(http.StatusOK, oauth.JWKS())
+}
+
+// OAuthAuthorize recibe al navegador que envía la aplicación cliente (GET /api/oauth/authorize) y lo manda
+// a la pantalla de consentimiento del frontend. Los errores se devuelven al cliente en su redirect_uri,
+// salvo si el propio cliente o la redirect_uri no son válidos (entonces no se redirige a ningún sitio).
+func OAuthAuthorize(c *gin.Context) {
+	var request oauth.AuthorizeRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+	client, oauthErr := oauth.ResolveClient(&request)
+	if oauthErr != nil {
+		c.JSON(oauthErr.Status, gin.H{"error": oauthErr.Code, "error_description": oauthErr.Description})
+		return
+	}
+
+	authorization, oauthErr := oauth.StartAuthorization(client, request)
+	if oauthErr != nil {
+		c.Redirect(http.StatusFound, oauth.ErrorRedirect(request.RedirectURI, oauthErr, request.State))
+		return
+	}
+	c.Redirect(http.StatusFound, oauth.ConsentURL(authorization))
+}
+
+// GetOAuthAuthorizationRequest devuelve lo que la pantalla de consentimiento muestra al usuario
+// (GET /api/oauth/requests/:id): la aplicación y los permisos que pide. Si "consent_required" es false
+// (ya los aceptó antes, o la aplicación es de confianza), el frontend puede enviar la decisión sin preguntar.
+func GetOAuthAuthorizationRequest(c *gin.Context) {
+	store, userID, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return
+	}
+	authorization, client, err := oauth.GetPendingAuthorization(c.Param("id"))
+	if errors.Is(err, oauth.ErrRequestNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer la petición de autorización"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"request_id":       authorization.ID,
+		"client":           gin.H{"client_id": client.ID, "name": client.Name},
+		"scopes":           strings.Fields(authorization.Scope),
+		"redirect_uri":     authorization.RedirectURI,
+		"consent_required": oauth.ConsentRequired(authorization, client, store, userID),
+		"expires_at":       authorization.ExpiresAt,
+	})
+}
+
+// DecideOAuthAuthorizationRequest guarda la decisión del usuario (POST /api/oauth/requests/:id/decision,
+// {"approve": true|false}) y devuelve a dónde mandar al navegador ("redirect_to": la redirect_uri del cliente)
+func DecideOAuthAuthorizationRequest(c *gin.Context) {
+	store, userID, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return
+	}
+	var input struct {
+		Approve *bool `json:"approve" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos: " + err.Error()})
+		return
+	}
+
+	// "auth_time" del ID token: cuándo inició sesión el usuario (el de su sesión, no el de esta petición)
+	authTime := sessions.AuthTime(c.GetString("sessionID"))
+	redirectTo, err := oauth.Decide(c.Param("id"), store, userID, *input.Approve, authTime)
+	if errors.Is(err, oauth.ErrRequestNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, oauth.ErrUserDisabled) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Tu cuenta tiene el borrado programado: no puedes dar acceso a aplicaciones"})
+		return
+	}
+	if err != nil {
+		logging.Log.Errorf("No se pudo guardar la decisión de la petición OAuth %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo guardar la decisión"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"redirect_to": redirectTo})
+}
+
+// OAuthToken emite tokens (POST /api/oauth/token, formulario): authorization_code, refresh_token o client_credentials
+func OAuthToken(c *gin.Context) {
+	// Las respuestas llevan tokens: que nada las guarde en caché
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	client, ok := oauthClient(c)
+	if !ok {
+		return
+	}
+
+	var response *oauth.TokenResponse
+	var err error
+	switch c.PostForm("grant_type") {
+	case models.GrantAuthorizationCode:
+		response, err = oauth.ExchangeCode(client, c.PostForm("code"), c.PostForm("redirect_uri"), c.PostForm("code_verifier"))
+	case models.GrantRefreshToken:
+		response, err = oauth.RefreshTokens(client, c.PostForm("refresh_token"), c.PostForm("scope"))
+	case models.GrantClientCredentials:
+		response, err = oauth.ClientCredentials(client, c.PostForm("scope"))
+	case "":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "Falta grant_type"})
+		return
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported_grant_type", "error_description": "grant_type no soportado"})
+		return
+	}
+	if err != nil {
+		oauthErrorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// OAuthUserInfo devuelve los datos del usuario del token de acceso (GET o POST /api/oauth/userinfo),
+// según los permisos que aceptó: "sub" siempre, "email" con email, "locale" y "picture" con profile
+func OAuthUserInfo(c *gin.Context) {
+	token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !found || token == "" {
+		c.Header("WWW-Authenticate", `Bearer realm="oauth"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_request", "error_description": "Falta el token de acceso"})
+		return
+	}
+	claims, err := oauth.ParseAccessToken(token)
+	if err != nil {
+		c.Header("WWW-Authenticate", `Bearer realm="oauth", error="invalid_token"`)
+		oauthErrorResponse(c, err)
+		return
+	}
+
+	info, oauthErr := oauth.UserInfo(claims)
+	if oauthErr != nil {
+		c.Header("WWW-Authenticate", `Bearer realm="oauth", error="`+oauthErr.Code+`"`)
+		oauthErrorResponse(c, oauthErr)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, info)
+}
+
+// OAuthIntrospect describe un token (POST /api/oauth/introspect, RFC 7662).
+// Solo para clientes confidenciales (los servidores de recursos que reciben los tokens).
+func OAuthIntrospect(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	client, ok := oauthClient(c)
+	if !ok {
+		return
+	}
+	if client.Public {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client", "error_description": "La introspección es solo para clientes confidenciales"})
+		return
+	}
+	token := c.PostForm("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "Falta token"})
+		return
+	}
+	c.JSON(http.StatusOK, oauth.Introspect(token, c.PostForm("token_type_hint")))
+}
+
+// OAuthRevoke revoca un token del cliente (POST /api/oauth/revoke, RFC 7009).
+// Responde 200 aunque el token no exista o ya no valga.
+func OAuthRevoke(c *gin.Context) {
+	client, ok := oauthClient(c)
+	if !ok {
+		return
+	}
+	token := c.PostForm("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "Falta token"})
+		return
+	}
+	if err := oauth.Revoke(client, token); err != nil {
+		oauthErrorResponse(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// ListOAuthConsents lista las aplicaciones a las que el usuario dio acceso a su cuenta (GET /api/users/oauth/consents)
+func ListOAuthConsents(c *gin.Context) {
+	store, userID, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return
+	}
+	consents, err := oauth.ListConsents(store, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer las aplicaciones autorizadas"})
+		return
+	}
+
+	views := make([]gin.H, 0, len(consents))
+	for _, consent := range consents {
+		view := gin.H{"client_id": consent.ClientID, "scopes": strings.Fields(consent.Scope), "updated_at": consent.UpdatedAt}
+		if client, err := oauth.GetClient(consent.ClientID); err == nil {
+			view["name"] = client.Name
+		}
+		views = append(views, view)
+	}
+	c.JSON(http.StatusOK, gin.H{"consents": views})
+}
+
+// RevokeOAuthConsent quita el acceso de una aplicación a la cuenta (DELETE /api/users/oauth/consents/:clientID):
+// sus tokens dejan de valer y la próxima vez se vuelve a preguntar
+func RevokeOAuthConsent(c *gin.Context) {
+	store, userID, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return
+	}
+	err := oauth.RevokeConsent(store, userID, c.Param("clientID"))
+	if errors.Is(err, oauth.ErrClientNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "La aplicación no tiene acceso a la cuenta"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo quitar el acceso a la aplicación"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Acceso de la aplicación revocado"})
+}
+
+// oauthClient identifica al cliente de la petición: "Authorization: Basic" (client_secret_basic) o los campos
+// client_id y client_secret del formulario (client_secret_post; los públicos solo envían client_id).
+// Si falla, ya ha respondido.
+func oauthClient(c *gin.Context) (*models.OAuthClient, bool) {
+	clientID, secret, basic := c.Request.BasicAuth()
+	if basic {
+		// En Basic el id y el secreto van codificados como en un formulario (RFC 6749, 2.3.1)
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID, secret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+
+	client, err := oauth.AuthenticateClient(clientID, secret)
+	if err != nil {
+		if basic {
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		oauthErrorResponse(c, err)
+		return nil, false
+	}
+	return client, true
+}
+
+// oauthErrorResponse responde un error de OAuth en el formato de la especificación
+func oauthErrorResponse(c *gin.Context, err error) {
+	var oauthErr *oauth.Error
+	if errors.As(err, &oauthErr) {
+		c.JSON(oauthErr.Status, gin.H{"error": oauthErr.Code, "error_description": oauthErr.Description})
+		return
+	}
+	logging.Log.Errorf("Error en el servidor OAuth (%s): %v", c.FullPath(), err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": "Error interno"})
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"errors"
+	"go-aprendizaje/database"
+	"go-aprendizaje/models"
+	"go-aprendizaje/outbox"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AdminListEmails lista la bandeja de salida (GET /api/admin/emails?status=dead&limit=50&offset=0).
+// Sin el cuerpo de los emails; para verlo, AdminGetEmail.
+func AdminListEmails(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	query := database.DB.Model(&models.OutboxEmail{}).Omit("body", "text_body")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var emails []models.OutboxEmail
+	if err := query.Order("id desc").Limit(limit).Offset(offset).Find(&emails).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer la bandeja de salida"})
+		return
+	}
+
+	// Resumen por estado (para ver de un vistazo si hay emails atascados)
+	var counts []struct {
+		Status string
+		Count  int64
+	}
+	database.DB.Model(&models.OutboxEmail{}).Select("status, count(*) as count").Group("status").Scan(&counts)
+	summary := gin.H{}
+	for _, count := range counts {
+		summary[count.Status] = count.Count
+	}
+
+	c.JSON(http.StatusOK, gin.H{"emails": emails, "total": total, "summary": summary})
+}
+
+// AdminGetEmail devuelve un email de la bandeja de salida con su cuerpo (salvo si es sensible)
+func AdminGetEmail(c *gin.Context) {
+	var email models.OutboxEmail
+	if err := database.DB.First(&email, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email no encontrado"})
+		return
+	}
+	if email.Sensitive {
+		email.Body, email.TextBody = "", "" // Lleva un enlace o código secreto del usuario
+	}
+	c.JSON(http.StatusOK, gin.H{"email": email})
+}
+
+// AdminResendEmail vuelve a poner en cola un email (ej: los "dead" después de arreglar el SMTP)
+func AdminResendEmail(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email no encontrado"})
+		return
+	}
+
+	if err := outbox.Resend(uint(id)); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Email no encontrado"})
+		case errors.Is(err, outbox.ErrNotResendable):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo reenviar el email"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Email puesto en cola de nuevo"})
+}
@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"go-aprendizaje/core"
+	"go-aprendizaje/database"
+	"go-aprendizaje/models"
+	"go-aprendizaje/outbox"
+	"go-aprendizaje/security"
+	"go-aprendizaje/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// validatePassword aplica la política de contraseñas global.
+// Si la contraseña no la cumple, responde 400 con un mensaje por cada regla incumplida y devuelve false.
+func validatePassword(c *gin.Context, password string, email string) bool {
+	violations := security.Policy.Validate(password, email)
+	if len(violations) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":      "La contraseña no cumple la política de seguridad",
+			"violations": violations,
+		})
+		return false
+	}
+	return true
+}
+
+// changePasswordInput es el cuerpo que esperamos al cambiar la contraseña (Postgres y Mongo)
+type changePasswordInput struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// ChangePassword cambia la contraseña de un usuario de Postgres (requiere la contraseña actual)
+func ChangePassword(c *gin.Context) {
+	// 1. Obtener el ID de usuario del token (los de Mongo cambian la contraseña en /mongo/password)
+	store, userID, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return
+	}
+	if store != "postgres" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El token no pertenece a un usuario de Postgres"})
+		return
+	}
+
+	// 2. Bindear el JSON
+	var input changePasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos: " + err.Error()})
+		return
+	}
+
+	// 3. Buscar al usuario y comprobar la contraseña actual
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+		return
+	}
+	if !security.Passwords.Matches(input.CurrentPassword, user.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "La contraseña actual no es correcta"})
+		return
+	}
+
+	// 4. Aplicar la política a la nueva contraseña
+	if !validatePassword(c, input.NewPassword, user.Email) {
+		return
+	}
+
+	// 5. Comprobar que no sea la actual ni una de las últimas usadas
+	var history []models.PasswordHistory
+	database.DB.Where("user_id = ?", user.ID).Order("created_at desc").Limit(security.Policy.HistorySize).Find(&history)
+
+	previousHashes := make([]string, 0, len(history))
+	for _, entry := range history {
+		previousHashes = append(previousHashes, entry.Hash)
+	}
+	if security.Policy.IsReused(input.NewPassword, user.Password, previousHashes) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No puedes reutilizar una contraseña reciente"})
+		return
+	}
+
+	// 6. Hashear la nueva contraseña
+	hashedPassword, err := security.Passwords.Hash(input.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al hashear la contraseña"})
+		return
+	}
+
+	// 7. Guardar la nueva contraseña y el hash anterior en el historial (en una transacción,
+	// junto con el aviso por email al usuario)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("password", hashedPassword).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.PasswordHistory{UserID: user.ID, Hash: user.Password}).Error; err != nil {
+			return err
+		}
+
+		// Borramos las entradas más antiguas que ya no hacen falta
+		err := tx.Where("user_id = ? AND id NOT IN (?)", user.ID,
+			tx.Model(&models.PasswordHistory{}).Select("id").Where("user_id = ?", user.ID).
+				Order("created_at desc").Limit(security.Policy.HistorySize),
+		).Delete(&models.PasswordHistory{}).Error
+		if err != nil {
+			return err
+		}
+
+		return outbox.Enqueue(tx, passwordChangedEmail(c, user.Locale, user.Email))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo actualizar la contraseña"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Contraseña actualizada exitosamente"})
+}
+
+// MongoChangePassword cambia la contraseña de un usuario de MongoDB (requiere la contraseña actual)
+func MongoChangePassword(c *gin.Context) {
+	// 1. Obtener el ID de usuario del token (en Mongo es un string hexadecimal)
+	store, userID, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return
+	}
+	if store != "mongo" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El token no pertenece a un usuario de Mongo"})
+		return
+	}
+
+	// 2. Bindear el JSON
+	var input changePasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos: " + err.Error()})
+		return
+	}
+
+	// 3. Buscar al usuario y comprobar la contraseña actual
+	user, err := core.MongoUserRepo.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado (Mongo)"})
+		return
+	}
+	if !security.Passwords.Matches(input.CurrentPassword, user.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "La contraseña actual no es correcta"})
+		return
+	}
+
+	// 4. Aplicar la política y el historial
+	if !validatePassword(c, input.NewPassword, user.Email) {
+		return
+	}
+	if security.Policy.IsReused(input.NewPassword, user.Password, user.PasswordHistory) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No puedes reutilizar una contraseña reciente"})
+		return
+	}
+
+	// 5. Hashear y guardar (el repositorio mueve el hash actual al historial)
+	hashedPassword, err := security.Passwords.Hash(input.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo hashear la contraseña"})
+		return
+	}
+
+	if err := core.MongoUserRepo.UpdatePassword(user.ID, hashedPassword, user.Password, security.Policy.HistorySize); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo actualizar la contraseña (Mongo)"})
+		return
+	}
+
+	// 6. Avisar al usuario por email (si no fue él, así se entera)
+	outbox.Send(passwordChangedEmail(c, user.Locale, user.Email))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Contraseña actualizada exitosamente (Mongo)"})
+}
+
+// passwordChangedEmail es el aviso de seguridad de "tu contraseña ha cambiado", con la IP y el navegador de la petición
+func passwordChangedEmail(c *gin.Context, locale string, email string) utils.Email {
+	return utils.SecurityAlertEmail(locale, email, utils.SecurityEventPasswordChanged, time.Now(), c.ClientIP(), c.Request.UserAgent())
+}
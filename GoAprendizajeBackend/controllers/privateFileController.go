@@ -0,0 +1,306 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go-aprendizaje/config"
+	"go-aprendizaje/core"
+	"go-aprendizaje/database"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/media"
+	"go-aprendizaje/models"
+	"go-aprendizaje/security"
+	"go-aprendizaje/storage"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// privateFileResponse es lo que devolvemos de cada archivo privado (nunca la clave interna)
+type privateFileResponse struct {
+	ID          uint      `json:"id"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+	ScanStatus  string    `json:"scan_status"`            // "pending" mientras está en cuarentena
+	DownloadURL string    `json:"download_url,omitempty"` // URL firmada de corta duración (solo si se puede descargar)
+}
+
+// UploadPrivateFile sube un archivo privado del usuario (ej: escaneo de un documento de identidad)
+func UploadPrivateFile(c *gin.Context) {
+	// 1. Saber quién es el usuario
+	store, userID, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return
+	}
+
+	// 2. Limitar el tamaño del cuerpo antes de leer el formulario
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, media.DocumentMaxBytes()+64*1024)
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": media.ErrTooLarge.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No se proporcionó ningún archivo"})
+		return
+	}
+
+	// 3. Validar y guardar el archivo
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No se pudo leer el archivo"})
+		return
+	}
+	defer src.Close()
+
+	record, uploadErr := savePrivateFile(c.Request.Context(), store, userID, file.Filename, src)
+	if uploadErr != nil {
+		c.JSON(uploadErr.status, uploadErr.body)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Archivo subido exitosamente", "file": toPrivateFileResponse(record)})
+}
+
+// savePrivateFile valida un archivo privado, lo guarda en la "carpeta" del usuario y lo registra
+// (lo usan la subida normal y la reanudable)
+func savePrivateFile(ctx context.Context, store string, userID string, fileName string, src io.Reader) (*models.StoredFile, *uploadError) {
+	// 1. Validar el contenido (tipo real, tamaño, imágenes sin metadatos)
+	document, err := media.ProcessDocument(src)
+	if err != nil {
+		switch {
+		case errors.Is(err, media.ErrTooLarge):
+			return nil, &uploadError{http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()}}
+		case errors.Is(err, media.ErrUnsupportedType):
+			return nil, &uploadError{http.StatusUnsupportedMediaType, gin.H{"error": err.Error()}}
+		case errors.Is(err, media.ErrInvalidImage), errors.Is(err, media.ErrTooManyPixels):
+			return nil, &uploadError{http.StatusBadRequest, gin.H{"error": err.Error()}}
+		default:
+			logging.Log.Errorf("Error al procesar un archivo privado de %s/%s: %v", store, userID, err)
+			return nil, &uploadError{http.StatusInternalServerError, gin.H{"error": "No se pudo procesar el archivo"}}
+		}
+	}
+
+	// 2. Comprobar la cuota
+	if err := media.CheckQuota(store, userID, int64(len(document.Data)), ""); err != nil {
+		return nil, &uploadError{http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()}}
+	}
+
+	// 3. Guardar en la "carpeta" privada del usuario
+	// (o en cuarentena si hay antivirus: no se podrá descargar hasta que se analice)
+	name := uuid.New().String() + document.Extension
+	key := media.PrivateKey(store, userID, name)
+	scanStatus := models.ScanNotScanned
+	if core.FileScanner != nil {
+		key = media.QuarantineKey(name)
+		scanStatus = models.ScanPending
+	}
+	if err := core.FileStorage.Put(ctx, key, bytes.NewReader(document.Data), int64(len(document.Data)), document.ContentType); err != nil {
+		logging.Log.Errorf("Error al guardar el archivo privado %s: %v", key, err)
+		return nil, &uploadError{http.StatusInternalServerError, gin.H{"error": "No se pudo guardar el archivo"}}
+	}
+
+	record := &models.StoredFile{
+		Key:         key,
+		UserStore:   store,
+		UserID:      userID,
+		Kind:        models.StoredFilePrivate,
+		Size:        int64(len(document.Data)),
+		FileName:    downloadName(fileName, document.Extension),
+		ContentType: document.ContentType,
+		ScanStatus:  scanStatus,
+	}
+	if err := media.RecordFile(record); err != nil {
+		core.FileStorage.Delete(ctx, key)
+		return nil, &uploadError{http.StatusInternalServerError, gin.H{"error": "No se pudo registrar el archivo"}}
+	}
+
+	// 4. Analizar: en segundo plano (por defecto) o antes de responder con SCANNER_ASYNC=false.
+	// En modo síncrono un archivo infectado se rechaza directamente; si el antivirus falla, queda pendiente.
+	if scanStatus == models.ScanPending {
+		if config.GetEnvBool("SCANNER_ASYNC", true) {
+			media.QueueScan(record.ID)
+		} else if err := media.ScanFile(ctx, core.FileStorage, core.FileScanner, record); err == nil && record.ScanStatus == models.ScanInfected {
+			return nil, &uploadError{http.StatusUnprocessableEntity, gin.H{"error": "El archivo contiene malware y se ha eliminado", "signature": record.ScanSignature}}
+		}
+	}
+	return record, nil
+}
+
+// ListPrivateFiles lista los archivos privados del usuario (con URLs de descarga firmadas)
+func ListPrivateFiles(c *gin.Context) {
+	store, userID, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"files": listPrivateFiles(store, userID)})
+}
+
+// AdminListUserFiles lista los archivos privados de cualquier usuario (solo admin, ej: para revisar documentos)
+func AdminListUserFiles(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"files": listPrivateFiles(c.Param("store"), c.Param("id"))})
+}
+
+// DownloadPrivateFile descarga un archivo privado con el token JWT (el dueño o un admin)
+func DownloadPrivateFile(c *gin.Context) {
+	record, ok := findOwnedFile(c)
+	if !ok {
+		return
+	}
+	servePrivateFile(c, record, c.Query("disposition") == "inline", "private, no-store")
+}
+
+// DeletePrivateFile borra un archivo privado del usuario
+func DeletePrivateFile(c *gin.Context) {
+	record, ok := findOwnedFile(c)
+	if !ok {
+		return
+	}
+
+	if err := core.FileStorage.Delete(c.Request.Context(), record.Key); err != nil {
+		logging.Log.Errorf("Error al borrar el archivo privado %s: %v", record.Key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo borrar el archivo"})
+		return
+	}
+	media.ForgetFile(record.Key)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Archivo borrado"})
+}
+
+// ServeSignedFile descarga un archivo privado con una URL firmada (GET /api/files/:id?expires=...&signature=...).
+// No necesita el token: la firma demuestra que la URL la generó la API para alguien autorizado.
+func ServeSignedFile(c *gin.Context) {
+	expires, valid := security.VerifySignedURL(c.Request.URL.Path, c.Request.URL.Query())
+	if !valid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Enlace inválido o caducado"})
+		return
+	}
+
+	var record models.StoredFile
+	if err := database.DB.Where("id = ? AND kind = ?", c.Param("id"), models.StoredFilePrivate).First(&record).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Archivo no encontrado"})
+		return
+	}
+
+	// La caché (solo del navegador) no puede durar más que la firma
+	maxAge := int(time.Until(expires).Seconds())
+	servePrivateFile(c, &record, c.Query("disposition") == "inline", "private, max-age="+strconv.Itoa(maxAge))
+}
+
+// findOwnedFile busca el archivo privado ':id' y comprueba que sea del usuario (o que el usuario sea admin)
+func findOwnedFile(c *gin.Context) (*models.StoredFile, bool) {
+	store, userID, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return nil, false
+	}
+
+	var record models.StoredFile
+	err := database.DB.Where("id = ? AND kind = ?", c.Param("id"), models.StoredFilePrivate).First(&record).Error
+	isOwner := err == nil && record.UserStore == store && record.UserID == userID
+
+	// Mismo error si no existe o si es de otro usuario: no revelamos qué IDs existen
+	// (los admins ven los de todos, salvo con una API key sin el permiso "admin")
+	if err != nil || (!isOwner && !actsAsAdmin(c)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Archivo no encontrado"})
+		return nil, false
+	}
+	return &record, true
+}
+
+// servePrivateFile envía el archivo con su nombre original en Content-Disposition
+func servePrivateFile(c *gin.Context, record *models.StoredFile, inline bool, cacheControl string) {
+	// Los archivos en cuarentena (o infectados) no se pueden descargar
+	if !media.CanDownload(record) {
+		c.JSON(http.StatusConflict, gin.H{"error": "El archivo no está disponible", "scan_status": record.ScanStatus})
+		return
+	}
+
+	file, info, err := core.FileStorage.Open(c.Request.Context(), record.Key)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Archivo no encontrado"})
+		return
+	}
+	if err != nil {
+		logging.Log.Errorf("Error al abrir el archivo privado %s: %v", record.Key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo leer el archivo"})
+		return
+	}
+	defer file.Close()
+
+	// Solo las imágenes se pueden ver en el navegador; el resto siempre se descarga
+	disposition := "attachment"
+	if inline && strings.HasPrefix(record.ContentType, "image/") {
+		disposition = "inline"
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": record.FileName}))
+	info.ContentType = record.ContentType
+
+	writeObject(c, file, info, cacheControl)
+}
+
+// listPrivateFiles devuelve los archivos privados de un usuario, del más reciente al más antiguo
+func listPrivateFiles(store string, userID string) []privateFileResponse {
+	var records []models.StoredFile
+	database.DB.Where("user_store = ? AND user_id = ? AND kind = ?", store, userID, models.StoredFilePrivate).
+		Order("created_at desc").Find(&records)
+
+	files := make([]privateFileResponse, 0, len(records))
+	for i := range records {
+		files = append(files, toPrivateFileResponse(&records[i]))
+	}
+	return files
+}
+
+func toPrivateFileResponse(record *models.StoredFile) privateFileResponse {
+	ttl, err := time.ParseDuration(config.GetEnv("FILE_URL_TTL", "5m"))
+	if err != nil {
+		ttl = 5 * time.Minute
+	}
+
+	response := privateFileResponse{
+		ID:          record.ID,
+		FileName:    record.FileName,
+		ContentType: record.ContentType,
+		Size:        record.Size,
+		CreatedAt:   record.CreatedAt,
+		ScanStatus:  record.ScanStatus,
+	}
+	if media.CanDownload(record) {
+		response.DownloadURL = security.SignURL("/api/files/"+strconv.FormatUint(uint64(record.ID), 10), url.Values{}, ttl)
+	}
+	return response
+}
+
+// downloadName limpia el nombre que envió el cliente y le pone la extensión real del archivo
+func downloadName(original string, extension string) string {
+	name := strings.TrimSuffix(filepath.Base(strings.ReplaceAll(original, "\\", "/")), filepath.Ext(original))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '"' || r == '/' {
+			return -1
+		}
+		return r
+	}, name)
+	if len([]rune(name)) > 100 {
+		name = string([]rune(name)[:100])
+	}
+	if name == "" || name == "." {
+		name = "archivo"
+	}
+	return name + extension
+}
@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"go-aprendizaje/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+// Un admin ve los archivos de todos con su sesión, pero con una API key solo si la key tiene el permiso "admin"
+func TestFindOwnedFileAdminAPIKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   float64
+		apiKey   *models.APIKey
+		expected int
+	}{
+		{"admin con sesión", 2, nil, http.StatusOK},
+		{"admin con key files:read", 2, &models.APIKey{Scopes: models.ScopeFilesRead}, http.StatusNotFound},
+		{"admin con key admin", 2, &models.APIKey{Scopes: models.ScopeFilesRead + " " + models.ScopeAdmin}, http.StatusOK},
+		{"dueño con key files:read", 1, &models.APIKey{Scopes: models.ScopeFilesRead}, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mock := setupAuthTest(t)
+			mock.ExpectQuery(`SELECT \* FROM "stored_files"`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "kind", "user_store", "user_id"}).
+					AddRow(7, models.StoredFilePrivate, "postgres", "1"))
+
+			router := gin.New()
+			router.GET("/:id", func(c *gin.Context) {
+				c.Set("userID", test.userID)
+				c.Set("role", map[float64]string{1: "user", 2: "admin"}[test.userID])
+				if test.apiKey != nil {
+					c.Set("apiKey", test.apiKey)
+				}
+				if _, ok := findOwnedFile(c); ok {
+					c.Status(http.StatusOK)
+				}
+			})
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/7", nil))
+			if recorder.Code != test.expected {
+				t.Fatalf("código = %d; se esperaba %d", recorder.Code, test.expected)
+			}
+		})
+	}
+}
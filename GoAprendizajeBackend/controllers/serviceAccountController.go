@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"go-aprendizaje/database"
+	"go-aprendizaje/models"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cuentas de servicio: usuarios de Postgres para scripts e integraciones. No tienen contraseña ni pueden
+// iniciar sesión; se usan solo con API keys (AdminCreateUserAPIKey). Su email es "<nombre>@service-accounts.invalid":
+// el dominio ".invalid" no existe, así que nunca se les envía correo (ver suppression.Check).
+
+const serviceAccountDomain = "@service-accounts.invalid"
+
+// serviceAccountName: minúsculas, números y guiones (ej: "backup-nocturno")
+var serviceAccountName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,38}[a-z0-9]$`)
+
+// AdminCreateServiceAccount crea una cuenta de servicio
+func AdminCreateServiceAccount(c *gin.Context) {
+	var input struct {
+		Name string `json:"name" binding:"required"`
+		Role string `json:"role"` // "user" (por defecto) o "admin"
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos: " + err.Error()})
+		return
+	}
+	if !serviceAccountName.MatchString(input.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nombre inválido: usa de 3 a 40 minúsculas, números o guiones"})
+		return
+	}
+	if input.Role == "" {
+		input.Role = "user"
+	}
+	if input.Role != "user" && input.Role != "admin" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role inválido (usa 'user' o 'admin')"})
+		return
+	}
+
+	account := models.User{
+		Email:          input.Name + serviceAccountDomain,
+		Role:           input.Role,
+		ServiceAccount: true,
+	}
+	var count int64
+	database.DB.Unscoped().Model(&models.User{}).Where("email = ?", account.Email).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ya existe una cuenta de servicio con ese nombre"})
+		return
+	}
+	if err := database.DB.Create(&account).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo crear la cuenta de servicio"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":         "Cuenta de servicio creada. Crea sus API keys en /api/admin/users/postgres/:id/api-keys",
+		"service_account": serviceAccountView(account),
+	})
+}
+
+// AdminListServiceAccounts lista las cuentas de servicio
+func AdminListServiceAccounts(c *gin.Context) {
+	var accounts []models.User
+	if err := database.DB.Where("service_account = ?", true).Order("id").Find(&accounts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer las cuentas de servicio"})
+		return
+	}
+	views := make([]gin.H, len(accounts))
+	for i, account := range accounts {
+		views[i] = serviceAccountView(account)
+	}
+	c.JSON(http.StatusOK, gin.H{"service_accounts": views})
+}
+
+func serviceAccountView(account models.User) gin.H {
+	return gin.H{
+		"id":         account.ID,
+		"name":       account.Email[:len(account.Email)-len(serviceAccountDomain)],
+		"role":       account.Role,
+		"created_at": account.CreatedAt,
+	}
+}
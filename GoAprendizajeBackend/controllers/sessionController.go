@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"errors"
+	"go-aprendizaje/authcookies"
+	"go-aprendizaje/models"
+	"go-aprendizaje/security"
+	"go-aprendizaje/sessions"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sessionResponse es una sesión tal como la ve el usuario ("current" = la del token con el que pregunta)
+type sessionResponse struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// ListSessions lista los dispositivos con la sesión abierta del usuario
+func ListSessions(c *gin.Context) {
+	store, userID, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return
+	}
+	listSessions(c, store, userID)
+}
+
+// RevokeSession cierra una sesión del usuario (por ejemplo, la de un móvil perdido). Puede ser la actual.
+func RevokeSession(c *gin.Context) {
+	store, userID, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return
+	}
+	revokeSession(c, store, userID, "self")
+}
+
+// RevokeOtherSessions cierra todas las sesiones del usuario salvo la actual
+func RevokeOtherSessions(c *gin.Context) {
+	store, userID, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return
+	}
+
+	// Con un token sin sesión (emitido antes de registrarlas) no hay "sesión actual" que conservar:
+	// se revocan todos los tokens, también ese
+	currentSession := c.GetString("sessionID")
+	if currentSession == "" {
+		if err := security.RevokeUserTokens(store, userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudieron cerrar las sesiones"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Todas las sesiones se han cerrado"})
+		return
+	}
+
+	revoked, err := sessions.RevokeOthers(store, userID, currentSession, "self")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudieron cerrar las sesiones"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Las demás sesiones se han cerrado", "revoked": revoked})
+}
+
+// Logout cierra la sesión actual (la revoca, así el token deja de valer aunque no haya caducado)
+// y, en modo cookie, borra las cookies (el JavaScript de la página no puede: son HttpOnly)
+func Logout(c *gin.Context) {
+	store, userID, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return
+	}
+	if sessionID := c.GetString("sessionID"); sessionID != "" {
+		err := sessions.Revoke(store, userID, sessionID, "self")
+		if err != nil && !errors.Is(err, sessions.ErrNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo cerrar la sesión"})
+			return
+		}
+	}
+	authcookies.Clear(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Sesión cerrada"})
+}
+
+// RefreshToken renueva el token de acceso del modo cookie con el refresh token (que se rota en cada uso).
+// Necesita el token CSRF igual que cualquier otra petición que cambia algo.
+func RefreshToken(c *gin.Context) {
+	if !authcookies.Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "El modo cookie no está activado"})
+		return
+	}
+	refreshToken, err := c.Cookie(authcookies.RefreshCookie)
+	if err != nil || refreshToken == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Falta el refresh token"})
+		return
+	}
+
+	// 1. CSRF: la sesión es la del refresh token (el token de acceso puede haber caducado ya)
+	sessionID, _, _ := strings.Cut(refreshToken, ".")
+	csrfToken, ok := authcookies.CSRFMatches(c)
+	if !ok || !security.ValidCSRFToken(csrfToken, sessionID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Prohibido: Token CSRF inválido o ausente"})
+		return
+	}
+
+	// 2. Canjear (y rotar) el refresh token
+	session, newRefreshToken, err := sessions.Refresh(refreshToken, c.ClientIP())
+	switch {
+	case errors.Is(err, sessions.ErrRevoked):
+		// La sesión se cerró o caducó (o se acaba de cerrar porque el refresh token ya se había usado): las cookies ya no sirven
+		authcookies.Clear(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, sessions.ErrInvalidRefresh):
+		// No borramos las cookies: puede que otra pestaña acabe de renovarlas y estas ya sean las nuevas
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo renovar la sesión"})
+		return
+	}
+
+	// 3. Firmar un token de acceso nuevo para la misma sesión (con el rol actual del usuario)
+	user, err := magicUserByID(session.UserStore, session.UserID)
+	if err != nil || user == nil {
+		authcookies.Clear(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no encontrado"})
+		return
+	}
+	accessExpires := time.Now().Add(authcookies.AccessTTL())
+	if accessExpires.After(session.ExpiresAt) {
+		accessExpires = session.ExpiresAt
+	}
+	tokenString, err := signToken(user.tokenID, user.role, session.ID, accessExpires)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al generar el token"})
+		return
+	}
+
+	authcookies.Set(c, tokenString, accessExpires, newRefreshToken, session.ExpiresAt, csrfToken)
+	c.JSON(http.StatusOK, gin.H{"token_transport": "cookie", "csrf_token": csrfToken, "expires_at": accessExpires})
+}
+
+// AdminListUserSessions lista las sesiones abiertas de cualquier usuario
+func AdminListUserSessions(c *gin.Context) {
+	store := c.Param("store")
+	if store != "postgres" && store != "mongo" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "store inválido (usa 'postgres' o 'mongo')"})
+		return
+	}
+	listSessions(c, store, c.Param("id"))
+}
+
+// AdminRevokeUserSession cierra una sesión de cualquier usuario
+func AdminRevokeUserSession(c *gin.Context) {
+	store := c.Param("store")
+	if store != "postgres" && store != "mongo" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "store inválido (usa 'postgres' o 'mongo')"})
+		return
+	}
+	_, adminID, _ := currentUser(c)
+	revokeSession(c, store, c.Param("id"), "admin:"+adminID)
+}
+
+// AdminRevokeUserSessions cierra todas las sesiones de un usuario (ej: cuenta comprometida)
+func AdminRevokeUserSessions(c *gin.Context) {
+	store := c.Param("store")
+	if store != "postgres" && store != "mongo" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "store inválido (usa 'postgres' o 'mongo')"})
+		return
+	}
+	if err := security.RevokeUserTokens(store, c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudieron cerrar las sesiones"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Todas las sesiones del usuario se han cerrado"})
+}
+
+func listSessions(c *gin.Context, store string, userID string) {
+	list, err := sessions.List(store, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer las sesiones"})
+		return
+	}
+
+	currentSession := c.GetString("sessionID")
+	response := make([]sessionResponse, len(list))
+	for i, session := range list {
+		response[i] = sessionResponse{Session: session, Current: session.ID == currentSession}
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": response})
+}
+
+func revokeSession(c *gin.Context, store string, userID string, revokedBy string) {
+	err := sessions.Revoke(store, userID, c.Param("sessionID"), revokedBy)
+	if errors.Is(err, sessions.ErrNotFound) {
+		// Mismo error si es de otro usuario: no revelamos qué sesiones existen
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo cerrar la sesión"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Sesión cerrada"})
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"errors"
+	"go-aprendizaje/database"
+	"go-aprendizaje/models"
+	"go-aprendizaje/suppression"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AdminListSuppressions lista las direcciones suprimidas (GET /api/admin/email-suppressions?reason=bounce&email=...)
+func AdminListSuppressions(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	query := database.DB.Model(&models.EmailSuppression{})
+	if reason := c.Query("reason"); reason != "" {
+		query = query.Where("reason = ?", reason)
+	}
+	if email := strings.ToLower(strings.TrimSpace(c.Query("email"))); email != "" {
+		query = query.Where("email LIKE ?", "%"+email+"%")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var entries []models.EmailSuppression
+	if err := query.Order("updated_at desc").Limit(limit).Offset(offset).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al leer la lista de supresión"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"suppressions": entries, "total": total})
+}
+
+// AdminAddSuppression suprime una dirección a mano (ej: el usuario pidió por otro canal que no le escribamos)
+func AdminAddSuppression(c *gin.Context) {
+	var input struct {
+		Email  string `json:"email" binding:"required,email"`
+		Detail string `json:"detail"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos: " + err.Error()})
+		return
+	}
+
+	_, adminID, _ := currentUser(c)
+	if err := suppression.Add(input.Email, models.SuppressionManual, input.Detail, "admin:"+adminID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo añadir la dirección"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Dirección añadida a la lista de supresión"})
+}
+
+// AdminRemoveSuppression vuelve a permitir los envíos a una dirección
+func AdminRemoveSuppression(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dirección no encontrada"})
+		return
+	}
+
+	entry, err := suppression.Remove(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dirección no encontrada"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo quitar la dirección"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Dirección quitada de la lista de supresión", "email": entry.Email})
+}
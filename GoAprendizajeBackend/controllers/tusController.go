@@ -0,0 +1,268 @@
+package controllers
+
+import (
+	"errors"
+	"go-aprendizaje/config"
+	"go-aprendizaje/core"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/media"
+	"go-aprendizaje/models"
+	"go-aprendizaje/tus"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Subidas reanudables con el protocolo tus 1.0 (https://tus.io/protocols/resumable-upload).
+// El cliente crea la subida (POST), envía el archivo en trozos (PATCH) y, si se corta la conexión,
+// pregunta cuánto llegó (HEAD) y sigue desde ahí. Al recibir el último byte se procesa como una subida normal.
+// En "Upload-Metadata" se indica qué es: "purpose" = "profile_picture" (por defecto) o "private_file".
+
+const tusBasePath = "/api/uploads/tus/"
+
+// TusOptions anuncia qué versión y extensiones del protocolo soportamos (no necesita token)
+func TusOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", tus.Version)
+	c.Header("Tus-Version", tus.Version)
+	c.Header("Tus-Extension", tus.Extensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(max(media.Limits.MaxBytes, media.DocumentMaxBytes()), 10))
+	c.Status(http.StatusNoContent)
+}
+
+// TusCreate crea una subida nueva (extensión "creation")
+func TusCreate(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	store, userID, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return
+	}
+
+	// 1. Tamaño total (no soportamos "Upload-Defer-Length": hay que saberlo desde el principio)
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Falta la cabecera Upload-Length o no es válida"})
+		return
+	}
+
+	// 2. Para qué es la subida (decide el tamaño máximo y qué se hace al terminar)
+	metadata := c.GetHeader("Upload-Metadata")
+	values, err := tus.ParseMetadata(metadata)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	purpose := values["purpose"]
+	if purpose == "" {
+		purpose = models.TusPurposeProfilePicture
+	}
+	var maxBytes int64
+	switch purpose {
+	case models.TusPurposeProfilePicture:
+		// La foto de perfil (de momento) solo existe para los usuarios de Postgres
+		if store != "postgres" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "La foto de perfil solo está disponible para usuarios de Postgres"})
+			return
+		}
+		maxBytes = media.Limits.MaxBytes
+	case models.TusPurposePrivateFile:
+		maxBytes = media.DocumentMaxBytes()
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'purpose' debe ser 'profile_picture' o 'private_file'"})
+		return
+	}
+	if length > maxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": media.ErrTooLarge.Error()})
+		return
+	}
+
+	// 3. Límites por usuario: subidas a medias (ocupan espacio hasta que caducan) y cuota
+	if tus.Pending(store, userID) >= int64(config.GetEnvInt("TUS_MAX_PENDING_UPLOADS", 5)) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Demasiadas subidas sin terminar"})
+		return
+	}
+	if purpose == models.TusPurposePrivateFile {
+		if err := media.CheckQuota(store, userID, length, ""); err != nil {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 4. Registrar la subida
+	upload, err := tus.Create(store, userID, purpose, length, metadata)
+	if err != nil {
+		logging.Log.Errorf("Error al crear una subida reanudable de %s/%s: %v", store, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo crear la subida"})
+		return
+	}
+
+	c.Header("Location", tusBasePath+upload.ID)
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusCreated)
+}
+
+// TusHead devuelve cuántos bytes se han recibido (para reanudar desde ahí)
+func TusHead(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	upload, ok := findOwnedUpload(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	if upload.Metadata != "" {
+		c.Header("Upload-Metadata", upload.Metadata)
+	}
+	c.Status(http.StatusOK)
+}
+
+// TusPatch recibe un trozo del archivo. Con el último trozo se procesa la subida completa.
+func TusPatch(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	if c.ContentType() != "application/offset+octet-stream" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "El Content-Type debe ser application/offset+octet-stream"})
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Falta la cabecera Upload-Offset o no es válida"})
+		return
+	}
+
+	upload, ok := findOwnedUpload(c)
+	if !ok {
+		return
+	}
+
+	// 1. Guardar el trozo
+	newOffset, err := tus.WriteChunk(c.Request.Context(), core.FileStorage, upload, offset, c.Request.Body, c.Request.ContentLength)
+	if err != nil {
+		switch {
+		case errors.Is(err, tus.ErrOffsetMismatch):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "offset": upload.Offset})
+		case errors.Is(err, tus.ErrTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		default:
+			// Normalmente el cliente cortó la conexión sin enviar nada: con HEAD sabrá desde dónde seguir
+			logging.Log.Warnf("Error al recibir un trozo de la subida %s: %v", upload.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo guardar el trozo"})
+		}
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(newOffset, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+
+	// 2. ¿Era el último trozo? Entonces se procesa como cualquier otra subida
+	if newOffset == upload.Length {
+		if uploadErr := finishTusUpload(c, upload); uploadErr != nil {
+			c.JSON(uploadErr.status, uploadErr.body)
+			return
+		}
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// TusDelete cancela una subida y borra lo recibido (extensión "termination")
+func TusDelete(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	upload, ok := findOwnedUpload(c)
+	if !ok {
+		return
+	}
+
+	if err := tus.Terminate(c.Request.Context(), core.FileStorage, upload); err != nil {
+		logging.Log.Errorf("Error al cancelar la subida %s: %v", upload.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo cancelar la subida"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// finishTusUpload pasa el archivo completo por el mismo proceso que la subida normal
+// y después borra los trozos (tanto si ha ido bien como si no: el archivo no cambiará)
+func finishTusUpload(c *gin.Context, upload *models.TusUpload) *uploadError {
+	ctx := c.Request.Context()
+	defer func() {
+		if err := tus.Terminate(ctx, core.FileStorage, upload); err != nil {
+			logging.Log.Errorf("Error al borrar los trozos de la subida %s: %v", upload.ID, err)
+		}
+	}()
+
+	file, err := tus.Open(ctx, core.FileStorage, upload)
+	if err != nil {
+		logging.Log.Errorf("Error al leer la subida completa %s: %v", upload.ID, err)
+		return &uploadError{http.StatusInternalServerError, gin.H{"error": "No se pudo leer el archivo subido"}}
+	}
+	defer file.Close()
+
+	switch upload.Purpose {
+	case models.TusPurposeProfilePicture:
+		userID, err := strconv.ParseUint(upload.UserID, 10, 64)
+		if err != nil {
+			return &uploadError{http.StatusNotFound, gin.H{"error": "Usuario no encontrado"}}
+		}
+		key, uploadErr := saveProfilePicture(ctx, uint(userID), file)
+		if uploadErr != nil {
+			return uploadErr
+		}
+		logging.Log.Infof("Subida reanudable %s terminada: foto de perfil %s", upload.ID, key)
+	case models.TusPurposePrivateFile:
+		record, uploadErr := savePrivateFile(ctx, upload.UserStore, upload.UserID, upload.FileName, file)
+		if uploadErr != nil {
+			return uploadErr
+		}
+		logging.Log.Infof("Subida reanudable %s terminada: archivo privado %d", upload.ID, record.ID)
+	}
+	return nil
+}
+
+// checkTusResumable comprueba la versión del protocolo que usa el cliente (obligatoria salvo en OPTIONS)
+func checkTusResumable(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tus.Version)
+	if c.GetHeader("Tus-Resumable") != tus.Version {
+		c.Header("Tus-Version", tus.Version)
+		c.AbortWithStatusJSON(http.StatusPreconditionFailed, gin.H{"error": "Versión del protocolo tus no soportada"})
+		return false
+	}
+	return true
+}
+
+// findOwnedUpload busca la subida ':id' y comprueba que sea del usuario del token
+func findOwnedUpload(c *gin.Context) (*models.TusUpload, bool) {
+	store, userID, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return nil, false
+	}
+
+	upload, err := tus.Get(c.Param("id"))
+	switch {
+	case errors.Is(err, tus.ErrNotFound), upload != nil && (upload.UserStore != store || upload.UserID != userID):
+		// Mismo error si es de otro usuario: no revelamos qué subidas existen
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subida no encontrada"})
+		return nil, false
+	case errors.Is(err, tus.ErrExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		return nil, false
+	case err != nil:
+		logging.Log.Errorf("Error al buscar la subida %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo leer la subida"})
+		return nil, false
+	}
+	return upload, true
+}
@@ -1,26 +1,54 @@
 package controllers
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"go-aprendizaje/authcookies"
 	"go-aprendizaje/config"
+	"go-aprendizaje/core"
 	"go-aprendizaje/database"
+	"go-aprendizaje/emails"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/loginhistory"
+	"go-aprendizaje/media"
 	"go-aprendizaje/models"
+	"go-aprendizaje/outbox"
+	"go-aprendizaje/security"
+	"go-aprendizaje/sessions"
+	"go-aprendizaje/storage"
 	"go-aprendizaje/utils"
+	"io"
 	"net/http"
-	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
+// RegisterAcceptedMessage es la respuesta genérica del registro (Postgres y Mongo),
+// igual tanto si el email ya estaba registrado como si no
+const RegisterAcceptedMessage = "Registro recibido. Revisa tu correo para continuar"
+
+// requestLocale elige el idioma de los emails de un usuario nuevo: el que pide en el registro ("locale")
+// si lo soportamos, si no el preferido del navegador (Accept-Language). Vacío = el idioma por defecto.
+func requestLocale(c *gin.Context, requested string) string {
+	if locale := emails.Match(requested); locale != "" {
+		return locale
+	}
+	return emails.FromAcceptLanguage(c.GetHeader("Accept-Language"))
+}
+
 func RegisterUser(c *gin.Context) {
 
 	// Estructura para enlazar los datos de entrada (el cuerpo que esperamos del JSON)
 	var input struct {
 		Email    string `json:"email" binding:"required,email"`
-		Password string `json:"password" binding:"required,min=6"`
+		Password string `json:"password" binding:"required"`
+		Locale   string `json:"locale"` // Opcional: idioma de los emails ("es", "en")
 	}
 
 	// Bindear (enlazar) el JSON de entrada a la estructura, mapea los datos del JSON a la estructura Go
@@ -30,48 +58,56 @@ func RegisterUser(c *gin.Context) {
 		return
 	}
 
-	// Verificar si el usuario ya existe
-	var existingUser models.User
-	result2 := database.DB.Where("email = ?", input.Email).First(&existingUser)
-	if result2.Error == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "El usuario ya existe"})
+	// Comprobar la política de contraseñas (longitud, tipos de caracteres, filtradas...)
+	if !validatePassword(c, input.Password, input.Email) {
 		return
 	}
 
-	// Hashear la contraseña usando bcrypt
-	// GenerateFromPassword toma la contraseña en bytes y un "costo" y devuelve la contraseña hasheada o un error.
-	// El costo (DefaultCost) define qué tan lenta/segura será la encriptación.
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	// Hashear la contraseña con el algoritmo configurado (argon2id por defecto)
+	// Devuelve un string en formato PHC que incluye el algoritmo, los costos y el salt.
+	// (Se hashea ANTES de comprobar si el usuario existe para que ambos casos tarden lo mismo)
+	hashedPassword, err := security.Passwords.Hash(input.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al hashear la contraseña"})
 		return
 	}
 
+	// Verificar si el usuario ya existe
+	// Si existe NO lo decimos en la respuesta (permitiría enumerar cuentas):
+	// avisamos al dueño por email y respondemos lo mismo que en un registro normal
+	var existingUser models.User
+	result2 := database.DB.Where("email = ?", input.Email).First(&existingUser)
+	if result2.Error == nil {
+		outbox.Send(utils.AccountExistsEmail(existingUser.Locale, existingUser.Email))
+		c.JSON(http.StatusAccepted, gin.H{"message": RegisterAcceptedMessage})
+		return
+	}
+
 	// Crear un nuevo usuario con el email y la contraseña hasheada
 	user := models.User{
 		Email:    input.Email,
-		Password: string(hashedPassword),
+		Password: hashedPassword,
+		Locale:   requestLocale(c, input.Locale),
 	}
 
-	// Guardar el usuario en la base de datos
-	result := database.DB.Create(&user)
+	// Guardar el usuario y su correo de bienvenida en la misma transacción:
+	// o se guardan los dos o ninguno (el correo lo envía después la bandeja de salida, con reintentos)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return outbox.Enqueue(tx, utils.WelcomeEmail(user.Locale, user.Email))
+	})
 
 	// Si hay un error al guardar el usuario, devolver un error
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al crear el usuario: " + result.Error.Error()})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al crear el usuario"})
 		return
 	}
 
-	// Enviar correo de bienvenida (de forma asíncrona para no bloquear la respuesta)
-	go utils.SendWelcomeEmail(user.Email)
-
 	// 7. Responder con éxito
-	// Es una buena práctica no devolver la contraseña (ni el hash)
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Usuario registrado exitosamente",
-		"userID":  user.ID,
-		"email":   user.Email,
-	})
+	// La respuesta es idéntica exista o no el usuario (sin ID ni email), así no se filtra información
+	c.JSON(http.StatusAccepted, gin.H{"message": RegisterAcceptedMessage})
 
 }
 
@@ -90,73 +126,159 @@ func Login(c *gin.Context) {
 	var user models.User
 
 	// SELECT * FROM users WHERE email = input.Email LIMIT 1;
-	result := database.DB.Where("email = ?", input.Email).First(&user)
+	// (las cuentas de servicio no tienen contraseña: nunca inician sesión)
+	result := database.DB.Where("email = ? AND service_account = ?", input.Email, false).First(&user)
 	if result.Error != nil {
+		// Comparamos contra un hash falso para tardar lo mismo que con un usuario real
+		security.Passwords.CompareDummy(input.Password)
+		recordLogin(c, loginhistory.Attempt{Store: "postgres", Email: input.Email, Method: models.SessionMethodPassword, Failure: models.LoginFailureUnknownUser}, "")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Credenciales inválidas"})
 		return
 	}
 
 	// Comparar la contraseña hasheada con la contraseña proporcionada
-	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password))
-	if err != nil {
+	// (VerifyLogin tarda al menos lo mismo que CompareDummy, aunque el hash sea antiguo y más barato)
+	attempt := loginhistory.Attempt{Store: "postgres", UserID: strconv.FormatUint(uint64(user.ID), 10), Email: user.Email, Method: models.SessionMethodPassword}
+	ok, needsRehash, err := security.Passwords.VerifyLogin(input.Password, user.Password)
+	if err != nil || !ok {
+		attempt.Failure = models.LoginFailureWrongPassword
+		recordLogin(c, attempt, user.Locale)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Credenciales inválidas"})
 		return
 	}
 
+	// Una cuenta con el borrado programado por un administrador no puede iniciar sesión
+	if deletionBlocksLogin(c, attempt, user.Locale, user.DeletionScheduledAt, user.DeletionRequestedBy) {
+		return
+	}
+
+	// Si el hash usa un algoritmo o costos antiguos, aprovechamos que tenemos la contraseña
+	// en claro para guardarlo con los parámetros actuales (si falla, el login sigue igual)
+	if needsRehash {
+		if newHash, err := security.Passwords.Hash(input.Password); err == nil {
+			database.DB.Model(&user).Update("password", newHash)
+		}
+	}
+
 	// Generar un token JWT
+	response, err := issueToken(c, user.ID, user.Role, models.SessionMethodPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al generar el token"})
+		return
+	}
+	recordLogin(c, attempt, user.Locale)
+
+	// Devolver el token al cliente (o, en modo cookie, el token CSRF)
+	c.JSON(http.StatusOK, withPendingDeletion(response, user.DeletionScheduledAt))
+}
+
+// issueToken genera el token JWT de un usuario (el mismo para todas las formas de iniciar sesión),
+// registra la sesión con el dispositivo y la IP de la petición y devuelve el cuerpo de la respuesta:
+// {"token"} o, si el cliente pide el modo cookie (ver authcookies), los tokens van en cookies
+// y el cuerpo solo lleva el token CSRF.
+// 'userID' es el ID numérico en Postgres o el ID hexadecimal (string) en Mongo.
+func issueToken(c *gin.Context, userID interface{}, role string, method string) (gin.H, error) {
+	// En modo cookie el token de acceso dura poco y la sesión lo que dure el refresh token
+	cookieMode := authcookies.Requested(c)
+	accessExpires := time.Now().Add(time.Hour * 24)
+	sessionExpires := accessExpires
+	if cookieMode {
+		accessExpires = time.Now().Add(authcookies.AccessTTL())
+		sessionExpires = time.Now().Add(authcookies.RefreshTTL())
+	}
+
+	// Registrar la sesión (su ID va en el token: cerrarla invalida el token)
+	store, id, _ := security.UserRef(userID)
+	session, err := sessions.Create(store, id, method, c.Request.UserAgent(), c.ClientIP(), sessionExpires)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenString, err := signToken(userID, role, session.ID, accessExpires)
+	if err != nil {
+		return nil, err
+	}
+	if !cookieMode {
+		return gin.H{"token": tokenString}, nil
+	}
+
+	refreshToken, err := sessions.NewRefreshToken(session)
+	if err != nil {
+		return nil, err
+	}
+	csrfToken, err := security.NewCSRFToken(session.ID)
+	if err != nil {
+		return nil, err
+	}
+	authcookies.Set(c, tokenString, accessExpires, refreshToken, session.ExpiresAt, csrfToken)
+	return gin.H{"token_transport": "cookie", "csrf_token": csrfToken, "expires_at": accessExpires}, nil
+}
 
+// signToken firma el token JWT de la sesión 'sessionID'
+func signToken(userID interface{}, role string, sessionID string, expiresAt time.Time) (string, error) {
 	jwtSecret := config.GetEnv("JWT_SECRET_KEY", "fallback_secret")
 
 	// Crear los claims del token
 	claims := jwt.MapClaims{
-		"userID": user.ID,
-		"role":   user.Role,
+		"userID": userID,
+		"role":   role,
+		"sid":    sessionID,
 		// "exp" (Expiration Time): Es OBLIGATORIO.
 		// Define cuándo expira el token. (Ej. en 24 horas)
-		"exp": time.Now().Add(time.Hour * 24).Unix(),
+		"exp": expiresAt.Unix(),
 		// "iat" (Issued At): Cuándo se emitió
 		"iat": time.Now().Unix(),
 	}
 
-	// Crear el token con los claims
+	// Crear el token con los claims y firmarlo con la clave secreta
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Firmar el token con la clave secreta
-	tokenString, err := token.SignedString([]byte(jwtSecret))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al generar el token"})
-		return
-	}
-
-	// Devolver el token al cliente
-	c.JSON(http.StatusOK, gin.H{"token": tokenString})
+	return token.SignedString([]byte(jwtSecret))
 }
 
 func GetProfile(c *gin.Context) {
 	// 1. Obtener los datos del usuario que el MIDDLEWARE puso en el contexto
-	// Hacemos "type assertion" para convertir el tipo 'any' a lo que sabemos que es
-	userID_any, _ := c.Get("userID")
-	userID := userID_any.(float64) // JWT guarda números como float64
+	// (el ID es un número en Postgres y un ObjectID en Mongo, también si llega con una API key)
+	store, userID, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return
+	}
 
 	role_any, _ := c.Get("role")
 	role := role_any.(string)
 
-	// 2. Buscar al usuario en la BD (opcional, pero buena práctica)
+	// 2. Buscar al usuario en su BD (opcional, pero buena práctica)
 	// (En este punto ya sabemos que es válido, pero quizás queremos datos frescos)
-	var user models.User
-	if err := database.DB.First(&user, uint(userID)); err.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
-		return
+	var id any
+	var email, profileImagePath string
+	if store == "mongo" {
+		user, err := core.MongoUserRepo.GetUserByID(userID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+			return
+		}
+		id, email, profileImagePath = user.ID.Hex(), user.Email, user.ProfileImagePath
+	} else {
+		var user models.User
+		if err := database.DB.First(&user, "id = ?", userID); err.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+			return
+		}
+		id, email, profileImagePath = user.ID, user.Email, user.ProfileImagePath
 	}
 
 	// 3. Devolver los datos (sin la contraseña)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Perfil obtenido exitosamente",
 		"user": gin.H{
-			"id":                 user.ID,
-			"email":              user.Email,
+			"id":                 id,
+			"email":              email,
 			"role":               role, // Podríamos usar 'user.Role' o el 'role' del token
-			"profile_image_path": user.ProfileImagePath,
+			"profile_image_path": storage.URL(profileImagePath),
+			// Miniaturas por tamaño (ej: "128"), para no descargar la imagen completa en los avatares
+			// (las WebP solo existen si la original es PNG)
+			"profile_image_variants":      media.VariantURLs(profileImagePath),
+			"profile_image_webp_variants": media.WebPVariantURLs(profileImagePath),
 		},
 	})
 }
@@ -174,7 +296,12 @@ func GetAllUsers(c *gin.Context) {
 		return
 	}
 
-	// 2. Devolver la lista de usuarios
+	// 2. Convertir las claves de las fotos en URLs
+	for i := range users {
+		users[i].ProfileImagePath = storage.URL(users[i].ProfileImagePath)
+	}
+
+	// 3. Devolver la lista de usuarios
 	c.JSON(http.StatusOK, gin.H{
 		"users": users,
 	})
@@ -183,51 +310,138 @@ func GetAllUsers(c *gin.Context) {
 // PgUploadProfilePicture maneja la subida de imágenes de perfil para usuarios en Postgres
 func PgUploadProfilePicture(c *gin.Context) {
 	// 1. Obtener el ID de usuario del token
-	userID_any, _ := c.Get("userID")
-	userID := uint(userID_any.(float64))
+	// (la foto de perfil, de momento, solo existe para los usuarios de Postgres)
+	store, userID_str, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return
+	}
+	if store != "postgres" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "La foto de perfil solo está disponible para usuarios de Postgres"})
+		return
+	}
+	userID_u64, err := strconv.ParseUint(userID_str, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no identificado"})
+		return
+	}
+	userID := uint(userID_u64)
+
+	// 2. Limitar el tamaño del cuerpo ANTES de leer el formulario
+	// (si no, Gin guardaría en disco un archivo de cualquier tamaño). El margen es para el resto del multipart.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, media.Limits.MaxBytes+64*1024)
 
-	// 2. Obtener el archivo del formulario
+	// 3. Obtener el archivo del formulario
 	file, err := c.FormFile("profile_picture")
 	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": media.ErrTooLarge.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No se proporcionó ningún archivo"})
 		return
 	}
 
-	// 3. Generar un nombre de archivo único
-	extension := filepath.Ext(file.Filename) // Obtener la extensión del archivo
-	uniqueFilename := uuid.New().String() + extension
-
-	// Obtener la ruta de guardado desde la configuración o usar el valor por defecto
-	filePathEnv := config.GetEnv("FILE_PATH", "./uploads")
-
-	// 4. Definir la ruta de destino
-	destinationPath := filepath.Join(filePathEnv, uniqueFilename) // Ruta completa donde se guardará el archivo (./uploads/nombre-archivo.ext)
+	// 4. Validar, guardar y asignar la imagen
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No se pudo leer el archivo"})
+		return
+	}
+	defer src.Close()
 
-	// 5. Guardar el archivo en ./uploads/
-	if err := c.SaveUploadedFile(file, destinationPath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo guardar el archivo"})
+	key, uploadErr := saveProfilePicture(c.Request.Context(), userID, src)
+	if uploadErr != nil {
+		c.JSON(uploadErr.status, uploadErr.body)
 		return
 	}
 
-	// 6. Generar la ruta de URL pública
-	publicPath := filepath.ToSlash(filepath.Join("/static", uniqueFilename))
+	// 5. Devolver la respuesta
+	c.JSON(http.StatusOK, gin.H{
+		"message":            "Archivo subido exitosamente",
+		"file_path":          storage.URL(key),
+		"file_variants":      media.VariantURLs(key),
+		"file_webp_variants": media.WebPVariantURLs(key),
+	})
+}
 
-	// 7. Actualizar la base de datos (usando 'database.DB' global)
+// saveProfilePicture valida la imagen, la guarda con sus miniaturas y la asigna al usuario (borrando la anterior).
+// La usan la subida normal (multipart) y la reanudable (tus) al terminar. Devuelve la clave guardada.
+func saveProfilePicture(ctx context.Context, userID uint, src io.Reader) (string, *uploadError) {
+	// 1. Comprobar que el usuario existe antes de procesar nada
 	var user models.User
 	if err := database.DB.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
-		return
+		return "", &uploadError{http.StatusNotFound, gin.H{"error": "Usuario no encontrado"}}
 	}
 
-	// Actualizamos el campo usando la BD global
-	if err := database.DB.Model(&user).Update("profile_image_path", publicPath).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo actualizar la ruta del archivo"})
-		return
+	// 2. Validar la imagen por su contenido y volver a codificarla (quita EXIF/GPS y contenido oculto)
+	img, err := media.ProcessImage(src)
+	if err != nil {
+		switch {
+		case errors.Is(err, media.ErrTooLarge):
+			return "", &uploadError{http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()}}
+		case errors.Is(err, media.ErrUnsupportedType):
+			return "", &uploadError{http.StatusUnsupportedMediaType, gin.H{"error": err.Error(), "allowed_types": media.Limits.AllowedTypes}}
+		case errors.Is(err, media.ErrInvalidImage), errors.Is(err, media.ErrTooManyPixels):
+			return "", &uploadError{http.StatusBadRequest, gin.H{"error": err.Error()}}
+		default:
+			logging.Log.Errorf("Error al procesar la foto de perfil del usuario %d: %v", userID, err)
+			return "", &uploadError{http.StatusInternalServerError, gin.H{"error": "No se pudo procesar la imagen"}}
+		}
 	}
 
-	// 8. Devolver la respuesta
-	c.JSON(http.StatusOK, gin.H{
-		"message":   "Archivo subido exitosamente",
-		"file_path": publicPath,
+	// 3. Generar una clave única para el archivo (ej: profile-pictures/<uuid>.png)
+	// La extensión sale del formato real de la imagen, nunca del nombre que envía el cliente
+	key := storage.ProfilePicturesPrefix + uuid.New().String() + img.Extension
+
+	// 4. Comprobar la cuota del usuario (la foto anterior no cuenta: se borrará al sustituirla)
+	ownerID := strconv.FormatUint(uint64(user.ID), 10)
+	previousKey := user.ProfileImagePath
+	if err := media.CheckQuota("postgres", ownerID, int64(len(img.Data)), previousKey); err != nil {
+		return "", &uploadError{http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()}}
+	}
+
+	// 5. Guardar el archivo en el backend de almacenamiento (disco local o S3)
+	if err := core.FileStorage.Put(ctx, key, bytes.NewReader(img.Data), int64(len(img.Data)), img.ContentType); err != nil {
+		logging.Log.Errorf("Error al guardar la foto de perfil %s: %v", key, err)
+		return "", &uploadError{http.StatusInternalServerError, gin.H{"error": "No se pudo guardar el archivo"}}
+	}
+
+	// 6. Generar las miniaturas (avatares pequeños) a partir de la imagen ya decodificada
+	variantsSize, err := media.GenerateVariants(ctx, core.FileStorage, key, img.Decoded)
+	if err != nil {
+		// No es grave: si falta alguna, se genera la primera vez que se pida
+		logging.Log.Errorf("Error al generar las miniaturas de %s: %v", key, err)
+	}
+
+	// 7. Actualizar la base de datos (usando 'database.DB' global)
+	// Guardamos la clave (no la URL): así la URL se puede generar siempre igual aunque cambie el backend
+	if err := database.DB.Model(&user).Update("profile_image_path", key).Error; err != nil {
+		// La nueva no llegó a usarse: la borramos para no dejarla huérfana
+		media.DeleteWithVariants(ctx, core.FileStorage, key)
+		return "", &uploadError{http.StatusInternalServerError, gin.H{"error": "No se pudo actualizar la ruta del archivo"}}
+	}
+	err = media.RecordFile(&models.StoredFile{
+		Key:         key,
+		UserStore:   "postgres",
+		UserID:      ownerID,
+		Kind:        models.StoredFileProfilePicture,
+		Size:        int64(len(img.Data)) + variantsSize,
+		ContentType: img.ContentType,
 	})
+	if err != nil {
+		logging.Log.Errorf("Error al registrar el archivo %s: %v", key, err)
+	}
+
+	// 8. Borrar la foto anterior (si falla, el recolector de basura la borrará más tarde)
+	if previousKey != "" {
+		if err := media.DeleteWithVariants(ctx, core.FileStorage, previousKey); err != nil {
+			logging.Log.Errorf("Error al borrar la foto de perfil anterior %s: %v", previousKey, err)
+		} else {
+			media.ForgetFile(previousKey)
+		}
+	}
+
+	return key, nil
 }
@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"go-aprendizaje/database"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/security"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testPassword cumple la política por defecto
+const testPassword = "Segura-Clave-2024"
+
+// setupAuthTest prepara lo que usan los handlers de registro e inicio de sesión: el hasher (bcrypt con
+// un costo bajo para que las pruebas vayan rápido), la política de contraseñas y una BD Postgres simulada.
+// Las consultas que no se esperan fallan: los handlers solo registran esos errores (historial, emails).
+func setupAuthTest(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	logging.Log = logrus.New()
+	logging.Log.SetOutput(io.Discard)
+
+	t.Setenv("PASSWORD_HASH_ALGORITHM", "bcrypt")
+	t.Setenv("BCRYPT_COST", "10")
+	t.Setenv("PASSWORD_PEPPER", "")
+	t.Setenv("BREACHED_PASSWORDS_FILE", "")
+	t.Setenv("LOGIN_ALERTS", "false")
+	security.InitPasswordHasher()
+	security.InitPasswordPolicy()
+
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	mock.MatchExpectationsInOrder(false)
+
+	database.DB, err = gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mock
+}
+
+// expectOutboxEmail espera que se guarde un email en la bandeja de salida (fuera de otra transacción)
+func expectOutboxEmail(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "outbox_emails"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+}
+
+// postJSON llama a 'handler' con un POST y el cuerpo JSON 'body'
+func postJSON(handler gin.HandlerFunc, body string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.POST("/", handler)
+
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// assertSameResponse comprueba que dos respuestas no se distinguen (mismo código y mismo cuerpo)
+func assertSameResponse(t *testing.T, first *httptest.ResponseRecorder, second *httptest.ResponseRecorder, expectedStatus int) {
+	t.Helper()
+	if first.Code != expectedStatus || second.Code != expectedStatus {
+		t.Fatalf("se esperaba %d en los dos casos, obtenidos %d y %d", expectedStatus, first.Code, second.Code)
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Fatalf("las respuestas se distinguen:\n%s\n%s", first.Body.String(), second.Body.String())
+	}
+}
+
+// assertComparableDuration comprueba que 'unknown' (usuario inexistente) gasta un tiempo del mismo orden que
+// 'known' (contraseña incorrecta): sin el hash falso respondería cientos de veces más rápido
+func assertComparableDuration(t *testing.T, unknown func(), known func()) {
+	t.Helper()
+	unknown() // La primera vez también se genera el hash falso
+	fastest := func(run func()) time.Duration {
+		best := time.Duration(1<<63 - 1)
+		for i := 0; i < 3; i++ {
+			start := time.Now()
+			run()
+			best = min(best, time.Since(start))
+		}
+		return best
+	}
+
+	unknownDuration, knownDuration := fastest(unknown), fastest(known)
+	if unknownDuration < knownDuration/3 {
+		t.Fatalf("el usuario inexistente responde demasiado rápido (%v frente a %v): no se comparó el hash falso", unknownDuration, knownDuration)
+	}
+}
+
+func TestRegisterUserSameResponseForExistingEmail(t *testing.T) {
+	mock := setupAuthTest(t)
+	body := `{"email":"ana@example.com","password":"` + testPassword + `"}`
+
+	// 1. El email ya existe: se avisa al dueño por email
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE email = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "locale"}).AddRow(1, "ana@example.com", "es"))
+	expectOutboxEmail(mock)
+	existing := postJSON(RegisterUser, body)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 2. El email es nuevo: se crea el usuario y su email de bienvenida en la misma transacción
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE email = \$1`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "users"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery(`INSERT INTO "outbox_emails"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectCommit()
+	created := postJSON(RegisterUser, body)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertSameResponse(t, existing, created, http.StatusAccepted)
+	if !strings.Contains(created.Body.String(), RegisterAcceptedMessage) {
+		t.Fatalf("respuesta inesperada: %s", created.Body.String())
+	}
+}
+
+func TestLoginSameResponseForUnknownUserAndWrongPassword(t *testing.T) {
+	mock := setupAuthTest(t)
+	hash, err := security.Passwords.Hash(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unknownUser := func() *httptest.ResponseRecorder {
+		mock.ExpectQuery(`SELECT \* FROM "users" WHERE \(email = \$1 AND service_account = \$2\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		return postJSON(Login, `{"email":"nadie@example.com","password":"`+testPassword+`"}`)
+	}
+	wrongPassword := func() *httptest.ResponseRecorder {
+		mock.ExpectQuery(`SELECT \* FROM "users" WHERE \(email = \$1 AND service_account = \$2\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "email", "password"}).AddRow(1, "ana@example.com", hash))
+		return postJSON(Login, `{"email":"ana@example.com","password":"Otra-Clave-2024"}`)
+	}
+
+	assertSameResponse(t, unknownUser(), wrongPassword(), http.StatusUnauthorized)
+	assertComparableDuration(t, func() { unknownUser() }, func() { wrongPassword() })
+}
+
+// Con el borrado programado por un administrador la cuenta queda bloqueada aunque la contraseña sea correcta
+func TestLoginBlockedByAdminScheduledDeletion(t *testing.T) {
+	mock := setupAuthTest(t)
+	hash, err := security.Passwords.Hash(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE \(email = \$1 AND service_account = \$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "password", "deletion_scheduled_at", "deletion_requested_by"}).
+			AddRow(1, "ana@example.com", hash, time.Now().Add(time.Hour), "admin:7"))
+	response := postJSON(Login, `{"email":"ana@example.com","password":"`+testPassword+`"}`)
+
+	if response.Code != http.StatusForbidden {
+		t.Fatalf("se esperaba 403, obtenido %d: %s", response.Code, response.Body.String())
+	}
+	if strings.Contains(response.Body.String(), "token") {
+		t.Fatalf("no debería emitirse ningún token: %s", response.Body.String())
+	}
+}
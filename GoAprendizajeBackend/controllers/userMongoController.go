@@ -1,17 +1,18 @@
 package controllers
 
 import (
-	"go-aprendizaje/config"
 	"go-aprendizaje/core"
+	"go-aprendizaje/database"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/loginhistory"
 	"go-aprendizaje/models"
+	"go-aprendizaje/outbox"
+	"go-aprendizaje/security"
 	"go-aprendizaje/utils"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/mongo"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // MongoRegister maneja el registro de un nuevo usuario en MongoDB
@@ -19,7 +20,8 @@ func MongoRegister(c *gin.Context) {
 	// 1. Definir el "Input" (lo que esperamos del JSON)
 	var input struct {
 		Email    string `json:"email" binding:"required,email"`
-		Password string `json:"password" binding:"required,min=6"`
+		Password string `json:"password" binding:"required"`
+		Locale   string `json:"locale"` // Opcional: idioma de los emails ("es", "en")
 	}
 
 	// 2. Bindear el JSON del body a nuestro struct
@@ -28,45 +30,65 @@ func MongoRegister(c *gin.Context) {
 		return
 	}
 
-	// Comprobamos si el usuario ya existe
-	existingUser, err := core.MongoUserRepo.GetUserByEmail(input.Email)
-	if err == nil && existingUser != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "El usuario ya existe"})
+	// Comprobar la política de contraseñas (longitud, tipos de caracteres, filtradas...)
+	if !validatePassword(c, input.Password, input.Email) {
 		return
 	}
 
-	// 3. Hashear la contraseña usando Bcrypt
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	// 3. Hashear la contraseña con el algoritmo configurado
+	// (Se hashea ANTES de comprobar si el usuario existe para que ambos casos tarden lo mismo)
+	hashedPassword, err := security.Passwords.Hash(input.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo hashear la contraseña"})
 		return
 	}
 
+	// Comprobamos si el usuario ya existe
+	// Si existe avisamos al dueño por email y respondemos lo mismo que en un registro normal
+	existingUser, err := core.MongoUserRepo.GetUserByEmail(input.Email)
+	if err == nil && existingUser != nil {
+		outbox.Send(utils.AccountExistsEmail(existingUser.Locale, existingUser.Email))
+		c.JSON(http.StatusAccepted, gin.H{"message": RegisterAcceptedMessage})
+		return
+	}
+
 	// 4. Crear la instancia del modelo MongoUser
 	user := models.MongoUser{
 		Email:    input.Email,
-		Password: string(hashedPassword),
+		Password: hashedPassword,
 		Role:     "user", // Mongo no tiene 'default' en el struct, lo definimos aquí
+		Locale:   requestLocale(c, input.Locale),
+		// La bienvenida queda "pendiente" en el mismo insert: así no se pierde aunque falle el paso 6
+		WelcomePending: true,
 		// CreatedAt y UpdatedAt se definen en el repositorio
 	}
 
 	// 5. Guardar el usuario en la BD usando el REPOSITORIO GLOBAL
-	newID, err := core.MongoUserRepo.CreateUser(&user)
+	userID, err := core.MongoUserRepo.CreateUser(&user)
 	if err != nil {
-		// (Esto podría ser un error de email duplicado)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No se pudo crear el usuario. ¿Email ya existe? (Mongo)"})
+		// Email duplicado (otra petición lo registró a la vez): mismo trato que un usuario existente
+		if mongo.IsDuplicateKeyError(err) {
+			outbox.Send(utils.AccountExistsEmail(user.Locale, user.Email))
+			c.JSON(http.StatusAccepted, gin.H{"message": RegisterAcceptedMessage})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo crear el usuario (Mongo)"})
 		return
 	}
 
-	// 6. Enviar email de bienvenida en segundo plano
-	go utils.SendWelcomeEmail(user.Email)
+	// 6. Guardar el email de bienvenida en la bandeja de salida y quitar la marca de pendiente.
+	// La bandeja está en Postgres: no puede ir en la misma transacción que el insert en Mongo.
+	// Si la API se cae antes de quitar la marca (o falla Postgres), outbox.RelayMongoWelcomes lo encola después
+	// (en el peor caso, caerse justo entre los dos pasos, la bienvenida llega dos veces: nunca se pierde).
+	if err := outbox.Enqueue(database.DB, utils.WelcomeEmail(user.Locale, user.Email)); err != nil {
+		logging.Log.Errorf("No se pudo encolar la bienvenida de %s (se reintentará): %v", userID.Hex(), err)
+	} else if err := core.MongoUserRepo.ClearWelcomePending(userID); err != nil {
+		logging.Log.Errorf("No se pudo quitar la bienvenida pendiente de %s: %v", userID.Hex(), err)
+	}
 
 	// 7. Responder con éxito
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Usuario registrado exitosamente (Mongo)",
-		"userID":  newID.Hex(), // Devolvemos el ID de Mongo como string
-		"email":   user.Email,
-	})
+	// (Misma respuesta que si el usuario ya existiera: sin ID ni email)
+	c.JSON(http.StatusAccepted, gin.H{"message": RegisterAcceptedMessage})
 }
 
 // MongoLogin maneja el inicio de sesión de un usuario desde MongoDB
@@ -88,6 +110,9 @@ func MongoLogin(c *gin.Context) {
 	if err != nil {
 		// Chequeo específico de Mongo para "no encontrado"
 		if err == mongo.ErrNoDocuments {
+			// Comparamos contra un hash falso para tardar lo mismo que con un usuario real
+			security.Passwords.CompareDummy(input.Password)
+			recordLogin(c, loginhistory.Attempt{Store: "mongo", Email: input.Email, Method: models.SessionMethodPassword, Failure: models.LoginFailureUnknownUser}, "")
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Email o contraseña incorrectos (Mongo)"})
 			return
 		}
@@ -97,35 +122,38 @@ func MongoLogin(c *gin.Context) {
 	}
 
 	// 4. Comparar la contraseña del input con el hash guardado
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password))
-	if err != nil {
+	attempt := loginhistory.Attempt{Store: "mongo", UserID: user.ID.Hex(), Email: user.Email, Method: models.SessionMethodPassword}
+	ok, needsRehash, err := security.Passwords.VerifyLogin(input.Password, user.Password)
+	if err != nil || !ok {
 		// ¡Las contraseñas NO coinciden!
+		attempt.Failure = models.LoginFailureWrongPassword
+		recordLogin(c, attempt, user.Locale)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Email o contraseña incorrectos (Mongo)"})
 		return
 	}
 
-	// 5. ¡Autenticación exitosa! Generar el Token JWT
-	jwtSecret := config.GetEnv("JWT_SECRET_KEY", "fallback_secret")
-
-	// 6. Crear los "Claims" (el payload del token)
-	claims := jwt.MapClaims{
-		"userID": user.ID.Hex(), // Importante: Usamos el ID de Mongo como string
-		"role":   user.Role,
-		"exp":    time.Now().Add(time.Hour * 24).Unix(),
-		"iat":    time.Now().Unix(),
+	// Una cuenta con el borrado programado por un administrador no puede iniciar sesión
+	if deletionBlocksLogin(c, attempt, user.Locale, user.DeletionScheduledAt, user.DeletionRequestedBy) {
+		return
 	}
 
-	// 7. Firmar el token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(jwtSecret))
+	// Si el hash está desactualizado lo regeneramos con los parámetros actuales
+	if needsRehash {
+		if newHash, err := security.Passwords.Hash(input.Password); err == nil {
+			core.MongoUserRepo.UpdatePasswordHash(user.ID, newHash)
+		}
+	}
+
+	// 5. ¡Autenticación exitosa! Generar el Token JWT
+	// (Importante: usamos el ID de Mongo como string)
+	response, err := issueToken(c, user.ID.Hex(), user.Role, models.SessionMethodPassword)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo generar el token"})
 		return
 	}
+	recordLogin(c, attempt, user.Locale)
 
-	// 8. Devolver el token al cliente
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Login exitoso (Mongo)",
-		"token":   tokenString,
-	})
+	// 6. Devolver el token al cliente (o, en modo cookie, el token CSRF)
+	response["message"] = "Login exitoso (Mongo)"
+	c.JSON(http.StatusOK, withPendingDeletion(response, user.DeletionScheduledAt))
 }
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"go-aprendizaje/core"
+	"go-aprendizaje/repositories"
+	"go-aprendizaje/security"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// usersNamespace es la colección de usuarios del cliente de Mongo simulado
+const usersNamespace = "test.users"
+
+func TestMongoRegisterSameResponseForExistingEmail(t *testing.T) {
+	mock := setupAuthTest(t)
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	body := `{"email":"ana@example.com","password":"` + testPassword + `"}`
+
+	mt.Run("registro", func(mt *mtest.T) {
+		core.MongoUserRepo = repositories.NewMongoUserRepositoryWithCollection(mt.Coll)
+
+		// 1. El email ya existe: se avisa al dueño por email
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, usersNamespace, mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "email", Value: "ana@example.com"}}))
+		expectOutboxEmail(mock)
+		existing := postJSON(MongoRegister, body)
+
+		// 2. Otra petición registró el email a la vez (índice único): mismo trato
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, usersNamespace, mtest.FirstBatch),
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key"}),
+		)
+		expectOutboxEmail(mock)
+		duplicate := postJSON(MongoRegister, body)
+
+		// 3. El email es nuevo: se crea el usuario, se envía la bienvenida y se quita la marca de pendiente
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, usersNamespace, mtest.FirstBatch),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+		expectOutboxEmail(mock)
+		created := postJSON(MongoRegister, body)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			mt.Fatal(err)
+		}
+		assertSameResponse(mt.T, existing, created, http.StatusAccepted)
+		assertSameResponse(mt.T, duplicate, created, http.StatusAccepted)
+		if !strings.Contains(created.Body.String(), RegisterAcceptedMessage) {
+			mt.Fatalf("respuesta inesperada: %s", created.Body.String())
+		}
+
+		// El usuario nuevo se inserta con la bienvenida pendiente (en el mismo insert)
+		// y la marca se quita después de encolarla
+		var inserted, unset bool
+		for _, event := range mt.GetAllStartedEvents() {
+			switch event.CommandName {
+			case "insert":
+				document := event.Command.Lookup("documents").Array().Index(0).Value().Document()
+				inserted = document.Lookup("welcome_pending").Boolean()
+			case "update":
+				update := event.Command.Lookup("updates").Array().Index(0).Value().Document()
+				unset = !update.Lookup("u", "$unset", "welcome_pending").IsZero()
+			}
+		}
+		if !inserted || !unset {
+			mt.Fatalf("welcome_pending: insertado = %v, quitado = %v", inserted, unset)
+		}
+	})
+}
+
+func TestMongoLoginSameResponseForUnknownUserAndWrongPassword(t *testing.T) {
+	setupAuthTest(t)
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	hash, err := security.Passwords.Hash(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mt.Run("login", func(mt *mtest.T) {
+		core.MongoUserRepo = repositories.NewMongoUserRepositoryWithCollection(mt.Coll)
+
+		unknownUser := func() *httptest.ResponseRecorder {
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, usersNamespace, mtest.FirstBatch))
+			return postJSON(MongoLogin, `{"email":"nadie@example.com","password":"`+testPassword+`"}`)
+		}
+		wrongPassword := func() *httptest.ResponseRecorder {
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, usersNamespace, mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "email", Value: "ana@example.com"},
+				{Key: "password", Value: hash},
+			}))
+			return postJSON(MongoLogin, `{"email":"ana@example.com","password":"Otra-Clave-2024"}`)
+		}
+
+		assertSameResponse(mt.T, unknownUser(), wrongPassword(), http.StatusUnauthorized)
+		assertComparableDuration(mt.T, func() { unknownUser() }, func() { wrongPassword() })
+	})
+}
@@ -1,8 +1,16 @@
 package core
 
 import (
+	"context"
+	"go-aprendizaje/config"
+	"go-aprendizaje/database"
+	"go-aprendizaje/mailer"
+	"go-aprendizaje/ratelimit"
 	"go-aprendizaje/repositories"
+	"go-aprendizaje/scanner"
+	"go-aprendizaje/storage"
 	"log"
+	"time"
 )
 
 // --- VARIABLES GLOBALES (Singleton) ---
@@ -13,6 +21,18 @@ var MongoUserRepo *repositories.MongoUserRepository
 
 // (Aquí podrías añadir: var MongoProductRepo *repositories.MongoProductRepository)
 
+// RateLimitStore es el backend compartido por todos los middlewares de rate limiting
+var RateLimitStore ratelimit.Store
+
+// FileStorage es el backend de archivos (fotos de perfil, etc.)
+var FileStorage storage.Storage
+
+// Mailer es el transporte con el que se envían los emails (SMTP, sendmail, archivos o captura)
+var Mailer mailer.Mailer
+
+// FileScanner es el antivirus de los archivos subidos (nil si está desactivado)
+var FileScanner scanner.Scanner
+
 // InitMongoRepositories es la función que llamará 'main.go'
 func InitMongoRepositories() {
 	// Llama al constructor del repositorio para instanciar la variable global
@@ -20,3 +40,79 @@ func InitMongoRepositories() {
 
 	log.Println("Registro global de repositorios MongoDB inicializado.")
 }
+
+// InitRateLimitStore elige el backend del rate limiter según RATE_LIMIT_BACKEND
+// - "memory" (por defecto): en memoria, solo válido con una única réplica
+// - "mongo": colección compartida entre todas las réplicas
+func InitRateLimitStore() {
+	backend := config.GetEnv("RATE_LIMIT_BACKEND", "memory")
+
+	switch backend {
+	case "mongo":
+		store, err := ratelimit.NewMongoStore(database.Mongo.Collection("rate_limits"))
+		if err != nil {
+			log.Fatal("Error fatal: No se pudo inicializar el rate limiter en MongoDB: ", err)
+		}
+		RateLimitStore = store
+	default:
+		RateLimitStore = ratelimit.NewMemoryStore(time.Minute)
+	}
+
+	log.Println("Rate limiter inicializado con backend: " + backend)
+}
+
+// InitFileStorage elige el backend de archivos según STORAGE_BACKEND
+// - "local" (por defecto): carpeta FILE_PATH, solo válido con una réplica o un volumen compartido
+// - "s3": bucket compatible con S3 (AWS, MinIO...), compartido entre todas las réplicas
+func InitFileStorage() {
+	backend := config.GetEnv("STORAGE_BACKEND", "local")
+
+	fileStorage, err := storage.New(backend)
+	if err != nil {
+		log.Fatal("Error fatal: No se pudo inicializar el almacenamiento de archivos: ", err)
+	}
+	FileStorage = fileStorage
+
+	log.Println("Almacenamiento de archivos inicializado con backend: " + backend)
+}
+
+// InitFileScanner elige el antivirus según SCANNER_BACKEND
+// - "none" (por defecto): los archivos no se analizan
+// - "clamd": ClamAV (CLAMD_ADDRESS); los archivos privados quedan en cuarentena hasta que se analizan
+func InitFileScanner() {
+	backend := config.GetEnv("SCANNER_BACKEND", "none")
+
+	fileScanner, err := scanner.New(backend)
+	if err != nil {
+		log.Fatal("Error fatal: No se pudo inicializar el antivirus: ", err)
+	}
+	FileScanner = fileScanner
+
+	// Si clamd no responde al arrancar solo avisamos: los archivos esperarán en cuarentena
+	if clamd, ok := fileScanner.(*scanner.ClamdScanner); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := clamd.Ping(ctx); err != nil {
+			log.Println("Aviso: clamd no responde: ", err)
+		}
+	}
+
+	log.Println("Antivirus inicializado con backend: " + backend)
+}
+
+// InitMailer elige cómo se envían los emails según MAIL_TRANSPORT
+// - "smtp" (por defecto): servidor SMTP (EMAIL_HOST, EMAIL_PORT, EMAIL_USER, EMAIL_PASSWORD)
+// - "sendmail": el sendmail del sistema (SENDMAIL_PATH)
+// - "file": un archivo .eml por email en MAIL_DROP_PATH
+// - "capture": en memoria, para desarrollo y tests (se ven en GET /api/dev/mail)
+func InitMailer() {
+	transport := config.GetEnv("MAIL_TRANSPORT", "smtp")
+
+	emailMailer, err := mailer.New(transport)
+	if err != nil {
+		log.Fatal("Error fatal: No se pudo inicializar el envío de emails: ", err)
+	}
+	Mailer = emailMailer
+
+	log.Println("Envío de emails inicializado con transporte: " + transport)
+}
@@ -37,5 +37,25 @@ func ConnectDatabase() {
 	log.Println("¡Conexión a la base de datos (Postgres) exitosa!")
 
 	// AutoMigrate crea las tablas en la base de datos basándose en los modelos definidos
-	DB.AutoMigrate(&models.User{})
+	DB.AutoMigrate(
+		&models.User{},
+		&models.PasswordHistory{},
+		&models.DataExport{},
+		&models.TokenRevocation{},
+		&models.DeletionTombstone{},
+		&models.StoredFile{},
+		&models.TusUpload{},
+		&models.OutboxEmail{},
+		&models.EmailSuppression{},
+		&models.MagicLogin{},
+		&models.Session{},
+		&models.LoginAttempt{},
+		&models.APIKey{},
+		&models.OAuthClient{},
+		&models.OAuthAuthorization{},
+		&models.OAuthGrant{},
+		&models.OAuthRefreshToken{},
+		&models.OAuthConsent{},
+		&models.OAuthRevokedToken{},
+	)
 }
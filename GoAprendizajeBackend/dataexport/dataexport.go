@@ -0,0 +1,266 @@
+package dataexport
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"go-aprendizaje/config"
+	"go-aprendizaje/core"
+	"go-aprendizaje/database"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/models"
+	"go-aprendizaje/outbox"
+	"go-aprendizaje/storage"
+	"go-aprendizaje/utils"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ErrExportInProgress se devuelve si el usuario ya tiene una exportación generándose
+var ErrExportInProgress = errors.New("ya hay una exportación en curso")
+
+// ErrInvalidLink se devuelve si el enlace de descarga no existe, no coincide o caducó
+var ErrInvalidLink = errors.New("enlace de descarga inválido o caducado")
+
+// Section es un bloque de datos del usuario que se añade al ZIP.
+// Cada módulo (perfil, historial de logins, auditoría...) registra la suya con RegisterSection.
+type Section func(zipWriter *zip.Writer, export *models.DataExport) error
+
+// sections son los bloques que se incluyen en cada exportación (en orden)
+var sections = []Section{profileSection, passwordHistorySection, profileImageSection}
+
+// RegisterSection añade un bloque más a todas las exportaciones
+func RegisterSection(section Section) {
+	sections = append(sections, section)
+}
+
+// Request crea la petición de exportación y genera el ZIP en segundo plano.
+// Cuando termina, se envía por email un enlace de descarga con caducidad.
+func Request(store string, userID string, email string, locale string) (*models.DataExport, error) {
+	// Solo una exportación a la vez por usuario
+	var count int64
+	database.DB.Model(&models.DataExport{}).
+		Where("user_store = ? AND user_id = ? AND status = ?", store, userID, models.DataExportPending).
+		Count(&count)
+	if count > 0 {
+		return nil, ErrExportInProgress
+	}
+
+	export := &models.DataExport{
+		UserStore: store,
+		UserID:    userID,
+		Email:     email,
+		Locale:    locale,
+		Status:    models.DataExportPending,
+	}
+	if err := database.DB.Create(export).Error; err != nil {
+		return nil, err
+	}
+
+	go build(export)
+
+	return export, nil
+}
+
+// build genera el ZIP, marca la exportación como lista y envía el enlace por email
+func build(export *models.DataExport) {
+	exportDir := config.GetEnv("EXPORT_PATH", "./exports")
+	ttl, err := time.ParseDuration(config.GetEnv("DATA_EXPORT_TTL", "48h"))
+	if err != nil {
+		ttl = 48 * time.Hour
+	}
+
+	filePath := filepath.Join(exportDir, "export-"+strconv.FormatUint(uint64(export.ID), 10)+".zip")
+	if err := writeArchive(filePath, export); err != nil {
+		logging.Log.Errorf("Error al generar la exportación %d: %v", export.ID, err)
+		os.Remove(filePath)
+		database.DB.Model(export).Update("status", models.DataExportFailed)
+		return
+	}
+
+	// El token solo viaja en el email; en la BD guardamos su hash
+	token, err := randomToken()
+	if err != nil {
+		logging.Log.Errorf("Error al generar el token de la exportación %d: %v", export.ID, err)
+		database.DB.Model(export).Update("status", models.DataExportFailed)
+		return
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	database.DB.Model(export).Updates(map[string]interface{}{
+		"status":     models.DataExportReady,
+		"file_path":  filePath,
+		"token_hash": hashToken(token),
+		"expires_at": expiresAt,
+	})
+
+	apiURL := config.GetEnv("API_URL", "http://localhost:8080")
+	link := apiURL + "/api/users/exports/" + strconv.FormatUint(uint64(export.ID), 10) + "/download?token=" + token
+	outbox.Send(utils.DataExportEmail(export.Locale, export.Email, link, expiresAt))
+}
+
+// writeArchive crea el ZIP con todas las secciones
+func writeArchive(filePath string, export *models.DataExport) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	zipWriter := zip.NewWriter(file)
+	for _, section := range sections {
+		if err := section(zipWriter, export); err != nil {
+			return err
+		}
+	}
+	return zipWriter.Close()
+}
+
+// WriteJSON añade un fichero JSON (con sangría, para que se pueda leer a mano) al ZIP
+func WriteJSON(zipWriter *zip.Writer, name string, data interface{}) error {
+	f, err := zipWriter.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+// profileSection añade profile.json (sin la contraseña)
+func profileSection(zipWriter *zip.Writer, export *models.DataExport) error {
+	if export.UserStore == "mongo" {
+		user, err := core.MongoUserRepo.GetUserByID(export.UserID)
+		if err != nil {
+			return err
+		}
+		return WriteJSON(zipWriter, "profile.json", map[string]interface{}{
+			"id":         user.ID.Hex(),
+			"email":      user.Email,
+			"role":       user.Role,
+			"created_at": user.CreatedAt,
+			"updated_at": user.UpdatedAt,
+		})
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, export.UserID).Error; err != nil {
+		return err
+	}
+	return WriteJSON(zipWriter, "profile.json", map[string]interface{}{
+		"id":                 user.ID,
+		"email":              user.Email,
+		"role":               user.Role,
+		"profile_image_path": storage.URL(user.ProfileImagePath),
+		"created_at":         user.CreatedAt,
+		"updated_at":         user.UpdatedAt,
+	})
+}
+
+// passwordHistorySection añade las fechas de los cambios de contraseña (nunca los hashes)
+func passwordHistorySection(zipWriter *zip.Writer, export *models.DataExport) error {
+	var changes []time.Time
+
+	if export.UserStore == "postgres" {
+		var history []models.PasswordHistory
+		database.DB.Where("user_id = ?", export.UserID).Order("created_at").Find(&history)
+		for _, entry := range history {
+			changes = append(changes, entry.CreatedAt)
+		}
+	}
+
+	return WriteJSON(zipWriter, "password_changes.json", map[string]interface{}{"password_changes": changes})
+}
+
+// profileImageSection copia la foto de perfil (si tiene) desde el backend de almacenamiento
+func profileImageSection(zipWriter *zip.Writer, export *models.DataExport) error {
+	if export.UserStore != "postgres" {
+		return nil
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, export.UserID).Error; err != nil || user.ProfileImagePath == "" {
+		return nil
+	}
+
+	source, _, err := core.FileStorage.Open(context.Background(), user.ProfileImagePath)
+	if err != nil {
+		logging.Log.Warnf("Exportación %d: no se encontró la foto de perfil %s", export.ID, user.ProfileImagePath)
+		return nil
+	}
+	defer source.Close()
+
+	f, err := zipWriter.Create("images/" + path.Base(user.ProfileImagePath))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, source)
+	return err
+}
+
+// FindDownload comprueba el enlace (ID + token) y devuelve la exportación si sigue vigente
+func FindDownload(id string, token string) (*models.DataExport, error) {
+	var export models.DataExport
+	err := database.DB.Where("id = ? AND token_hash = ? AND status = ?", id, hashToken(token), models.DataExportReady).
+		First(&export).Error
+	if err != nil || time.Now().After(export.ExpiresAt) {
+		return nil, ErrInvalidLink
+	}
+	return &export, nil
+}
+
+// StartJanitor lanza una goroutine que borra los ZIP caducados cada cierto tiempo
+func StartJanitor(every time.Duration) {
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for range ticker.C {
+			expireArchives()
+		}
+	}()
+}
+
+// expireArchives borra del disco los ZIP caducados y los marca como "expired"
+func expireArchives() {
+	var expired []models.DataExport
+	database.DB.Where("status = ? AND expires_at < ?", models.DataExportReady, time.Now()).Find(&expired)
+
+	for _, export := range expired {
+		if err := os.Remove(export.FilePath); err != nil && !os.IsNotExist(err) {
+			logging.Log.Errorf("No se pudo borrar la exportación caducada %d: %v", export.ID, err)
+			continue
+		}
+		database.DB.Model(&export).Updates(map[string]interface{}{
+			"status":     models.DataExportExpired,
+			"file_path":  "",
+			"token_hash": "",
+		})
+	}
+}
+
+// randomToken genera un token aleatorio de 32 bytes en hexadecimal
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken devuelve el SHA-256 del token (lo que se guarda en la BD)
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
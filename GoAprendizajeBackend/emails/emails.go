@@ -0,0 +1,201 @@
+package emails
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"go-aprendizaje/config"
+	htmltemplate "html/template"
+	"io/fs"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Plantillas de los emails (html/template para la parte HTML y text/template para la de texto plano).
+// Cada email está en templates/<idioma>/<nombre>.html y <nombre>.txt:
+//   - el .txt define "subject" (el asunto) y "content" (el cuerpo en texto plano)
+//   - el .html define "content" (el cuerpo en HTML, que se escapa automáticamente)
+//
+// Los dos se pintan dentro de un diseño común (templates/layout.html y layout.txt), que usa los
+// trozos de templates/<idioma>/common.tmpl (saludo, despedida, pie...).
+
+//go:embed templates
+var templateFS embed.FS
+
+// FallbackLocale es el idioma en el que existen todas las plantillas
+const FallbackLocale = "es"
+
+// Rendered es un email ya generado
+type Rendered struct {
+	Locale  string `json:"locale"`
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+}
+
+// TemplateInfo describe una plantilla (para el panel de admin)
+type TemplateInfo struct {
+	Name    string   `json:"name"`
+	Locales []string `json:"locales"`
+}
+
+// parsedTemplate son las dos versiones (HTML y texto) de un email en un idioma
+type parsedTemplate struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+// registry guarda las plantillas por nombre e idioma. Se cargan (y se prueban con los datos de ejemplo)
+// al arrancar: una plantilla rota hace fallar el arranque, no el envío de un email.
+var registry = mustLoad()
+
+// Render genera el email 'name' en el idioma 'locale' (si no existe en ese idioma, en el de por defecto)
+func Render(name string, locale string, data map[string]any) (Rendered, error) {
+	locale = ResolveLocale(locale)
+	versions, ok := registry[name]
+	if !ok {
+		return Rendered{}, fmt.Errorf("plantilla de email desconocida: %s", name)
+	}
+	tmpl, ok := versions[locale]
+	if !ok {
+		locale = FallbackLocale
+		tmpl = versions[locale]
+	}
+	return execute(tmpl, locale, data)
+}
+
+// Preview genera una plantilla con sus datos de ejemplo
+func Preview(name string, locale string) (Rendered, error) {
+	data, ok := samples()[name]
+	if !ok {
+		return Rendered{}, fmt.Errorf("plantilla de email desconocida: %s", name)
+	}
+	return Render(name, locale, data)
+}
+
+// Templates lista las plantillas disponibles y sus idiomas
+func Templates() []TemplateInfo {
+	var list []TemplateInfo
+	for name, versions := range registry {
+		info := TemplateInfo{Name: name}
+		for locale := range versions {
+			info.Locales = append(info.Locales, locale)
+		}
+		sort.Strings(info.Locales)
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// execute pinta el asunto, el HTML y el texto. A los datos se añaden AppName, Locale y Subject.
+func execute(tmpl parsedTemplate, locale string, data map[string]any) (Rendered, error) {
+	values := map[string]any{"AppName": config.GetEnv("APP_NAME", "Mi API"), "Locale": locale}
+	for key, value := range data {
+		values[key] = value
+	}
+
+	var subject, html, text bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", values); err != nil {
+		return Rendered{}, err
+	}
+	// El asunto va en una cabecera: una sola línea
+	values["Subject"] = strings.Join(strings.Fields(subject.String()), " ")
+
+	if err := tmpl.html.Execute(&html, values); err != nil {
+		return Rendered{}, err
+	}
+	if err := tmpl.text.Execute(&text, values); err != nil {
+		return Rendered{}, err
+	}
+
+	return Rendered{Locale: locale, Subject: values["Subject"].(string), HTML: html.String(), Text: text.String()}, nil
+}
+
+// mustLoad lee todas las plantillas de todos los idiomas y las prueba con los datos de ejemplo
+func mustLoad() map[string]map[string]parsedTemplate {
+	loaded := map[string]map[string]parsedTemplate{}
+
+	entries, err := fs.ReadDir(templateFS, "templates")
+	if err != nil {
+		panic(err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		locale := entry.Name()
+
+		files, err := fs.Glob(templateFS, "templates/"+locale+"/*.html")
+		if err != nil {
+			panic(err)
+		}
+		for _, file := range files {
+			name := strings.TrimSuffix(file[strings.LastIndex(file, "/")+1:], ".html")
+			tmpl, err := parse(locale, name)
+			if err != nil {
+				panic(fmt.Sprintf("plantilla de email %s/%s: %v", locale, name, err))
+			}
+			if loaded[name] == nil {
+				loaded[name] = map[string]parsedTemplate{}
+			}
+			loaded[name][locale] = tmpl
+		}
+	}
+
+	// Todas las plantillas deben existir en el idioma por defecto y tener datos de ejemplo (y funcionar con ellos)
+	sampleData := samples()
+	for name, versions := range loaded {
+		if _, ok := versions[FallbackLocale]; !ok {
+			panic(fmt.Sprintf("la plantilla de email %s no existe en '%s'", name, FallbackLocale))
+		}
+		for locale, tmpl := range versions {
+			if _, err := execute(tmpl, locale, sampleData[name]); err != nil {
+				panic(fmt.Sprintf("plantilla de email %s/%s: %v", locale, name, err))
+			}
+		}
+	}
+	for name := range sampleData {
+		if _, ok := loaded[name]; !ok {
+			panic(fmt.Sprintf("falta la plantilla de email %s", name))
+		}
+	}
+	return loaded
+}
+
+// parse lee las dos versiones de un email en un idioma, junto con el diseño común
+func parse(locale string, name string) (parsedTemplate, error) {
+	dir := "templates/" + locale + "/"
+	funcs := templateFuncs(locale)
+
+	html, err := htmltemplate.New("layout.html").Funcs(htmltemplate.FuncMap(funcs)).
+		ParseFS(templateFS, "templates/layout.html", dir+"common.tmpl", dir+name+".html")
+	if err != nil {
+		return parsedTemplate{}, err
+	}
+	text, err := texttemplate.New("layout.txt").Funcs(texttemplate.FuncMap(funcs)).
+		ParseFS(templateFS, "templates/layout.txt", dir+"common.tmpl", dir+name+".txt")
+	if err != nil {
+		return parsedTemplate{}, err
+	}
+	return parsedTemplate{html: html, text: text}, nil
+}
+
+// dateFormats es cómo se escriben las fechas en cada idioma (siempre en UTC)
+var dateFormats = map[string]string{
+	"es": "02/01/2006 15:04",
+	"en": "Jan 2, 2006 15:04",
+}
+
+// templateFuncs son las funciones disponibles en las plantillas
+func templateFuncs(locale string) map[string]any {
+	format, ok := dateFormats[locale]
+	if !ok {
+		format = dateFormats[FallbackLocale]
+	}
+	return map[string]any{
+		"date": func(t time.Time) string { return t.UTC().Format(format) + " (UTC)" },
+	}
+}
@@ -0,0 +1,79 @@
+package emails
+
+import (
+	"go-aprendizaje/config"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Locales devuelve los idiomas en los que hay plantillas
+func Locales() []string {
+	seen := map[string]bool{}
+	for _, versions := range registry {
+		for locale := range versions {
+			seen[locale] = true
+		}
+	}
+	locales := make([]string, 0, len(seen))
+	for locale := range seen {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// DefaultLocale es el idioma de los usuarios que no tienen uno (DEFAULT_LOCALE)
+func DefaultLocale() string {
+	if locale := Match(config.GetEnv("DEFAULT_LOCALE", FallbackLocale)); locale != "" {
+		return locale
+	}
+	return FallbackLocale
+}
+
+// Match devuelve el idioma soportado que corresponde a 'tag' (ej: "en-US" -> "en"), o "" si no hay ninguno
+func Match(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	if tag == "" {
+		return ""
+	}
+	for _, locale := range Locales() {
+		if locale == tag {
+			return locale
+		}
+	}
+	return ""
+}
+
+// ResolveLocale devuelve el idioma con el que se envían los emails a un usuario con idioma 'locale'
+func ResolveLocale(locale string) string {
+	if matched := Match(locale); matched != "" {
+		return matched
+	}
+	return DefaultLocale()
+}
+
+// FromAcceptLanguage elige el idioma soportado preferido en una cabecera Accept-Language
+// (ej: "en-GB,en;q=0.9,es;q=0.8" -> "en"), o "" si no hay ninguno
+func FromAcceptLanguage(header string) string {
+	best, bestQuality := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		// Con la misma calidad gana el primero (el orden también expresa preferencia)
+		if locale := Match(tag); locale != "" && quality > bestQuality {
+			best, bestQuality = locale, quality
+		}
+	}
+	return best
+}
@@ -0,0 +1,29 @@
+package emails
+
+import "time"
+
+// samples son los datos de ejemplo de cada plantilla: se usan en la vista previa del panel de admin
+// y al arrancar, para comprobar que todas las plantillas se pueden generar.
+// Al añadir una plantilla hay que añadir aquí sus datos (con las mismas claves que usa el código que la envía).
+func samples() map[string]map[string]any {
+	soon := time.Date(2030, 1, 15, 18, 30, 0, 0, time.UTC)
+
+	return map[string]map[string]any{
+		"welcome":        {},
+		"account_exists": {},
+		"verification":   {"Link": "https://example.com/verify?token=ejemplo", "ExpiresAt": soon},
+		"lockout":        {"Until": soon},
+		"magic_link":     {"Link": "https://example.com/login/magic?token=ejemplo", "Code": "", "ExpiresAt": soon},
+		"security_alert": {
+			"Event":      "password_changed",
+			"OccurredAt": soon,
+			"IP":         "203.0.113.7",
+			"Device":     "Firefox / Linux",
+			"Location":   "Madrid, ES",
+		},
+		"data_export":        {"Link": "https://example.com/api/users/exports/1/download?token=ejemplo", "ExpiresAt": soon},
+		"deletion_scheduled": {"ScheduledAt": soon},
+		"account_deleted":    {},
+		"infected_file":      {"FileName": "factura.pdf"},
+	}
+}
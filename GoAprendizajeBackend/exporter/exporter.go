@@ -0,0 +1,226 @@
+package exporter
+
+import (
+	"errors"
+	"go-aprendizaje/core"
+	"go-aprendizaje/database"
+	"go-aprendizaje/models"
+	"go-aprendizaje/storage"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AvailableColumns son las columnas que se pueden exportar (en el orden por defecto).
+// La contraseña NUNCA se exporta.
+var AvailableColumns = []string{"id", "email", "role", "profile_image_path", "created_at", "updated_at"}
+
+// Options son los parámetros de la exportación
+type Options struct {
+	Format        string    // "csv", "jsonl" o "xlsx"
+	Store         string    // "postgres" o "mongo"
+	Columns       []string  // Vacío = todas
+	Role          string    // Filtro por rol exacto
+	Search        string    // Filtro: el email contiene este texto
+	CreatedAfter  time.Time // Filtro: creados a partir de esta fecha (cero = sin filtro)
+	CreatedBefore time.Time // Filtro: creados antes de esta fecha (cero = sin filtro)
+}
+
+// ContentTypes es el "Content-Type" HTTP de cada formato
+var ContentTypes = map[string]string{
+	"csv":   "text/csv; charset=utf-8",
+	"jsonl": "application/x-ndjson",
+	"xlsx":  "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// userRow es un usuario ya "aplanado", común a Postgres y Mongo
+type userRow struct {
+	ID               string
+	Email            string
+	Role             string
+	ProfileImagePath string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// value devuelve el valor de una columna como texto
+func (r userRow) value(column string) string {
+	switch column {
+	case "id":
+		return r.ID
+	case "email":
+		return r.Email
+	case "role":
+		return r.Role
+	case "profile_image_path":
+		return r.ProfileImagePath
+	case "created_at":
+		return r.CreatedAt.UTC().Format(time.RFC3339)
+	case "updated_at":
+		return r.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+	return ""
+}
+
+// ParseColumns convierte "id,email" en una lista validada de columnas (vacío = todas)
+func ParseColumns(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return AvailableColumns, nil
+	}
+
+	var columns []string
+	for _, column := range strings.Split(value, ",") {
+		column = strings.TrimSpace(column)
+		if !isAvailableColumn(column) {
+			return nil, errors.New("columna desconocida: " + column)
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// ParseDate acepta fechas "2006-01-02" o RFC 3339 (vacío = sin filtro)
+func ParseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, nil
+	}
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.New("fecha inválida (usa AAAA-MM-DD): " + value)
+	}
+	return date, nil
+}
+
+func isAvailableColumn(column string) bool {
+	for _, available := range AvailableColumns {
+		if available == column {
+			return true
+		}
+	}
+	return false
+}
+
+// Export escribe los usuarios en 'w' fila a fila (sin cargarlos todos en memoria)
+// y devuelve cuántos se exportaron
+func Export(w io.Writer, opts Options) (int, error) {
+	if len(opts.Columns) == 0 {
+		opts.Columns = AvailableColumns
+	}
+
+	writer, err := newRowWriter(w, opts.Format)
+	if err != nil {
+		return 0, err
+	}
+	if err := writer.WriteHeader(opts.Columns); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	write := func(row userRow) error {
+		values := make([]string, len(opts.Columns))
+		for i, column := range opts.Columns {
+			values[i] = row.value(column)
+		}
+		count++
+		return writer.WriteRow(values)
+	}
+
+	switch opts.Store {
+	case "postgres":
+		err = streamPostgres(opts, write)
+	case "mongo":
+		err = streamMongo(opts, write)
+	default:
+		err = errors.New("store inválido (usa 'postgres' o 'mongo'): " + opts.Store)
+	}
+	if err != nil {
+		return count, err
+	}
+
+	return count, writer.Close()
+}
+
+// streamPostgres recorre los usuarios de Postgres con un cursor (Rows) en lugar de Find
+func streamPostgres(opts Options, write func(userRow) error) error {
+	query := database.DB.Model(&models.User{}).Order("id")
+	if opts.Role != "" {
+		query = query.Where("role = ?", opts.Role)
+	}
+	if opts.Search != "" {
+		// Escapamos los comodines de LIKE para buscar el texto literal
+		search := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(opts.Search)
+		query = query.Where("email ILIKE ?", "%"+search+"%")
+	}
+	if !opts.CreatedAfter.IsZero() {
+		query = query.Where("created_at >= ?", opts.CreatedAfter)
+	}
+	if !opts.CreatedBefore.IsZero() {
+		query = query.Where("created_at < ?", opts.CreatedBefore)
+	}
+
+	rows, err := query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user models.User
+		if err := database.DB.ScanRows(rows, &user); err != nil {
+			return err
+		}
+
+		err := write(userRow{
+			ID:               strconv.FormatUint(uint64(user.ID), 10),
+			Email:            user.Email,
+			Role:             user.Role,
+			ProfileImagePath: storage.URL(user.ProfileImagePath),
+			CreatedAt:        user.CreatedAt,
+			UpdatedAt:        user.UpdatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// streamMongo recorre los usuarios de Mongo con el cursor del repositorio
+func streamMongo(opts Options, write func(userRow) error) error {
+	filter := bson.M{}
+	if opts.Role != "" {
+		filter["role"] = opts.Role
+	}
+	if opts.Search != "" {
+		filter["email"] = bson.M{"$regex": primitive.Regex{Pattern: regexp.QuoteMeta(opts.Search), Options: "i"}}
+	}
+	createdAt := bson.M{}
+	if !opts.CreatedAfter.IsZero() {
+		createdAt["$gte"] = opts.CreatedAfter
+	}
+	if !opts.CreatedBefore.IsZero() {
+		createdAt["$lt"] = opts.CreatedBefore
+	}
+	if len(createdAt) > 0 {
+		filter["created_at"] = createdAt
+	}
+
+	return core.MongoUserRepo.StreamUsers(filter, func(user *models.MongoUser) error {
+		return write(userRow{
+			ID:        user.ID.Hex(),
+			Email:     user.Email,
+			Role:      user.Role,
+			CreatedAt: user.CreatedAt,
+			UpdatedAt: user.UpdatedAt,
+		})
+	})
+}
@@ -0,0 +1,162 @@
+package exporter
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// rowWriter escribe las filas en un formato concreto, una a una
+type rowWriter interface {
+	WriteHeader(columns []string) error
+	WriteRow(values []string) error
+	Close() error // Termina el fichero (no cierra el io.Writer de destino)
+}
+
+// newRowWriter elige el writer según el formato
+func newRowWriter(w io.Writer, format string) (rowWriter, error) {
+	switch format {
+	case "csv":
+		return &csvRowWriter{writer: csv.NewWriter(w)}, nil
+	case "jsonl":
+		return &jsonlRowWriter{writer: bufio.NewWriter(w)}, nil
+	case "xlsx":
+		return &xlsxRowWriter{zip: zip.NewWriter(w)}, nil
+	}
+	return nil, errors.New("formato inválido (usa 'csv', 'jsonl' o 'xlsx'): " + format)
+}
+
+// --- CSV ---
+
+type csvRowWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvRowWriter) WriteHeader(columns []string) error {
+	return w.writer.Write(columns)
+}
+
+func (w *csvRowWriter) WriteRow(values []string) error {
+	// Evitamos "CSV injection": Excel ejecuta como fórmula lo que empieza por =, +, - o @
+	for i, value := range values {
+		if value != "" && (value[0] == '=' || value[0] == '+' || value[0] == '-' || value[0] == '@') {
+			values[i] = "'" + value
+		}
+	}
+	return w.writer.Write(values)
+}
+
+func (w *csvRowWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// --- JSONL (un objeto JSON por línea) ---
+
+type jsonlRowWriter struct {
+	writer  *bufio.Writer
+	columns []string
+}
+
+func (w *jsonlRowWriter) WriteHeader(columns []string) error {
+	w.columns = columns
+	return nil
+}
+
+func (w *jsonlRowWriter) WriteRow(values []string) error {
+	object := make(map[string]string, len(values))
+	for i, column := range w.columns {
+		object[column] = values[i]
+	}
+
+	line, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+	if _, err := w.writer.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *jsonlRowWriter) Close() error {
+	return w.writer.Flush()
+}
+
+// --- XLSX ---
+// Un .xlsx es un ZIP con varios XML. Los ficheros "fijos" se escriben al principio y
+// la hoja (sheet1.xml) se va escribiendo fila a fila, así nunca tenemos todo en memoria.
+// Los textos van como "inlineStr" para no necesitar la tabla de strings compartidos.
+
+type xlsxRowWriter struct {
+	zip   *zip.Writer
+	sheet *bufio.Writer
+	row   int
+}
+
+// xlsxStaticFiles son los ficheros mínimos que Excel/LibreOffice necesitan para abrir el libro
+var xlsxStaticFiles = []struct{ name, content string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Usuarios" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`},
+}
+
+func (w *xlsxRowWriter) WriteHeader(columns []string) error {
+	for _, file := range xlsxStaticFiles {
+		f, err := w.zip.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, file.content); err != nil {
+			return err
+		}
+	}
+
+	// La hoja es el último fichero del ZIP: a partir de aquí solo escribimos en ella
+	sheet, err := w.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	w.sheet = bufio.NewWriter(sheet)
+
+	if _, err := io.WriteString(w.sheet, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return err
+	}
+
+	return w.WriteRow(columns)
+}
+
+func (w *xlsxRowWriter) WriteRow(values []string) error {
+	w.row++
+	fmt.Fprintf(w.sheet, `<row r="%d">`, w.row)
+	for _, value := range values {
+		io.WriteString(w.sheet, `<c t="inlineStr"><is><t xml:space="preserve">`)
+		if err := xml.EscapeText(w.sheet, []byte(value)); err != nil {
+			return err
+		}
+		io.WriteString(w.sheet, `</t></is></c>`)
+	}
+	_, err := io.WriteString(w.sheet, `</row>`)
+	return err
+}
+
+func (w *xlsxRowWriter) Close() error {
+	if _, err := io.WriteString(w.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+	return w.zip.Close()
+}
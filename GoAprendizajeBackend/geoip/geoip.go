@@ -0,0 +1,107 @@
+package geoip
+
+import (
+	"go-aprendizaje/config"
+	"log"
+	"math"
+	"net"
+	"time"
+)
+
+// Localización aproximada de las IPs con una base de datos MaxMind descargada (GeoLite2-City o compatible).
+// Todo se resuelve en local: ninguna IP de los usuarios sale del servidor.
+
+// Location es dónde está (más o menos) una IP. La precisión de una base de ciudades es de decenas de km.
+type Location struct {
+	Country   string  // Código ISO (ej: "ES")
+	City      string  // Nombre en inglés (ej: "Madrid"), puede estar vacío
+	Latitude  float64 // Grados
+	Longitude float64
+}
+
+// String es la ubicación legible (ej: "Madrid, ES")
+func (l Location) String() string {
+	if l.City == "" {
+		return l.Country
+	}
+	if l.Country == "" {
+		return l.City
+	}
+	return l.City + ", " + l.Country
+}
+
+// db es la base de datos cargada (nil = geolocalización desactivada)
+var db *Reader
+
+// Init carga la base de datos de GEOIP_DATABASE (ruta a un .mmdb). Vacía = desactivada.
+func Init() {
+	path := config.GetEnv("GEOIP_DATABASE", "")
+	if path == "" {
+		log.Println("Geolocalización de IPs desactivada (GEOIP_DATABASE vacío)")
+		return
+	}
+
+	reader, err := Open(path)
+	if err != nil {
+		log.Fatal("Error fatal: No se pudo cargar la base de datos de geolocalización: ", err)
+	}
+	db = reader
+
+	// Las IPs cambian de dueño: una base de datos vieja ubica mal
+	built := time.Unix(int64(reader.BuildEpoch), 0)
+	if time.Since(built) > 90*24*time.Hour {
+		log.Println("Aviso: la base de datos de geolocalización es de " + built.Format("2006-01-02") + ", conviene actualizarla")
+	}
+	log.Println("Geolocalización de IPs inicializada con " + reader.DatabaseType)
+}
+
+// Enabled indica si hay base de datos cargada
+func Enabled() bool {
+	return db != nil
+}
+
+// Lookup localiza una IP. 'ok' es false si la geolocalización está desactivada,
+// la IP es privada/desconocida o la base de datos no tiene coordenadas para ella.
+func Lookup(ip string) (Location, bool) {
+	parsed := net.ParseIP(ip)
+	if db == nil || parsed == nil || parsed.IsPrivate() || parsed.IsLoopback() {
+		return Location{}, false
+	}
+
+	record, err := db.Lookup(parsed)
+	if err != nil || record == nil {
+		return Location{}, false
+	}
+
+	// Estructura de GeoLite2-City: {"country": {"iso_code"}, "city": {"names": {"en"}}, "location": {"latitude", "longitude"}}
+	coordinates, _ := record["location"].(map[string]any)
+	latitude, hasLatitude := coordinates["latitude"].(float64)
+	longitude, hasLongitude := coordinates["longitude"].(float64)
+	if !hasLatitude || !hasLongitude {
+		return Location{}, false
+	}
+
+	location := Location{Latitude: latitude, Longitude: longitude}
+	if country, ok := record["country"].(map[string]any); ok {
+		location.Country, _ = country["iso_code"].(string)
+	}
+	if city, ok := record["city"].(map[string]any); ok {
+		if names, ok := city["names"].(map[string]any); ok {
+			location.City, _ = names["en"].(string)
+		}
+	}
+	return location, true
+}
+
+// earthRadiusKm es el radio medio de la Tierra
+const earthRadiusKm = 6371.0
+
+// DistanceKm es la distancia en km entre dos puntos (fórmula del semiverseno, sobre una esfera)
+func DistanceKm(fromLatitude, fromLongitude, toLatitude, toLongitude float64) float64 {
+	radians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+	deltaLatitude := radians(toLatitude - fromLatitude)
+	deltaLongitude := radians(toLongitude - fromLongitude)
+	a := math.Sin(deltaLatitude/2)*math.Sin(deltaLatitude/2) +
+		math.Cos(radians(fromLatitude))*math.Cos(radians(toLatitude))*math.Sin(deltaLongitude/2)*math.Sin(deltaLongitude/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
@@ -0,0 +1,342 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+// Lector del formato MaxMind DB (.mmdb), el de GeoLite2/GeoIP2 y otros proveedores compatibles.
+// El archivo tiene tres partes:
+//  1. Un árbol binario de búsqueda: se baja bit a bit por la IP hasta llegar a un registro de datos
+//  2. La sección de datos: valores codificados (mapas, strings, números...) que pueden compartirse con punteros
+//  3. Los metadatos al final, tras el marcador "\xAB\xCD\xEFMaxMind.com"
+// Especificación: https://maxmind.github.io/MaxMind-DB/
+
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// ErrInvalidDatabase se devuelve si el archivo no es una base de datos MaxMind válida
+var ErrInvalidDatabase = errors.New("base de datos MaxMind inválida")
+
+// Reader es una base de datos .mmdb cargada en memoria (solo lectura: se puede usar desde varias goroutines)
+type Reader struct {
+	tree         []byte
+	data         []byte
+	nodeCount    uint
+	recordSize   uint
+	ipVersion    uint
+	ipv4Start    uint // Nodo del que cuelgan las IPv4 en un árbol IPv6 (::/96)
+	DatabaseType string
+	BuildEpoch   uint64
+}
+
+// Open carga el archivo completo en memoria (una base de ciudades ocupa unas decenas de MB)
+func Open(path string) (*Reader, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return newReader(content)
+}
+
+func newReader(content []byte) (*Reader, error) {
+	// 1. Los metadatos están tras la ÚLTIMA aparición del marcador
+	markerAt := bytes.LastIndex(content, metadataMarker)
+	if markerAt < 0 {
+		return nil, ErrInvalidDatabase
+	}
+	metadataStart := markerAt + len(metadataMarker)
+	value, _, err := decoder{content[metadataStart:]}.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: metadatos: %v", ErrInvalidDatabase, err)
+	}
+	metadata, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: metadatos", ErrInvalidDatabase)
+	}
+
+	reader := &Reader{
+		nodeCount:  uint(asUint(metadata["node_count"])),
+		recordSize: uint(asUint(metadata["record_size"])),
+		ipVersion:  uint(asUint(metadata["ip_version"])),
+		BuildEpoch: asUint(metadata["build_epoch"]),
+	}
+	reader.DatabaseType, _ = metadata["database_type"].(string)
+	if reader.recordSize != 24 && reader.recordSize != 28 && reader.recordSize != 32 {
+		return nil, fmt.Errorf("%w: record_size %d no soportado", ErrInvalidDatabase, reader.recordSize)
+	}
+	if reader.ipVersion != 4 && reader.ipVersion != 6 {
+		return nil, fmt.Errorf("%w: ip_version %d", ErrInvalidDatabase, reader.ipVersion)
+	}
+
+	// 2. Tras el árbol van 16 bytes a cero y luego la sección de datos
+	treeSize := int(reader.nodeCount * reader.recordSize / 4)
+	if treeSize+16 > markerAt {
+		return nil, fmt.Errorf("%w: árbol truncado", ErrInvalidDatabase)
+	}
+	reader.tree = content[:treeSize]
+	reader.data = content[treeSize+16 : markerAt]
+
+	// 3. En un árbol IPv6 las IPv4 están en ::a.b.c.d: buscamos una vez el nodo de los 96 primeros bits a cero
+	if reader.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < reader.nodeCount; i++ {
+			node = reader.record(node, 0)
+		}
+		reader.ipv4Start = node
+	}
+	return reader, nil
+}
+
+// Lookup devuelve el registro de datos de la IP (nil si la base de datos no la conoce)
+func (r *Reader) Lookup(ip net.IP) (map[string]any, error) {
+	node, bits := uint(0), ip.To16()
+	if ip4 := ip.To4(); ip4 != nil {
+		bits = ip4
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if r.ipVersion == 4 {
+		return nil, nil // Una base de datos solo IPv4 no sabe nada de IPv6
+	}
+	if bits == nil {
+		return nil, nil
+	}
+
+	// Bajar por el árbol bit a bit (del más significativo al menos) hasta salir de él
+	for i := 0; i < len(bits)*8 && node < r.nodeCount; i++ {
+		bit := (bits[i/8] >> (7 - uint(i%8))) & 1
+		node = r.record(node, uint(bit))
+	}
+	if node == r.nodeCount {
+		return nil, nil // Registro vacío: IP desconocida
+	}
+	if node < r.nodeCount {
+		return nil, fmt.Errorf("%w: el árbol no termina", ErrInvalidDatabase)
+	}
+
+	// Los registros mayores que el número de nodos apuntan a la sección de datos
+	offset := int(node-r.nodeCount) - 16
+	value, _, err := decoder{r.data}.decode(offset)
+	if err != nil {
+		return nil, err
+	}
+	result, _ := value.(map[string]any)
+	return result, nil
+}
+
+// record lee el registro izquierdo (bit 0) o derecho (bit 1) de un nodo del árbol
+func (r *Reader) record(node uint, bit uint) uint {
+	switch r.recordSize {
+	case 24:
+		b := r.tree[node*6+bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		// 7 bytes: 3 del izquierdo, un byte compartido (4 bits altos de cada uno) y 3 del derecho
+		b := r.tree[node*7:]
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(r.tree[node*8+bit*4:]))
+	}
+}
+
+// Tipos de la sección de datos
+const (
+	typeExtended = 0
+	typePointer  = 1
+	typeString   = 2
+	typeDouble   = 3
+	typeBytes    = 4
+	typeUint16   = 5
+	typeUint32   = 6
+	typeMap      = 7
+	typeInt32    = 8
+	typeUint64   = 9
+	typeUint128  = 10
+	typeArray    = 11
+	typeBoolean  = 14
+	typeFloat    = 15
+)
+
+// maxDepth limita el anidamiento (un archivo manipulado podría provocar una recursión infinita)
+const maxDepth = 32
+
+// decoder lee los valores de una sección de datos (los punteros son relativos a su inicio)
+type decoder struct {
+	buf []byte
+}
+
+// decode lee el valor de 'offset' y devuelve también dónde empieza el siguiente
+func (d decoder) decode(offset int) (any, int, error) {
+	return d.decodeAt(offset, 0)
+}
+
+func (d decoder) decodeAt(offset int, depth int) (any, int, error) {
+	if depth > maxDepth {
+		return nil, 0, fmt.Errorf("%w: demasiado anidamiento", ErrInvalidDatabase)
+	}
+	kind, size, offset, err := d.header(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if kind == typePointer {
+		// El valor está en otra parte; lo siguiente va justo después del puntero
+		target, next, err := d.pointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decodeAt(target, depth+1)
+		return value, next, err
+	}
+
+	switch kind {
+	case typeMap:
+		result := make(map[string]any, size)
+		for i := 0; i < size; i++ {
+			key, next, err := d.decodeAt(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("%w: clave de mapa no textual", ErrInvalidDatabase)
+			}
+			value, next, err := d.decodeAt(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			result[name] = value
+			offset = next
+		}
+		return result, offset, nil
+	case typeArray:
+		result := make([]any, 0, min(size, 1024))
+		for i := 0; i < size; i++ {
+			value, next, err := d.decodeAt(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			result = append(result, value)
+			offset = next
+		}
+		return result, offset, nil
+	case typeBoolean:
+		return size != 0, offset, nil
+	}
+
+	// El resto son valores de 'size' bytes
+	if offset+size > len(d.buf) {
+		return nil, 0, fmt.Errorf("%w: valor truncado", ErrInvalidDatabase)
+	}
+	raw := d.buf[offset : offset+size]
+	next := offset + size
+	switch kind {
+	case typeString:
+		return string(raw), next, nil
+	case typeBytes:
+		return append([]byte(nil), raw...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("%w: double de %d bytes", ErrInvalidDatabase, size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(raw)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("%w: float de %d bytes", ErrInvalidDatabase, size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(raw))), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		return readUint(raw), next, nil
+	case typeInt32:
+		return int64(int32(readUint(raw))), next, nil
+	case typeUint128:
+		// No cabe en un uint64 y aquí no se usa: lo devolvemos en bruto
+		return append([]byte(nil), raw...), next, nil
+	default:
+		return nil, 0, fmt.Errorf("%w: tipo %d desconocido", ErrInvalidDatabase, kind)
+	}
+}
+
+// header lee el byte de control (tipo en los 3 bits altos, tamaño en los 5 bajos) y sus extensiones
+func (d decoder) header(offset int) (kind int, size int, next int, err error) {
+	if offset < 0 || offset >= len(d.buf) {
+		return 0, 0, 0, fmt.Errorf("%w: desplazamiento %d fuera de rango", ErrInvalidDatabase, offset)
+	}
+	control := d.buf[offset]
+	offset++
+	kind = int(control >> 5)
+
+	if kind == typePointer {
+		// En los punteros los 5 bits bajos no son un tamaño: se interpretan en pointer()
+		return kind, int(control & 0x1F), offset, nil
+	}
+	if kind == typeExtended {
+		if offset >= len(d.buf) {
+			return 0, 0, 0, fmt.Errorf("%w: tipo truncado", ErrInvalidDatabase)
+		}
+		kind = 7 + int(d.buf[offset])
+		offset++
+	}
+
+	size = int(control & 0x1F)
+	if size >= 29 {
+		extra := size - 28 // 29 → 1 byte más, 30 → 2, 31 → 3
+		if offset+extra > len(d.buf) {
+			return 0, 0, 0, fmt.Errorf("%w: tamaño truncado", ErrInvalidDatabase)
+		}
+		value := int(readUint(d.buf[offset : offset+extra]))
+		switch size {
+		case 29:
+			size = 29 + value
+		case 30:
+			size = 285 + value
+		default:
+			size = 65821 + value
+		}
+		offset += extra
+	}
+	return kind, size, offset, nil
+}
+
+// pointer calcula el destino de un puntero: 'bits' son los 5 bits bajos del byte de control (SS VVV)
+func (d decoder) pointer(bits int, offset int) (target int, next int, err error) {
+	length := (bits >> 3) + 1
+	if offset+length > len(d.buf) {
+		return 0, 0, fmt.Errorf("%w: puntero truncado", ErrInvalidDatabase)
+	}
+	raw := int(readUint(d.buf[offset : offset+length]))
+	value := bits & 0x7
+	switch length {
+	case 1:
+		target = value<<8 | raw
+	case 2:
+		target = (value<<16 | raw) + 2048
+	case 3:
+		target = (value<<24 | raw) + 526336
+	default:
+		target = raw
+	}
+	return target, offset + length, nil
+}
+
+// readUint lee un entero sin signo big-endian de hasta 8 bytes
+func readUint(raw []byte) uint64 {
+	var value uint64
+	for _, b := range raw {
+		value = value<<8 | uint64(b)
+	}
+	return value
+}
+
+func asUint(value any) uint64 {
+	number, _ := value.(uint64)
+	return number
+}
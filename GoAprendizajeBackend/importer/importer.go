@@ -0,0 +1,224 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"go-aprendizaje/core"
+	"go-aprendizaje/database"
+	"go-aprendizaje/models"
+	"go-aprendizaje/security"
+	"io"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Record es un usuario tal como viene del sistema antiguo (una fila del CSV o una línea del JSONL)
+type Record struct {
+	Email        string `json:"email"`
+	PasswordHash string `json:"password_hash"`
+	HashType     string `json:"hash_type"` // Opcional: si está vacío se detecta por el formato del hash
+	Role         string `json:"role"`      // Opcional: "user" por defecto
+}
+
+// RowError describe una fila que no se pudo importar
+type RowError struct {
+	Line  int    `json:"line"`
+	Email string `json:"email,omitempty"`
+	Error string `json:"error"`
+}
+
+// Report es el resumen de la importación
+type Report struct {
+	Imported int        `json:"imported"`
+	Skipped  int        `json:"skipped"` // Emails que ya existían
+	Failed   []RowError `json:"failed"`
+}
+
+// ImportUsers lee usuarios en formato "csv" o "jsonl" y los guarda en "postgres" o "mongo".
+// Los hashes se guardan con su marcador de tipo y se re-hashean en el primer login.
+// Una fila con errores no detiene la importación: se anota en el informe y se sigue.
+func ImportUsers(reader io.Reader, format string, store string) (*Report, error) {
+	if store != "postgres" && store != "mongo" {
+		return nil, errors.New("store inválido (usa 'postgres' o 'mongo'): " + store)
+	}
+
+	report := &Report{Failed: []RowError{}}
+
+	// 'handle' procesa cada registro leído, venga del formato que venga
+	handle := func(line int, record Record) {
+		skipped, err := importRecord(record, store)
+		switch {
+		case err != nil:
+			report.Failed = append(report.Failed, RowError{Line: line, Email: record.Email, Error: err.Error()})
+		case skipped:
+			report.Skipped++
+		default:
+			report.Imported++
+		}
+	}
+
+	var err error
+	switch format {
+	case "csv":
+		err = readCSV(reader, handle, report)
+	case "jsonl":
+		err = readJSONL(reader, handle, report)
+	default:
+		return nil, errors.New("formato inválido (usa 'csv' o 'jsonl'): " + format)
+	}
+
+	return report, err
+}
+
+// readCSV lee un CSV con cabecera. Columnas reconocidas: email, password_hash (o password), hash_type, role
+func readCSV(reader io.Reader, handle func(int, Record), report *Report) error {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1 // Permitimos filas con columnas de menos (campos opcionales)
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return errors.New("no se pudo leer la cabecera del CSV: " + err.Error())
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["password_hash"]; !ok {
+		if index, ok := columns["password"]; ok {
+			columns["password_hash"] = index
+		}
+	}
+	if _, ok := columns["email"]; !ok {
+		return errors.New("el CSV no tiene columna 'email'")
+	}
+	if _, ok := columns["password_hash"]; !ok {
+		return errors.New("el CSV no tiene columna 'password_hash'")
+	}
+
+	field := func(row []string, name string) string {
+		index, ok := columns[name]
+		if !ok || index >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[index])
+	}
+
+	line := 1
+	for {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		line++
+		if err != nil {
+			report.Failed = append(report.Failed, RowError{Line: line, Error: err.Error()})
+			continue
+		}
+
+		handle(line, Record{
+			Email:        field(row, "email"),
+			PasswordHash: field(row, "password_hash"),
+			HashType:     field(row, "hash_type"),
+			Role:         field(row, "role"),
+		})
+	}
+}
+
+// readJSONL lee un objeto JSON por línea (las líneas vacías se ignoran)
+func readJSONL(reader io.Reader, handle func(int, Record), report *Report) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			report.Failed = append(report.Failed, RowError{Line: line, Error: "JSON inválido: " + err.Error()})
+			continue
+		}
+		record.Email = strings.TrimSpace(record.Email)
+		handle(line, record)
+	}
+
+	return scanner.Err()
+}
+
+// importRecord valida y guarda un usuario. Devuelve skipped=true si el email ya existía.
+func importRecord(record Record, store string) (bool, error) {
+	if record.Email == "" || !strings.Contains(record.Email, "@") {
+		return false, errors.New("email inválido")
+	}
+	if record.PasswordHash == "" {
+		return false, errors.New("falta el hash de la contraseña")
+	}
+
+	password, err := storedPassword(record)
+	if err != nil {
+		return false, err
+	}
+
+	role := record.Role
+	if role == "" {
+		role = "user"
+	}
+
+	if store == "mongo" {
+		return importMongoUser(record.Email, password, role)
+	}
+	return importPostgresUser(record.Email, password, role)
+}
+
+// storedPassword decide el tipo del hash y devuelve el valor a guardar en la BD
+func storedPassword(record Record) (string, error) {
+	hashType := record.HashType
+	if hashType == "" {
+		detected, err := security.DetectHashType(record.PasswordHash)
+		if err != nil {
+			return "", err
+		}
+		hashType = detected
+	}
+
+	// "" = hash PHC generado por este mismo sistema, se guarda tal cual
+	if hashType == "" {
+		return record.PasswordHash, nil
+	}
+	if !security.IsLegacyHashType(hashType) {
+		return "", errors.New("tipo de hash no soportado: " + hashType)
+	}
+	return security.MarkLegacyHash(hashType, record.PasswordHash), nil
+}
+
+func importPostgresUser(email string, password string, role string) (bool, error) {
+	// Unscoped: un usuario borrado (soft delete) sigue ocupando el email en el índice único
+	var count int64
+	if err := database.DB.Unscoped().Model(&models.User{}).Where("email = ?", email).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+
+	user := models.User{Email: email, Password: password, Role: role}
+	return false, database.DB.Create(&user).Error
+}
+
+func importMongoUser(email string, password string, role string) (bool, error) {
+	user := models.MongoUser{Email: email, Password: password, Role: role}
+
+	_, err := core.MongoUserRepo.CreateUser(&user)
+	if mongo.IsDuplicateKeyError(err) {
+		return true, nil
+	}
+	return false, err
+}
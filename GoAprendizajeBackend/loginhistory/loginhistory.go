@@ -0,0 +1,207 @@
+package loginhistory
+
+import (
+	"archive/zip"
+	"go-aprendizaje/config"
+	"go-aprendizaje/database"
+	"go-aprendizaje/dataexport"
+	"go-aprendizaje/geoip"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/models"
+	"go-aprendizaje/sessions"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Historial de inicios de sesión: cada intento (correcto o fallido) con la fecha, la IP, el dispositivo y
+// la ubicación aproximada. Al guardar un login correcto se marca si viene de un dispositivo nuevo o de
+// un sitio lejos de los anteriores, para avisar al usuario por email.
+
+// locationWindow son los logins correctos más recientes con los que se compara la ubicación de uno nuevo
+const locationWindow = 50
+
+// Attempt es lo que se sabe de un intento de inicio de sesión en el controlador
+type Attempt struct {
+	Store     string // "postgres" o "mongo"
+	UserID    string // Vacío si el email no existe
+	Email     string
+	Method    string // Ver models.SessionMethodPassword...
+	Failure   string // Vacío = login correcto; si no, ver models.LoginFailureUnknownUser...
+	IP        string
+	UserAgent string
+}
+
+// Filter selecciona intentos del historial (los campos vacíos no filtran)
+type Filter struct {
+	Store   string
+	UserID  string
+	Email   string
+	IP      string
+	Success *bool
+}
+
+// Record guarda un intento. Si es correcto, lo compara con los anteriores del usuario y marca
+// NewDevice / UnusualLocation (nunca en el primer login de la cuenta: no hay con qué comparar).
+func Record(attempt Attempt) (*models.LoginAttempt, error) {
+	device := sessions.ParseUserAgent(attempt.UserAgent)
+	entry := &models.LoginAttempt{
+		UserStore:  attempt.Store,
+		UserID:     attempt.UserID,
+		Email:      strings.ToLower(strings.TrimSpace(attempt.Email)),
+		Method:     attempt.Method,
+		Success:    attempt.Failure == "",
+		Failure:    attempt.Failure,
+		IP:         attempt.IP,
+		UserAgent:  strings.ToValidUTF8(truncate(attempt.UserAgent, 512), ""),
+		Browser:    device.Browser,
+		OS:         device.OS,
+		DeviceType: device.Type,
+		DeviceKey:  deviceKey(device),
+	}
+	location, located := geoip.Lookup(attempt.IP)
+	if located {
+		entry.Country = location.Country
+		entry.City = location.City
+		entry.Latitude = &location.Latitude
+		entry.Longitude = &location.Longitude
+	}
+
+	if entry.Success && entry.UserID != "" {
+		previous := func() *gorm.DB {
+			return database.DB.Model(&models.LoginAttempt{}).
+				Where("user_store = ? AND user_id = ? AND success = ?", entry.UserStore, entry.UserID, true)
+		}
+
+		// 1. ¿Hay logins anteriores? Si no, es el primero y no se avisa de nada
+		var count int64
+		if err := previous().Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			// 2. Dispositivo nuevo: ningún login correcto anterior con el mismo navegador y sistema
+			var sameDevice int64
+			if err := previous().Where("device_key = ?", entry.DeviceKey).Count(&sameDevice).Error; err != nil {
+				return nil, err
+			}
+			entry.NewDevice = sameDevice == 0
+
+			// 3. Ubicación inusual: lejos de TODOS los últimos logins localizados
+			if located {
+				var recent []models.LoginAttempt
+				err := previous().Where("latitude IS NOT NULL AND longitude IS NOT NULL").
+					Order("created_at desc").Limit(locationWindow).Find(&recent).Error
+				if err != nil {
+					return nil, err
+				}
+				entry.UnusualLocation = farFromAll(location, recent)
+			}
+		}
+	}
+
+	if err := database.DB.Create(entry).Error; err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// List devuelve una página del historial (lo más reciente primero) y el total
+func List(filter Filter, limit int, offset int) ([]models.LoginAttempt, int64, error) {
+	query := database.DB.Model(&models.LoginAttempt{})
+	if filter.Store != "" {
+		query = query.Where("user_store = ?", filter.Store)
+	}
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Email != "" {
+		query = query.Where("email = ?", strings.ToLower(strings.TrimSpace(filter.Email)))
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+	if filter.Success != nil {
+		query = query.Where("success = ?", *filter.Success)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var attempts []models.LoginAttempt
+	err := query.Order("created_at desc").Limit(limit).Offset(offset).Find(&attempts).Error
+	return attempts, total, err
+}
+
+// DeleteUserHistory borra el historial de un usuario (al borrar su cuenta), también los intentos
+// fallidos con su email de antes de que existiera la cuenta
+func DeleteUserHistory(store string, userID string, email string) error {
+	return database.DB.
+		Where("(user_store = ? AND user_id = ?) OR email = ?", store, userID, strings.ToLower(strings.TrimSpace(email))).
+		Delete(&models.LoginAttempt{}).Error
+}
+
+// StartJanitor arranca en segundo plano el borrado de los intentos de hace más de LOGIN_HISTORY_RETENTION
+func StartJanitor(every time.Duration) {
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for range ticker.C {
+			retention, err := time.ParseDuration(config.GetEnv("LOGIN_HISTORY_RETENTION", "2160h"))
+			if err != nil || retention <= 0 {
+				retention = 90 * 24 * time.Hour
+			}
+			result := database.DB.Where("created_at < ?", time.Now().Add(-retention)).Delete(&models.LoginAttempt{})
+			if result.RowsAffected > 0 {
+				logging.Log.Infof("Intentos de inicio de sesión antiguos borrados: %d", result.RowsAffected)
+			}
+		}
+	}()
+}
+
+// ExportSection añade el historial de inicios de sesión a la exportación de datos personales (RGPD)
+func ExportSection(zipWriter *zip.Writer, export *models.DataExport) error {
+	var attempts []models.LoginAttempt
+	database.DB.Where("user_store = ? AND user_id = ?", export.UserStore, export.UserID).Order("created_at").Find(&attempts)
+	return dataexport.WriteJSON(zipWriter, "login_history.json", map[string]interface{}{"login_history": attempts})
+}
+
+// farFromAll indica si 'location' está a más de LOGIN_ALERT_DISTANCE_KM de todos los logins anteriores
+// (false si ninguno tiene ubicación: no hay con qué comparar)
+func farFromAll(location geoip.Location, previous []models.LoginAttempt) bool {
+	if len(previous) == 0 {
+		return false
+	}
+	threshold := float64(config.GetEnvInt("LOGIN_ALERT_DISTANCE_KM", 500))
+	for _, attempt := range previous {
+		if geoip.DistanceKm(*attempt.Latitude, *attempt.Longitude, location.Latitude, location.Longitude) <= threshold {
+			return false
+		}
+	}
+	return true
+}
+
+// deviceKey identifica el dispositivo sin las versiones (ej: "Chrome|Windows|desktop"),
+// para que actualizar el navegador o el sistema no cuente como un dispositivo nuevo
+func deviceKey(device sessions.Device) string {
+	return withoutVersion(device.Browser) + "|" + withoutVersion(device.OS) + "|" + device.Type
+}
+
+// withoutVersion quita el número de versión del final (ej: "Samsung Internet 25" → "Samsung Internet")
+func withoutVersion(name string) string {
+	if space := strings.LastIndex(name, " "); space > 0 {
+		if _, err := strconv.ParseFloat(name[space+1:], 64); err == nil {
+			return name[:space]
+		}
+	}
+	return name
+}
+
+func truncate(value string, max int) string {
+	if len(value) > max {
+		return value[:max]
+	}
+	return value
+}
@@ -0,0 +1,71 @@
+package mailer
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CapturedMessage es un email guardado por el CaptureMailer
+type CapturedMessage struct {
+	ID     int       `json:"id"`
+	SentAt time.Time `json:"sent_at"`
+	Message
+}
+
+// CaptureMailer guarda los emails en memoria en lugar de enviarlos.
+// Sirve para desarrollo (se ven en GET /api/dev/mail) y para comprobar en tests qué se envió.
+type CaptureMailer struct {
+	mu       sync.Mutex
+	limit    int
+	nextID   int
+	messages []CapturedMessage
+}
+
+// NewCaptureMailer crea el transporte; guarda como mucho 'limit' emails (los más antiguos se descartan)
+func NewCaptureMailer(limit int) *CaptureMailer {
+	if limit <= 0 {
+		limit = 100
+	}
+	return &CaptureMailer{limit: limit, nextID: 1}
+}
+
+func (m *CaptureMailer) Name() string { return "capture" }
+
+func (m *CaptureMailer) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, CapturedMessage{ID: m.nextID, SentAt: time.Now(), Message: msg})
+	m.nextID++
+	if len(m.messages) > m.limit {
+		m.messages = m.messages[len(m.messages)-m.limit:]
+	}
+	return nil
+}
+
+// Messages devuelve una copia de los emails capturados, del más antiguo al más reciente
+func (m *CaptureMailer) Messages() []CapturedMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]CapturedMessage(nil), m.messages...)
+}
+
+// Get devuelve un email capturado por su ID
+func (m *CaptureMailer) Get(id int) (CapturedMessage, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, message := range m.messages {
+		if message.ID == id {
+			return message, true
+		}
+	}
+	return CapturedMessage{}, false
+}
+
+// Clear borra todos los emails capturados
+func (m *CaptureMailer) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = nil
+}
@@ -0,0 +1,44 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FileMailer no envía nada: guarda cada email como un archivo .eml en una carpeta
+// (se abren con cualquier cliente de correo; útil en desarrollo o para que otro proceso los recoja)
+type FileMailer struct {
+	Dir string
+}
+
+// NewFileMailer crea el transporte y la carpeta si no existe
+func NewFileMailer(dir string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileMailer{Dir: dir}, nil
+}
+
+func (m *FileMailer) Name() string { return "file" }
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	var message bytes.Buffer
+	if err := writeMIME(&message, msg); err != nil {
+		return err
+	}
+
+	// Nombre ordenable por fecha y único; se escribe a un temporal y se renombra
+	// para que quien vigile la carpeta nunca vea un email a medias
+	name := strconv.FormatInt(time.Now().UnixNano(), 10) + "-" + uuid.New().String() + ".eml"
+	temp := filepath.Join(m.Dir, "."+name+".tmp")
+	if err := os.WriteFile(temp, message.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(temp, filepath.Join(m.Dir, name))
+}
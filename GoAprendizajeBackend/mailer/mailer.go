@@ -0,0 +1,80 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"go-aprendizaje/config"
+	"io"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+// Message es un email listo para enviar
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	HTML    string
+	Text    string // Versión en texto plano (opcional): se envía como alternativa al HTML
+	Kind    string // Solo para los logs y la captura (ej: "bienvenida")
+}
+
+// Mailer es la interfaz común a todas las formas de enviar email (SMTP, sendmail, archivos, captura en memoria).
+// La bandeja de salida (paquete outbox) la usa para entregar los emails.
+type Mailer interface {
+	// Name es el identificador del transporte ("smtp", "sendmail", "file", "capture")
+	Name() string
+	// Send entrega el mensaje. Un error hace que la bandeja de salida lo reintente más tarde.
+	Send(ctx context.Context, msg Message) error
+}
+
+// New crea el transporte indicado en MAIL_TRANSPORT
+func New(transport string) (Mailer, error) {
+	switch transport {
+	case "smtp", "":
+		return &SMTPMailer{
+			Host:     config.GetEnv("EMAIL_HOST", "smtp.gmail.com"),
+			Port:     config.GetEnvInt("EMAIL_PORT", 587),
+			User:     config.GetEnv("EMAIL_USER", ""),
+			Password: config.GetEnv("EMAIL_PASSWORD", ""),
+		}, nil
+	case "sendmail":
+		return &SendmailMailer{Path: config.GetEnv("SENDMAIL_PATH", "/usr/sbin/sendmail")}, nil
+	case "file":
+		return NewFileMailer(config.GetEnv("MAIL_DROP_PATH", "./mail"))
+	case "capture":
+		return NewCaptureMailer(config.GetEnvInt("MAIL_CAPTURE_LIMIT", 100)), nil
+	default:
+		return nil, fmt.Errorf("transporte de email desconocido: %s", transport)
+	}
+}
+
+// DefaultFrom es el remitente de los emails (MAIL_FROM, o el usuario SMTP como hasta ahora)
+func DefaultFrom() string {
+	return config.GetEnv("MAIL_FROM", config.GetEnv("EMAIL_USER", ""))
+}
+
+// writeMIME escribe el mensaje en formato RFC 5322 (cabeceras + cuerpo HTML codificado),
+// que es lo que esperan sendmail y los clientes de correo al abrir un .eml
+func writeMIME(w io.Writer, msg Message) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", msg.From)
+	m.SetHeader("To", msg.To)
+	m.SetHeader("Subject", msg.Subject)
+	m.SetDateHeader("Date", time.Now())
+	setBody(m, msg)
+	_, err := m.WriteTo(w)
+	return err
+}
+
+// setBody pone el cuerpo del mensaje: texto plano + HTML como alternativas (multipart/alternative),
+// o solo HTML si no hay versión en texto
+func setBody(m *gomail.Message, msg Message) {
+	if msg.Text == "" {
+		m.SetBody("text/html", msg.HTML)
+		return
+	}
+	m.SetBody("text/plain", msg.Text)
+	m.AddAlternative("text/html", msg.HTML)
+}
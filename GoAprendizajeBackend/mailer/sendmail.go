@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// SendmailMailer entrega los emails al "sendmail" del sistema (Postfix, Exim, msmtp...),
+// que se encarga de la cola y los reintentos hacia fuera
+type SendmailMailer struct {
+	Path string
+}
+
+func (m *SendmailMailer) Name() string { return "sendmail" }
+
+func (m *SendmailMailer) Send(ctx context.Context, msg Message) error {
+	var message bytes.Buffer
+	if err := writeMIME(&message, msg); err != nil {
+		return err
+	}
+
+	// -i: un "." en una línea no termina el mensaje; -t: los destinatarios se leen de las cabeceras
+	cmd := exec.CommandContext(ctx, m.Path, "-i", "-t")
+	cmd.Stdin = &message
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("sendmail: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
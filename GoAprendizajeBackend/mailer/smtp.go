@@ -0,0 +1,30 @@
+package mailer
+
+import (
+	"context"
+
+	"gopkg.in/gomail.v2"
+)
+
+// SMTPMailer envía los emails a un servidor SMTP (Gmail, SES, Mailpit...)
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+}
+
+func (m *SMTPMailer) Name() string { return "smtp" }
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	// 1. Crear el mensaje (el correo)
+	message := gomail.NewMessage()
+	message.SetHeader("From", msg.From) // De: tu-correo@gmail.com
+	message.SetHeader("To", msg.To)     // Para: el-nuevo-usuario@dominio.com
+	message.SetHeader("Subject", msg.Subject)
+	setBody(message, msg)
+
+	// 2. Conectarse al servidor SMTP (host, puerto, usuario, contraseña) y enviar
+	dialer := gomail.NewDialer(m.Host, m.Port, m.User, m.Password)
+	return dialer.DialAndSend(message)
+}
@@ -1,13 +1,28 @@
 package main
 
 import (
+	"go-aprendizaje/accountdeletion"
+	"go-aprendizaje/apikeys"
+	"go-aprendizaje/commands"
 	"go-aprendizaje/config"
 	"go-aprendizaje/core"
 	"go-aprendizaje/database"
+	"go-aprendizaje/dataexport"
+	"go-aprendizaje/geoip"
 	"go-aprendizaje/logging"
+	"go-aprendizaje/loginhistory"
+	"go-aprendizaje/media"
 	"go-aprendizaje/middleware"
+	"go-aprendizaje/oauth"
+	"go-aprendizaje/outbox"
+	"go-aprendizaje/passwordless"
 	"go-aprendizaje/routes"
+	"go-aprendizaje/security"
+	"go-aprendizaje/sessions"
+	"go-aprendizaje/tus"
 	"log"
+	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -28,6 +43,93 @@ func main() {
 	// Inicializar los repositorios globales de MongoDB
 	core.InitMongoRepositories()
 
+	// Inicializar el almacenamiento de archivos (disco local o S3)
+	core.InitFileStorage()
+
+	// Inicializar el envío de emails (SMTP, sendmail, archivos o captura en memoria)
+	core.InitMailer()
+
+	// Inicializar el antivirus de los archivos subidos (opcional)
+	core.InitFileScanner()
+
+	// Inicializar los límites de las imágenes subidas
+	media.InitImageLimits()
+
+	// Inicializar la geolocalización de IPs (opcional, con una base de datos MaxMind descargada)
+	geoip.Init()
+
+	// Inicializar el hasher y la política de contraseñas
+	security.InitPasswordHasher()
+	security.InitPasswordPolicy()
+
+	// Inicializar la clave de las URLs firmadas de los archivos privados
+	security.InitURLSigner()
+
+	// Inicializar la clave de los tokens CSRF del modo cookie
+	security.InitCSRF()
+
+	// Inicializar la clave de firma de los tokens del servidor OAuth / OpenID Connect
+	oauth.Init()
+
+	// Si se pasa un subcomando (ej: ./main import-users -file usuarios.csv) se ejecuta y se termina
+	if len(os.Args) > 1 {
+		os.Exit(commands.Run(os.Args[1:]))
+	}
+
+	// Inicializar el backend del rate limiter
+	core.InitRateLimitStore()
+
+	// Enviar los emails de la bandeja de salida (con reintentos)
+	outboxPoll, _ := time.ParseDuration(config.GetEnv("OUTBOX_POLL_INTERVAL", "5s"))
+	if outboxPoll <= 0 {
+		outboxPoll = 5 * time.Second
+	}
+	outbox.StartWorkers(config.GetEnvInt("OUTBOX_WORKERS", 4), outboxPoll)
+
+	// Encolar las bienvenidas de usuarios de Mongo que se quedaron sin encolar (ver outbox/mongoWelcome.go)
+	outbox.StartMongoWelcomeRelay(time.Minute)
+
+	// Borrar periódicamente las exportaciones de datos caducadas
+	dataexport.StartJanitor(time.Hour)
+
+	// Ejecutar periódicamente los borrados de cuentas cuyo periodo de gracia terminó
+	accountdeletion.StartWorker(time.Hour)
+
+	// Borrar periódicamente las fotos de perfil huérfanas
+	gcInterval, _ := time.ParseDuration(config.GetEnv("STORAGE_GC_INTERVAL", "24h"))
+	gcMinAge, _ := time.ParseDuration(config.GetEnv("STORAGE_GC_MIN_AGE", "1h"))
+	if gcInterval > 0 {
+		media.StartGarbageCollector(gcInterval, gcMinAge)
+	}
+
+	// Analizar los archivos en cuarentena (y reintentar los que fallaron)
+	scanRetry, _ := time.ParseDuration(config.GetEnv("SCANNER_RETRY_INTERVAL", "1m"))
+	if scanRetry <= 0 {
+		scanRetry = time.Minute
+	}
+	media.StartScanWorker(scanRetry)
+
+	// Borrar periódicamente las subidas reanudables que se quedaron a medias
+	tus.StartJanitor(core.FileStorage, time.Hour)
+
+	// Borrar periódicamente las sesiones antiguas (y añadirlas a la exportación de datos personales)
+	sessions.StartJanitor(time.Hour)
+	dataexport.RegisterSection(sessions.ExportSection)
+
+	// Borrar periódicamente el historial de inicios de sesión antiguo (y añadirlo a la exportación de datos personales)
+	loginhistory.StartJanitor(time.Hour)
+	dataexport.RegisterSection(loginhistory.ExportSection)
+
+	// Añadir las API keys (sin los hashes) a la exportación de datos personales
+	dataexport.RegisterSection(apikeys.ExportSection)
+
+	// Borrar periódicamente los enlaces/códigos de inicio de sesión sin contraseña caducados
+	passwordless.StartJanitor(time.Hour)
+
+	// Borrar periódicamente los códigos y tokens de OAuth caducados (y añadir las aplicaciones autorizadas a la exportación)
+	oauth.StartJanitor(time.Hour)
+	dataexport.RegisterSection(oauth.ExportSection)
+
 	// Configurar el router
 	router := gin.Default()
 	router.Use(middleware.SetupCorsConfig())
@@ -0,0 +1,99 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"go-aprendizaje/config"
+	"go-aprendizaje/database"
+	"go-aprendizaje/models"
+	"go-aprendizaje/storage"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// PrivatePrefix es la "carpeta" de los archivos privados: private/<store>/<userID>/<uuid><ext>
+// (/static nunca sirve nada de aquí)
+const PrivatePrefix = "private/"
+
+// documentTypes son los tipos permitidos en los archivos privados (documentos de identidad, etc.)
+var documentTypes = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+}
+
+// Document es un archivo privado ya validado, listo para guardar
+type Document struct {
+	Data        []byte
+	ContentType string
+	Extension   string
+}
+
+// DocumentMaxBytes es el tamaño máximo de un archivo privado
+func DocumentMaxBytes() int64 {
+	return int64(config.GetEnvInt("PRIVATE_FILE_MAX_BYTES", 10*1024*1024))
+}
+
+// ProcessDocument valida un archivo privado por su contenido.
+// Las imágenes se vuelven a codificar (igual que las fotos de perfil) para quitar el EXIF/GPS;
+// los PDF se guardan tal cual (se sirven siempre como descarga y con "nosniff").
+func ProcessDocument(r io.Reader) (*Document, error) {
+	maxBytes := DocumentMaxBytes()
+
+	data, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, ErrTooLarge
+	}
+
+	contentType := mimetype.Detect(data).String()
+	extension, ok := documentTypes[contentType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+
+	if strings.HasPrefix(contentType, "image/") {
+		img, _, err := decodeImage(data)
+		if err != nil {
+			return nil, err
+		}
+		if contentType == "image/jpeg" {
+			img = applyOrientation(img, jpegOrientation(data))
+		}
+		if data, err = encodeImage(img, contentType); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Document{Data: data, ContentType: contentType, Extension: extension}, nil
+}
+
+// IsPublicKey indica si la clave se puede servir en /static: solo las fotos de perfil.
+// Todo lo demás (archivos privados, cuarentena, trozos de subidas...) nunca es público.
+func IsPublicKey(key string) bool {
+	return isProfilePictureKey(key)
+}
+
+// PrivateKey genera la clave de un archivo privado del usuario
+func PrivateKey(store string, userID string, name string) string {
+	return PrivatePrefix + store + "/" + userID + "/" + path.Base(name)
+}
+
+// DeleteUserFiles borra del almacenamiento y del registro todos los archivos del usuario (al borrar la cuenta)
+func DeleteUserFiles(ctx context.Context, store storage.Storage, userStore string, userID string) error {
+	var files []models.StoredFile
+	database.DB.Where("user_store = ? AND user_id = ?", userStore, userID).Find(&files)
+
+	for _, file := range files {
+		if err := DeleteWithVariants(ctx, store, file.Key); err != nil {
+			return err
+		}
+		ForgetFile(file.Key)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+// jpegOrientation lee la etiqueta "Orientation" (0x0112) del bloque EXIF de un JPEG.
+// Devuelve 1 (sin rotación) si no hay EXIF o no se puede leer.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	// Recorremos los segmentos del JPEG hasta encontrar APP1 ("Exif\0\0")
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xFF { // Byte de relleno
+			i++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 { // Empiezan los datos de la imagen: ya no hay más metadatos
+			return 1
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// tiffOrientation busca la orientación en el primer directorio (IFD0) de la cabecera TIFF del EXIF
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+
+	entries := int(order.Uint16(tiff[offset:]))
+	for n := 0; n < entries; n++ {
+		entry := offset + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
+
+// applyOrientation gira/voltea la imagen según la orientación EXIF (1-8)
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// De la 5 a la 8 la imagen se gira 90º: se intercambian ancho y alto
+	outWidth, outHeight := width, height
+	if orientation >= 5 {
+		outWidth, outHeight = height, width
+	}
+	out := image.NewRGBA(image.Rect(0, 0, outWidth, outHeight))
+
+	for y := 0; y < outHeight; y++ {
+		for x := 0; x < outWidth; x++ {
+			// Para cada píxel del resultado, calculamos de qué píxel del original viene
+			var srcX, srcY int
+			switch orientation {
+			case 2: // Volteo horizontal
+				srcX, srcY = width-1-x, y
+			case 3: // Giro de 180º
+				srcX, srcY = width-1-x, height-1-y
+			case 4: // Volteo vertical
+				srcX, srcY = x, height-1-y
+			case 5: // Trasposición
+				srcX, srcY = y, x
+			case 6: // Giro de 90º en sentido horario
+				srcX, srcY = y, height-1-x
+			case 7: // Trasposición inversa
+				srcX, srcY = width-1-y, height-1-x
+			case 8: // Giro de 90º en sentido antihorario
+				srcX, srcY = width-1-y, x
+			}
+			out.Set(x, y, img.At(bounds.Min.X+srcX, bounds.Min.Y+srcY))
+		}
+	}
+	return out
+}
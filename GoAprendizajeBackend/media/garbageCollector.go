@@ -0,0 +1,126 @@
+package media
+
+import (
+	"context"
+	"go-aprendizaje/core"
+	"go-aprendizaje/database"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/models"
+	"go-aprendizaje/storage"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// GCReport es el resumen de una pasada del recolector de basura
+type GCReport struct {
+	Scanned      int      `json:"scanned"`
+	Kept         int      `json:"kept"`
+	Deleted      []string `json:"deleted"` // En modo "dry run": los que se borrarían
+	DeletedBytes int64    `json:"deleted_bytes"`
+	Failed       []string `json:"failed"`
+}
+
+// CollectGarbage borra las fotos de perfil que ya no usa nadie (ni en Postgres ni en Mongo).
+// Los archivos más recientes que 'minAge' no se tocan: pueden ser subidas que aún no se guardaron en la BD.
+// Con 'dryRun' solo informa de lo que borraría.
+func CollectGarbage(ctx context.Context, store storage.Storage, minAge time.Duration, dryRun bool) (*GCReport, error) {
+	report := &GCReport{Deleted: []string{}, Failed: []string{}}
+
+	// 1. Claves usadas por algún usuario
+	referenced, err := referencedKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Recorrer las fotos de perfil (y las de antes, que estaban en la raíz sin carpeta)
+	cutoff := time.Now().Add(-minAge)
+	err = store.Walk(ctx, "", func(info storage.ObjectInfo) error {
+		if !isProfilePictureKey(info.Key) {
+			return nil
+		}
+		report.Scanned++
+
+		// Una miniatura se conserva si su original está en uso (sea cual sea su tamaño)
+		original := info.Key
+		if match := variantPattern.FindStringSubmatch(info.Key); match != nil {
+			original = match[1] + match[3]
+		}
+		if referenced[info.Key] || referenced[original] || info.ModTime.After(cutoff) {
+			report.Kept++
+			return nil
+		}
+
+		if !dryRun {
+			if err := store.Delete(ctx, info.Key); err != nil {
+				report.Failed = append(report.Failed, info.Key)
+				return nil
+			}
+			ForgetFile(info.Key)
+		}
+		report.Deleted = append(report.Deleted, info.Key)
+		report.DeletedBytes += info.Size
+		return nil
+	})
+
+	return report, err
+}
+
+// StartGarbageCollector lanza una goroutine que ejecuta el recolector cada cierto tiempo
+func StartGarbageCollector(every time.Duration, minAge time.Duration) {
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for range ticker.C {
+			report, err := CollectGarbage(context.Background(), core.FileStorage, minAge, false)
+			if err != nil {
+				logging.Log.Errorf("Error en el recolector de archivos: %v", err)
+				continue
+			}
+			if len(report.Deleted) > 0 || len(report.Failed) > 0 {
+				logging.Log.Infof("Recolector de archivos: %d borrados (%d bytes), %d con errores",
+					len(report.Deleted), report.DeletedBytes, len(report.Failed))
+			}
+		}
+	}()
+}
+
+// referencedKeys devuelve las claves de todas las fotos de perfil en uso (normalizadas)
+func referencedKeys() (map[string]bool, error) {
+	referenced := make(map[string]bool)
+	add := func(stored string) {
+		if key, err := storage.CleanKey(stored); err == nil {
+			referenced[key] = true
+		}
+	}
+
+	// Postgres (Unscoped: un usuario con "soft delete" aún se puede restaurar)
+	var paths []string
+	err := database.DB.Unscoped().Model(&models.User{}).
+		Where("profile_image_path IS NOT NULL AND profile_image_path <> ''").
+		Pluck("profile_image_path", &paths).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		add(path)
+	}
+
+	// Mongo
+	err = core.MongoUserRepo.StreamUsers(bson.M{"profile_image_path": bson.M{"$exists": true, "$ne": ""}}, func(user *models.MongoUser) error {
+		add(user.ProfileImagePath)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return referenced, nil
+}
+
+// isProfilePictureKey indica si la clave es de una foto de perfil:
+// las actuales van en "profile-pictures/" y las antiguas en la raíz (sin carpeta)
+func isProfilePictureKey(key string) bool {
+	return strings.HasPrefix(key, storage.ProfilePicturesPrefix) || !strings.Contains(key, "/")
+}
@@ -0,0 +1,182 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go-aprendizaje/config"
+	"image"
+	_ "image/gif" // Registra el decodificador de GIF en el paquete "image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// Errores de validación (el controlador los traduce a códigos HTTP)
+var (
+	ErrTooLarge        = errors.New("el archivo supera el tamaño máximo permitido")
+	ErrUnsupportedType = errors.New("tipo de archivo no permitido")
+	ErrInvalidImage    = errors.New("el archivo no es una imagen válida")
+	ErrTooManyPixels   = errors.New("la imagen tiene demasiados píxeles")
+)
+
+// ImageLimits son las restricciones que se aplican a las imágenes subidas
+type ImageLimits struct {
+	MaxBytes     int64    // Tamaño máximo del archivo subido
+	MaxDimension int      // Ancho y alto máximos (en píxeles)
+	MaxPixels    int64    // Ancho x alto máximo: evita las "bombas de descompresión"
+	AllowedTypes []string // Tipos MIME permitidos (detectados por el contenido, no por la extensión)
+	JPEGQuality  int      // Calidad al volver a codificar los JPEG
+
+	UserQuotaBytes int64 // Espacio máximo por usuario (0 = sin límite)
+	ThumbnailSizes []int // Tamaños (en píxeles, cuadrados) de las miniaturas que se generan al subir
+}
+
+// supportedTypes son los formatos que sabemos decodificar y volver a codificar
+var supportedTypes = map[string]bool{"image/jpeg": true, "image/png": true, "image/gif": true}
+
+// Limits son los límites globales (se inicializan con InitImageLimits desde 'main.go')
+var Limits *ImageLimits
+
+// InitImageLimits carga los límites desde el .env
+func InitImageLimits() {
+	Limits = &ImageLimits{
+		MaxBytes:     int64(config.GetEnvInt("UPLOAD_MAX_BYTES", 5*1024*1024)),
+		MaxDimension: config.GetEnvInt("UPLOAD_IMAGE_MAX_DIMENSION", 6000),
+		MaxPixels:    int64(config.GetEnvInt("UPLOAD_IMAGE_MAX_PIXELS", 24_000_000)),
+		JPEGQuality:  config.GetEnvInt("UPLOAD_JPEG_QUALITY", 90),
+
+		UserQuotaBytes: int64(config.GetEnvInt("UPLOAD_USER_QUOTA_BYTES", 20*1024*1024)),
+	}
+
+	for _, contentType := range strings.Split(config.GetEnv("UPLOAD_ALLOWED_TYPES", "image/jpeg,image/png,image/gif"), ",") {
+		contentType = strings.TrimSpace(contentType)
+		if !supportedTypes[contentType] {
+			log.Fatal("Error fatal: Tipo de imagen no soportado en UPLOAD_ALLOWED_TYPES: ", contentType)
+		}
+		Limits.AllowedTypes = append(Limits.AllowedTypes, contentType)
+	}
+
+	for _, value := range strings.Split(config.GetEnv("AVATAR_SIZES", "64,128,256"), ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || size <= 0 {
+			log.Fatal("Error fatal: Tamaño de miniatura inválido en AVATAR_SIZES: ", value)
+		}
+		Limits.ThumbnailSizes = append(Limits.ThumbnailSizes, size)
+	}
+
+	log.Printf("Límites de subida inicializados: %d bytes, tipos %v", Limits.MaxBytes, Limits.AllowedTypes)
+}
+
+// Image es una imagen ya validada y "limpia", lista para guardar
+type Image struct {
+	Data        []byte
+	ContentType string
+	Extension   string // Con punto, ej: ".png" (se deduce del contenido, nunca del nombre del archivo)
+	Width       int
+	Height      int
+	Decoded     image.Image // La imagen ya decodificada (para generar las miniaturas sin volver a decodificar)
+}
+
+// ProcessImage valida una imagen subida y la vuelve a codificar desde cero.
+// Al re-codificar se pierden los metadatos (EXIF, GPS...) y cualquier contenido "escondido"
+// en el archivo (ej: un HTML o un ZIP pegado detrás de la imagen).
+func ProcessImage(r io.Reader) (*Image, error) {
+	// 1. Leer como máximo MaxBytes (+1 para saber si se pasó)
+	data, err := io.ReadAll(io.LimitReader(r, Limits.MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > Limits.MaxBytes {
+		return nil, ErrTooLarge
+	}
+
+	// 2. Detectar el tipo por el contenido (la extensión y el Content-Type del cliente no son fiables)
+	detected := mimetype.Detect(data).String()
+	if !Limits.isAllowed(detected) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, detected)
+	}
+
+	// 3. Decodificar comprobando antes las dimensiones
+	img, format, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+	if "image/"+format != detected {
+		return nil, ErrInvalidImage
+	}
+
+	// 4. Aplicar la orientación EXIF antes de perderla, para que las fotos del móvil no salgan giradas
+	if format == "jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	// 5. Volver a codificar. Los GIF se guardan como PNG (estático, sin animación)
+	contentType := "image/png"
+	if format == "jpeg" {
+		contentType = "image/jpeg"
+	}
+	encoded, err := encodeImage(img, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Image{
+		Data:        encoded,
+		ContentType: contentType,
+		Extension:   extensions[contentType],
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+		Decoded:     img,
+	}, nil
+}
+
+// extensions es la extensión de archivo de cada formato que generamos
+var extensions = map[string]string{"image/jpeg": ".jpg", "image/png": ".png"}
+
+// decodeImage lee primero solo la cabecera para conocer las dimensiones ANTES de decodificar
+// (una imagen de 50.000 x 50.000 puede ocupar pocos KB comprimida y varios GB en memoria).
+// En los GIF solo se decodifica el primer fotograma.
+func decodeImage(data []byte) (image.Image, string, error) {
+	imageConfig, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrInvalidImage
+	}
+	if imageConfig.Width <= 0 || imageConfig.Height <= 0 ||
+		imageConfig.Width > Limits.MaxDimension || imageConfig.Height > Limits.MaxDimension ||
+		int64(imageConfig.Width)*int64(imageConfig.Height) > Limits.MaxPixels {
+		return nil, "", ErrTooManyPixels
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrInvalidImage
+	}
+	return img, format, nil
+}
+
+// encodeImage codifica la imagen en JPEG o PNG
+func encodeImage(img image.Image, contentType string) ([]byte, error) {
+	var out bytes.Buffer
+	var err error
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&out, img, &jpeg.Options{Quality: Limits.JPEGQuality})
+	} else {
+		err = png.Encode(&out, img)
+	}
+	return out.Bytes(), err
+}
+
+func (limits *ImageLimits) isAllowed(contentType string) bool {
+	for _, allowed := range limits.AllowedTypes {
+		if allowed == contentType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"go-aprendizaje/config"
+	"go-aprendizaje/core"
+	"go-aprendizaje/database"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/models"
+	"go-aprendizaje/outbox"
+	"go-aprendizaje/scanner"
+	"go-aprendizaje/storage"
+	"go-aprendizaje/utils"
+	"path"
+	"time"
+)
+
+// QuarantinePrefix es la "carpeta" donde esperan los archivos hasta que el antivirus los analiza.
+// Desde aquí no se pueden descargar: solo cuando están limpios se mueven a su sitio definitivo.
+// (Las fotos de perfil no pasan por cuarentena: se reconstruyen desde los píxeles, así que no queda nada ejecutable)
+const QuarantinePrefix = "quarantine/"
+
+// scanQueue son los archivos recién subidos pendientes de analizar.
+// Si se llena (o se reinicia la API), el worker los recoge igualmente de la BD.
+var scanQueue = make(chan uint, 100)
+
+// errNotPending se devuelve si otro worker (u otra réplica) ya analizó el archivo, o el usuario lo borró mientras tanto
+var errNotPending = errors.New("el archivo ya no está pendiente de análisis")
+
+// QuarantineKey genera la clave de un archivo en cuarentena
+func QuarantineKey(name string) string {
+	return QuarantinePrefix + path.Base(name)
+}
+
+// CanDownload indica si un archivo se puede descargar según su análisis
+func CanDownload(file *models.StoredFile) bool {
+	return file.ScanStatus == models.ScanNotScanned || file.ScanStatus == models.ScanClean
+}
+
+// QueueScan pide que se analice un archivo en cuanto sea posible
+func QueueScan(fileID uint) {
+	select {
+	case scanQueue <- fileID:
+	default: // Cola llena: lo recogerá la próxima pasada del worker
+	}
+}
+
+// StartScanWorker lanza una goroutine que analiza los archivos de la cola y,
+// cada 'every', reintenta los que siguen pendientes (ej: clamd estaba caído)
+func StartScanWorker(every time.Duration) {
+	if core.FileScanner == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case fileID := <-scanQueue:
+				var file models.StoredFile
+				if err := database.DB.Where("id = ? AND scan_status = ?", fileID, models.ScanPending).First(&file).Error; err == nil {
+					ScanFile(context.Background(), core.FileStorage, core.FileScanner, &file)
+				}
+			case <-ticker.C:
+				ScanPending(context.Background())
+			}
+		}
+	}()
+}
+
+// ScanPending analiza todos los archivos que siguen en cuarentena. Devuelve cuántos se analizaron.
+func ScanPending(ctx context.Context) int {
+	var pending []models.StoredFile
+	database.DB.Where("scan_status = ?", models.ScanPending).Order("id").Find(&pending)
+
+	scanned := 0
+	for i := range pending {
+		if ScanFile(ctx, core.FileStorage, core.FileScanner, &pending[i]) == nil {
+			scanned++
+		}
+	}
+	return scanned
+}
+
+// ScanFile analiza un archivo en cuarentena y, según el resultado:
+// - limpio: lo mueve a su clave definitiva y ya se puede descargar
+// - infectado: lo borra, guarda la firma y avisa al usuario
+// - error: lo deja en cuarentena para reintentarlo (tras SCANNER_MAX_ATTEMPTS queda como "failed")
+// Actualiza 'file' con el nuevo estado.
+func ScanFile(ctx context.Context, store storage.Storage, fileScanner scanner.Scanner, file *models.StoredFile) error {
+	// 1. Analizar
+	result, err := scanObject(ctx, store, fileScanner, file.Key)
+	if err != nil {
+		file.ScanAttempts++
+		updates := map[string]interface{}{"scan_attempts": file.ScanAttempts}
+		if file.ScanAttempts >= config.GetEnvInt("SCANNER_MAX_ATTEMPTS", 5) {
+			file.ScanStatus = models.ScanFailed
+			updates["scan_status"] = file.ScanStatus
+			logging.Log.Errorf("No se pudo analizar el archivo %d tras %d intentos: %v", file.ID, file.ScanAttempts, err)
+		} else {
+			logging.Log.Warnf("No se pudo analizar el archivo %d (intento %d): %v", file.ID, file.ScanAttempts, err)
+		}
+		database.DB.Model(&models.StoredFile{}).Where("id = ? AND scan_status = ?", file.ID, models.ScanPending).Updates(updates)
+		return err
+	}
+	now := time.Now()
+
+	// 2. Infectado: se borra y se avisa
+	if result.Infected {
+		if err := store.Delete(ctx, file.Key); err != nil {
+			return err
+		}
+		updated := database.DB.Model(&models.StoredFile{}).
+			Where("id = ? AND scan_status = ?", file.ID, models.ScanPending).
+			Updates(map[string]interface{}{
+				"scan_status":    models.ScanInfected,
+				"scan_signature": result.Signature,
+				"scanned_at":     now,
+				"size":           0, // Ya no ocupa espacio en la cuota
+			})
+		if updated.Error != nil {
+			return updated.Error
+		}
+		if updated.RowsAffected == 0 {
+			return errNotPending // Quien lo procesó ya avisó al usuario
+		}
+		file.ScanStatus, file.ScanSignature, file.ScannedAt, file.Size = models.ScanInfected, result.Signature, &now, 0
+
+		logging.Log.Warnf("Malware detectado en el archivo %d de %s/%s (%s): %s", file.ID, file.UserStore, file.UserID, file.FileName, result.Signature)
+		if email, locale := userContact(file.UserStore, file.UserID); email != "" {
+			outbox.Send(utils.InfectedFileEmail(locale, email, file.FileName))
+		}
+		return nil
+	}
+
+	// 3. Limpio: se mueve de la cuarentena a la carpeta privada del usuario
+	finalKey := PrivateKey(file.UserStore, file.UserID, path.Base(file.Key))
+	if err := moveObject(ctx, store, file.Key, finalKey, file.ContentType); err != nil {
+		return err
+	}
+	updated := database.DB.Model(&models.StoredFile{}).
+		Where("id = ? AND scan_status = ?", file.ID, models.ScanPending).
+		Updates(map[string]interface{}{"key": finalKey, "scan_status": models.ScanClean, "scanned_at": now})
+	if updated.Error != nil || updated.RowsAffected == 0 {
+		// Otro worker (u otra réplica) ya lo había procesado, o el usuario lo borró mientras tanto
+		store.Delete(ctx, finalKey)
+		return errNotPending
+	}
+	store.Delete(ctx, file.Key)
+
+	file.Key, file.ScanStatus, file.ScannedAt = finalKey, models.ScanClean, &now
+	return nil
+}
+
+func scanObject(ctx context.Context, store storage.Storage, fileScanner scanner.Scanner, key string) (*scanner.Result, error) {
+	object, _, err := store.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+	return fileScanner.Scan(ctx, object)
+}
+
+// moveObject copia un archivo a otra clave (el almacenamiento no tiene "renombrar": en S3 no existe)
+func moveObject(ctx context.Context, store storage.Storage, from string, to string, contentType string) error {
+	object, info, err := store.Open(ctx, from)
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+	return store.Put(ctx, to, object, info.Size, contentType)
+}
+
+// userContact busca el email y el idioma del dueño de un archivo (email vacío si ya no existe)
+func userContact(store string, userID string) (string, string) {
+	if store == "mongo" {
+		user, err := core.MongoUserRepo.GetUserByID(userID)
+		if err != nil {
+			return "", ""
+		}
+		return user.Email, user.Locale
+	}
+
+	var user models.User
+	if err := database.DB.Select("email", "locale").Where("id = ?", userID).First(&user).Error; err != nil {
+		return "", ""
+	}
+	return user.Email, user.Locale
+}
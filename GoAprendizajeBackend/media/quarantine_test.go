@@ -0,0 +1,66 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"go-aprendizaje/database"
+	"go-aprendizaje/models"
+	"go-aprendizaje/scanner"
+	"go-aprendizaje/storage"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type infectedScanner struct{}
+
+func (infectedScanner) Name() string { return "test" }
+
+func (infectedScanner) Scan(ctx context.Context, r io.Reader) (*scanner.Result, error) {
+	io.Copy(io.Discard, r)
+	return &scanner.Result{Infected: true, Signature: "Win.Test.EICAR_HDB-1"}, nil
+}
+
+// Si otro worker ya marcó el archivo (o el usuario lo borró), no se vuelve a marcar ni a avisar al usuario
+func TestScanFileInfectedAlreadyProcessed(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqlDB.Close()
+	database.DB, err = gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("gorm: %v", err)
+	}
+
+	ctx := context.Background()
+	store := storage.NewLocalStorage(t.TempDir())
+	file := &models.StoredFile{ID: 7, Key: QuarantineKey("virus.exe"), UserStore: "postgres", UserID: "1", Size: 33, ScanStatus: models.ScanPending}
+	if err := store.Put(ctx, file.Key, strings.NewReader("X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR"), -1, ""); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	// El UPDATE solo afecta a archivos aún pendientes: aquí ya no lo estaba (0 filas).
+	// No se espera nada más: ni buscar al usuario ni escribir el email en el outbox.
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "stored_files" SET .* WHERE id = \$\d+ AND scan_status = \$\d+`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), file.ID, models.ScanPending).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err = ScanFile(ctx, store, infectedScanner{}, file)
+	if !errors.Is(err, errNotPending) {
+		t.Fatalf("ScanFile = %v; se esperaba errNotPending", err)
+	}
+	if file.ScanStatus != models.ScanPending {
+		t.Fatalf("file.ScanStatus = %q; no debería cambiar", file.ScanStatus)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,63 @@
+package media
+
+import (
+	"errors"
+	"go-aprendizaje/database"
+	"go-aprendizaje/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrQuotaExceeded se devuelve si el usuario no tiene espacio para el archivo nuevo
+var ErrQuotaExceeded = errors.New("has superado tu cuota de almacenamiento")
+
+// Usage devuelve los bytes que ocupan los archivos del usuario (sin contar 'exceptKey')
+// más los que tiene reservados en subidas reanudables sin terminar
+func Usage(store string, userID string, exceptKey string) int64 {
+	var used int64
+	database.DB.Model(&models.StoredFile{}).
+		Where("user_store = ? AND user_id = ? AND key <> ?", store, userID, exceptKey).
+		Select("COALESCE(SUM(size), 0)").
+		Scan(&used)
+
+	// Las subidas tus a medias cuentan con su tamaño completo: si no, se podría pasar de la cuota
+	// abriendo varias a la vez. Las completas no cuentan (se están guardando y se comprueban ellas mismas)
+	// ni las caducadas (el janitor las borrará).
+	var reserved int64
+	database.DB.Model(&models.TusUpload{}).
+		Where("user_store = ? AND user_id = ? AND upload_offset < length AND expires_at > ?", store, userID, time.Now()).
+		Select("COALESCE(SUM(length), 0)").
+		Scan(&reserved)
+	return used + reserved
+}
+
+// CheckQuota comprueba si caben 'incoming' bytes más.
+// 'replacing' es la clave del archivo que se va a sustituir (no cuenta, porque se borrará).
+func CheckQuota(store string, userID string, incoming int64, replacing string) error {
+	if Limits.UserQuotaBytes <= 0 {
+		return nil // Sin cuota
+	}
+	if Usage(store, userID, replacing)+incoming > Limits.UserQuotaBytes {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// RecordFile registra un archivo nuevo del usuario
+func RecordFile(file *models.StoredFile) error {
+	return database.DB.Create(file).Error
+}
+
+// RecordVariant suma a la cuota una miniatura generada al pedirla: cuenta en el registro de su original.
+// (Si dos peticiones la generan a la vez se suma dos veces: mejor pasarse que dejar espacio sin contar)
+func RecordVariant(originalKey string, size int64) error {
+	return database.DB.Model(&models.StoredFile{}).
+		Where("key = ?", originalKey).
+		UpdateColumn("size", gorm.Expr("size + ?", size)).Error
+}
+
+// ForgetFile quita un archivo del registro (después de borrarlo del almacenamiento)
+func ForgetFile(key string) {
+	database.DB.Where("key = ?", key).Delete(&models.StoredFile{})
+}
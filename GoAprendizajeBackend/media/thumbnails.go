@@ -0,0 +1,256 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"go-aprendizaje/storage"
+	"image"
+	"image/draw"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Las miniaturas se guardan junto a la original con el tamaño en el nombre:
+// "profile-pictures/<uuid>.jpg" → "profile-pictures/<uuid>_128.jpg"
+// Las PNG tienen además una versión WebP: "profile-pictures/<uuid>_128.png.webp"
+// (WebP sin pérdida ocupa menos que PNG, pero más que JPEG: para las JPEG no merece la pena)
+var variantPattern = regexp.MustCompile(`^(.+)_(\d+)(\.(?:jpg|jpeg|png))(\.webp)?$`)
+
+const webpExtension = ".webp"
+
+// VariantKey devuelve la clave de la miniatura de 'size' píxeles de una imagen
+func VariantKey(key string, size int) string {
+	extension := path.Ext(key)
+	return strings.TrimSuffix(key, extension) + "_" + strconv.Itoa(size) + extension
+}
+
+// WebPVariantKey devuelve la clave de la miniatura WebP de 'size' píxeles de una imagen
+func WebPVariantKey(key string, size int) string {
+	return VariantKey(key, size) + webpExtension
+}
+
+// ParseVariantKey hace lo contrario que VariantKey y WebPVariantKey: de la clave de una miniatura
+// saca la original, el tamaño y si es la versión WebP.
+// Solo acepta los tamaños configurados (para que nadie pueda pedir miniaturas de cualquier tamaño).
+func ParseVariantKey(key string) (original string, size int, webp bool, ok bool) {
+	match := variantPattern.FindStringSubmatch(key)
+	if match == nil {
+		return "", 0, false, false
+	}
+	size, err := strconv.Atoi(match[2])
+	if err != nil || !Limits.isThumbnailSize(size) {
+		return "", 0, false, false
+	}
+	original = match[1] + match[3]
+	webp = match[4] != ""
+	if webp && !hasWebPVariants(original) {
+		return "", 0, false, false
+	}
+	return original, size, webp, true
+}
+
+// VariantURLs devuelve la URL de cada miniatura (ej: {"64": "/static/...", "128": ...}).
+// Si la imagen no tiene miniaturas (formatos antiguos), todas apuntan a la original.
+func VariantURLs(key string) map[string]string {
+	if key == "" {
+		return nil
+	}
+
+	hasVariants := contentTypeFor(key) != ""
+	urls := make(map[string]string, len(Limits.ThumbnailSizes))
+	for _, size := range Limits.ThumbnailSizes {
+		if hasVariants {
+			urls[strconv.Itoa(size)] = storage.URL(VariantKey(key, size))
+		} else {
+			urls[strconv.Itoa(size)] = storage.URL(key)
+		}
+	}
+	return urls
+}
+
+// WebPVariantURLs devuelve la URL de cada miniatura WebP (nil si la imagen no las tiene)
+func WebPVariantURLs(key string) map[string]string {
+	if !hasWebPVariants(key) {
+		return nil
+	}
+
+	urls := make(map[string]string, len(Limits.ThumbnailSizes))
+	for _, size := range Limits.ThumbnailSizes {
+		urls[strconv.Itoa(size)] = storage.URL(WebPVariantKey(key, size))
+	}
+	return urls
+}
+
+// GenerateVariants crea y guarda todas las miniaturas (y sus versiones WebP) de una imagen ya decodificada.
+// Devuelve cuántos bytes ocupan en total (para la cuota).
+func GenerateVariants(ctx context.Context, store storage.Storage, key string, img image.Image) (int64, error) {
+	if contentTypeFor(key) == "" {
+		return 0, nil
+	}
+
+	var total int64
+	for _, size := range Limits.ThumbnailSizes {
+		thumbnail := Thumbnail(img, size)
+		written, err := putVariant(ctx, store, key, size, false, thumbnail)
+		total += written
+		if err != nil {
+			return total, err
+		}
+		if !hasWebPVariants(key) {
+			continue
+		}
+		written, err = putVariant(ctx, store, key, size, true, thumbnail)
+		total += written
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// GenerateVariant crea una sola miniatura a partir de la original guardada
+// (se usa cuando se pide una miniatura que aún no existe, ej: fotos subidas antes de que hubiera miniaturas).
+// Devuelve cuántos bytes ocupa (para sumarlos a la cuota del dueño).
+func GenerateVariant(ctx context.Context, store storage.Storage, key string, size int, webp bool) (int64, error) {
+	if contentTypeFor(key) == "" || (webp && !hasWebPVariants(key)) {
+		return 0, storage.ErrNotFound
+	}
+
+	source, _, err := store.Open(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	defer source.Close()
+
+	// Las originales antiguas no pasaron la validación: limitamos lo que leemos y las dimensiones
+	data, err := io.ReadAll(io.LimitReader(source, Limits.MaxBytes*4))
+	if err != nil {
+		return 0, err
+	}
+	img, _, err := decodeImage(data)
+	if err != nil {
+		return 0, err
+	}
+
+	return putVariant(ctx, store, key, size, webp, Thumbnail(img, size))
+}
+
+// putVariant codifica una miniatura (en el formato de la original o en WebP) y la guarda.
+// Devuelve cuántos bytes ocupa.
+func putVariant(ctx context.Context, store storage.Storage, key string, size int, webp bool, thumbnail image.Image) (int64, error) {
+	variantKey, contentType := VariantKey(key, size), contentTypeFor(key)
+	var data []byte
+	if webp {
+		var buf bytes.Buffer
+		if err := EncodeWebP(&buf, thumbnail); err != nil {
+			return 0, err
+		}
+		variantKey, contentType, data = WebPVariantKey(key, size), "image/webp", buf.Bytes()
+	} else {
+		encoded, err := encodeImage(thumbnail, contentType)
+		if err != nil {
+			return 0, err
+		}
+		data = encoded
+	}
+
+	if err := store.Put(ctx, variantKey, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+// DeleteWithVariants borra una imagen y todas sus miniaturas
+func DeleteWithVariants(ctx context.Context, store storage.Storage, key string) error {
+	if err := store.Delete(ctx, key); err != nil {
+		return err
+	}
+	if contentTypeFor(key) == "" {
+		return nil
+	}
+	for _, size := range Limits.ThumbnailSizes {
+		if err := store.Delete(ctx, VariantKey(key, size)); err != nil {
+			return err
+		}
+		if !hasWebPVariants(key) {
+			continue
+		}
+		if err := store.Delete(ctx, WebPVariantKey(key, size)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Thumbnail recorta el centro de la imagen en un cuadrado y lo reduce a 'size' x 'size'
+// (nunca se amplía: si la imagen es más pequeña, se queda con su tamaño)
+func Thumbnail(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	if size > side {
+		size = side
+	}
+
+	// 1. Recorte cuadrado centrado, copiado a RGBA para poder leer los píxeles rápido
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	offset := image.Pt(bounds.Min.X+(bounds.Dx()-side)/2, bounds.Min.Y+(bounds.Dy()-side)/2)
+	draw.Draw(square, square.Bounds(), img, offset, draw.Src)
+
+	// 2. Reducción por "promedio de área": cada píxel del resultado es la media de su bloque en el original
+	out := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0, y1 := y*side/size, (y+1)*side/size
+		for x := 0; x < size; x++ {
+			x0, x1 := x*side/size, (x+1)*side/size
+
+			var r, g, b, a, count uint64
+			for sy := y0; sy < y1; sy++ {
+				row := square.Pix[sy*square.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					pixel := row[sx*4 : sx*4+4]
+					r += uint64(pixel[0])
+					g += uint64(pixel[1])
+					b += uint64(pixel[2])
+					a += uint64(pixel[3])
+					count++
+				}
+			}
+
+			i := y*out.Stride + x*4
+			out.Pix[i] = uint8(r / count)
+			out.Pix[i+1] = uint8(g / count)
+			out.Pix[i+2] = uint8(b / count)
+			out.Pix[i+3] = uint8(a / count)
+		}
+	}
+	return out
+}
+
+// contentTypeFor devuelve el formato de las miniaturas según la extensión de la original
+// ("" si no generamos miniaturas para ese formato)
+func contentTypeFor(key string) string {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".png":
+		return "image/png"
+	}
+	return ""
+}
+
+// hasWebPVariants indica si la imagen tiene miniaturas WebP (solo las PNG, ver variantPattern)
+func hasWebPVariants(key string) bool {
+	return strings.ToLower(path.Ext(key)) == ".png"
+}
+
+func (limits *ImageLimits) isThumbnailSize(size int) bool {
+	for _, thumbnailSize := range limits.ThumbnailSizes {
+		if thumbnailSize == size {
+			return true
+		}
+	}
+	return false
+}
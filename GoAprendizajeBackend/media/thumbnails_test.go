@@ -0,0 +1,102 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"go-aprendizaje/storage"
+	"image"
+	"image/color"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func TestParseVariantKey(t *testing.T) {
+	Limits = &ImageLimits{ThumbnailSizes: []int{64, 128}}
+
+	cases := []struct {
+		key      string
+		original string
+		size     int
+		webp     bool
+		ok       bool
+	}{
+		{"profile-pictures/a_128.png", "profile-pictures/a.png", 128, false, true},
+		{"profile-pictures/a_64.png.webp", "profile-pictures/a.png", 64, true, true},
+		{"profile-pictures/a_64.jpg", "profile-pictures/a.jpg", 64, false, true},
+		{"profile-pictures/a_64.jpg.webp", "", 0, false, false}, // Las JPEG no tienen versión WebP
+		{"profile-pictures/a_100.png", "", 0, false, false},     // Tamaño no configurado
+		{"profile-pictures/a.png", "", 0, false, false},
+	}
+	for _, tc := range cases {
+		original, size, webp, ok := ParseVariantKey(tc.key)
+		if original != tc.original || size != tc.size || webp != tc.webp || ok != tc.ok {
+			t.Errorf("ParseVariantKey(%q) = %q, %d, %v, %v; se esperaba %q, %d, %v, %v",
+				tc.key, original, size, webp, ok, tc.original, tc.size, tc.webp, tc.ok)
+		}
+	}
+}
+
+// Una PNG guarda sus miniaturas también en WebP (y se borran con ella); una JPEG solo en JPEG
+func TestGenerateAndDeleteVariants(t *testing.T) {
+	Limits = &ImageLimits{ThumbnailSizes: []int{16, 32}}
+	store := storage.NewLocalStorage(t.TempDir())
+	ctx := context.Background()
+
+	img := image.NewNRGBA(image.Rect(0, 0, 80, 60))
+	for y := 0; y < 60; y++ {
+		for x := 0; x < 80; x++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 3), uint8(y * 4), 120, 255})
+		}
+	}
+
+	for _, key := range []string{"profile-pictures/foto.png", "profile-pictures/foto.jpg"} {
+		total, err := GenerateVariants(ctx, store, key, img)
+		if err != nil {
+			t.Fatalf("GenerateVariants(%s): %v", key, err)
+		}
+
+		var stored int64
+		for _, size := range Limits.ThumbnailSizes {
+			_, info, err := store.Open(ctx, VariantKey(key, size))
+			if err != nil {
+				t.Fatalf("falta la miniatura %s: %v", VariantKey(key, size), err)
+			}
+			stored += info.Size
+
+			file, info, err := store.Open(ctx, WebPVariantKey(key, size))
+			if !hasWebPVariants(key) {
+				if !errors.Is(err, storage.ErrNotFound) {
+					t.Fatalf("%s no debería tener miniatura WebP (err = %v)", key, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("falta la miniatura %s: %v", WebPVariantKey(key, size), err)
+			}
+			decoded, err := webp.Decode(file)
+			file.Close()
+			if err != nil {
+				t.Fatalf("la miniatura %s no es un WebP válido: %v", WebPVariantKey(key, size), err)
+			}
+			if decoded.Bounds().Dx() != size || decoded.Bounds().Dy() != size {
+				t.Fatalf("la miniatura %s mide %v", WebPVariantKey(key, size), decoded.Bounds())
+			}
+			stored += info.Size
+		}
+		if total != stored {
+			t.Fatalf("GenerateVariants(%s) devolvió %d bytes, pero se guardaron %d", key, total, stored)
+		}
+
+		if err := DeleteWithVariants(ctx, store, key); err != nil {
+			t.Fatalf("DeleteWithVariants(%s): %v", key, err)
+		}
+		for _, size := range Limits.ThumbnailSizes {
+			for _, variant := range []string{VariantKey(key, size), WebPVariantKey(key, size)} {
+				if _, _, err := store.Open(ctx, variant); !errors.Is(err, storage.ErrNotFound) {
+					t.Fatalf("%s sigue existiendo tras borrar la original (err = %v)", variant, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,675 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+	"sort"
+)
+
+// Codificador WebP sin pérdida (formato VP8L) para las miniaturas.
+// La librería estándar de Go no sabe escribir WebP y las librerías que lo hacen necesitan cgo (libwebp),
+// así que implementamos la parte mínima del formato:
+//   - transformación "restar verde" y predictores por bloques (lo que más reduce el tamaño en fotos)
+//   - referencias hacia atrás (LZ77) y un código de Huffman por canal (sin caché de colores)
+//
+// Especificación: https://developers.google.com/speed/webp/docs/webp_lossless_bitstream_specification
+
+// ErrWebPTooLarge se devuelve si la imagen no cabe en la cabecera de VP8L (máximo 16384 píxeles por lado)
+var ErrWebPTooLarge = errors.New("imagen demasiado grande para WebP")
+
+const (
+	webpMaxSide = 1 << 14
+
+	// Los bloques de predicción son de 1<<webpPredictorBits píxeles de lado
+	webpPredictorBits = 4
+
+	// Tamaño de cada alfabeto: verde (+24 códigos de longitud), rojo, azul, alfa y distancia
+	webpGreenAlphabet    = 256 + 24
+	webpColorAlphabet    = 256
+	webpDistanceAlphabet = 40
+
+	// Longitud máxima de los códigos de Huffman y de los códigos de las longitudes
+	webpMaxCodeLength       = 15
+	webpMaxCodeLengthLength = 7
+)
+
+// Orden en el que se escriben las longitudes del "código de longitudes" (fijado por el formato)
+var webpCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// EncodeWebP escribe la imagen en formato WebP sin pérdida
+func EncodeWebP(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 1 || height < 1 || width > webpMaxSide || height > webpMaxSide {
+		return ErrWebPTooLarge
+	}
+
+	// 1. Pasar los píxeles a ARGB sin premultiplicar (como los guarda VP8L: 0xAARRGGBB)
+	nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+	argb := make([]uint32, width*height)
+	hasAlpha := false
+	for i := range argb {
+		p := nrgba.Pix[i*4 : i*4+4]
+		argb[i] = uint32(p[3])<<24 | uint32(p[0])<<16 | uint32(p[1])<<8 | uint32(p[2])
+		if p[3] != 0xff {
+			hasAlpha = true
+		}
+	}
+
+	// 2. Cabecera de VP8L: firma, dimensiones, si usa transparencia y versión (0)
+	bw := &webpBitWriter{}
+	bw.writeBits(0x2f, 8)
+	bw.writeBits(uint32(width-1), 14)
+	bw.writeBits(uint32(height-1), 14)
+	if hasAlpha {
+		bw.writeBits(1, 1)
+	} else {
+		bw.writeBits(0, 1)
+	}
+	bw.writeBits(0, 3)
+
+	// 3. Transformaciones (el decodificador las deshace en orden inverso)
+	// "Restar verde": rojo y azul se guardan como diferencia con el verde (están muy correlacionados)
+	bw.writeBits(1, 1)
+	bw.writeBits(2, 2)
+	webpSubtractGreen(argb)
+
+	// Predictores: cada píxel se guarda como diferencia con una predicción a partir de sus vecinos,
+	// eligiendo para cada bloque el predictor que deja las diferencias más pequeñas
+	bw.writeBits(1, 1)
+	bw.writeBits(0, 2)
+	bw.writeBits(webpPredictorBits-2, 3)
+	modes, tilesX, residuals := webpPredict(argb, width, height)
+	webpWriteImage(bw, modes, tilesX, false)
+
+	bw.writeBits(0, 1) // no hay más transformaciones
+
+	// 4. Los píxeles (ya transformados) codificados con Huffman
+	webpWriteImage(bw, residuals, width, true)
+	data := bw.bytes()
+
+	// 5. Contenedor RIFF: "RIFF" <tamaño> "WEBP" "VP8L" <tamaño> <datos> (con relleno hasta un tamaño par)
+	padding := len(data) % 2
+	var out bytes.Buffer
+	out.WriteString("RIFF")
+	binary.Write(&out, binary.LittleEndian, uint32(4+8+len(data)+padding))
+	out.WriteString("WEBPVP8L")
+	binary.Write(&out, binary.LittleEndian, uint32(len(data)))
+	out.Write(data)
+	if padding == 1 {
+		out.WriteByte(0)
+	}
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// webpSubtractGreen resta el verde al rojo y al azul de cada píxel
+func webpSubtractGreen(argb []uint32) {
+	for i, p := range argb {
+		green := (p >> 8) & 0xff
+		red := ((p >> 16) - green) & 0xff
+		blue := (p - green) & 0xff
+		argb[i] = p&0xff00ff00 | red<<16 | blue
+	}
+}
+
+// webpPredict elige un predictor para cada bloque y devuelve la imagen de predictores
+// (el modo va en el canal verde), su anchura y las diferencias de cada píxel con su predicción
+func webpPredict(argb []uint32, width, height int) (modes []uint32, tilesX int, residuals []uint32) {
+	blockSize := 1 << webpPredictorBits
+	tilesX = (width + blockSize - 1) / blockSize
+	tilesY := (height + blockSize - 1) / blockSize
+	modes = make([]uint32, tilesX*tilesY)
+	residuals = make([]uint32, len(argb))
+
+	for ty := 0; ty < tilesY; ty++ {
+		for tx := 0; tx < tilesX; tx++ {
+			x0, y0 := tx*blockSize, ty*blockSize
+			x1, y1 := min(x0+blockSize, width), min(y0+blockSize, height)
+
+			// Probar los 14 predictores y quedarse con el que da diferencias más pequeñas
+			bestMode, bestCost := 0, -1
+			for mode := 0; mode < 14; mode++ {
+				cost := 0
+				for y := y0; y < y1; y++ {
+					for x := x0; x < x1; x++ {
+						cost += webpResidualCost(webpSubPixels(argb[y*width+x], webpPrediction(argb, width, x, y, mode)))
+					}
+				}
+				if bestCost < 0 || cost < bestCost {
+					bestMode, bestCost = mode, cost
+				}
+			}
+
+			modes[ty*tilesX+tx] = uint32(bestMode) << 8
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					i := y*width + x
+					residuals[i] = webpSubPixels(argb[i], webpPrediction(argb, width, x, y, bestMode))
+				}
+			}
+		}
+	}
+	return modes, tilesX, residuals
+}
+
+// webpResidualCost estima lo que cuesta una diferencia: la suma de sus canales en valor absoluto
+func webpResidualCost(diff uint32) int {
+	cost := 0
+	for shift := 0; shift < 32; shift += 8 {
+		value := int(int8(diff >> shift))
+		if value < 0 {
+			value = -value
+		}
+		cost += value
+	}
+	return cost
+}
+
+// webpPrediction calcula la predicción de un píxel a partir de sus vecinos ya codificados.
+// La primera fila y la primera columna usan siempre el de la izquierda y el de arriba.
+func webpPrediction(argb []uint32, width, x, y, mode int) uint32 {
+	i := y*width + x
+	switch {
+	case x == 0 && y == 0:
+		return 0xff000000
+	case y == 0:
+		return argb[i-1]
+	case x == 0:
+		return argb[i-width]
+	}
+
+	// En la última columna, el "arriba a la derecha" es el primer píxel de la fila actual
+	// (que es justo lo que hay en i-width+1)
+	left, top, topLeft, topRight := argb[i-1], argb[i-width], argb[i-width-1], argb[i-width+1]
+	switch mode {
+	case 0:
+		return 0xff000000
+	case 1:
+		return left
+	case 2:
+		return top
+	case 3:
+		return topRight
+	case 4:
+		return topLeft
+	case 5:
+		return webpAverage(webpAverage(left, topRight), top)
+	case 6:
+		return webpAverage(left, topLeft)
+	case 7:
+		return webpAverage(left, top)
+	case 8:
+		return webpAverage(topLeft, top)
+	case 9:
+		return webpAverage(top, topRight)
+	case 10:
+		return webpAverage(webpAverage(left, topLeft), webpAverage(top, topRight))
+	case 11:
+		return webpSelect(left, top, topLeft)
+	case 12:
+		return webpMapChannels(left, top, topLeft, func(a, b, c int) int { return a + b - c })
+	default:
+		return webpMapChannels(webpAverage(left, top), topLeft, 0, func(a, b, _ int) int { return a + (a-b)/2 })
+	}
+}
+
+// webpAverage hace la media (redondeando hacia abajo) de cada canal
+func webpAverage(a, b uint32) uint32 {
+	return (((a ^ b) & 0xfefefefe) >> 1) + (a & b)
+}
+
+// webpSelect devuelve el vecino (izquierda o arriba) más parecido a la estimación izquierda+arriba-diagonal
+func webpSelect(left, top, topLeft uint32) uint32 {
+	distanceLeft, distanceTop := 0, 0
+	for shift := 0; shift < 32; shift += 8 {
+		l, t, tl := int(left>>shift&0xff), int(top>>shift&0xff), int(topLeft>>shift&0xff)
+		estimate := l + t - tl
+		distanceLeft += webpAbs(estimate - l)
+		distanceTop += webpAbs(estimate - t)
+	}
+	if distanceLeft < distanceTop {
+		return left
+	}
+	return top
+}
+
+// webpMapChannels aplica 'f' a cada canal y recorta el resultado a 0..255
+func webpMapChannels(a, b, c uint32, f func(a, b, c int) int) uint32 {
+	var out uint32
+	for shift := 0; shift < 32; shift += 8 {
+		value := f(int(a>>shift&0xff), int(b>>shift&0xff), int(c>>shift&0xff))
+		value = max(0, min(255, value))
+		out |= uint32(value) << shift
+	}
+	return out
+}
+
+// webpSubPixels resta dos píxeles canal a canal (módulo 256, como los suma el decodificador)
+func webpSubPixels(a, b uint32) uint32 {
+	alphaGreen := 0x00ff00ff + (a & 0xff00ff00) - (b & 0xff00ff00)
+	redBlue := 0xff00ff00 + (a & 0x00ff00ff) - (b & 0x00ff00ff)
+	return alphaGreen&0xff00ff00 | redBlue&0x00ff00ff
+}
+
+func webpAbs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+// webpWriteImage codifica una imagen ARGB: repeticiones como referencias hacia atrás y el resto píxel a píxel,
+// con un código de Huffman por canal
+// (la imagen principal lleva un bit más que las auxiliares: el de "varios grupos de códigos")
+func webpWriteImage(bw *webpBitWriter, argb []uint32, width int, main bool) {
+	// Caché de colores: los píxeles ya vistos se pueden repetir con su posición en la caché
+	bw.writeBits(1, 1)
+	bw.writeBits(webpCacheBits, 4)
+	if main {
+		bw.writeBits(0, 1) // un solo grupo de códigos para toda la imagen
+	}
+
+	// 1. Buscar repeticiones y contar cuántas veces aparece cada símbolo en cada alfabeto
+	symbols := webpBackwardReferences(argb, width)
+	green := make([]int, webpGreenAlphabet+1<<webpCacheBits)
+	red := make([]int, webpColorAlphabet)
+	blue := make([]int, webpColorAlphabet)
+	alpha := make([]int, webpColorAlphabet)
+	distance := make([]int, webpDistanceAlphabet)
+	for _, s := range symbols {
+		if s.cached {
+			green[webpGreenAlphabet+s.cacheIndex]++
+			continue
+		}
+		if s.length == 0 {
+			green[s.pixel>>8&0xff]++
+			red[s.pixel>>16&0xff]++
+			blue[s.pixel&0xff]++
+			alpha[s.pixel>>24]++
+			continue
+		}
+		lengthPrefix, _, _ := webpPrefixEncode(s.length)
+		distancePrefix, _, _ := webpPrefixEncode(s.distance)
+		green[256+lengthPrefix]++
+		distance[distancePrefix]++
+	}
+
+	// 2. Escribir los cinco códigos
+	greenCode := webpWriteHuffmanCode(bw, green)
+	redCode := webpWriteHuffmanCode(bw, red)
+	blueCode := webpWriteHuffmanCode(bw, blue)
+	alphaCode := webpWriteHuffmanCode(bw, alpha)
+	distanceCode := webpWriteHuffmanCode(bw, distance)
+
+	// 3. Escribir los símbolos: un píxel de la caché, un píxel (verde, rojo, azul y alfa)
+	// o una copia (longitud y distancia)
+	for _, s := range symbols {
+		if s.cached {
+			greenCode.write(bw, webpGreenAlphabet+s.cacheIndex)
+			continue
+		}
+		if s.length == 0 {
+			greenCode.write(bw, int(s.pixel>>8&0xff))
+			redCode.write(bw, int(s.pixel>>16&0xff))
+			blueCode.write(bw, int(s.pixel&0xff))
+			alphaCode.write(bw, int(s.pixel>>24))
+			continue
+		}
+		prefix, extra, extraBits := webpPrefixEncode(s.length)
+		greenCode.write(bw, 256+prefix)
+		bw.writeBits(extra, extraBits)
+		prefix, extra, extraBits = webpPrefixEncode(s.distance)
+		distanceCode.write(bw, prefix)
+		bw.writeBits(extra, extraBits)
+	}
+}
+
+// webpSymbol es un píxel literal (length == 0), un píxel que está en la caché de colores
+// o una copia de 'length' píxeles desde 'distance' (ya convertida al código de distancia de VP8L)
+type webpSymbol struct {
+	pixel      uint32
+	cached     bool
+	cacheIndex int
+	length     int
+	distance   int
+}
+
+const (
+	webpMinMatch  = 3
+	webpMaxMatch  = 4096
+	webpMaxChain  = 32
+	webpWindow    = 1 << 18
+	webpHashBits  = 16
+	webpHashShift = 32 - webpHashBits
+
+	// La caché de colores tiene 1<<webpCacheBits entradas
+	webpCacheBits = 10
+)
+
+// webpBackwardReferences busca repeticiones (LZ77 voraz con cadenas de hash sobre parejas de píxeles)
+// y los píxeles sueltos que ya están en la caché de colores
+func webpBackwardReferences(argb []uint32, width int) []webpSymbol {
+	n := len(argb)
+	// La caché se actualiza con cada píxel, sea literal o copiado (igual que hará el decodificador)
+	cache := make([]uint32, 1<<webpCacheBits)
+
+	head := make([]int32, 1<<webpHashBits)
+	for i := range head {
+		head[i] = -1
+	}
+	prev := make([]int32, n)
+	hash := func(i int) uint32 {
+		return (argb[i]*0x1e35a7bd + argb[i+1]*0x9e3779b1) >> webpHashShift
+	}
+	insert := func(i int) {
+		if i+1 < n {
+			h := hash(i)
+			prev[i] = head[h]
+			head[h] = int32(i)
+		}
+	}
+
+	symbols := make([]webpSymbol, 0, n)
+	for i := 0; i < n; {
+		bestLength, bestDistance := 0, 0
+		if i+1 < n {
+			for j, chain := int(head[hash(i)]), 0; j >= 0 && chain < webpMaxChain && i-j <= webpWindow; j, chain = int(prev[j]), chain+1 {
+				length := 0
+				for length < webpMaxMatch && i+length < n && argb[j+length] == argb[i+length] {
+					length++
+				}
+				if length > bestLength {
+					bestLength, bestDistance = length, i-j
+				}
+			}
+		}
+
+		if bestLength < webpMinMatch {
+			index := webpCacheIndex(argb[i])
+			symbols = append(symbols, webpSymbol{pixel: argb[i], cached: cache[index] == argb[i], cacheIndex: index})
+			cache[index] = argb[i]
+			insert(i)
+			i++
+			continue
+		}
+		symbols = append(symbols, webpSymbol{length: bestLength, distance: webpDistanceCode(bestDistance, width)})
+		for k := i; k < i+bestLength; k++ {
+			cache[webpCacheIndex(argb[k])] = argb[k]
+			insert(k)
+		}
+		i += bestLength
+	}
+	return symbols
+}
+
+// webpCacheIndex es la posición de un color en la caché (el hash que fija el formato)
+func webpCacheIndex(pixel uint32) int {
+	return int((pixel * 0x1e35a7bd) >> (32 - webpCacheBits))
+}
+
+// webpDistanceCode convierte una distancia en píxeles al código de VP8L: los 120 primeros códigos
+// son vecinos en 2D (1 = el de arriba, 2 = el de la izquierda...) y el resto son distancias lineales
+func webpDistanceCode(distance, width int) int {
+	switch distance {
+	case width:
+		return 1
+	case 1:
+		return 2
+	}
+	return distance + 120
+}
+
+// webpPrefixEncode separa un valor (longitud o código de distancia, desde 1) en el prefijo que se codifica
+// con Huffman y los bits extra que van tal cual
+func webpPrefixEncode(value int) (prefix int, extra uint32, extraBits int) {
+	value--
+	if value < 4 {
+		return value, 0, 0
+	}
+	highest := 31
+	for value>>highest == 0 {
+		highest--
+	}
+	second := (value >> (highest - 1)) & 1
+	extraBits = highest - 1
+	return 2*highest + second, uint32(value & (1<<extraBits - 1)), extraBits
+}
+
+// webpHuffmanCode guarda el código (ya invertido, porque VP8L escribe los bits del menos al más significativo)
+// y la longitud de cada símbolo
+type webpHuffmanCode struct {
+	codes   []uint32
+	lengths []int
+}
+
+func (h *webpHuffmanCode) write(bw *webpBitWriter, symbol int) {
+	bw.writeBits(h.codes[symbol], h.lengths[symbol])
+}
+
+// webpWriteHuffmanCode calcula el código de Huffman de un histograma, lo escribe y lo devuelve
+func webpWriteHuffmanCode(bw *webpBitWriter, histogram []int) *webpHuffmanCode {
+	var used []int
+	for symbol, count := range histogram {
+		if count > 0 {
+			used = append(used, symbol)
+		}
+	}
+
+	// 1. Código "simple": uno o dos símbolos menores de 256 (con uno solo no se gasta ni un bit por píxel)
+	if len(used) <= 2 && (len(used) == 0 || used[len(used)-1] < 256) {
+		lengths := make([]int, len(histogram))
+		if len(used) == 0 {
+			used = []int{0}
+		}
+		bw.writeBits(1, 1)
+		bw.writeBits(uint32(len(used)-1), 1)
+		if used[0] < 2 {
+			bw.writeBits(0, 1)
+			bw.writeBits(uint32(used[0]), 1)
+		} else {
+			bw.writeBits(1, 1)
+			bw.writeBits(uint32(used[0]), 8)
+		}
+		if len(used) == 2 {
+			bw.writeBits(uint32(used[1]), 8)
+			lengths[used[0]], lengths[used[1]] = 1, 1
+		}
+		return &webpHuffmanCode{codes: webpCanonicalCodes(lengths), lengths: lengths}
+	}
+
+	// 2. Código normal: las longitudes de cada símbolo, a su vez comprimidas con otro código de Huffman
+	lengths := webpHuffmanLengths(histogram, webpMaxCodeLength)
+	bw.writeBits(0, 1)
+
+	// Las longitudes se escriben tal cual (0..15) salvo las rachas de ceros:
+	// 17 = de 3 a 10 ceros, 18 = de 11 a 138 ceros
+	type token struct{ symbol, extra, extraBits int }
+	var tokens []token
+	for i := 0; i < len(lengths); {
+		if lengths[i] != 0 {
+			tokens = append(tokens, token{symbol: lengths[i]})
+			i++
+			continue
+		}
+		run := 1
+		for i+run < len(lengths) && lengths[i+run] == 0 && run < 138 {
+			run++
+		}
+		switch {
+		case run >= 11:
+			tokens = append(tokens, token{symbol: 18, extra: run - 11, extraBits: 7})
+		case run >= 3:
+			tokens = append(tokens, token{symbol: 17, extra: run - 3, extraBits: 3})
+		default:
+			run = 1
+			tokens = append(tokens, token{symbol: 0})
+		}
+		i += run
+	}
+
+	tokenHistogram := make([]int, len(webpCodeLengthOrder))
+	for _, t := range tokens {
+		tokenHistogram[t.symbol]++
+	}
+	tokenLengths := webpHuffmanLengths(tokenHistogram, webpMaxCodeLengthLength)
+	tokenCodes := webpCanonicalCodes(tokenLengths)
+
+	// Longitudes del código de longitudes, en el orden del formato (sin los ceros del final; mínimo 4)
+	count := 4
+	for i, symbol := range webpCodeLengthOrder {
+		if tokenLengths[symbol] > 0 && i+1 > count {
+			count = i + 1
+		}
+	}
+	bw.writeBits(uint32(count-4), 4)
+	for _, symbol := range webpCodeLengthOrder[:count] {
+		bw.writeBits(uint32(tokenLengths[symbol]), 3)
+	}
+
+	bw.writeBits(0, 1) // se escriben las longitudes de todo el alfabeto
+	for _, t := range tokens {
+		bw.writeBits(tokenCodes[t.symbol], tokenLengths[t.symbol])
+		if t.extraBits > 0 {
+			bw.writeBits(uint32(t.extra), t.extraBits)
+		}
+	}
+
+	return &webpHuffmanCode{codes: webpCanonicalCodes(lengths), lengths: lengths}
+}
+
+// webpHuffmanLengths calcula la longitud del código de cada símbolo sin pasar de 'maxLength'.
+// Si el árbol sale demasiado profundo, se repite subiendo los recuentos más bajos (como hace libwebp).
+func webpHuffmanLengths(histogram []int, maxLength int) []int {
+	// Un código normal necesita al menos dos símbolos: si solo se usa uno, añadimos otro que no se usará
+	used := 0
+	for _, count := range histogram {
+		if count > 0 {
+			used++
+		}
+	}
+	if used == 1 {
+		histogram = append([]int(nil), histogram...)
+		if histogram[0] == 0 {
+			histogram[0] = 1
+		} else {
+			histogram[1] = 1
+		}
+	}
+
+	type node struct {
+		weight int
+		symbol int // -1 en los nodos internos
+		left   int
+		right  int
+	}
+
+	for minCount := 1; ; minCount *= 2 {
+		// 1. Hojas ordenadas por peso
+		var nodes []node
+		for symbol, count := range histogram {
+			if count > 0 {
+				nodes = append(nodes, node{weight: max(count, minCount), symbol: symbol, left: -1, right: -1})
+			}
+		}
+		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].weight < nodes[j].weight })
+
+		// 2. Árbol de Huffman con dos colas: las hojas (ya ordenadas) y los nodos internos (que salen ordenados)
+		leaves := len(nodes)
+		nextLeaf, nextInternal := 0, leaves
+		takeSmallest := func() int {
+			if nextLeaf < leaves && (nextInternal >= len(nodes) || nodes[nextLeaf].weight <= nodes[nextInternal].weight) {
+				nextLeaf++
+				return nextLeaf - 1
+			}
+			nextInternal++
+			return nextInternal - 1
+		}
+		for i := 0; i < leaves-1; i++ {
+			a, b := takeSmallest(), takeSmallest()
+			nodes = append(nodes, node{weight: nodes[a].weight + nodes[b].weight, symbol: -1, left: a, right: b})
+		}
+
+		// 3. La longitud de cada símbolo es la profundidad de su hoja
+		lengths := make([]int, len(histogram))
+		tooDeep := false
+		var walk func(n, depth int)
+		walk = func(n, depth int) {
+			if nodes[n].symbol >= 0 {
+				lengths[nodes[n].symbol] = max(depth, 1)
+				tooDeep = tooDeep || depth > maxLength
+				return
+			}
+			walk(nodes[n].left, depth+1)
+			walk(nodes[n].right, depth+1)
+		}
+		if len(nodes) > 0 {
+			walk(len(nodes)-1, 0)
+		}
+		if !tooDeep {
+			return lengths
+		}
+	}
+}
+
+// webpCanonicalCodes asigna los códigos canónicos (como en deflate: por longitud y luego por símbolo)
+// y los devuelve con los bits invertidos, listos para el escritor de bits
+func webpCanonicalCodes(lengths []int) []uint32 {
+	var lengthCount [webpMaxCodeLength + 1]uint32
+	for _, length := range lengths {
+		lengthCount[length]++
+	}
+	lengthCount[0] = 0
+
+	var nextCode [webpMaxCodeLength + 1]uint32
+	code := uint32(0)
+	for length := 1; length <= webpMaxCodeLength; length++ {
+		code = (code + lengthCount[length-1]) << 1
+		nextCode[length] = code
+	}
+
+	codes := make([]uint32, len(lengths))
+	for symbol, length := range lengths {
+		if length == 0 {
+			continue
+		}
+		code := nextCode[length]
+		nextCode[length]++
+		var reversed uint32
+		for i := 0; i < length; i++ {
+			reversed = reversed<<1 | (code>>i)&1
+		}
+		codes[symbol] = reversed
+	}
+	return codes
+}
+
+// webpBitWriter escribe bits empezando por el menos significativo de cada byte (como lee VP8L)
+type webpBitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits int
+}
+
+func (bw *webpBitWriter) writeBits(value uint32, n int) {
+	bw.acc |= uint64(value&(1<<n-1)) << bw.nbits
+	bw.nbits += n
+	for bw.nbits >= 8 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc >>= 8
+		bw.nbits -= 8
+	}
+}
+
+func (bw *webpBitWriter) bytes() []byte {
+	if bw.nbits > 0 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc, bw.nbits = 0, 0
+	}
+	return bw.buf
+}
@@ -0,0 +1,78 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+// Lo que escribe EncodeWebP lo tiene que leer un decodificador independiente sin perder ni un píxel
+func TestEncodeWebPRoundTrip(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	gradient := image.NewNRGBA(image.Rect(0, 0, 67, 45))
+	noise := image.NewNRGBA(image.Rect(0, 0, 40, 33))
+	transparent := image.NewNRGBA(image.Rect(0, 0, 19, 23))
+	for y := 0; y < 45; y++ {
+		for x := 0; x < 67; x++ {
+			gradient.Set(x, y, color.NRGBA{uint8(x * 3), uint8(y * 5), uint8(x + y), 255})
+		}
+	}
+	for i := range noise.Pix {
+		noise.Pix[i] = uint8(random.Intn(256))
+	}
+	for y := 0; y < 23; y++ {
+		for x := 0; x < 19; x++ {
+			transparent.Set(x, y, color.NRGBA{200, 10, 90, uint8(x * 13)})
+		}
+	}
+	solid := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	solid.Set(0, 0, color.NRGBA{12, 34, 56, 255})
+
+	cases := map[string]*image.NRGBA{
+		"degradado":     gradient,
+		"ruido":         noise,
+		"transparencia": transparent,
+		"un píxel":      solid,
+		"miniatura":     toNRGBA(Thumbnail(gradient, 32)),
+	}
+	for name, img := range cases {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := EncodeWebP(&buf, img); err != nil {
+				t.Fatalf("EncodeWebP: %v", err)
+			}
+			decoded, err := webp.Decode(&buf)
+			if err != nil {
+				t.Fatalf("el WebP generado no se puede decodificar: %v", err)
+			}
+			if decoded.Bounds() != img.Bounds() {
+				t.Fatalf("tamaño %v, se esperaba %v", decoded.Bounds(), img.Bounds())
+			}
+			for y := 0; y < img.Bounds().Dy(); y++ {
+				for x := 0; x < img.Bounds().Dx(); x++ {
+					got := color.NRGBAModel.Convert(decoded.At(x, y)).(color.NRGBA)
+					want := img.NRGBAAt(x, y)
+					// Con alfa 0 el color no importa (y el decodificador puede no conservarlo)
+					if got != want && !(want.A == 0 && got.A == 0) {
+						t.Fatalf("píxel (%d,%d) = %v, se esperaba %v", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func toNRGBA(img image.Image) *image.NRGBA {
+	out := image.NewNRGBA(img.Bounds())
+	for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+		for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+			out.Set(x, y, img.At(x, y))
+		}
+	}
+	return out
+}
@@ -2,7 +2,11 @@ package middleware
 
 import (
 	"errors"
+	"go-aprendizaje/apikeys"
+	"go-aprendizaje/authcookies"
 	"go-aprendizaje/config"
+	"go-aprendizaje/security"
+	"go-aprendizaje/sessions"
 	"net/http"
 	"strings"
 
@@ -10,27 +14,41 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware exige un token JWT ("Authorization: Bearer" o, en modo cookie, la cookie access_token).
+// Si se pasan 'scopes', la ruta también acepta API keys ("Authorization: ApiKey <key>" o "X-API-Key")
+// que tengan alguno de esos permisos. Sin 'scopes' la ruta es solo para personas.
+func AuthMiddleware(scopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Obtener el token del encabezado Authorization
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token no autorizado"})
-			c.Abort()
+		// Las API keys se comprueban aparte (no son tokens JWT)
+		if apiKey := requestAPIKey(c); apiKey != "" {
+			authenticateAPIKey(c, apiKey, scopes)
 			return
 		}
 
-		// Validar el formato del token (Bearer <token>)
-		// Usamos strings.Split para separar "Bearer" del token en sí
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "No autorizado: Formato de token inválido"})
+		// Obtener el token del encabezado Authorization o, en modo cookie, de la cookie access_token
+		var tokenString string
+		fromCookie := false
+		authHeader := c.GetHeader("Authorization")
+		if authHeader != "" {
+			// Validar el formato del token (Bearer <token>)
+			// Usamos strings.Split para separar "Bearer" del token en sí
+			parts := strings.Split(authHeader, " ")
+			if len(parts) != 2 || parts[0] != "Bearer" {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "No autorizado: Formato de token inválido"})
+				return
+			}
+
+			// Sacamos el token
+			tokenString = parts[1]
+		} else if cookie, err := c.Cookie(authcookies.AccessCookie); err == nil && cookie != "" && authcookies.Enabled() {
+			tokenString = cookie
+			fromCookie = true
+		} else {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token no autorizado"})
+			c.Abort()
 			return
 		}
 
-		// Sacamos el token
-		tokenString := parts[1]
-
 		// Parsear y validar el token JWT
 
 		// Obtener la clave secreta desde la configuración
@@ -55,11 +73,47 @@ func AuthMiddleware() gin.HandlerFunc {
 		// Extraer los "claims" (datos) del token y chequear que el token sea valido
 		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
 
+			// Comprobar que los tokens del usuario no se hayan revocado (ej: cuenta borrada)
+			if store, userID, ok := security.UserRef(claims["userID"]); ok {
+				issuedAt, err := claims.GetIssuedAt()
+				if err != nil || issuedAt == nil || security.IsTokenRevoked(store, userID, issuedAt.Time) {
+					c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "No autorizado: Token revocado"})
+					return
+				}
+			}
+
+			// Comprobar que la sesión del token siga abierta (el usuario puede cerrarla desde otro dispositivo).
+			// Los tokens emitidos antes de registrar sesiones no llevan "sid": valen hasta que caduquen.
+			sessionID, _ := claims["sid"].(string)
+			if sessionID != "" {
+				if err := sessions.Check(sessionID, c.ClientIP()); err != nil {
+					c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "No autorizado: Sesión cerrada"})
+					return
+				}
+			}
+
+			// En modo cookie el navegador envía el token solo, también en peticiones que lance otra web:
+			// las que cambian algo tienen que llevar además el token CSRF de esta sesión
+			if fromCookie {
+				if sessionID == "" {
+					c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "No autorizado: Token inválido o expirado"})
+					return
+				}
+				if !authcookies.SafeMethod(c.Request.Method) {
+					csrfToken, ok := authcookies.CSRFMatches(c)
+					if !ok || !security.ValidCSRFToken(csrfToken, sessionID) {
+						c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Prohibido: Token CSRF inválido o ausente"})
+						return
+					}
+				}
+			}
+
 			// ¡ÉXITO! Guardar los datos del usuario en el "contexto" de Gin
 			// Esto permite que el *siguiente* handler (el controlador)
 			// pueda saber qué usuario está haciendo la petición.
 			c.Set("userID", claims["userID"])
 			c.Set("role", claims["role"])
+			c.Set("sessionID", sessionID)
 
 			// 6. Permitir que la petición continúe
 			c.Next()
@@ -70,3 +124,51 @@ func AuthMiddleware() gin.HandlerFunc {
 
 	}
 }
+
+// requestAPIKey devuelve la API key de la petición ("Authorization: ApiKey <key>" o la cabecera "X-API-Key")
+func requestAPIKey(c *gin.Context) string {
+	if authHeader := c.GetHeader("Authorization"); strings.HasPrefix(authHeader, "ApiKey ") {
+		return strings.TrimPrefix(authHeader, "ApiKey ")
+	}
+	return c.GetHeader("X-API-Key")
+}
+
+// authenticateAPIKey valida la key y deja en el contexto los mismos datos que un token JWT
+// (el usuario dueño de la key y su rol), más "apiKeyID" y la propia key en "apiKey" (para consultar sus permisos)
+func authenticateAPIKey(c *gin.Context, rawKey string, scopes []string) {
+	if len(scopes) == 0 {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Prohibido: Esta ruta no admite API keys"})
+		return
+	}
+
+	apiKey, err := apikeys.Authenticate(rawKey, c.ClientIP())
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "No autorizado: " + apikeys.ErrInvalid.Error()})
+		return
+	}
+
+	allowed := false
+	for _, scope := range scopes {
+		if apiKey.HasScope(scope) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Prohibido: La API key no tiene permiso (" + strings.Join(scopes, " o ") + ")"})
+		return
+	}
+
+	owner, err := apikeys.FindOwner(apiKey)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "No autorizado: " + err.Error()})
+		return
+	}
+
+	c.Set("userID", owner.TokenID)
+	c.Set("role", owner.Role)
+	c.Set("sessionID", "")
+	c.Set("apiKeyID", apiKey.ID)
+	c.Set("apiKey", apiKey)
+	c.Next()
+}
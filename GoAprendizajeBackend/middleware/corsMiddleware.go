@@ -18,15 +18,19 @@ func SetupCorsConfig() gin.HandlerFunc {
 	config.AllowOrigins = []string{url}
 
 	// B) Permitir métodos
-	config.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	config.AllowMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
 
 	// C) Permitir encabezados (Authorization es clave para el Token)
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
+	// (X-CSRF-Token y X-Auth-Transport son del modo cookie; los "Upload-*" y "Tus-Resumable" son del protocolo de subidas reanudables)
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "X-CSRF-Token", "X-Auth-Transport",
+		"Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Metadata"}
 
 	// D) Exponer encabezados (opcional, útil si necesitas leer headers en el front)
-	config.ExposeHeaders = []string{"Content-Length"}
+	config.ExposeHeaders = []string{"Content-Length", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After",
+		"Location", "Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size", "Upload-Offset", "Upload-Length", "Upload-Metadata", "Upload-Expires"}
 
-	// E) Permitir credenciales (cookies, auth headers)
+	// E) Permitir credenciales (cookies, auth headers). Imprescindible para el modo cookie:
+	// sin esto el navegador no envía ni guarda las cookies en las peticiones desde FRONTEND_URL
 	config.AllowCredentials = true
 
 	return cors.New(config)
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"fmt"
+	"go-aprendizaje/core"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/ratelimit"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyFunc decide a quién se le aplica la cuota (IP, usuario, API key...)
+type KeyFunc func(c *gin.Context) string
+
+// KeyByIP limita por la IP del cliente
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// KeyByUser limita por el usuario autenticado (debe ir después de AuthMiddleware).
+// Si no hay usuario en el contexto, se limita por IP.
+func KeyByUser(c *gin.Context) string {
+	userID, exists := c.Get("userID")
+	if !exists || userID == nil {
+		return KeyByIP(c)
+	}
+	return fmt.Sprintf("user:%v", userID)
+}
+
+// KeyByAPIKey limita por la API key con la que se autenticó la petición (debe ir después de AuthMiddleware).
+// Solo se usa una key ya validada: con la cabecera sin comprobar, cada key inventada tendría su propia cuota.
+// Si la petición no llegó con API key, se limita por usuario (o por IP).
+func KeyByAPIKey(c *gin.Context) string {
+	apiKeyID, exists := c.Get("apiKeyID")
+	if !exists || apiKeyID == nil {
+		return KeyByUser(c)
+	}
+	return fmt.Sprintf("apikey:%v", apiKeyID)
+}
+
+// RateLimitMiddleware limita las peticiones de un grupo de rutas.
+// 'name' separa las cuotas de cada grupo (ej: "register" y "global" no comparten contador).
+func RateLimitMiddleware(name string, limit ratelimit.Limit, keyFunc KeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := name + ":" + keyFunc(c)
+
+		result, err := core.RateLimitStore.Allow(c.Request.Context(), key, limit)
+		if err != nil {
+			// Si el store falla dejamos pasar la petición: preferimos no tumbar la API
+			logging.Log.Errorf("Error en el rate limiter (%s): %v", key, err)
+			c.Next()
+			return
+		}
+
+		// Cabeceras estándar (draft IETF "RateLimit header fields")
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", secondsHeader(result.ResetAfter))
+
+		if !result.Allowed {
+			c.Header("Retry-After", secondsHeader(result.RetryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Demasiadas peticiones, inténtalo más tarde"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// secondsHeader redondea hacia arriba a segundos enteros (las cabeceras no admiten decimales)
+func secondsHeader(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
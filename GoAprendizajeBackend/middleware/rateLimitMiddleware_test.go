@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"go-aprendizaje/core"
+	"go-aprendizaje/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Una API key sin validar no puede dar una cuota nueva: con keys inventadas se sigue limitando por IP
+func TestKeyByAPIKeyIgnoresUnauthenticatedKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	core.RateLimitStore = ratelimit.NewMemoryStore(time.Minute)
+
+	router := gin.New()
+	router.Use(RateLimitMiddleware("test", ratelimit.Limit{Rate: 2, Period: time.Minute, Burst: 2}, KeyByAPIKey))
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	statuses := make([]int, 0, 3)
+	for _, key := range []string{"inventada-1", "inventada-2", "inventada-3"} {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.Header.Set("X-API-Key", key)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		statuses = append(statuses, recorder.Code)
+	}
+
+	if statuses[2] != http.StatusTooManyRequests {
+		t.Fatalf("la tercera petición con otra key inventada debería limitarse por IP, códigos: %v", statuses)
+	}
+}
+
+// Con la key ya autenticada (AuthMiddleware deja "apiKeyID"), cada key tiene su propia cuota
+func TestKeyByAPIKeyUsesAuthenticatedKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	context, _ := gin.CreateTestContext(httptest.NewRecorder())
+	context.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	if key := KeyByAPIKey(context); key != KeyByIP(context) {
+		t.Fatalf("sin usuario ni key autenticada se esperaba la clave por IP, obtenida %q", key)
+	}
+
+	context.Set("userID", float64(7))
+	context.Set("apiKeyID", uint(3))
+	if key := KeyByAPIKey(context); key != "apikey:3" {
+		t.Fatalf("clave inesperada: %q", key)
+	}
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Permisos (scopes) de las API keys. Una key solo sirve en las rutas que aceptan alguno de sus permisos
+// (ver middleware.AuthMiddleware); el resto de rutas (contraseña, sesiones, las propias API keys...) son solo para personas.
+const (
+	ScopeProfileRead = "profile:read" // Ver el perfil
+	ScopeFilesRead   = "files:read"   // Listar y descargar los archivos privados
+	ScopeFilesWrite  = "files:write"  // Subir y borrar archivos (también la foto de perfil)
+	ScopeAdmin       = "admin"        // Rutas de administración (solo si el dueño es admin)
+)
+
+// APIKeyScopes son todos los permisos que existen
+var APIKeyScopes = []string{ScopeProfileRead, ScopeFilesRead, ScopeFilesWrite, ScopeAdmin}
+
+// APIKey es una clave para scripts y otros clientes automáticos ("Authorization: ApiKey <key>").
+// La key completa solo se muestra al crearla: aquí se guarda su hash y el prefijo, que sirve para reconocerla.
+type APIKey struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	Name       string     `json:"name" gorm:"not null"`
+	Prefix     string     `json:"prefix" gorm:"uniqueIndex;not null"` // Ej: "ak_3f9c1a7b" (el principio de la key)
+	SecretHash string     `json:"-" gorm:"not null"`
+	UserStore  string     `json:"user_store" gorm:"index:idx_api_key_user;not null"`
+	UserID     string     `json:"user_id" gorm:"index:idx_api_key_user;not null"`
+	Scopes     string     `json:"scopes"` // Separados por espacios (ej: "files:read files:write")
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	CreatedBy  string     `json:"created_by"` // "self" o "admin:<id>"
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// HasScope indica si la key tiene el permiso 'scope'
+func (k *APIKey) HasScope(scope string) bool {
+	for _, granted := range strings.Fields(k.Scopes) {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
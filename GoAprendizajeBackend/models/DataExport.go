@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Estados de una exportación de datos personales
+const (
+	DataExportPending = "pending" // Se está generando el ZIP
+	DataExportReady   = "ready"   // Listo para descargar
+	DataExportFailed  = "failed"  // Falló la generación
+	DataExportExpired = "expired" // Caducó y el fichero se borró
+)
+
+// DataExport es una petición de "descargar mis datos" (RGPD).
+// Se guarda en Postgres tanto para usuarios de Postgres como de Mongo.
+type DataExport struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserStore string    `json:"user_store" gorm:"not null"` // "postgres" o "mongo"
+	UserID    string    `json:"user_id" gorm:"index;not null"`
+	Email     string    `json:"-" gorm:"not null"`
+	Locale    string    `json:"-"` // Idioma del email con el enlace
+	Status    string    `json:"status" gorm:"not null"`
+	FilePath  string    `json:"-"`
+	TokenHash string    `json:"-" gorm:"index"` // SHA-256 del token del enlace de descarga
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// DeletionTombstone es la "lápida" que prueba que una cuenta se borró.
+// No guarda datos personales: solo el ID original y un hash del email.
+type DeletionTombstone struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	UserStore   string    `json:"user_store" gorm:"not null"` // "postgres" o "mongo"
+	UserID      string    `json:"user_id" gorm:"index;not null"`
+	EmailHash   string    `json:"email_hash" gorm:"index;not null"` // SHA-256 del email en minúsculas
+	Method      string    `json:"method" gorm:"not null"`           // "hard_delete" o "anonymize"
+	RequestedBy string    `json:"requested_by" gorm:"not null"`     // "self" o "admin:<id>"
+	RequestedAt time.Time `json:"requested_at"`
+	DeletedAt   time.Time `json:"deleted_at"`
+}
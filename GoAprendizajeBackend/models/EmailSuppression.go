@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Motivos por los que se dejan de enviar emails a una dirección
+const (
+	SuppressionBounce    = "bounce"    // Rebote permanente: la dirección no existe o no acepta correo
+	SuppressionComplaint = "complaint" // El destinatario marcó un email como spam
+	SuppressionManual    = "manual"    // Añadida a mano por un admin
+	// Dominio reservado que no existe (".invalid", RFC 2606), ej: las cuentas de servicio. No está en la lista: se deduce.
+	SuppressionReservedDomain = "reserved_domain"
+)
+
+// EmailSuppression es una dirección a la que no se envían más emails (lista de supresión).
+// Seguir enviando a direcciones que rebotan o se quejan daña la reputación del remitente.
+type EmailSuppression struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Email     string    `json:"email" gorm:"uniqueIndex;not null"` // En minúsculas
+	Reason    string    `json:"reason" gorm:"not null"`
+	Detail    string    `json:"detail,omitempty"` // Ej: "5.1.1 smtp; 550 User unknown"
+	Source    string    `json:"source"`           // "webhook", "dsn" o "admin:<id>"
+	Events    int       `json:"events"`           // Cuántas veces se ha notificado (rebotes o quejas repetidos)
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Motivos por los que falla un intento de inicio de sesión
+const (
+	LoginFailureUnknownUser    = "unknown_user"    // No hay ninguna cuenta con ese email
+	LoginFailureWrongPassword  = "wrong_password"  // La cuenta existe pero la contraseña no coincide
+	LoginFailureInvalidCode    = "invalid_code"    // Enlace o código sin contraseña inválido, caducado o ya usado
+	LoginFailureDeviceMismatch = "device_mismatch" // Enlace o código sin contraseña canjeado desde otro navegador
+	LoginFailureAccountLocked  = "account_locked"  // Credenciales correctas, pero un administrador programó el borrado de la cuenta
+)
+
+// LoginAttempt es un intento de inicio de sesión (correcto o no): el historial de accesos de la cuenta.
+// Los intentos con un email que no existe se guardan sin usuario, solo con el email (los ven los admins).
+type LoginAttempt struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	UserStore       string    `json:"user_store" gorm:"index:idx_login_attempt_user"`
+	UserID          string    `json:"user_id,omitempty" gorm:"index:idx_login_attempt_user"` // Vacío si el email no existe
+	Email           string    `json:"email" gorm:"index"`                                    // El email que se intentó (en minúsculas)
+	Method          string    `json:"method"`                                                // Ver SessionMethodPassword...
+	Success         bool      `json:"success"`
+	Failure         string    `json:"failure,omitempty"` // Ver LoginFailureUnknownUser...
+	IP              string    `json:"ip" gorm:"index"`
+	UserAgent       string    `json:"user_agent"`
+	Browser         string    `json:"browser"`
+	OS              string    `json:"os"`
+	DeviceType      string    `json:"device_type"`
+	DeviceKey       string    `json:"-"` // Navegador + sistema sin versión: lo que se compara para saber si el dispositivo es nuevo
+	Country         string    `json:"country,omitempty"`
+	City            string    `json:"city,omitempty"`
+	Latitude        *float64  `json:"latitude,omitempty"`
+	Longitude       *float64  `json:"longitude,omitempty"`
+	NewDevice       bool      `json:"new_device"`       // Primer login correcto desde este dispositivo
+	UnusualLocation bool      `json:"unusual_location"` // Login correcto lejos de todos los anteriores
+	CreatedAt       time.Time `json:"created_at" gorm:"index"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Formas de iniciar sesión sin contraseña
+const (
+	MagicLoginLink = "link" // Enlace en el email
+	MagicLoginCode = "code" // Código de 6 dígitos en el email
+)
+
+// MagicLogin es una petición de inicio de sesión sin contraseña (enlace o código enviado por email).
+// Solo se guardan hashes: el enlace/código solo está en el email y el secreto del navegador solo en su cookie.
+type MagicLogin struct {
+	ID         string     `json:"id" gorm:"primaryKey"` // UUID
+	UserStore  string     `json:"user_store" gorm:"index:idx_magic_user;not null"`
+	UserID     string     `json:"user_id" gorm:"index:idx_magic_user;not null"`
+	Mode       string     `json:"mode" gorm:"not null"`
+	SecretHash string     `json:"-" gorm:"index;not null"` // SHA-256 del token del enlace o HMAC del código
+	DeviceHash string     `json:"-"`                       // SHA-256 del secreto del navegador que lo pidió
+	Attempts   int        `json:"attempts"`                // Códigos incorrectos probados
+	IP         string     `json:"ip"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"index"`
+	ConsumedAt *time.Time `json:"consumed_at,omitempty"` // Ya se usó (o se invalidó por demasiados intentos)
+	CreatedAt  time.Time  `json:"created_at"`
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Tipos de concesión (grant types) que puede usar un cliente OAuth
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+)
+
+// Estados de una petición de autorización
+const (
+	OAuthAuthorizationPending  = "pending"  // Esperando a que el usuario acepte o rechace
+	OAuthAuthorizationApproved = "approved" // Aceptada: el código se puede canjear
+	OAuthAuthorizationDenied   = "denied"
+	OAuthAuthorizationUsed     = "used" // El código ya se canjeó
+)
+
+// OAuthClient es una aplicación que usa nuestras cuentas para iniciar sesión ("Iniciar sesión con...")
+// o que pide tokens en su propio nombre (client_credentials)
+type OAuthClient struct {
+	ID           string    `json:"client_id" gorm:"primaryKey"`
+	Name         string    `json:"name" gorm:"not null"`
+	SecretHash   string    `json:"-"`                                    // Vacío en los clientes públicos
+	Public       bool      `json:"public" gorm:"not null;default:false"` // SPA o app nativa: no puede guardar un secreto
+	RedirectURIs string    `json:"redirect_uris"`                        // Separadas por espacios, se comparan exactas
+	Scopes       string    `json:"scopes"`                               // Los que puede pedir, separados por espacios
+	GrantTypes   string    `json:"grant_types"`                          // Separados por espacios
+	SkipConsent  bool      `json:"skip_consent"`                         // Aplicación interna de confianza: no se pide consentimiento
+	CreatedBy    string    `json:"created_by"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Allows indica si el cliente puede usar el tipo de concesión 'grantType'
+func (c *OAuthClient) Allows(grantType string) bool {
+	return containsField(c.GrantTypes, grantType)
+}
+
+// AllowsRedirectURI indica si 'uri' es una de las URIs de redirección registradas (comparación exacta)
+func (c *OAuthClient) AllowsRedirectURI(uri string) bool {
+	return containsField(c.RedirectURIs, uri)
+}
+
+// OAuthAuthorization es una petición de autorización (GET /api/oauth/authorize): primero pendiente del
+// consentimiento del usuario y, si la acepta, con el código que el cliente canjea por los tokens
+type OAuthAuthorization struct {
+	ID            string     `json:"id" gorm:"primaryKey"` // UUID: lo recibe la pantalla de consentimiento
+	ClientID      string     `json:"client_id" gorm:"index;not null"`
+	RedirectURI   string     `json:"redirect_uri"`
+	Scope         string     `json:"scope"`
+	State         string     `json:"-"`
+	Nonce         string     `json:"-"`
+	CodeChallenge string     `json:"-"` // PKCE (S256)
+	Status        string     `json:"status" gorm:"not null"`
+	UserStore     string     `json:"-"`
+	UserID        string     `json:"-"`
+	CodeHash      string     `json:"-" gorm:"index"`
+	GrantID       string     `json:"-"` // Concesión creada al canjear el código
+	AuthTime      *time.Time `json:"-"` // Cuándo inició sesión el usuario que aceptó (claim "auth_time" del ID token)
+	ExpiresAt     time.Time  `json:"expires_at" gorm:"index"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// OAuthGrant es el permiso de un usuario a un cliente obtenido con un código de autorización.
+// Todos los tokens que salen de él (y de sus refresh tokens) llevan su ID: revocarlo los invalida a todos.
+type OAuthGrant struct {
+	ID        string     `json:"id" gorm:"primaryKey"`
+	ClientID  string     `json:"client_id" gorm:"index:idx_oauth_grant_user_client;not null"`
+	UserStore string     `json:"user_store" gorm:"index:idx_oauth_grant_user_client;not null"`
+	UserID    string     `json:"user_id" gorm:"index:idx_oauth_grant_user_client;not null"`
+	Scope     string     `json:"scope"`
+	AuthTime  *time.Time `json:"-"` // Del inicio de sesión original: los ID tokens de los refresh tokens
+	Nonce     string     `json:"-"` // llevan los mismos "auth_time" y "nonce"
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// OAuthRefreshToken es un refresh token (solo se guarda el hash). Se rota en cada uso: si un token ya usado
+// vuelve a aparecer, alguien lo ha copiado y se revoca toda la concesión.
+type OAuthRefreshToken struct {
+	ID        uint       `gorm:"primaryKey"`
+	TokenHash string     `gorm:"uniqueIndex;not null"`
+	GrantID   string     `gorm:"index;not null"`
+	ClientID  string     `gorm:"not null"`
+	ExpiresAt time.Time  `gorm:"index"`
+	UsedAt    *time.Time // Cuándo se canjeó (ya no vale)
+	CreatedAt time.Time
+}
+
+// OAuthConsent recuerda qué permisos aceptó un usuario para un cliente (para no volver a preguntar)
+type OAuthConsent struct {
+	ID        uint      `json:"-" gorm:"primaryKey"`
+	ClientID  string    `json:"client_id" gorm:"uniqueIndex:idx_oauth_consent;not null"`
+	UserStore string    `json:"-" gorm:"uniqueIndex:idx_oauth_consent;not null"`
+	UserID    string    `json:"-" gorm:"uniqueIndex:idx_oauth_consent;not null"`
+	Scope     string    `json:"scope"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OAuthRevokedToken es un token de acceso revocado antes de caducar (RFC 7009): su "jti" hasta que caduque
+type OAuthRevokedToken struct {
+	JTI       string    `gorm:"primaryKey"`
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+// containsField indica si 'value' es una de las palabras de 'list' (separadas por espacios)
+func containsField(list string, value string) bool {
+	for _, field := range strings.Fields(list) {
+		if field == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Estados de un email de la bandeja de salida
+const (
+	OutboxPending = "pending" // Esperando a enviarse (o a reintentarse en NextAttemptAt)
+	OutboxSending = "sending" // Un worker lo está enviando (si se cae, se recupera al pasar LockedUntil)
+	OutboxSent    = "sent"    // Enviado
+	OutboxDead    = "dead"    // Falló demasiadas veces: solo se reenvía a mano desde el panel de admin
+
+	OutboxSuppressed = "suppressed" // No se envió: el destinatario está en la lista de supresión
+)
+
+// OutboxEmail es un email pendiente de enviar (bandeja de salida).
+// Se guarda en la misma transacción que el cambio que lo provoca (ej: el alta del usuario),
+// así no se pierde si el SMTP falla o la API se reinicia.
+type OutboxEmail struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	Kind          string     `json:"kind"`
+	Recipient     string     `json:"recipient" gorm:"not null"`
+	Locale        string     `json:"locale,omitempty"`
+	Subject       string     `json:"subject"`
+	Body          string     `json:"body,omitempty" gorm:"type:text"`      // HTML
+	TextBody      string     `json:"text_body,omitempty" gorm:"type:text"` // Texto plano
+	Sensitive     bool       `json:"sensitive"`                            // El cuerpo lleva un secreto: no se muestra y se borra al enviarse
+	Status        string     `json:"status" gorm:"index:idx_outbox_next;not null"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"index:idx_outbox_next"`
+	LockedUntil   *time.Time `json:"-"`
+	LastError     string     `json:"last_error,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
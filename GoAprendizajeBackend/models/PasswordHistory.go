@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// PasswordHistory guarda los hashes de las contraseñas anteriores de un usuario (Postgres)
+// para impedir que las reutilice al cambiarla
+type PasswordHistory struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"index;not null"`
+	Hash      string    `json:"-" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Formas de iniciar sesión (con qué se creó la sesión)
+const (
+	SessionMethodPassword  = "password"
+	SessionMethodMagicLink = "magic_link"
+	SessionMethodMagicCode = "magic_code"
+)
+
+// Session es un inicio de sesión: cada token emitido lleva su ID en el claim "sid".
+// Permite al usuario ver dónde tiene la sesión abierta y cerrarla (revocar ese token) desde otro dispositivo.
+type Session struct {
+	ID         string     `json:"id" gorm:"primaryKey"` // UUID (claim "sid" del token)
+	UserStore  string     `json:"user_store" gorm:"index:idx_session_user;not null"`
+	UserID     string     `json:"user_id" gorm:"index:idx_session_user;not null"`
+	Method     string     `json:"method"`
+	UserAgent  string     `json:"user_agent"`
+	Browser    string     `json:"browser"`     // Ej: "Firefox 131"
+	OS         string     `json:"os"`          // Ej: "Windows", "iOS 17"
+	DeviceType string     `json:"device_type"` // "desktop", "mobile", "tablet", "bot" u "other"
+	FirstIP    string     `json:"first_ip"`
+	LastIP     string     `json:"last_ip"`
+	CreatedAt  time.Time  `json:"first_seen_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"index"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy  string     `json:"revoked_by,omitempty"` // "self", "admin:<id>" o "system"
+	// Hash del refresh token (solo en el modo cookie): permite renovar el token de acceso hasta ExpiresAt
+	RefreshHash string `json:"-"`
+	// El refresh token anterior y cuándo se rotó: si vuelve a aparecer justo después es otra pestaña que lo
+	// renovaba a la vez; más tarde, alguien lo ha copiado (y se cierra la sesión)
+	PreviousRefreshHash string     `json:"-"`
+	RefreshRotatedAt    *time.Time `json:"-"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Tipos de archivo guardados
+const (
+	StoredFileProfilePicture = "profile_picture" // Pública (se sirve en /static)
+	StoredFilePrivate        = "private"         // Privada (solo con token o URL firmada)
+)
+
+// Estados del análisis antivirus de un archivo
+const (
+	ScanNotScanned = ""         // Subido sin antivirus (o foto de perfil, que se vuelve a codificar)
+	ScanPending    = "pending"  // En cuarentena esperando al antivirus
+	ScanClean      = "clean"    // Analizado y limpio
+	ScanInfected   = "infected" // Se encontró malware: el archivo se borró
+	ScanFailed     = "failed"   // No se pudo analizar tras varios intentos (sigue sin poder descargarse)
+)
+
+// StoredFile registra cada archivo subido y a quién pertenece.
+// Sirve para calcular la cuota de cada usuario sin recorrer el almacenamiento.
+type StoredFile struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	Key         string `json:"key" gorm:"uniqueIndex;not null"`                       // Clave en el backend de almacenamiento
+	UserStore   string `json:"user_store" gorm:"index:idx_stored_file_user;not null"` // "postgres" o "mongo"
+	UserID      string `json:"user_id" gorm:"index:idx_stored_file_user;not null"`
+	Kind        string `json:"kind" gorm:"not null"`
+	Size        int64  `json:"size"`         // Bytes (incluidas las miniaturas)
+	FileName    string `json:"file_name"`    // Nombre original (para el Content-Disposition de la descarga)
+	ContentType string `json:"content_type"` // Detectado por el contenido
+
+	ScanStatus    string     `json:"scan_status" gorm:"index"`
+	ScanSignature string     `json:"scan_signature,omitempty"` // Firma del malware encontrado
+	ScanAttempts  int        `json:"-"`
+	ScannedAt     *time.Time `json:"scanned_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
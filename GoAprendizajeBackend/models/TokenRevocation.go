@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// TokenRevocation invalida todos los tokens de un usuario emitidos antes de 'RevokedBefore'
+// (se usa al borrar la cuenta o al "cerrar sesión en todos los dispositivos")
+type TokenRevocation struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	UserStore     string    `json:"user_store" gorm:"uniqueIndex:idx_token_revocation_user;not null"`
+	UserID        string    `json:"user_id" gorm:"uniqueIndex:idx_token_revocation_user;not null"`
+	RevokedBefore time.Time `json:"revoked_before" gorm:"not null"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Destinos de una subida reanudable (qué se hace con el archivo cuando está completo)
+const (
+	TusPurposeProfilePicture = "profile_picture" // Se procesa como foto de perfil
+	TusPurposePrivateFile    = "private_file"    // Se guarda como archivo privado del usuario
+)
+
+// TusUpload es una subida reanudable (protocolo tus) en curso.
+// Los trozos se guardan en el almacenamiento ("tus/<id>/<offset>") y aquí llevamos la cuenta de lo recibido.
+type TusUpload struct {
+	ID        string    `json:"id" gorm:"primaryKey"` // UUID (va en la URL de la subida)
+	UserStore string    `json:"user_store" gorm:"not null"`
+	UserID    string    `json:"user_id" gorm:"index;not null"`
+	Purpose   string    `json:"purpose" gorm:"not null"`
+	FileName  string    `json:"file_name"`                          // Del "Upload-Metadata" (solo informativo)
+	Length    int64     `json:"length"`                             // Tamaño total anunciado en "Upload-Length"
+	Offset    int64     `json:"offset" gorm:"column:upload_offset"` // Bytes recibidos hasta ahora ("offset" es palabra reservada en SQL)
+	Metadata  string    `json:"metadata"`                           // "Upload-Metadata" tal cual (se devuelve en el HEAD)
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -14,6 +14,19 @@ type User struct {
 	Password         string `json:"password" gorm:"not null"`
 	Role             string `json:"role" gorm:"default:'user';not null"`
 	ProfileImagePath string `json:"profile_image_path" gorm:"default:null"`
+	Locale           string `json:"locale" gorm:"size:10"` // Idioma de los emails ("es", "en"...). Vacío = DEFAULT_LOCALE
+
+	// Cuenta de servicio (para scripts): sin contraseña, no puede iniciar sesión, solo se usa con API keys
+	ServiceAccount bool `json:"service_account" gorm:"not null;default:false"`
+
+	// Si su email está en la lista de supresión (rebota o se quejó), cuándo y por qué: no se le envían emails
+	EmailSuppressedAt      *time.Time `json:"email_suppressed_at,omitempty"`
+	EmailSuppressionReason string     `json:"email_suppression_reason,omitempty"`
+
+	// Borrado de cuenta programado (derecho al olvido): nil si no se pidió
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty" gorm:"index"`
+	DeletionRequestedAt *time.Time `json:"-"`
+	DeletionRequestedBy string     `json:"-"` // "self" o "admin:<id>"
 }
 
 // MongoUser representa el modelo de usuario en la base de datos MongoDB
@@ -30,6 +43,28 @@ type MongoUser struct {
 	Password string `bson:"password" json:"password"` // bson es el nombre de la variable en MongoDB
 	Role     string `bson:"role" json:"role"`
 
+	// Clave de la foto de perfil en el almacenamiento (igual que en Postgres)
+	ProfileImagePath string `bson:"profile_image_path,omitempty" json:"profile_image_path,omitempty"`
+
+	// Idioma de los emails ("es", "en"...). Vacío = DEFAULT_LOCALE
+	Locale string `bson:"locale,omitempty" json:"locale,omitempty"`
+
+	// Si su email está en la lista de supresión (rebota o se quejó): no se le envían emails
+	EmailSuppressedAt      *time.Time `bson:"email_suppressed_at,omitempty" json:"email_suppressed_at,omitempty"`
+	EmailSuppressionReason string     `bson:"email_suppression_reason,omitempty" json:"email_suppression_reason,omitempty"`
+
+	// Hashes de las últimas contraseñas (para no permitir reutilizarlas)
+	PasswordHistory []string `bson:"password_history,omitempty" json:"-"`
+
+	// Borrado de cuenta programado (derecho al olvido)
+	DeletionScheduledAt *time.Time `bson:"deletion_scheduled_at,omitempty" json:"deletion_scheduled_at,omitempty"`
+	DeletionRequestedAt *time.Time `bson:"deletion_requested_at,omitempty" json:"-"`
+	DeletionRequestedBy string     `bson:"deletion_requested_by,omitempty" json:"-"`
+
+	// El email de bienvenida aún no está en la bandeja de salida (que está en Postgres).
+	// Se marca en el mismo insert que crea al usuario y se quita al encolar el email (ver outbox.RelayMongoWelcomes).
+	WelcomePending bool `bson:"welcome_pending,omitempty" json:"-"`
+
 	CreatedAt time.Time `bson:"created_at"`
 	UpdatedAt time.Time `bson:"updated_at"`
 }
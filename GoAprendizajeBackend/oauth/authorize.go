@@ -0,0 +1,289 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"go-aprendizaje/config"
+	"go-aprendizaje/database"
+	"go-aprendizaje/models"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Flujo del código de autorización:
+//  1. La aplicación cliente manda al navegador a GET /api/oauth/authorize. Se valida y se guarda la petición
+//     como pendiente, y se redirige a la pantalla de consentimiento del frontend (OAUTH_CONSENT_URL?request=<id>)
+//  2. El frontend (con la sesión del usuario, que inicia sesión si hace falta) pide los datos de la petición
+//     y envía la decisión del usuario. Si acepta, se genera el código (de un solo uso, caduca en OAUTH_CODE_TTL)
+//  3. El frontend manda al navegador a la redirect_uri del cliente con el código, y el cliente lo canjea
+//     en POST /api/oauth/token junto con el code_verifier de PKCE
+
+// requestTTL es lo que tiene el usuario para aceptar o rechazar una petición de autorización
+const requestTTL = 10 * time.Minute
+
+// ErrRequestNotFound se devuelve si la petición de autorización no existe, caducó o ya se decidió
+var ErrRequestNotFound = errors.New("petición de autorización no encontrada o caducada")
+
+// AuthorizeRequest son los parámetros de GET /api/oauth/authorize
+type AuthorizeRequest struct {
+	ClientID            string `form:"client_id"`
+	RedirectURI         string `form:"redirect_uri"`
+	ResponseType        string `form:"response_type"`
+	Scope               string `form:"scope"`
+	State               string `form:"state"`
+	Nonce               string `form:"nonce"`
+	CodeChallenge       string `form:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method"`
+	Prompt              string `form:"prompt"`
+}
+
+// ResolveClient valida el cliente y la URI de redirección de la petición. Si fallan, el error se muestra al
+// usuario y no se redirige (redirigir a una URI no registrada convertiría el servidor en una redirección abierta).
+// Sin redirect_uri se usa la registrada, si el cliente solo tiene una.
+func ResolveClient(request *AuthorizeRequest) (*models.OAuthClient, *Error) {
+	client, err := GetClient(request.ClientID)
+	if err != nil {
+		return nil, newError("invalid_request", "client_id desconocido", http.StatusBadRequest)
+	}
+	if request.RedirectURI == "" {
+		if registered := strings.Fields(client.RedirectURIs); len(registered) == 1 {
+			request.RedirectURI = registered[0]
+		}
+	}
+	if !client.AllowsRedirectURI(request.RedirectURI) {
+		return nil, newError("invalid_request", "redirect_uri no registrada para este cliente", http.StatusBadRequest)
+	}
+	return client, nil
+}
+
+// StartAuthorization valida el resto de la petición (los errores se devuelven al cliente en la redirección)
+// y la guarda como pendiente del consentimiento del usuario
+func StartAuthorization(client *models.OAuthClient, request AuthorizeRequest) (*models.OAuthAuthorization, *Error) {
+	if request.ResponseType != "code" {
+		return nil, newError("unsupported_response_type", "Solo se admite response_type=code", http.StatusBadRequest)
+	}
+	if !client.Allows(models.GrantAuthorizationCode) {
+		return nil, newError("unauthorized_client", "El cliente no puede usar el código de autorización", http.StatusBadRequest)
+	}
+	// PKCE obligatorio para todos los clientes (OAuth 2.1), y solo con S256 ("plain" no protege nada)
+	if request.CodeChallenge == "" || request.CodeChallengeMethod != "S256" {
+		return nil, newError("invalid_request", "Falta code_challenge con code_challenge_method=S256 (PKCE)", http.StatusBadRequest)
+	}
+	if len(request.CodeChallenge) != 43 {
+		return nil, newError("invalid_request", "code_challenge inválido", http.StatusBadRequest)
+	}
+	scope, err := requestedScope(request.Scope, client.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	// Aquí no se sabe quién es el usuario (inicia sesión en el frontend): no se puede autorizar sin mostrarle nada
+	if slices.Contains(strings.Fields(request.Prompt), "none") {
+		return nil, newError("login_required", "prompt=none no está soportado: el usuario tiene que iniciar sesión", http.StatusBadRequest)
+	}
+
+	authorization := &models.OAuthAuthorization{
+		ID:            uuid.NewString(),
+		ClientID:      client.ID,
+		RedirectURI:   request.RedirectURI,
+		Scope:         scope,
+		State:         request.State,
+		Nonce:         request.Nonce,
+		CodeChallenge: request.CodeChallenge,
+		Status:        models.OAuthAuthorizationPending,
+		ExpiresAt:     time.Now().Add(requestTTL),
+	}
+	if err := database.DB.Create(authorization).Error; err != nil {
+		return nil, newError("server_error", "No se pudo guardar la petición de autorización", http.StatusInternalServerError)
+	}
+	return authorization, nil
+}
+
+// GetPendingAuthorization devuelve una petición que todavía espera la decisión del usuario, con su cliente
+func GetPendingAuthorization(id string) (*models.OAuthAuthorization, *models.OAuthClient, error) {
+	var authorization models.OAuthAuthorization
+	err := database.DB.Where("id = ? AND status = ? AND expires_at > ?", id, models.OAuthAuthorizationPending, time.Now()).
+		First(&authorization).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrRequestNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := GetClient(authorization.ClientID)
+	if errors.Is(err, ErrClientNotFound) {
+		return nil, nil, ErrRequestNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &authorization, client, nil
+}
+
+// ConsentRequired indica si hay que preguntar al usuario: no, si el cliente es de confianza (SkipConsent)
+// o si el usuario ya aceptó antes todos los permisos que se piden
+func ConsentRequired(authorization *models.OAuthAuthorization, client *models.OAuthClient, store string, userID string) bool {
+	if client.SkipConsent {
+		return false
+	}
+	var consent models.OAuthConsent
+	err := database.DB.Where("client_id = ? AND user_store = ? AND user_id = ?", client.ID, store, userID).First(&consent).Error
+	if err != nil {
+		return true
+	}
+	for _, scope := range strings.Fields(authorization.Scope) {
+		if !slices.Contains(strings.Fields(consent.Scope), scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// Decide guarda la decisión del usuario y devuelve la URL del cliente a la que hay que mandar al navegador:
+// con el código si aceptó, o con error=access_denied si no. 'authTime' es cuándo inició sesión el usuario.
+func Decide(id string, store string, userID string, approve bool, authTime time.Time) (string, error) {
+	authorization, client, err := GetPendingAuthorization(id)
+	if err != nil {
+		return "", err
+	}
+
+	if !approve {
+		result := database.DB.Model(&models.OAuthAuthorization{}).
+			Where("id = ? AND status = ?", id, models.OAuthAuthorizationPending).
+			Updates(map[string]interface{}{"status": models.OAuthAuthorizationDenied, "user_store": store, "user_id": userID})
+		if result.Error != nil {
+			return "", result.Error
+		}
+		if result.RowsAffected == 0 {
+			return "", ErrRequestNotFound
+		}
+		return ErrorRedirect(authorization.RedirectURI, newError("access_denied", "El usuario no dio permiso", http.StatusForbidden), authorization.State), nil
+	}
+
+	// 1. Una cuenta con el borrado programado no puede dar acceso a nadie
+	if _, err := findUser(store, userID); err != nil {
+		return "", err
+	}
+
+	// 2. Generar el código (solo se guarda su hash) y marcar la petición como aceptada.
+	// El UPDATE solo funciona si sigue pendiente: si se decide dos veces a la vez, solo una vale.
+	codeBytes := make([]byte, 32)
+	if _, err := rand.Read(codeBytes); err != nil {
+		return "", err
+	}
+	code := base64.RawURLEncoding.EncodeToString(codeBytes)
+	result := database.DB.Model(&models.OAuthAuthorization{}).
+		Where("id = ? AND status = ?", id, models.OAuthAuthorizationPending).
+		Updates(map[string]interface{}{
+			"status":     models.OAuthAuthorizationApproved,
+			"user_store": store,
+			"user_id":    userID,
+			"code_hash":  hashSecret(code),
+			"auth_time":  authTime,
+			"expires_at": time.Now().Add(durationFromEnv("OAUTH_CODE_TTL", time.Minute)),
+		})
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return "", ErrRequestNotFound
+	}
+
+	// 3. Recordar el consentimiento (sumando los permisos a los que ya había aceptado)
+	if !client.SkipConsent {
+		if err := saveConsent(client.ID, store, userID, authorization.Scope); err != nil {
+			return "", err
+		}
+	}
+
+	return redirectWith(authorization.RedirectURI, url.Values{"code": {code}}, authorization.State), nil
+}
+
+// ListConsents devuelve las aplicaciones a las que el usuario dio permiso
+func ListConsents(store string, userID string) ([]models.OAuthConsent, error) {
+	var consents []models.OAuthConsent
+	err := database.DB.Where("user_store = ? AND user_id = ?", store, userID).Order("updated_at desc").Find(&consents).Error
+	return consents, err
+}
+
+// RevokeConsent quita el permiso de un usuario a una aplicación: borra el consentimiento (se le volverá a
+// preguntar) y revoca sus concesiones, con lo que dejan de valer los tokens que tenga la aplicación
+func RevokeConsent(store string, userID string, clientID string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		deleted := tx.Where("client_id = ? AND user_store = ? AND user_id = ?", clientID, store, userID).Delete(&models.OAuthConsent{})
+		if deleted.Error != nil {
+			return deleted.Error
+		}
+		revoked := tx.Model(&models.OAuthGrant{}).
+			Where("client_id = ? AND user_store = ? AND user_id = ? AND revoked_at IS NULL", clientID, store, userID).
+			Update("revoked_at", time.Now())
+		if revoked.Error != nil {
+			return revoked.Error
+		}
+		if deleted.RowsAffected == 0 && revoked.RowsAffected == 0 {
+			return ErrClientNotFound
+		}
+		return nil
+	})
+}
+
+// ErrorRedirect es la URL del cliente con el error (para los errores que no son del cliente o la redirect_uri)
+func ErrorRedirect(redirectURI string, err *Error, state string) string {
+	return redirectWith(redirectURI, url.Values{"error": {err.Code}, "error_description": {err.Description}}, state)
+}
+
+// ConsentURL es la pantalla de consentimiento del frontend para una petición
+func ConsentURL(authorization *models.OAuthAuthorization) string {
+	return consentBaseURL() + "?request=" + url.QueryEscape(authorization.ID)
+}
+
+// redirectWith añade los parámetros a la redirect_uri, más "state" y el emisor "iss" (RFC 9207: así el cliente
+// sabe qué servidor le responde aunque use varios)
+func redirectWith(redirectURI string, params url.Values, state string) string {
+	if state != "" {
+		params.Set("state", state)
+	}
+	params.Set("iss", Issuer())
+	separator := "?"
+	if strings.Contains(redirectURI, "?") {
+		separator = "&"
+	}
+	return redirectURI + separator + params.Encode()
+}
+
+// requestedScope valida los permisos pedidos contra los del cliente (sin "scope" se dan todos los del cliente)
+func requestedScope(requested string, allowed string) (string, *Error) {
+	scopes := unique(strings.Fields(requested))
+	if len(scopes) == 0 {
+		return allowed, nil
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(strings.Fields(allowed), scope) {
+			return "", newError("invalid_scope", "Permiso no permitido para este cliente: "+scope, http.StatusBadRequest)
+		}
+	}
+	return strings.Join(scopes, " "), nil
+}
+
+func saveConsent(clientID string, store string, userID string, scope string) error {
+	var existing models.OAuthConsent
+	if err := database.DB.Where("client_id = ? AND user_store = ? AND user_id = ?", clientID, store, userID).First(&existing).Error; err == nil {
+		scope = strings.Join(unique(append(strings.Fields(existing.Scope), strings.Fields(scope)...)), " ")
+	}
+	consent := models.OAuthConsent{ClientID: clientID, UserStore: store, UserID: userID, Scope: scope}
+	return database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "client_id"}, {Name: "user_store"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"scope", "updated_at"}),
+	}).Create(&consent).Error
+}
+
+func consentBaseURL() string {
+	return config.GetEnv("OAUTH_CONSENT_URL", config.GetEnv("FRONTEND_URL", "http://localhost:3000")+"/oauth/consent")
+}
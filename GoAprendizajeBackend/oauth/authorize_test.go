@@ -0,0 +1,126 @@
+package oauth
+
+import (
+	"go-aprendizaje/models"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// PKCE es obligatorio y solo con S256
+func TestStartAuthorizationPKCE(t *testing.T) {
+	client := &models.OAuthClient{ID: "app", Scopes: "openid profile", GrantTypes: models.GrantAuthorizationCode}
+	challenge := challengeFor("verificador-pkce-de-la-aplicacion-0123456789abcdef")
+	base := AuthorizeRequest{ClientID: "app", RedirectURI: "https://app.example.com/callback", ResponseType: "code"}
+
+	rejected := map[string]AuthorizeRequest{
+		"sin code_challenge":        base,
+		"sin método":                withPKCE(base, challenge, ""),
+		"método plain":              withPKCE(base, challenge, "plain"),
+		"challenge demasiado corto": withPKCE(base, challenge[:20], "S256"),
+	}
+	for name, request := range rejected {
+		t.Run(name, func(t *testing.T) {
+			mock := setupOAuthTest(t)
+			if _, err := StartAuthorization(client, request); err == nil || err.Code != "invalid_request" {
+				t.Fatalf("StartAuthorization = %v; se esperaba invalid_request", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	t.Run("S256", func(t *testing.T) {
+		mock := setupOAuthTest(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO "o_auth_authorizations"`).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		authorization, err := StartAuthorization(client, withPKCE(base, challenge, "S256"))
+		if err != nil {
+			t.Fatalf("StartAuthorization: %v", err)
+		}
+		if authorization.CodeChallenge != challenge || authorization.Status != models.OAuthAuthorizationPending {
+			t.Fatalf("authorization = %+v", authorization)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func withPKCE(request AuthorizeRequest, challenge string, method string) AuthorizeRequest {
+	request.CodeChallenge = challenge
+	request.CodeChallengeMethod = method
+	return request
+}
+
+func TestValidVerifier(t *testing.T) {
+	verifier := "verificador-pkce-de-la-aplicacion-0123456789abcdef"
+	challenge := challengeFor(verifier)
+
+	if !validVerifier(verifier, challenge) {
+		t.Fatal("el verificador correcto no coincide")
+	}
+	for name, presented := range map[string]string{
+		"vacío":     "",
+		"otro":      "otro-verificador-que-no-es-el-de-la-aplicacion-012345",
+		"plain":     challenge, // El propio challenge (lo que mandaría un cliente con method=plain)
+		"muy corto": "corto",
+	} {
+		if validVerifier(presented, challenge) {
+			t.Errorf("%s: validVerifier aceptó %q", name, presented)
+		}
+	}
+}
+
+// La redirect_uri se compara exacta con las registradas: ni prefijos, ni barras de más, ni parámetros
+func TestResolveClientRedirectURI(t *testing.T) {
+	expectClient := func(mock sqlmock.Sqlmock, redirectURIs string) {
+		mock.ExpectQuery(`SELECT \* FROM "o_auth_clients" WHERE id = \$1`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "redirect_uris", "grant_types"}).
+				AddRow("app", redirectURIs, models.GrantAuthorizationCode))
+	}
+	const registered = "https://app.example.com/callback com.ejemplo.app:/oauth"
+
+	for _, redirectURI := range []string{"https://app.example.com/callback", "com.ejemplo.app:/oauth"} {
+		t.Run(redirectURI, func(t *testing.T) {
+			mock := setupOAuthTest(t)
+			expectClient(mock, registered)
+			request := AuthorizeRequest{ClientID: "app", RedirectURI: redirectURI}
+			if _, err := ResolveClient(&request); err != nil {
+				t.Fatalf("ResolveClient: %v", err)
+			}
+		})
+	}
+
+	for _, redirectURI := range []string{
+		"https://app.example.com/callback/",
+		"https://app.example.com/callback?next=/admin",
+		"https://app.example.com/callback/../evil",
+		"https://app.example.com/callbackx",
+		"https://APP.example.com/callback",
+		"http://app.example.com/callback",
+		"https://evil.example/?https://app.example.com/callback",
+		"", // Sin redirect_uri y con dos registradas no se sabe a cuál volver
+	} {
+		t.Run("rechaza "+redirectURI, func(t *testing.T) {
+			mock := setupOAuthTest(t)
+			expectClient(mock, registered)
+			request := AuthorizeRequest{ClientID: "app", RedirectURI: redirectURI}
+			if _, err := ResolveClient(&request); err == nil || err.Code != "invalid_request" {
+				t.Fatalf("ResolveClient = %v; se esperaba invalid_request", err)
+			}
+		})
+	}
+
+	t.Run("sin redirect_uri se usa la única registrada", func(t *testing.T) {
+		mock := setupOAuthTest(t)
+		expectClient(mock, "https://app.example.com/callback")
+		request := AuthorizeRequest{ClientID: "app"}
+		if _, err := ResolveClient(&request); err != nil || request.RedirectURI != "https://app.example.com/callback" {
+			t.Fatalf("ResolveClient = %v, redirect_uri = %q", err, request.RedirectURI)
+		}
+	})
+}
@@ -0,0 +1,253 @@
+package oauth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"go-aprendizaje/database"
+	"go-aprendizaje/models"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Clientes OAuth: los registra un admin. Los confidenciales (servidores) reciben un secreto "cs_..." que solo se
+// muestra al crearlo o rotarlo; de él solo se guarda el hash SHA-256. Los públicos (SPA, apps nativas) no tienen.
+
+var (
+	// ErrClientNotFound se devuelve si el cliente no existe
+	ErrClientNotFound = errors.New("cliente OAuth no encontrado")
+	// ErrInvalidClientConfig se devuelve si los datos del cliente no son coherentes (el mensaje dice por qué)
+	ErrInvalidClientConfig = errors.New("configuración del cliente OAuth inválida")
+)
+
+// ClientInput son los datos para registrar un cliente
+type ClientInput struct {
+	Name         string
+	Public       bool
+	RedirectURIs []string
+	Scopes       []string // Vacío = todos (ver Scopes)
+	GrantTypes   []string // Vacío = authorization_code y refresh_token
+	SkipConsent  bool
+}
+
+// clientConfigError es un ErrInvalidClientConfig con el motivo
+type clientConfigError struct{ reason string }
+
+func (e *clientConfigError) Error() string { return ErrInvalidClientConfig.Error() + ": " + e.reason }
+func (e *clientConfigError) Unwrap() error { return ErrInvalidClientConfig }
+
+// CreateClient registra un cliente. Devuelve su secreto (vacío si es público): es la única vez que se puede ver.
+func CreateClient(input ClientInput, createdBy string) (*models.OAuthClient, string, error) {
+	// 1. Validar
+	if len(input.Scopes) == 0 {
+		input.Scopes = Scopes
+	}
+	if len(input.GrantTypes) == 0 {
+		input.GrantTypes = []string{models.GrantAuthorizationCode, models.GrantRefreshToken}
+	}
+	if err := validateClient(input); err != nil {
+		return nil, "", err
+	}
+
+	// 2. Generar el client_id y, si es confidencial, el secreto
+	idBytes := make([]byte, 12)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, "", err
+	}
+	client := &models.OAuthClient{
+		ID:           hex.EncodeToString(idBytes),
+		Name:         strings.TrimSpace(input.Name),
+		Public:       input.Public,
+		RedirectURIs: strings.Join(input.RedirectURIs, " "),
+		Scopes:       strings.Join(unique(input.Scopes), " "),
+		GrantTypes:   strings.Join(unique(input.GrantTypes), " "),
+		SkipConsent:  input.SkipConsent,
+		CreatedBy:    createdBy,
+	}
+	secret := ""
+	if !input.Public {
+		var err error
+		if secret, err = newClientSecret(); err != nil {
+			return nil, "", err
+		}
+		client.SecretHash = hashSecret(secret)
+	}
+
+	if err := database.DB.Create(client).Error; err != nil {
+		return nil, "", err
+	}
+	return client, secret, nil
+}
+
+// ListClients devuelve todos los clientes registrados
+func ListClients() ([]models.OAuthClient, error) {
+	var clients []models.OAuthClient
+	err := database.DB.Order("created_at").Find(&clients).Error
+	return clients, err
+}
+
+// GetClient busca un cliente por su client_id
+func GetClient(clientID string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	if err := database.DB.Where("id = ?", clientID).First(&client).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrClientNotFound
+		}
+		return nil, err
+	}
+	return &client, nil
+}
+
+// DeleteClient borra un cliente y todo lo que salió de él: sus tokens dejan de valer al momento
+func DeleteClient(clientID string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", clientID).Delete(&models.OAuthClient{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrClientNotFound
+		}
+		for _, model := range []interface{}{&models.OAuthRefreshToken{}, &models.OAuthConsent{}, &models.OAuthAuthorization{}} {
+			if err := tx.Where("client_id = ?", clientID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		// Las concesiones se marcan como revocadas (no se borran) para que ParseAccessToken rechace sus tokens
+		return tx.Model(&models.OAuthGrant{}).Where("client_id = ? AND revoked_at IS NULL", clientID).
+			Update("revoked_at", time.Now()).Error
+	})
+}
+
+// RotateSecret genera un secreto nuevo para un cliente confidencial (el anterior deja de valer)
+func RotateSecret(clientID string) (string, error) {
+	client, err := GetClient(clientID)
+	if err != nil {
+		return "", err
+	}
+	if client.Public {
+		return "", &clientConfigError{"un cliente público no tiene secreto"}
+	}
+	secret, err := newClientSecret()
+	if err != nil {
+		return "", err
+	}
+	if err := database.DB.Model(client).Update("secret_hash", hashSecret(secret)).Error; err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// AuthenticateClient identifica al cliente de una petición al endpoint de tokens, de introspección o de revocación.
+// Los confidenciales tienen que enviar su secreto; los públicos solo se identifican (no se pueden autenticar).
+func AuthenticateClient(clientID string, secret string) (*models.OAuthClient, error) {
+	if clientID == "" {
+		return nil, errInvalidClient
+	}
+	client, err := GetClient(clientID)
+	if errors.Is(err, ErrClientNotFound) {
+		return nil, errInvalidClient
+	}
+	if err != nil {
+		return nil, err
+	}
+	if client.Public {
+		if secret != "" {
+			return nil, errInvalidClient
+		}
+		return client, nil
+	}
+	if secret == "" || !hmac.Equal([]byte(hashSecret(secret)), []byte(client.SecretHash)) {
+		return nil, errInvalidClient
+	}
+	return client, nil
+}
+
+// validateClient comprueba que los tipos de concesión, permisos y URIs de redirección tengan sentido juntos
+func validateClient(input ClientInput) error {
+	if strings.TrimSpace(input.Name) == "" {
+		return &clientConfigError{"falta el nombre"}
+	}
+	for _, scope := range input.Scopes {
+		if !slices.Contains(Scopes, scope) {
+			return &clientConfigError{"permiso desconocido: " + scope}
+		}
+	}
+	for _, grantType := range input.GrantTypes {
+		switch grantType {
+		case models.GrantAuthorizationCode, models.GrantRefreshToken:
+		case models.GrantClientCredentials:
+			if input.Public {
+				return &clientConfigError{"un cliente público no puede usar client_credentials"}
+			}
+		default:
+			return &clientConfigError{"tipo de concesión desconocido: " + grantType}
+		}
+	}
+	usesCode := slices.Contains(input.GrantTypes, models.GrantAuthorizationCode)
+	if slices.Contains(input.GrantTypes, models.GrantRefreshToken) && !usesCode {
+		return &clientConfigError{"refresh_token solo tiene sentido con authorization_code"}
+	}
+	if usesCode && len(input.RedirectURIs) == 0 {
+		return &clientConfigError{"authorization_code necesita al menos una URI de redirección"}
+	}
+	for _, redirectURI := range input.RedirectURIs {
+		if !validRedirectURI(redirectURI) {
+			return &clientConfigError{"URI de redirección no permitida: " + redirectURI}
+		}
+	}
+	return nil
+}
+
+// validRedirectURI acepta https, http solo en loopback (apps nativas y desarrollo) y esquemas propios de apps
+// nativas con punto (ej: "com.ejemplo.app:/callback", RFC 8252). Nunca con fragmento (#).
+func validRedirectURI(redirectURI string) bool {
+	parsed, err := url.Parse(redirectURI)
+	if err != nil || parsed.Fragment != "" || strings.ContainsAny(redirectURI, " #") {
+		return false
+	}
+	switch parsed.Scheme {
+	case "https":
+		return parsed.Host != ""
+	case "http":
+		host := parsed.Hostname()
+		ip := net.ParseIP(host)
+		return host == "localhost" || (ip != nil && ip.IsLoopback())
+	case "":
+		return false
+	default:
+		return strings.Contains(parsed.Scheme, ".")
+	}
+}
+
+func newClientSecret() (string, error) {
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", err
+	}
+	return "cs_" + base64.RawURLEncoding.EncodeToString(secretBytes), nil
+}
+
+// hashSecret es el SHA-256 de un secreto aleatorio y largo (secretos de cliente, códigos y refresh tokens)
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func unique(values []string) []string {
+	result := []string{}
+	for _, value := range values {
+		if !slices.Contains(result, value) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,100 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"go-aprendizaje/config"
+	"log"
+	"math/big"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Los tokens de OAuth se firman con RSA (RS256) y no con el secreto HMAC de la API: así las aplicaciones
+// cliente pueden comprobar los ID tokens con la clave pública (JWKS) sin conocer ningún secreto.
+
+// signingKey es la clave privada con la que se firman los tokens (se inicializa con Init desde 'main.go')
+var signingKey *rsa.PrivateKey
+
+// keyID es el "kid" de la clave: su huella RFC 7638 (cambia si se cambia la clave)
+var keyID string
+
+// Init carga la clave de firma de OAUTH_SIGNING_KEY (ruta a un PEM con la clave privada RSA, PKCS#1 o PKCS#8).
+// Si no hay, genera una temporal: vale para desarrollo, pero los tokens dejan de valer al reiniciar
+// (y cada réplica firmaría con una clave distinta), así que en modo release (GIN_MODE=release) es obligatoria.
+func Init() {
+	path := config.GetEnv("OAUTH_SIGNING_KEY", "")
+	if path == "" {
+		if gin.Mode() == gin.ReleaseMode {
+			log.Fatal("Error fatal: OAUTH_SIGNING_KEY es obligatorio en modo release")
+		}
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			log.Fatal("Error fatal: No se pudo generar la clave de firma de OAuth: ", err)
+		}
+		log.Println("Aviso: OAUTH_SIGNING_KEY vacío, se usa una clave de firma temporal (los tokens de OAuth no sobreviven a un reinicio)")
+		setSigningKey(key)
+		return
+	}
+
+	key, err := loadSigningKey(path)
+	if err != nil {
+		log.Fatal("Error fatal: No se pudo cargar la clave de firma de OAuth: ", err)
+	}
+	setSigningKey(key)
+	log.Println("Servidor OAuth inicializado con la clave " + keyID)
+}
+
+func loadSigningKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("el archivo no es un PEM")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("la clave no es RSA")
+	}
+	return key, nil
+}
+
+func setSigningKey(key *rsa.PrivateKey) {
+	signingKey = key
+	// Huella RFC 7638: SHA-256 del JWK con solo los campos obligatorios, en orden alfabético y sin espacios
+	jwk := publicJWK(&key.PublicKey)
+	sum := sha256.Sum256([]byte(`{"e":"` + jwk["e"] + `","kty":"RSA","n":"` + jwk["n"] + `"}`))
+	keyID = base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// JWKS es el documento con las claves públicas (GET /api/oauth/jwks)
+func JWKS() map[string]interface{} {
+	jwk := publicJWK(&signingKey.PublicKey)
+	jwk["kid"] = keyID
+	jwk["use"] = "sig"
+	jwk["alg"] = "RS256"
+	return map[string]interface{}{"keys": []map[string]string{jwk}}
+}
+
+func publicJWK(key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
@@ -0,0 +1,135 @@
+package oauth
+
+import (
+	"archive/zip"
+	"go-aprendizaje/config"
+	"go-aprendizaje/database"
+	"go-aprendizaje/dataexport"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/models"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Servidor de autorización OAuth 2.1 / OpenID Connect: otras aplicaciones pueden usar nuestras cuentas
+// ("Iniciar sesión con...") o pedir tokens en su propio nombre.
+//   - Código de autorización con PKCE obligatorio (S256). Sin flujo implícito ni contraseña (los quita OAuth 2.1)
+//   - Credenciales de cliente (client_credentials) para los clientes confidenciales
+//   - Refresh tokens rotatorios: reutilizar uno ya usado revoca la concesión entera
+//   - Tokens de acceso e ID tokens JWT firmados con RS256 (clave pública en el JWKS)
+//   - Introspección (RFC 7662) y revocación (RFC 7009) de tokens
+// Los tokens de acceso de OAuth no sirven en el resto de la API (son para las aplicaciones cliente).
+
+// Permisos (scopes) que pueden pedir los clientes
+const (
+	ScopeOpenID        = "openid"         // Obligatorio para recibir un ID token y usar userinfo
+	ScopeProfile       = "profile"        // Idioma y foto de perfil en userinfo
+	ScopeEmail         = "email"          // Email en el ID token y en userinfo
+	ScopeOfflineAccess = "offline_access" // Refresh token (seguir con acceso sin que el usuario esté presente)
+)
+
+// Scopes son todos los permisos que conoce el servidor
+var Scopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopeOfflineAccess}
+
+// Error es un error de OAuth en el formato de la especificación ({"error", "error_description"})
+type Error struct {
+	Code        string // Ej: "invalid_grant"
+	Description string
+	Status      int // Código HTTP si se responde directamente (no con una redirección)
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func newError(code string, description string, status int) *Error {
+	return &Error{Code: code, Description: description, Status: status}
+}
+
+// Issuer es el identificador del servidor ("iss" de los tokens): la URL pública del backend, sin "/" final
+func Issuer() string {
+	return strings.TrimSuffix(config.GetEnv("OAUTH_ISSUER", "http://localhost:8080"), "/")
+}
+
+// Discovery es el documento de /.well-known/openid-configuration (y /.well-known/oauth-authorization-server)
+func Discovery() map[string]interface{} {
+	issuer := Issuer()
+	return map[string]interface{}{
+		"issuer":                                         issuer,
+		"authorization_endpoint":                         issuer + "/api/oauth/authorize",
+		"token_endpoint":                                 issuer + "/api/oauth/token",
+		"userinfo_endpoint":                              issuer + "/api/oauth/userinfo",
+		"jwks_uri":                                       issuer + "/api/oauth/jwks",
+		"introspection_endpoint":                         issuer + "/api/oauth/introspect",
+		"revocation_endpoint":                            issuer + "/api/oauth/revoke",
+		"scopes_supported":                               Scopes,
+		"response_types_supported":                       []string{"code"},
+		"response_modes_supported":                       []string{"query"},
+		"grant_types_supported":                          []string{models.GrantAuthorizationCode, models.GrantRefreshToken, models.GrantClientCredentials},
+		"subject_types_supported":                        []string{"public"},
+		"id_token_signing_alg_values_supported":          []string{"RS256"},
+		"code_challenge_methods_supported":               []string{"S256"},
+		"token_endpoint_auth_methods_supported":          []string{"client_secret_basic", "client_secret_post", "none"},
+		"introspection_endpoint_auth_methods_supported":  []string{"client_secret_basic", "client_secret_post"},
+		"revocation_endpoint_auth_methods_supported":     []string{"client_secret_basic", "client_secret_post", "none"},
+		"claims_supported":                               []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "azp", "email", "locale", "picture"},
+		"authorization_response_iss_parameter_supported": true,
+	}
+}
+
+// DeleteUserData borra las concesiones, refresh tokens, consentimientos y peticiones de un usuario (al borrar su cuenta).
+// Los tokens de acceso que queden dejan de valer porque su concesión ya no existe.
+func DeleteUserData(store string, userID string) error {
+	grants := database.DB.Model(&models.OAuthGrant{}).Select("id").Where("user_store = ? AND user_id = ?", store, userID)
+	if err := database.DB.Where("grant_id IN (?)", grants).Delete(&models.OAuthRefreshToken{}).Error; err != nil {
+		return err
+	}
+	if err := database.DB.Where("user_store = ? AND user_id = ?", store, userID).Delete(&models.OAuthGrant{}).Error; err != nil {
+		return err
+	}
+	if err := database.DB.Where("user_store = ? AND user_id = ?", store, userID).Delete(&models.OAuthConsent{}).Error; err != nil {
+		return err
+	}
+	return database.DB.Where("user_store = ? AND user_id = ?", store, userID).Delete(&models.OAuthAuthorization{}).Error
+}
+
+// StartJanitor arranca en segundo plano el borrado de lo caducado: peticiones de autorización,
+// refresh tokens y la lista de tokens de acceso revocados
+func StartJanitor(every time.Duration) {
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for range ticker.C {
+			now := time.Now()
+			var deleted int64
+			deleted += database.DB.Where("expires_at < ?", now).Delete(&models.OAuthAuthorization{}).RowsAffected
+			deleted += database.DB.Where("expires_at < ?", now).Delete(&models.OAuthRefreshToken{}).RowsAffected
+			deleted += database.DB.Where("expires_at < ?", now).Delete(&models.OAuthRevokedToken{}).RowsAffected
+			if deleted > 0 {
+				logging.Log.Infof("Datos de OAuth caducados borrados: %d", deleted)
+			}
+		}
+	}()
+}
+
+// ExportSection añade las aplicaciones autorizadas por el usuario a su exportación de datos personales (RGPD)
+func ExportSection(zipWriter *zip.Writer, export *models.DataExport) error {
+	consents, _ := ListConsents(export.UserStore, export.UserID)
+	return dataexport.WriteJSON(zipWriter, "oauth_consents.json", map[string]interface{}{"oauth_consents": consents})
+}
+
+// durationFromEnv lee una duración de la configuración (si falta o no es válida, 'fallback')
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(config.GetEnv(key, ""))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// Errores de OAuth frecuentes
+var (
+	errInvalidClient = newError("invalid_client", "Cliente desconocido o credenciales incorrectas", http.StatusUnauthorized)
+	errInvalidGrant  = newError("invalid_grant", "El código o el refresh token no es válido, caducó o ya se usó", http.StatusBadRequest)
+)
@@ -0,0 +1,481 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"go-aprendizaje/database"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/models"
+	"go-aprendizaje/security"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TokenResponse es la respuesta del endpoint de tokens
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// AccessClaims son los datos de un token de acceso válido
+type AccessClaims struct {
+	Subject   string // "<store>:<id>" o, con client_credentials, el client_id
+	UserStore string // Vacío si el token es del propio cliente (client_credentials)
+	UserID    string
+	ClientID  string
+	Scope     string
+	JTI       string
+	GrantID   string // Vacío con client_credentials
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// HasScope indica si el token tiene el permiso 'scope'
+func (c *AccessClaims) HasScope(scope string) bool {
+	return slices.Contains(strings.Fields(c.Scope), scope)
+}
+
+// ExchangeCode canjea un código de autorización (grant_type=authorization_code) por los tokens
+func ExchangeCode(client *models.OAuthClient, code string, redirectURI string, codeVerifier string) (*TokenResponse, error) {
+	if !client.Allows(models.GrantAuthorizationCode) {
+		return nil, newError("unauthorized_client", "El cliente no puede usar el código de autorización", http.StatusBadRequest)
+	}
+	if code == "" {
+		return nil, newError("invalid_request", "Falta el código", http.StatusBadRequest)
+	}
+
+	// 1. Buscar la petición por el hash del código
+	var authorization models.OAuthAuthorization
+	if err := database.DB.Where("code_hash = ?", hashSecret(code)).First(&authorization).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidGrant
+		}
+		return nil, err
+	}
+	if authorization.ClientID != client.ID {
+		return nil, errInvalidGrant
+	}
+	// Un código usado dos veces es que alguien lo interceptó: se revoca lo que se emitió con él
+	if authorization.Status == models.OAuthAuthorizationUsed {
+		if authorization.GrantID != "" {
+			revokeGrant(authorization.GrantID)
+			logging.Log.Warnf("Código de autorización reutilizado (cliente %s): concesión %s revocada", client.ID, authorization.GrantID)
+		}
+		return nil, errInvalidGrant
+	}
+	if authorization.Status != models.OAuthAuthorizationApproved || time.Now().After(authorization.ExpiresAt) {
+		return nil, errInvalidGrant
+	}
+
+	// 2. La redirect_uri tiene que ser la de la petición, y el code_verifier el de su code_challenge (PKCE)
+	if redirectURI != authorization.RedirectURI {
+		return nil, errInvalidGrant
+	}
+	if !validVerifier(codeVerifier, authorization.CodeChallenge) {
+		return nil, newError("invalid_grant", "code_verifier incorrecto", http.StatusBadRequest)
+	}
+
+	// 3. El usuario tiene que seguir activo
+	if _, err := findUser(authorization.UserStore, authorization.UserID); err != nil {
+		return nil, errInvalidGrant
+	}
+
+	// 4. Crear la concesión y marcar el código como usado (el UPDATE solo funciona una vez).
+	// La petición se conserva un rato más para reconocer si el código se vuelve a usar.
+	grant := models.OAuthGrant{
+		ID:        uuid.NewString(),
+		ClientID:  client.ID,
+		UserStore: authorization.UserStore,
+		UserID:    authorization.UserID,
+		Scope:     authorization.Scope,
+		AuthTime:  authorization.AuthTime,
+		Nonce:     authorization.Nonce,
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.OAuthAuthorization{}).
+			Where("id = ? AND status = ?", authorization.ID, models.OAuthAuthorizationApproved).
+			Updates(map[string]interface{}{
+				"status":     models.OAuthAuthorizationUsed,
+				"grant_id":   grant.ID,
+				"expires_at": time.Now().Add(requestTTL),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidGrant
+		}
+		return tx.Create(&grant).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return issueTokens(client, &grant, grant.Scope)
+}
+
+// RefreshTokens canjea un refresh token (grant_type=refresh_token) por tokens nuevos. El refresh token se rota:
+// el usado deja de valer y, si vuelve a aparecer, se revoca la concesión entera (alguien lo copió).
+// 'scope' puede pedir menos permisos que los de la concesión, nunca más.
+func RefreshTokens(client *models.OAuthClient, token string, scope string) (*TokenResponse, error) {
+	if !client.Allows(models.GrantRefreshToken) {
+		return nil, newError("unauthorized_client", "El cliente no puede usar refresh tokens", http.StatusBadRequest)
+	}
+
+	// 1. Buscar el token y su concesión
+	var refresh models.OAuthRefreshToken
+	if err := database.DB.Where("token_hash = ?", hashSecret(token)).First(&refresh).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidGrant
+		}
+		return nil, err
+	}
+	if refresh.ClientID != client.ID {
+		return nil, errInvalidGrant
+	}
+	if refresh.UsedAt != nil {
+		revokeGrant(refresh.GrantID)
+		logging.Log.Warnf("Refresh token de OAuth reutilizado (cliente %s): concesión %s revocada", client.ID, refresh.GrantID)
+		return nil, errInvalidGrant
+	}
+	if time.Now().After(refresh.ExpiresAt) {
+		return nil, errInvalidGrant
+	}
+	grant, err := activeGrant(refresh.GrantID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Permisos: los pedidos tienen que estar en la concesión
+	grantedScope := grant.Scope
+	if strings.TrimSpace(scope) != "" {
+		var scopeErr *Error
+		if grantedScope, scopeErr = requestedScope(scope, grant.Scope); scopeErr != nil {
+			return nil, scopeErr
+		}
+	}
+
+	// 3. El usuario tiene que seguir activo
+	if _, err := findUser(grant.UserStore, grant.UserID); err != nil {
+		return nil, errInvalidGrant
+	}
+
+	// 4. Marcar el token como usado (si dos peticiones lo usan a la vez, solo una lo consigue)
+	result := database.DB.Model(&models.OAuthRefreshToken{}).
+		Where("id = ? AND used_at IS NULL", refresh.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errInvalidGrant
+	}
+
+	return issueTokens(client, grant, grantedScope)
+}
+
+// ClientCredentials emite un token para el propio cliente (grant_type=client_credentials), sin usuario:
+// solo clientes confidenciales y sin refresh token ni ID token
+func ClientCredentials(client *models.OAuthClient, scope string) (*TokenResponse, error) {
+	if client.Public || !client.Allows(models.GrantClientCredentials) {
+		return nil, newError("unauthorized_client", "El cliente no puede usar client_credentials", http.StatusBadRequest)
+	}
+	// Sin usuario, openid y offline_access no tienen sentido
+	allowed := []string{}
+	for _, clientScope := range strings.Fields(client.Scopes) {
+		if clientScope != ScopeOpenID && clientScope != ScopeOfflineAccess {
+			allowed = append(allowed, clientScope)
+		}
+	}
+	granted, scopeErr := requestedScope(scope, strings.Join(allowed, " "))
+	if scopeErr != nil {
+		return nil, scopeErr
+	}
+
+	accessToken, expiresIn, err := signAccessToken(client.ID, client.ID, granted, "")
+	if err != nil {
+		return nil, err
+	}
+	return &TokenResponse{AccessToken: accessToken, TokenType: "Bearer", ExpiresIn: expiresIn, Scope: granted}, nil
+}
+
+// ParseAccessToken valida un token de acceso: firma, emisor, caducidad, que no se haya revocado (él, su concesión
+// o todos los tokens del usuario, ej: al borrar la cuenta) y que no sea un ID token
+func ParseAccessToken(tokenString string) (*AccessClaims, error) {
+	claims, err := parseSigned(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	var revoked int64
+	database.DB.Model(&models.OAuthRevokedToken{}).Where("jti = ?", claims.JTI).Count(&revoked)
+	if revoked > 0 {
+		return nil, errInvalidToken
+	}
+	if claims.GrantID != "" {
+		if _, err := activeGrant(claims.GrantID); err != nil {
+			return nil, errInvalidToken
+		}
+	} else if _, err := GetClient(claims.ClientID); err != nil {
+		// Los de client_credentials no tienen concesión: dejan de valer al borrar el cliente
+		return nil, errInvalidToken
+	}
+	if claims.UserStore != "" && security.IsTokenRevoked(claims.UserStore, claims.UserID, claims.IssuedAt) {
+		return nil, errInvalidToken
+	}
+	return claims, nil
+}
+
+// Introspect describe un token (RFC 7662) para los servidores de recursos. Si no es válido solo se dice
+// {"active": false}, sin explicar por qué.
+func Introspect(token string, tokenTypeHint string) map[string]interface{} {
+	inactive := map[string]interface{}{"active": false}
+
+	// El "token_type_hint" solo decide por dónde se empieza a buscar
+	lookups := []func(string) map[string]interface{}{introspectAccessToken, introspectRefreshToken}
+	if tokenTypeHint == "refresh_token" {
+		slices.Reverse(lookups)
+	}
+	for _, lookup := range lookups {
+		if response := lookup(token); response != nil {
+			return response
+		}
+	}
+	return inactive
+}
+
+// Revoke revoca un token del cliente (RFC 7009). Un token de acceso deja de valer; un refresh token revoca su
+// concesión entera (también los tokens de acceso que salieron de ella). Los tokens desconocidos, caducados
+// o de otro cliente se ignoran: la respuesta es la misma para no dar pistas.
+func Revoke(client *models.OAuthClient, token string) error {
+	if claims, err := parseSigned(token); err == nil {
+		if claims.ClientID != client.ID {
+			return nil
+		}
+		return database.DB.Where("jti = ?", claims.JTI).
+			FirstOrCreate(&models.OAuthRevokedToken{JTI: claims.JTI, ExpiresAt: claims.ExpiresAt}).Error
+	}
+
+	var refresh models.OAuthRefreshToken
+	if err := database.DB.Where("token_hash = ?", hashSecret(token)).First(&refresh).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if refresh.ClientID != client.ID {
+		return nil
+	}
+	return revokeGrant(refresh.GrantID)
+}
+
+// errInvalidToken se devuelve si un token de acceso no es válido (userinfo responde con WWW-Authenticate)
+var errInvalidToken = newError("invalid_token", "Token de acceso inválido, caducado o revocado", http.StatusUnauthorized)
+
+// issueTokens emite el token de acceso y, según los permisos, el ID token (openid) y el refresh token
+// (offline_access, si el cliente puede usar refresh tokens). El ID token lleva el "auth_time" y el "nonce"
+// del inicio de sesión original, también cuando sale de un refresh token.
+func issueTokens(client *models.OAuthClient, grant *models.OAuthGrant, scope string) (*TokenResponse, error) {
+	subject := Subject(grant.UserStore, grant.UserID)
+	accessToken, expiresIn, err := signAccessToken(subject, client.ID, scope, grant.ID)
+	if err != nil {
+		return nil, err
+	}
+	response := &TokenResponse{AccessToken: accessToken, TokenType: "Bearer", ExpiresIn: expiresIn, Scope: scope}
+	scopes := strings.Fields(scope)
+
+	if slices.Contains(scopes, ScopeOpenID) {
+		found, err := findUser(grant.UserStore, grant.UserID)
+		if err != nil {
+			return nil, errInvalidGrant
+		}
+		claims := jwt.MapClaims{
+			"iss": Issuer(),
+			"sub": subject,
+			"aud": client.ID,
+			"azp": client.ID,
+			"iat": time.Now().Unix(),
+			"exp": time.Now().Add(time.Duration(expiresIn) * time.Second).Unix(),
+		}
+		if grant.AuthTime != nil {
+			claims["auth_time"] = grant.AuthTime.Unix()
+		}
+		if grant.Nonce != "" {
+			claims["nonce"] = grant.Nonce
+		}
+		if slices.Contains(scopes, ScopeEmail) {
+			claims["email"] = found.email
+		}
+		if response.IDToken, err = sign(claims, "JWT"); err != nil {
+			return nil, err
+		}
+	}
+
+	if slices.Contains(scopes, ScopeOfflineAccess) && client.Allows(models.GrantRefreshToken) {
+		secretBytes := make([]byte, 32)
+		if _, err := rand.Read(secretBytes); err != nil {
+			return nil, err
+		}
+		refreshToken := "rt_" + base64.RawURLEncoding.EncodeToString(secretBytes)
+		err := database.DB.Create(&models.OAuthRefreshToken{
+			TokenHash: hashSecret(refreshToken),
+			GrantID:   grant.ID,
+			ClientID:  client.ID,
+			ExpiresAt: time.Now().Add(durationFromEnv("OAUTH_REFRESH_TTL", 30*24*time.Hour)),
+		}).Error
+		if err != nil {
+			return nil, err
+		}
+		response.RefreshToken = refreshToken
+	}
+	return response, nil
+}
+
+// signAccessToken firma un token de acceso (JWT con "typ": "at+jwt", RFC 9068). Devuelve su duración en segundos.
+func signAccessToken(subject string, clientID string, scope string, grantID string) (string, int64, error) {
+	ttl := durationFromEnv("OAUTH_ACCESS_TTL", 15*time.Minute)
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":       Issuer(),
+		"sub":       subject,
+		"aud":       Issuer(),
+		"client_id": clientID,
+		"scope":     scope,
+		"jti":       uuid.NewString(),
+		"iat":       now.Unix(),
+		"exp":       now.Add(ttl).Unix(),
+	}
+	if grantID != "" {
+		claims["gid"] = grantID
+	}
+	token, err := sign(claims, "at+jwt")
+	return token, int64(ttl.Seconds()), err
+}
+
+func sign(claims jwt.MapClaims, tokenType string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["typ"] = tokenType
+	token.Header["kid"] = keyID
+	return token.SignedString(signingKey)
+}
+
+// parseSigned comprueba la firma, el emisor, la caducidad y el tipo de un token de acceso
+// (el "typ" evita que un ID token, firmado con la misma clave, se use como token de acceso)
+func parseSigned(tokenString string) (*AccessClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return &signingKey.PublicKey, nil
+	}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithIssuer(Issuer()), jwt.WithAudience(Issuer()))
+	if err != nil || token.Header["typ"] != "at+jwt" {
+		return nil, errInvalidToken
+	}
+
+	mapClaims, _ := token.Claims.(jwt.MapClaims)
+	issuedAt, _ := mapClaims.GetIssuedAt()
+	expiresAt, _ := mapClaims.GetExpirationTime()
+	if issuedAt == nil || expiresAt == nil {
+		return nil, errInvalidToken
+	}
+	claims := &AccessClaims{IssuedAt: issuedAt.Time, ExpiresAt: expiresAt.Time}
+	claims.Subject, _ = mapClaims["sub"].(string)
+	claims.ClientID, _ = mapClaims["client_id"].(string)
+	claims.Scope, _ = mapClaims["scope"].(string)
+	claims.JTI, _ = mapClaims["jti"].(string)
+	claims.GrantID, _ = mapClaims["gid"].(string)
+	if claims.JTI == "" || claims.ClientID == "" {
+		return nil, errInvalidToken
+	}
+	if claims.GrantID != "" {
+		var ok bool
+		if claims.UserStore, claims.UserID, ok = parseSubject(claims.Subject); !ok {
+			return nil, errInvalidToken
+		}
+	}
+	return claims, nil
+}
+
+func introspectAccessToken(token string) map[string]interface{} {
+	claims, err := ParseAccessToken(token)
+	if err != nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"active":     true,
+		"token_type": "access_token",
+		"scope":      claims.Scope,
+		"client_id":  claims.ClientID,
+		"sub":        claims.Subject,
+		"aud":        Issuer(),
+		"iss":        Issuer(),
+		"jti":        claims.JTI,
+		"iat":        claims.IssuedAt.Unix(),
+		"exp":        claims.ExpiresAt.Unix(),
+	}
+}
+
+func introspectRefreshToken(token string) map[string]interface{} {
+	var refresh models.OAuthRefreshToken
+	if err := database.DB.Where("token_hash = ?", hashSecret(token)).First(&refresh).Error; err != nil {
+		return nil
+	}
+	if refresh.UsedAt != nil || time.Now().After(refresh.ExpiresAt) {
+		return nil
+	}
+	grant, err := activeGrant(refresh.GrantID)
+	if err != nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"active":     true,
+		"token_type": "refresh_token",
+		"scope":      grant.Scope,
+		"client_id":  refresh.ClientID,
+		"sub":        Subject(grant.UserStore, grant.UserID),
+		"iss":        Issuer(),
+		"iat":        refresh.CreatedAt.Unix(),
+		"exp":        refresh.ExpiresAt.Unix(),
+	}
+}
+
+// activeGrant devuelve la concesión si existe y no está revocada (si no, invalid_grant)
+func activeGrant(id string) (*models.OAuthGrant, error) {
+	var grant models.OAuthGrant
+	if err := database.DB.Where("id = ? AND revoked_at IS NULL", id).First(&grant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidGrant
+		}
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// revokeGrant revoca una concesión y borra sus refresh tokens
+func revokeGrant(id string) error {
+	if err := database.DB.Model(&models.OAuthGrant{}).Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+	return database.DB.Where("grant_id = ?", id).Delete(&models.OAuthRefreshToken{}).Error
+}
+
+// validVerifier comprueba el code_verifier de PKCE: BASE64URL(SHA256(verifier)) == code_challenge
+func validVerifier(verifier string, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
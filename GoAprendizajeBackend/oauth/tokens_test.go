@@ -0,0 +1,450 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"go-aprendizaje/database"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/models"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testKey es la clave de firma de los tests (generarla en cada test es lento)
+var testKey *rsa.PrivateKey
+
+func setupOAuthTest(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	logging.Log = logrus.New()
+	logging.Log.SetOutput(io.Discard)
+
+	if testKey == nil {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testKey = key
+	}
+	setSigningKey(testKey)
+
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	database.DB, err = gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mock
+}
+
+// oauthErrorCode devuelve el código de OAuth de 'err' (ej: "invalid_grant") o "" si no es un *Error
+func oauthErrorCode(err error) string {
+	var oauthErr *Error
+	if errors.As(err, &oauthErr) {
+		return oauthErr.Code
+	}
+	return ""
+}
+
+func challengeFor(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func expectUser(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT \* FROM "users"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(1, "ana@example.com"))
+}
+
+// expectRevokeGrant son las consultas de revokeGrant: marcar la concesión y borrar sus refresh tokens
+func expectRevokeGrant(mock sqlmock.Sqlmock, grantID string) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "o_auth_grants" SET "revoked_at"`).
+		WithArgs(sqlmock.AnyArg(), grantID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "o_auth_refresh_tokens" WHERE grant_id = \$1`).
+		WithArgs(grantID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+// El ID token lleva el "auth_time" y el "nonce" del inicio de sesión original, también cuando sale de un refresh token
+func TestIssueTokensIDTokenClaims(t *testing.T) {
+	mock := setupOAuthTest(t)
+	expectUser(mock)
+
+	authTime := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	client := &models.OAuthClient{ID: "app"}
+	grant := &models.OAuthGrant{ID: "g1", ClientID: "app", UserStore: "postgres", UserID: "1", AuthTime: &authTime, Nonce: "n-0S6_WzA2Mj"}
+
+	response, err := issueTokens(client, grant, ScopeOpenID)
+	if err != nil {
+		t.Fatalf("issueTokens: %v", err)
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(response.IDToken, claims); err != nil {
+		t.Fatalf("ID token inválido: %v", err)
+	}
+	if claims["auth_time"] != float64(authTime.Unix()) || claims["nonce"] != grant.Nonce {
+		t.Fatalf("auth_time = %v, nonce = %v; se esperaban %d y %q", claims["auth_time"], claims["nonce"], authTime.Unix(), grant.Nonce)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExchangeCode(t *testing.T) {
+	const (
+		code        = "codigo-de-un-solo-uso"
+		redirectURI = "https://app.example.com/callback"
+		verifier    = "verificador-pkce-de-la-aplicacion-0123456789abcdef"
+	)
+	client := &models.OAuthClient{ID: "app", GrantTypes: models.GrantAuthorizationCode}
+
+	expectAuthorization := func(mock sqlmock.Sqlmock, status string, grantID string) {
+		mock.ExpectQuery(`SELECT \* FROM "o_auth_authorizations" WHERE code_hash = \$1`).
+			WithArgs(hashSecret(code), 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "client_id", "redirect_uri", "scope", "code_challenge", "status", "user_store", "user_id", "grant_id", "expires_at"}).
+				AddRow("a1", "app", redirectURI, ScopeProfile, challengeFor(verifier), status, "postgres", "1", grantID, time.Now().Add(time.Minute)))
+	}
+
+	t.Run("canje correcto", func(t *testing.T) {
+		mock := setupOAuthTest(t)
+		expectAuthorization(mock, models.OAuthAuthorizationApproved, "")
+		expectUser(mock)
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "o_auth_authorizations" SET .* WHERE id = \$\d+ AND status = \$\d+`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO "o_auth_grants"`).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		response, err := ExchangeCode(client, code, redirectURI, verifier)
+		if err != nil || response.AccessToken == "" || response.Scope != ScopeProfile {
+			t.Fatalf("ExchangeCode = %+v, %v", response, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// PKCE: sin el code_verifier (o con otro) quien intercepte el código no puede canjearlo
+	for name, presented := range map[string]string{
+		"sin code_verifier":            "",
+		"code_verifier incorrecto":     "otro-verificador-que-no-es-el-de-la-aplicacion-012345",
+		"el code_challenge como plain": challengeFor(verifier),
+	} {
+		t.Run(name, func(t *testing.T) {
+			mock := setupOAuthTest(t)
+			expectAuthorization(mock, models.OAuthAuthorizationApproved, "")
+
+			// Se rechaza por el verificador, antes de buscar al usuario
+			var oauthErr *Error
+			_, err := ExchangeCode(client, code, redirectURI, presented)
+			if !errors.As(err, &oauthErr) || oauthErr.Code != "invalid_grant" || !strings.Contains(oauthErr.Description, "code_verifier") {
+				t.Fatalf("ExchangeCode = %v; se esperaba invalid_grant por el code_verifier", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	// La redirect_uri tiene que ser exactamente la de la petición
+	for _, presented := range []string{"", redirectURI + "/", redirectURI + "?next=/", "https://APP.example.com/callback", "https://app.example.com/callback2"} {
+		t.Run("redirect_uri "+presented, func(t *testing.T) {
+			mock := setupOAuthTest(t)
+			expectAuthorization(mock, models.OAuthAuthorizationApproved, "")
+
+			if _, err := ExchangeCode(client, code, presented, verifier); oauthErrorCode(err) != "invalid_grant" {
+				t.Fatalf("ExchangeCode = %v; se esperaba invalid_grant", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	t.Run("código usado dos veces revoca la concesión", func(t *testing.T) {
+		mock := setupOAuthTest(t)
+		expectAuthorization(mock, models.OAuthAuthorizationUsed, "g1")
+		expectRevokeGrant(mock, "g1")
+
+		if _, err := ExchangeCode(client, code, redirectURI, verifier); oauthErrorCode(err) != "invalid_grant" {
+			t.Fatalf("ExchangeCode = %v; se esperaba invalid_grant", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("código de otro cliente", func(t *testing.T) {
+		mock := setupOAuthTest(t)
+		expectAuthorization(mock, models.OAuthAuthorizationApproved, "")
+
+		other := &models.OAuthClient{ID: "otra", GrantTypes: models.GrantAuthorizationCode}
+		if _, err := ExchangeCode(other, code, redirectURI, verifier); oauthErrorCode(err) != "invalid_grant" {
+			t.Fatalf("ExchangeCode = %v; se esperaba invalid_grant", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestRefreshTokens(t *testing.T) {
+	const token = "rt_refresh-token-de-la-aplicacion"
+	client := &models.OAuthClient{ID: "app", GrantTypes: models.GrantAuthorizationCode + " " + models.GrantRefreshToken}
+
+	expectRefresh := func(mock sqlmock.Sqlmock, usedAt *time.Time) {
+		mock.ExpectQuery(`SELECT \* FROM "o_auth_refresh_tokens" WHERE token_hash = \$1`).
+			WithArgs(hashSecret(token), 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "token_hash", "grant_id", "client_id", "expires_at", "used_at"}).
+				AddRow(7, hashSecret(token), "g1", "app", time.Now().Add(time.Hour), usedAt))
+	}
+	expectGrant := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(`SELECT \* FROM "o_auth_grants" WHERE id = \$1 AND revoked_at IS NULL`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "client_id", "user_store", "user_id", "scope"}).
+				AddRow("g1", "app", "postgres", "1", ScopeProfile+" "+ScopeOfflineAccess))
+	}
+
+	t.Run("se rota", func(t *testing.T) {
+		mock := setupOAuthTest(t)
+		expectRefresh(mock, nil)
+		expectGrant(mock)
+		expectUser(mock)
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "o_auth_refresh_tokens" SET "used_at"=\$1 WHERE id = \$2 AND used_at IS NULL`).
+			WithArgs(sqlmock.AnyArg(), 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO "o_auth_refresh_tokens"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+		mock.ExpectCommit()
+
+		response, err := RefreshTokens(client, token, "")
+		if err != nil {
+			t.Fatalf("RefreshTokens: %v", err)
+		}
+		if response.RefreshToken == "" || response.RefreshToken == token {
+			t.Fatalf("se esperaba un refresh token nuevo, llegó %q", response.RefreshToken)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("uno ya usado revoca la concesión", func(t *testing.T) {
+		mock := setupOAuthTest(t)
+		usedAt := time.Now().Add(-time.Minute)
+		expectRefresh(mock, &usedAt)
+		expectRevokeGrant(mock, "g1")
+
+		if _, err := RefreshTokens(client, token, ""); oauthErrorCode(err) != "invalid_grant" {
+			t.Fatalf("RefreshTokens = %v; se esperaba invalid_grant", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("no puede pedir más permisos que los de la concesión", func(t *testing.T) {
+		mock := setupOAuthTest(t)
+		expectRefresh(mock, nil)
+		expectGrant(mock)
+
+		if _, err := RefreshTokens(client, token, ScopeProfile+" "+ScopeEmail); oauthErrorCode(err) != "invalid_scope" {
+			t.Fatalf("RefreshTokens = %v; se esperaba invalid_scope", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestClientCredentials(t *testing.T) {
+	setupOAuthTest(t)
+	client := &models.OAuthClient{
+		ID:         "backend",
+		SecretHash: hashSecret("cs_secreto"),
+		Scopes:     "openid profile email offline_access",
+		GrantTypes: models.GrantClientCredentials,
+	}
+
+	// Sin "scope" se dan los del cliente, salvo los que necesitan un usuario
+	response, err := ClientCredentials(client, "")
+	if err != nil || response.Scope != "profile email" || response.RefreshToken != "" || response.IDToken != "" {
+		t.Fatalf("ClientCredentials = %+v, %v", response, err)
+	}
+	if response, err := ClientCredentials(client, "email"); err != nil || response.Scope != "email" {
+		t.Fatalf("ClientCredentials(email) = %+v, %v", response, err)
+	}
+
+	for _, scope := range []string{ScopeOpenID, ScopeOfflineAccess, "profile admin"} {
+		if _, err := ClientCredentials(client, scope); oauthErrorCode(err) != "invalid_scope" {
+			t.Errorf("ClientCredentials(%q) = %v; se esperaba invalid_scope", scope, err)
+		}
+	}
+
+	public := *client
+	public.Public = true
+	notAllowed := *client
+	notAllowed.GrantTypes = models.GrantAuthorizationCode
+	for _, other := range []*models.OAuthClient{&public, &notAllowed} {
+		if _, err := ClientCredentials(other, ""); oauthErrorCode(err) != "unauthorized_client" {
+			t.Errorf("ClientCredentials (público: %v, concesiones: %q) = %v; se esperaba unauthorized_client", other.Public, other.GrantTypes, err)
+		}
+	}
+}
+
+// Un token que no vale solo responde {"active": false}, sin decir por qué
+func TestIntrospectInactive(t *testing.T) {
+	inactive := func(t *testing.T, response map[string]interface{}) {
+		t.Helper()
+		if len(response) != 1 || response["active"] != false {
+			t.Fatalf("Introspect = %v; se esperaba solo {\"active\": false}", response)
+		}
+	}
+	noRefresh := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(`SELECT \* FROM "o_auth_refresh_tokens"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	}
+
+	t.Run("desconocido", func(t *testing.T) {
+		mock := setupOAuthTest(t)
+		noRefresh(mock)
+		inactive(t, Introspect("no-es-un-token", ""))
+	})
+
+	t.Run("token de acceso caducado", func(t *testing.T) {
+		mock := setupOAuthTest(t)
+		token, err := sign(jwt.MapClaims{"iss": Issuer(), "aud": Issuer(), "sub": "postgres:1", "client_id": "app", "gid": "g1",
+			"jti": "j1", "iat": time.Now().Add(-time.Hour).Unix(), "exp": time.Now().Add(-time.Minute).Unix()}, "at+jwt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		noRefresh(mock)
+		inactive(t, Introspect(token, "access_token"))
+	})
+
+	t.Run("token de acceso revocado", func(t *testing.T) {
+		mock := setupOAuthTest(t)
+		token, _, err := signAccessToken("postgres:1", "app", ScopeProfile, "g1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "o_auth_revoked_tokens"`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		noRefresh(mock)
+		inactive(t, Introspect(token, ""))
+	})
+
+	t.Run("un ID token no es un token de acceso", func(t *testing.T) {
+		mock := setupOAuthTest(t)
+		idToken, err := sign(jwt.MapClaims{"iss": Issuer(), "aud": Issuer(), "sub": "postgres:1", "client_id": "app",
+			"jti": "j1", "iat": time.Now().Unix(), "exp": time.Now().Add(time.Hour).Unix()}, "JWT")
+		if err != nil {
+			t.Fatal(err)
+		}
+		noRefresh(mock)
+		inactive(t, Introspect(idToken, ""))
+	})
+
+	t.Run("refresh token ya usado", func(t *testing.T) {
+		mock := setupOAuthTest(t)
+		mock.ExpectQuery(`SELECT \* FROM "o_auth_refresh_tokens"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "grant_id", "client_id", "expires_at", "used_at"}).
+				AddRow(7, "g1", "app", time.Now().Add(time.Hour), time.Now()))
+		inactive(t, Introspect("rt_usado", "refresh_token"))
+	})
+}
+
+// RFC 7009: cada cliente solo revoca sus tokens, y la respuesta no dice si el token existía
+func TestRevoke(t *testing.T) {
+	client := &models.OAuthClient{ID: "app"}
+
+	t.Run("token de acceso", func(t *testing.T) {
+		mock := setupOAuthTest(t)
+		token, _, err := signAccessToken("postgres:1", "app", ScopeProfile, "g1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		mock.ExpectQuery(`SELECT \* FROM "o_auth_revoked_tokens" WHERE jti = \$1`).WillReturnRows(sqlmock.NewRows([]string{"jti"}))
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO "o_auth_revoked_tokens"`).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if err := Revoke(client, token); err != nil {
+			t.Fatalf("Revoke: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("token de acceso de otro cliente", func(t *testing.T) {
+		mock := setupOAuthTest(t)
+		token, _, err := signAccessToken("postgres:1", "otra", ScopeProfile, "g2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Revoke(client, token); err != nil {
+			t.Fatalf("Revoke: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("refresh token revoca la concesión", func(t *testing.T) {
+		mock := setupOAuthTest(t)
+		mock.ExpectQuery(`SELECT \* FROM "o_auth_refresh_tokens" WHERE token_hash = \$1`).
+			WithArgs(hashSecret("rt_de_app"), 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "grant_id", "client_id"}).AddRow(7, "g1", "app"))
+		expectRevokeGrant(mock, "g1")
+
+		if err := Revoke(client, "rt_de_app"); err != nil {
+			t.Fatalf("Revoke: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("refresh token de otro cliente", func(t *testing.T) {
+		mock := setupOAuthTest(t)
+		mock.ExpectQuery(`SELECT \* FROM "o_auth_refresh_tokens"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "grant_id", "client_id"}).AddRow(9, "g2", "otra"))
+
+		if err := Revoke(client, "rt_de_otra"); err != nil {
+			t.Fatalf("Revoke: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("desconocido", func(t *testing.T) {
+		mock := setupOAuthTest(t)
+		mock.ExpectQuery(`SELECT \* FROM "o_auth_refresh_tokens"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		if err := Revoke(client, "no-existe"); err != nil {
+			t.Fatalf("Revoke: %v", err)
+		}
+	})
+}
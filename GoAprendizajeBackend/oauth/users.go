@@ -0,0 +1,83 @@
+package oauth
+
+import (
+	"errors"
+	"go-aprendizaje/core"
+	"go-aprendizaje/database"
+	"go-aprendizaje/models"
+	"go-aprendizaje/storage"
+	"net/http"
+	"strings"
+)
+
+// ErrUserDisabled se devuelve si el usuario ya no existe o tiene el borrado programado
+// (no puede autorizar aplicaciones ni sus concesiones emiten tokens)
+var ErrUserDisabled = errors.New("el usuario ya no está activo")
+
+// user son los datos del usuario que pueden salir en el ID token y en userinfo
+type user struct {
+	email   string
+	locale  string
+	picture string // Clave en el almacenamiento (vacía si no tiene foto)
+}
+
+// Subject es el "sub" de los tokens de un usuario: "<store>:<id>" (los IDs de las dos BD pueden coincidir)
+func Subject(store string, userID string) string {
+	return store + ":" + userID
+}
+
+// parseSubject separa un "sub" de usuario; 'ok' es false si es el de un cliente (client_credentials)
+func parseSubject(subject string) (store string, userID string, ok bool) {
+	store, userID, found := strings.Cut(subject, ":")
+	if !found || (store != "postgres" && store != "mongo") || userID == "" {
+		return "", "", false
+	}
+	return store, userID, true
+}
+
+// findUser busca al usuario de una concesión. Las cuentas de servicio no pueden iniciar sesión: tampoco aquí.
+func findUser(store string, userID string) (*user, error) {
+	if store == "mongo" {
+		mongoUser, err := core.MongoUserRepo.GetUserByID(userID)
+		if err != nil || mongoUser.DeletionScheduledAt != nil {
+			return nil, ErrUserDisabled
+		}
+		return &user{email: mongoUser.Email, locale: mongoUser.Locale, picture: mongoUser.ProfileImagePath}, nil
+	}
+
+	var pgUser models.User
+	if err := database.DB.First(&pgUser, userID).Error; err != nil || pgUser.DeletionScheduledAt != nil || pgUser.ServiceAccount {
+		return nil, ErrUserDisabled
+	}
+	return &user{email: pgUser.Email, locale: pgUser.Locale, picture: pgUser.ProfileImagePath}, nil
+}
+
+// UserInfo son los datos del usuario del token según sus permisos (GET /api/oauth/userinfo)
+func UserInfo(claims *AccessClaims) (map[string]interface{}, *Error) {
+	if !claims.HasScope(ScopeOpenID) || claims.UserStore == "" {
+		return nil, newError("insufficient_scope", "El token no tiene el permiso openid", http.StatusForbidden)
+	}
+	found, err := findUser(claims.UserStore, claims.UserID)
+	if err != nil {
+		return nil, newError("invalid_token", "El usuario del token ya no está activo", http.StatusUnauthorized)
+	}
+
+	info := map[string]interface{}{"sub": claims.Subject}
+	if claims.HasScope(ScopeEmail) {
+		info["email"] = found.email
+	}
+	if claims.HasScope(ScopeProfile) {
+		if found.locale != "" {
+			info["locale"] = found.locale
+		}
+		if found.picture != "" {
+			// Sin STORAGE_PUBLIC_URL la URL es relativa ("/static/..."): el cliente está en otro dominio
+			picture := storage.URL(found.picture)
+			if strings.HasPrefix(picture, "/") {
+				picture = Issuer() + picture
+			}
+			info["picture"] = picture
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,54 @@
+package outbox
+
+import (
+	"go-aprendizaje/core"
+	"go-aprendizaje/database"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/utils"
+	"time"
+)
+
+// Los usuarios de Mongo se crean con "welcome_pending" y la marca se quita al encolar la bienvenida
+// (la bandeja de salida está en Postgres y no se puede escribir en la misma transacción que el insert en Mongo).
+// Si la API se cae entre los dos pasos o Postgres falla, el relevo encola después las que se quedaron sin encolar.
+
+// welcomeRelayMinAge es la antigüedad mínima de un usuario para encolar su bienvenida desde aquí
+// (los más recientes aún pueden estar en mitad del registro, que la encola él mismo)
+const welcomeRelayMinAge = time.Minute
+
+// StartMongoWelcomeRelay lanza una goroutine que encola cada 'every' las bienvenidas pendientes
+func StartMongoWelcomeRelay(every time.Duration) {
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for range ticker.C {
+			if relayed := RelayMongoWelcomes(); relayed > 0 {
+				logging.Log.Infof("Bienvenidas de Mongo encoladas por el relevo: %d", relayed)
+			}
+		}
+	}()
+}
+
+// RelayMongoWelcomes encola las bienvenidas pendientes de los usuarios de Mongo y les quita la marca.
+// Devuelve cuántas encoló.
+func RelayMongoWelcomes() int {
+	users, err := core.MongoUserRepo.GetPendingWelcomes(time.Now().Add(-welcomeRelayMinAge))
+	if err != nil {
+		logging.Log.Errorf("Error al buscar las bienvenidas pendientes en Mongo: %v", err)
+		return 0
+	}
+
+	relayed := 0
+	for _, user := range users {
+		if err := Enqueue(database.DB, utils.WelcomeEmail(user.Locale, user.Email)); err != nil {
+			logging.Log.Errorf("No se pudo encolar la bienvenida de %s: %v", user.ID.Hex(), err)
+			continue
+		}
+		if err := core.MongoUserRepo.ClearWelcomePending(user.ID); err != nil {
+			logging.Log.Errorf("No se pudo quitar la bienvenida pendiente de %s: %v", user.ID.Hex(), err)
+			continue
+		}
+		relayed++
+	}
+	return relayed
+}
@@ -0,0 +1,88 @@
+package outbox
+
+import (
+	"go-aprendizaje/core"
+	"go-aprendizaje/database"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/repositories"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// El relevo encola la bienvenida de un usuario que se quedó sin ella y le quita la marca;
+// si no se puede encolar, la marca se queda para el siguiente intento
+func TestRelayMongoWelcomes(t *testing.T) {
+	logging.Log = logrus.New()
+	logging.Log.SetOutput(io.Discard)
+
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+	database.DB, err = gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("relevo", func(mt *mtest.T) {
+		core.MongoUserRepo = repositories.NewMongoUserRepositoryWithCollection(mt.Coll)
+		pendingUser := func(email string) bson.D {
+			return bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "email", Value: email},
+				{Key: "welcome_pending", Value: true},
+				{Key: "created_at", Value: time.Now().Add(-time.Hour)},
+			}
+		}
+
+		// 1. Dos usuarios pendientes: el primero se encola y se desmarca, el segundo falla al encolar
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, pendingUser("ana@example.com"), pendingUser("luis@example.com")),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO "outbox_emails"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO "outbox_emails"`).WillReturnError(gorm.ErrInvalidDB)
+		mock.ExpectRollback()
+
+		if relayed := RelayMongoWelcomes(); relayed != 1 {
+			mt.Fatalf("RelayMongoWelcomes = %d; se esperaba 1", relayed)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			mt.Fatal(err)
+		}
+
+		// 2. Solo se buscan los pendientes con cierta antigüedad, y solo se desmarca el que se encoló
+		var find, updates []bson.Raw
+		for _, event := range mt.GetAllStartedEvents() {
+			switch event.CommandName {
+			case "find":
+				find = append(find, event.Command)
+			case "update":
+				updates = append(updates, event.Command)
+			}
+		}
+		if len(find) != 1 || find[0].Lookup("filter", "welcome_pending").Boolean() != true ||
+			find[0].Lookup("filter", "created_at", "$lt").Time().After(time.Now().Add(-welcomeRelayMinAge)) {
+			mt.Fatalf("búsqueda inesperada: %v", find)
+		}
+		if len(updates) != 1 || updates[0].Lookup("updates").Array().Index(0).Value().Document().Lookup("u", "$unset", "welcome_pending").IsZero() {
+			mt.Fatalf("se esperaba un solo $unset de welcome_pending: %v", updates)
+		}
+	})
+}
@@ -0,0 +1,259 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"go-aprendizaje/config"
+	"go-aprendizaje/core"
+	"go-aprendizaje/database"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/mailer"
+	"go-aprendizaje/models"
+	"go-aprendizaje/suppression"
+	"go-aprendizaje/utils"
+	"math/rand"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNotResendable se devuelve al intentar reenviar un email sensible que ya se envió o descartó (su cuerpo se borró)
+var ErrNotResendable = errors.New("el contenido del email se ha borrado")
+
+// ErrEmptyEmail se devuelve al encolar un email sin cuerpo (no se pudo generar su plantilla)
+var ErrEmptyEmail = errors.New("el email está vacío")
+
+// wakeUp avisa al dispatcher de que hay emails nuevos (para no esperar al siguiente sondeo)
+var wakeUp = make(chan struct{}, 1)
+
+// Enqueue guarda un email en la bandeja de salida usando 'tx'.
+// Pasando la transacción del cambio que lo provoca, el email se guarda si y solo si el cambio se guarda.
+func Enqueue(tx *gorm.DB, email utils.Email) error {
+	if email.Body == "" {
+		return ErrEmptyEmail
+	}
+
+	err := tx.Create(&models.OutboxEmail{
+		Kind:          email.Kind,
+		Recipient:     email.To,
+		Locale:        email.Locale,
+		Subject:       email.Subject,
+		Body:          email.Body,
+		TextBody:      email.Text,
+		Sensitive:     email.Sensitive,
+		Status:        models.OutboxPending,
+		NextAttemptAt: time.Now(),
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	select {
+	case wakeUp <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Send guarda un email en la bandeja de salida fuera de cualquier transacción
+// (para los avisos que no acompañan a ningún cambio en Postgres). Si falla solo lo loguea.
+func Send(email utils.Email) {
+	if err := Enqueue(database.DB, email); err != nil {
+		logging.Log.Errorf("No se pudo guardar el email de %s para %s: %v", email.Kind, email.To, err)
+	}
+}
+
+// Resend vuelve a poner un email en cola (ej: uno "dead" después de arreglar el SMTP)
+func Resend(id uint) error {
+	var email models.OutboxEmail
+	if err := database.DB.First(&email, id).Error; err != nil {
+		return err
+	}
+	if email.Sensitive && email.Body == "" {
+		return ErrNotResendable
+	}
+
+	err := database.DB.Model(&email).Updates(map[string]interface{}{
+		"status":          models.OutboxPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+		"locked_until":    nil,
+		"last_error":      "",
+	}).Error
+	if err == nil {
+		select {
+		case wakeUp <- struct{}{}:
+		default:
+		}
+	}
+	return err
+}
+
+// StartWorkers arranca el envío en segundo plano: un dispatcher que reparte los emails pendientes
+// entre 'workers' goroutines y comprueba la cola cada 'poll' (o en cuanto se encola uno nuevo).
+// Con varias réplicas, cada email lo coge solo una (SELECT ... FOR UPDATE SKIP LOCKED).
+func StartWorkers(workers int, poll time.Duration) {
+	jobs := make(chan models.OutboxEmail)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for email := range jobs {
+				deliver(&email)
+			}
+		}()
+	}
+
+	go func() {
+		ticker := time.NewTicker(poll)
+		defer ticker.Stop()
+		lastPurge := time.Time{}
+		for {
+			// Repartir lo que haya pendiente (en lotes, hasta vaciar la cola)
+			for {
+				batch, err := claim(workers * 4)
+				if err != nil {
+					logging.Log.Errorf("Error al leer la bandeja de salida: %v", err)
+					break
+				}
+				for _, email := range batch {
+					jobs <- email
+				}
+				if len(batch) == 0 {
+					break
+				}
+			}
+
+			if time.Since(lastPurge) > time.Hour {
+				purgeSent()
+				lastPurge = time.Now()
+			}
+
+			select {
+			case <-ticker.C:
+			case <-wakeUp:
+			}
+		}
+	}()
+}
+
+// claim reserva hasta 'limit' emails listos para enviar: los pendientes cuya hora ha llegado
+// y los que se quedaron "enviándose" en un worker que murió (LockedUntil ya pasó)
+func claim(limit int) ([]models.OutboxEmail, error) {
+	var batch []models.OutboxEmail
+	now := time.Now()
+	lockedUntil := now.Add(leaseDuration())
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND next_attempt_at <= ?) OR (status = ? AND locked_until < ?)",
+				models.OutboxPending, now, models.OutboxSending, now).
+			Order("next_attempt_at").Limit(limit).
+			Find(&batch).Error
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+
+		ids := make([]uint, len(batch))
+		for i := range batch {
+			ids[i] = batch[i].ID
+		}
+		return tx.Model(&models.OutboxEmail{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"status":       models.OutboxSending,
+			"locked_until": lockedUntil,
+		}).Error
+	})
+	return batch, err
+}
+
+// deliver envía un email y guarda el resultado: enviado, reintento con espera exponencial o "dead".
+// Si el destinatario está en la lista de supresión no se envía.
+func deliver(email *models.OutboxEmail) {
+	if reason, suppressed := suppression.Check(email.Recipient); suppressed {
+		logging.Log.Infof("Email %d de %s a %s no enviado: dirección suprimida (%s)", email.ID, email.Kind, email.Recipient, reason)
+		updates := map[string]interface{}{"status": models.OutboxSuppressed, "locked_until": nil, "last_error": "dirección suprimida: " + reason}
+		if email.Sensitive {
+			updates["body"], updates["text_body"] = "", ""
+		}
+		database.DB.Model(email).Updates(updates)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), leaseDuration())
+	err := core.Mailer.Send(ctx, mailer.Message{
+		From:    mailer.DefaultFrom(),
+		To:      email.Recipient,
+		Subject: email.Subject,
+		HTML:    email.Body,
+		Text:    email.TextBody,
+		Kind:    email.Kind,
+	})
+	cancel()
+	now := time.Now()
+
+	if err == nil {
+		logging.Log.Infof("Email de %s enviado a %s (%s)", email.Kind, email.Recipient, core.Mailer.Name())
+		updates := map[string]interface{}{"status": models.OutboxSent, "sent_at": now, "locked_until": nil, "last_error": ""}
+		if email.Sensitive {
+			// El secreto ya está en el buzón del usuario: no lo guardamos más
+			updates["body"], updates["text_body"] = "", ""
+		}
+		database.DB.Model(email).Updates(updates)
+		return
+	}
+
+	attempts := email.Attempts + 1
+	updates := map[string]interface{}{"attempts": attempts, "last_error": err.Error(), "locked_until": nil}
+	if attempts >= config.GetEnvInt("OUTBOX_MAX_ATTEMPTS", 8) {
+		updates["status"] = models.OutboxDead
+		logging.Log.Errorf("Email %d de %s a %s descartado tras %d intentos: %v", email.ID, email.Kind, email.Recipient, attempts, err)
+	} else {
+		updates["status"] = models.OutboxPending
+		updates["next_attempt_at"] = now.Add(backoff(attempts))
+		logging.Log.Warnf("Error al enviar el email %d de %s a %s (intento %d): %v", email.ID, email.Kind, email.Recipient, attempts, err)
+	}
+	database.DB.Model(email).Updates(updates)
+}
+
+// backoff es la espera antes del reintento número 'attempt': OUTBOX_RETRY_BASE * 2^(attempt-1),
+// con un máximo de OUTBOX_RETRY_MAX y un ±20% aleatorio (para que no reintenten todos a la vez)
+func backoff(attempt int) time.Duration {
+	base, err := time.ParseDuration(config.GetEnv("OUTBOX_RETRY_BASE", "30s"))
+	if err != nil || base <= 0 {
+		base = 30 * time.Second
+	}
+	maxWait, err := time.ParseDuration(config.GetEnv("OUTBOX_RETRY_MAX", "6h"))
+	if err != nil || maxWait <= 0 {
+		maxWait = 6 * time.Hour
+	}
+
+	wait := base
+	for i := 1; i < attempt && wait < maxWait; i++ {
+		wait *= 2
+	}
+	wait = min(wait, maxWait)
+
+	jitter := time.Duration(rand.Int63n(int64(wait)/5*2+1)) - wait/5
+	return wait + jitter
+}
+
+// leaseDuration es cuánto tiempo tiene un worker para enviar un email antes de que otro lo recupere
+func leaseDuration() time.Duration {
+	lease, err := time.ParseDuration(config.GetEnv("OUTBOX_LEASE", "2m"))
+	if err != nil || lease <= 0 {
+		return 2 * time.Minute
+	}
+	return lease
+}
+
+// purgeSent borra los emails enviados hace más de OUTBOX_RETENTION
+// (llevan direcciones de email: no los guardamos más de lo necesario)
+func purgeSent() {
+	retention, err := time.ParseDuration(config.GetEnv("OUTBOX_RETENTION", "168h"))
+	if err != nil || retention <= 0 {
+		return
+	}
+	result := database.DB.Where("status = ? AND sent_at < ?", models.OutboxSent, time.Now().Add(-retention)).Delete(&models.OutboxEmail{})
+	if result.RowsAffected > 0 {
+		logging.Log.Infof("Bandeja de salida: %d emails enviados antiguos borrados", result.RowsAffected)
+	}
+}
@@ -0,0 +1,201 @@
+package passwordless
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-aprendizaje/config"
+	"go-aprendizaje/database"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/models"
+	"math/big"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Inicio de sesión sin contraseña: se envía por email un enlace o un código de 6 dígitos,
+// de un solo uso y con caducidad corta. Además la petición queda ligada al navegador que la hizo
+// (un secreto en una cookie): un enlace reenviado o interceptado no sirve en otro dispositivo.
+
+var (
+	ErrInvalid         = errors.New("enlace o código inválido o caducado")
+	ErrDeviceMismatch  = errors.New("abre el enlace en el mismo navegador en el que lo pediste")
+	ErrTooManyRequests = errors.New("demasiadas peticiones de inicio de sesión pendientes")
+)
+
+// TTL es cuánto vale un enlace o código (MAGIC_LINK_TTL)
+func TTL() time.Duration {
+	ttl, err := time.ParseDuration(config.GetEnv("MAGIC_LINK_TTL", "15m"))
+	if err != nil || ttl <= 0 {
+		return 15 * time.Minute
+	}
+	return ttl
+}
+
+// DeviceBinding indica si el enlace/código solo vale en el navegador que lo pidió (MAGIC_LINK_DEVICE_BINDING)
+func DeviceBinding() bool {
+	return config.GetEnvBool("MAGIC_LINK_DEVICE_BINDING", true)
+}
+
+// Request crea una petición para el usuario y devuelve el secreto que va en el email
+// (el token del enlace o el código). 'deviceSecret' es el secreto de la cookie del navegador.
+func Request(store string, userID string, mode string, deviceSecret string, ip string) (string, *models.MagicLogin, error) {
+	// 1. Límite de peticiones sin usar por usuario (cada una es un email: evita que se use para bombardear el buzón)
+	var pending int64
+	database.DB.Model(&models.MagicLogin{}).
+		Where("user_store = ? AND user_id = ? AND consumed_at IS NULL AND expires_at > ?", store, userID, time.Now()).
+		Count(&pending)
+	if pending >= int64(config.GetEnvInt("MAGIC_LINK_MAX_PENDING", 3)) {
+		return "", nil, ErrTooManyRequests
+	}
+
+	// 2. Generar el secreto
+	login := &models.MagicLogin{
+		ID:         uuid.New().String(),
+		UserStore:  store,
+		UserID:     userID,
+		Mode:       mode,
+		DeviceHash: hashSecret(deviceSecret),
+		IP:         ip,
+		ExpiresAt:  time.Now().Add(TTL()),
+	}
+
+	var secret string
+	var err error
+	if mode == models.MagicLoginCode {
+		secret, err = randomCode()
+		login.SecretHash = codeHash(login.ID, secret)
+	} else {
+		secret, err = RandomSecret()
+		login.SecretHash = hashSecret(secret)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	// 3. Guardar la petición
+	if err := database.DB.Create(login).Error; err != nil {
+		return "", nil, err
+	}
+	return secret, login, nil
+}
+
+// ExchangeLink canjea el token de un enlace. Devuelve la petición (con el usuario) si es válido.
+func ExchangeLink(token string, deviceSecret string) (*models.MagicLogin, error) {
+	var login models.MagicLogin
+	err := database.DB.Where("secret_hash = ? AND mode = ?", hashSecret(token), models.MagicLoginLink).First(&login).Error
+	if err != nil || login.ConsumedAt != nil || time.Now().After(login.ExpiresAt) {
+		return nil, ErrInvalid
+	}
+	if !deviceMatches(&login, deviceSecret) {
+		return nil, ErrDeviceMismatch
+	}
+	return &login, consume(&login)
+}
+
+// ExchangeCode canjea un código para el usuario. Cada código incorrecto cuenta como un intento
+// contra sus peticiones pendientes; al pasar de MAGIC_CODE_MAX_ATTEMPTS se invalidan.
+func ExchangeCode(store string, userID string, code string, deviceSecret string) (*models.MagicLogin, error) {
+	var pending []models.MagicLogin
+	database.DB.Where("user_store = ? AND user_id = ? AND mode = ? AND consumed_at IS NULL AND expires_at > ?",
+		store, userID, models.MagicLoginCode, time.Now()).
+		Order("created_at desc").Find(&pending)
+
+	for i := range pending {
+		login := &pending[i]
+		if !hmac.Equal([]byte(codeHash(login.ID, code)), []byte(login.SecretHash)) {
+			continue
+		}
+		if !deviceMatches(login, deviceSecret) {
+			return nil, ErrDeviceMismatch
+		}
+		return login, consume(login)
+	}
+
+	// Código incorrecto: sumar un intento a las peticiones pendientes (y anular las que llegan al máximo)
+	if len(pending) > 0 {
+		maxAttempts := config.GetEnvInt("MAGIC_CODE_MAX_ATTEMPTS", 5)
+		for i := range pending {
+			updates := map[string]interface{}{"attempts": pending[i].Attempts + 1}
+			if pending[i].Attempts+1 >= maxAttempts {
+				updates["consumed_at"] = time.Now()
+				logging.Log.Warnf("Código de inicio de sesión de %s/%s anulado tras %d intentos", store, userID, maxAttempts)
+			}
+			database.DB.Model(&pending[i]).Updates(updates)
+		}
+	}
+	return nil, ErrInvalid
+}
+
+// StartJanitor arranca en segundo plano el borrado de las peticiones caducadas hace más de un día
+func StartJanitor(every time.Duration) {
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for range ticker.C {
+			result := database.DB.Where("expires_at < ?", time.Now().Add(-24*time.Hour)).Delete(&models.MagicLogin{})
+			if result.RowsAffected > 0 {
+				logging.Log.Infof("Peticiones de inicio de sesión sin contraseña caducadas borradas: %d", result.RowsAffected)
+			}
+		}
+	}()
+}
+
+// RandomSecret genera un secreto aleatorio para enlaces y cookies (256 bits en base64 URL)
+func RandomSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// consume marca la petición como usada. Solo una petición concurrente puede conseguirlo.
+func consume(login *models.MagicLogin) error {
+	now := time.Now()
+	result := database.DB.Model(&models.MagicLogin{}).
+		Where("id = ? AND consumed_at IS NULL", login.ID).
+		Update("consumed_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalid
+	}
+	login.ConsumedAt = &now
+	return nil
+}
+
+// deviceMatches comprueba que el canje viene del navegador que hizo la petición
+func deviceMatches(login *models.MagicLogin, deviceSecret string) bool {
+	if !DeviceBinding() {
+		return true
+	}
+	return deviceSecret != "" && hmac.Equal([]byte(hashSecret(deviceSecret)), []byte(login.DeviceHash))
+}
+
+// randomCode genera un código de 6 dígitos (uniforme, con ceros a la izquierda)
+func randomCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// codeHash es el HMAC del código con el ID de la petición: el mismo código en dos peticiones da hashes distintos
+func codeHash(id string, code string) string {
+	mac := hmac.New(sha256.New, []byte(id))
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
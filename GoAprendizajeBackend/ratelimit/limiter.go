@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"go-aprendizaje/config"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit describe una cuota: 'Rate' peticiones cada 'Period', permitiendo ráfagas de hasta 'Burst'
+type Limit struct {
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+// Result es la respuesta del limitador para una petición concreta
+type Result struct {
+	Allowed    bool
+	Limit      int           // Tamaño de la cuota (se devuelve en RateLimit-Limit)
+	Remaining  int           // Peticiones que aún quedan en la ventana
+	ResetAfter time.Duration // Tiempo hasta que la cuota se recupere por completo
+	RetryAfter time.Duration // Tiempo que hay que esperar si la petición fue rechazada
+}
+
+// Store es la interfaz que implementan los distintos "backends" del limitador
+// (en memoria para una sola réplica, MongoDB para compartir el estado entre réplicas)
+type Store interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// interval devuelve el tiempo que "cuesta" cada petición dentro de la cuota
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Rate)
+}
+
+// burst devuelve la ráfaga máxima (si no se define, es igual a 'Rate')
+func (l Limit) burst() int {
+	if l.Burst <= 0 {
+		return l.Rate
+	}
+	return l.Burst
+}
+
+// gcra implementa el algoritmo GCRA (Generic Cell Rate Algorithm).
+// En lugar de guardar un contador, guardamos el "TAT" (Theoretical Arrival Time):
+// el instante en el que la cuota volvería a estar completamente libre.
+// Recibe el TAT guardado (o el cero si la clave es nueva) y devuelve el nuevo TAT y el resultado.
+func gcra(now, tat time.Time, limit Limit) (time.Time, Result) {
+	interval := limit.interval()
+	burst := limit.burst()
+	tolerance := interval * time.Duration(burst)
+
+	if tat.Before(now) {
+		tat = now
+	}
+
+	newTat := tat.Add(interval)
+	allowAt := newTat.Add(-tolerance)
+
+	// Si el momento permitido está en el futuro, la petición se rechaza y el TAT no cambia
+	if allowAt.After(now) {
+		return tat, Result{
+			Allowed:    false,
+			Limit:      burst,
+			Remaining:  0,
+			ResetAfter: tat.Sub(now),
+			RetryAfter: allowAt.Sub(now),
+		}
+	}
+
+	remaining := int((tolerance - newTat.Sub(now)) / interval)
+	return newTat, Result{
+		Allowed:    true,
+		Limit:      burst,
+		Remaining:  remaining,
+		ResetAfter: newTat.Sub(now),
+	}
+}
+
+// ParseLimit convierte un texto con formato "<peticiones>/<periodo>[/<ráfaga>]" (ej: "5/1m" o "100/1h/20") en un Limit
+func ParseLimit(value string) (Limit, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Limit{}, fmt.Errorf("formato de límite inválido: %q", value)
+	}
+
+	rate, err := strconv.Atoi(parts[0])
+	if err != nil || rate <= 0 {
+		return Limit{}, fmt.Errorf("número de peticiones inválido: %q", parts[0])
+	}
+
+	period, err := time.ParseDuration(parts[1])
+	if err != nil || period <= 0 {
+		return Limit{}, fmt.Errorf("periodo inválido: %q", parts[1])
+	}
+
+	limit := Limit{Rate: rate, Period: period}
+	// Cada petición tiene que "costar" al menos 1ns (ej: "10/5ns" daría 0 y GCRA dividiría entre cero)
+	if limit.interval() <= 0 {
+		return Limit{}, fmt.Errorf("periodo demasiado corto para %d peticiones: %q", rate, value)
+	}
+	if len(parts) == 3 {
+		burst, err := strconv.Atoi(parts[2])
+		if err != nil || burst <= 0 {
+			return Limit{}, fmt.Errorf("ráfaga inválida: %q", parts[2])
+		}
+		limit.Burst = burst
+	}
+
+	return limit, nil
+}
+
+// LimitFromEnv lee un límite desde una variable de entorno, usando 'defaultValue' si no existe o es inválida
+func LimitFromEnv(key string, defaultValue string) Limit {
+	limit, err := ParseLimit(config.GetEnv(key, defaultValue))
+	if err != nil {
+		log.Printf("Límite inválido en %s (%v), usando el valor por defecto %s", key, err, defaultValue)
+		limit, _ = ParseLimit(defaultValue)
+	}
+	return limit
+}
@@ -0,0 +1,41 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	valid := map[string]Limit{
+		"5/1m":      {Rate: 5, Period: time.Minute},
+		"100/1h/20": {Rate: 100, Period: time.Hour, Burst: 20},
+		"10/10ns":   {Rate: 10, Period: 10 * time.Nanosecond},
+	}
+	for value, expected := range valid {
+		if limit, err := ParseLimit(value); err != nil || limit != expected {
+			t.Fatalf("ParseLimit(%q) = %+v, %v; se esperaba %+v", value, limit, err, expected)
+		}
+	}
+
+	// "10/5ns": cada petición costaría 0ns y GCRA dividiría entre cero
+	for _, value := range []string{"", "5", "0/1m", "-1/1m", "5/0s", "5/-1m", "5/1m/0", "5/1m/1/1", "10/5ns"} {
+		if limit, err := ParseLimit(value); err == nil {
+			t.Fatalf("ParseLimit(%q) = %+v; se esperaba un error", value, limit)
+		}
+	}
+}
+
+// Con el intervalo más corto posible (1ns por petición) GCRA sigue funcionando
+func TestGCRAMinimumInterval(t *testing.T) {
+	limit, err := ParseLimit("10/10ns/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	tat, first := gcra(now, time.Time{}, limit)
+	_, second := gcra(now, tat, limit)
+	_, third := gcra(now, tat.Add(limit.interval()), limit)
+	if !first.Allowed || !second.Allowed || third.Allowed {
+		t.Fatalf("resultados inesperados: %+v %+v %+v", first, second, third)
+	}
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore guarda el estado del limitador en memoria.
+// Solo sirve cuando hay una única réplica del servidor.
+type MemoryStore struct {
+	mu   sync.Mutex
+	tats map[string]time.Time
+}
+
+// NewMemoryStore crea el store en memoria y lanza una goroutine que limpia las claves caducadas
+func NewMemoryStore(cleanupEvery time.Duration) *MemoryStore {
+	store := &MemoryStore{tats: make(map[string]time.Time)}
+
+	go func() {
+		ticker := time.NewTicker(cleanupEvery)
+		defer ticker.Stop()
+		for range ticker.C {
+			store.cleanup()
+		}
+	}()
+
+	return store
+}
+
+// Allow aplica GCRA sobre la clave de forma atómica (protegido por el mutex)
+func (s *MemoryStore) Allow(_ context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	newTat, result := gcra(now, s.tats[key], limit)
+	s.tats[key] = newTat
+
+	return result, nil
+}
+
+// cleanup borra las claves cuyo TAT ya pasó (su cuota está completamente libre)
+func (s *MemoryStore) cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, tat := range s.tats {
+		if tat.Before(now) {
+			delete(s.tats, key)
+		}
+	}
+}
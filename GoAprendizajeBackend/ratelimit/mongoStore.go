@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxRetries es el número de intentos cuando otra réplica modificó la misma clave a la vez
+const maxRetries = 5
+
+// MongoStore guarda el estado del limitador en una colección de MongoDB,
+// así todas las réplicas del servidor comparten las mismas cuotas.
+type MongoStore struct {
+	collection *mongo.Collection
+}
+
+// rateLimitDocument es el documento guardado por cada clave
+type rateLimitDocument struct {
+	Key       string    `bson:"_id"`
+	TAT       time.Time `bson:"tat"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// NewMongoStore crea el store sobre la colección indicada y su índice TTL
+// (Mongo borra solo los documentos cuando llega 'expires_at')
+func NewMongoStore(collection *mongo.Collection) (*MongoStore, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"expires_at": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &MongoStore{collection: collection}, nil
+}
+
+// Allow aplica GCRA con concurrencia optimista: solo se guarda el nuevo TAT si nadie
+// lo cambió desde que lo leímos; si no, se vuelve a intentar.
+func (s *MongoStore) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		var doc rateLimitDocument
+		exists := true
+
+		err := s.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&doc)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			exists = false
+		} else if err != nil {
+			return Result{}, err
+		}
+
+		newTat, result := gcra(time.Now(), doc.TAT, limit)
+		if !result.Allowed {
+			return result, nil
+		}
+
+		if !exists {
+			_, err = s.collection.InsertOne(ctx, rateLimitDocument{Key: key, TAT: newTat, ExpiresAt: newTat})
+			if mongo.IsDuplicateKeyError(err) {
+				continue // Otra réplica creó la clave justo antes
+			}
+			if err != nil {
+				return Result{}, err
+			}
+			return result, nil
+		}
+
+		update, err := s.collection.UpdateOne(ctx,
+			bson.M{"_id": key, "tat": doc.TAT},
+			bson.M{"$set": bson.M{"tat": newTat, "expires_at": newTat}},
+		)
+		if err != nil {
+			return Result{}, err
+		}
+		if update.MatchedCount == 0 {
+			continue // Otra réplica actualizó el TAT, lo leemos de nuevo
+		}
+		return result, nil
+	}
+
+	return Result{}, errors.New("demasiados conflictos al actualizar el límite de " + key)
+}
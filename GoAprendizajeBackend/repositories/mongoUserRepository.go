@@ -4,11 +4,13 @@ import (
 	"context"
 	"go-aprendizaje/database"
 	"go-aprendizaje/models"
+	"log"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // MongoUserRepository maneja la lógica de BD para usuarios en MongoDB
@@ -20,11 +22,37 @@ type MongoUserRepository struct {
 // NewMongoUserRepository es un "constructor" para crear el repositorio
 func NewMongoUserRepository() *MongoUserRepository {
 	// Obtenemos la colección "users" de nuestra BD "Mongo"
+	collection := database.Mongo.Collection("users") // crea una conexión a la colección "users"
+
+	// Índice único en "email": evita duplicados aunque lleguen dos registros a la vez
+	_, err := collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.M{"email": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("No se pudo crear el índice único de email en Mongo: %v", err)
+	}
+
+	// Índice "sparse" en "welcome_pending": solo lo tienen los usuarios con la bienvenida sin encolar
+	_, err = collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.M{"welcome_pending": 1},
+		Options: options.Index().SetSparse(true),
+	})
+	if err != nil {
+		log.Printf("No se pudo crear el índice de welcome_pending en Mongo: %v", err)
+	}
+
 	return &MongoUserRepository{
-		collection: database.Mongo.Collection("users"), // crea una conexión a la colección "users"
+		collection: collection,
 	}
 }
 
+// NewMongoUserRepositoryWithCollection crea el repositorio sobre una colección ya abierta, sin crear índices
+// (ej: la colección de un cliente de Mongo simulado en las pruebas)
+func NewMongoUserRepositoryWithCollection(collection *mongo.Collection) *MongoUserRepository {
+	return &MongoUserRepository{collection: collection}
+}
+
 // CreateUser inserta un nuevo usuario en Mongo
 // (Estos métodos serán los que implementen nuestra interfaz en el Paso 11)
 // func (r *MongoUserRepository) indica que este método es un metodo asociado a un puntero de MongoUserRepository, es decir,
@@ -93,3 +121,180 @@ func (r *MongoUserRepository) GetUsers() ([]models.MongoUser, error) {
 
 	return users, nil
 }
+
+// GetUserByID busca un usuario por su ID (el string hexadecimal que va en el token)
+func (r *MongoUserRepository) GetUserByID(id string) (*models.MongoUser, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.MongoUser
+	err = r.collection.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// UpdatePassword cambia el hash de la contraseña y guarda el anterior en el historial,
+// conservando solo los últimos 'historySize' hashes
+func (r *MongoUserRepository) UpdatePassword(id primitive.ObjectID, newHash string, oldHash string, historySize int) error {
+	update := bson.M{
+		"$set": bson.M{
+			"password":   newHash,
+			"updated_at": time.Now(),
+		},
+		// $push con $slice negativo: añade al final y se queda con los N últimos
+		"$push": bson.M{
+			"password_history": bson.M{
+				"$each":  []string{oldHash},
+				"$slice": -historySize,
+			},
+		},
+	}
+
+	_, err := r.collection.UpdateByID(context.Background(), id, update)
+	return err
+}
+
+// UpdatePasswordHash reemplaza el hash de la contraseña sin tocar el historial
+// (se usa al "re-hashear" con un algoritmo más moderno, la contraseña en sí no cambia)
+func (r *MongoUserRepository) UpdatePasswordHash(id primitive.ObjectID, newHash string) error {
+	_, err := r.collection.UpdateByID(context.Background(), id, bson.M{
+		"$set": bson.M{"password": newHash},
+	})
+	return err
+}
+
+// StreamUsers recorre los usuarios que cumplen 'filter' de uno en uno con un cursor
+// (a diferencia de GetUsers, no los carga todos en memoria)
+func (r *MongoUserRepository) StreamUsers(filter bson.M, fn func(user *models.MongoUser) error) error {
+	ctx := context.Background()
+
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user models.MongoUser
+		if err := cursor.Decode(&user); err != nil {
+			return err
+		}
+		if err := fn(&user); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// ScheduleDeletion programa el borrado de la cuenta para 'scheduledAt'
+func (r *MongoUserRepository) ScheduleDeletion(id primitive.ObjectID, scheduledAt time.Time, requestedBy string) error {
+	now := time.Now()
+	_, err := r.collection.UpdateByID(context.Background(), id, bson.M{
+		"$set": bson.M{
+			"deletion_scheduled_at": scheduledAt,
+			"deletion_requested_at": now,
+			"deletion_requested_by": requestedBy,
+			"updated_at":            now,
+		},
+	})
+	return err
+}
+
+// CancelDeletion anula un borrado programado
+func (r *MongoUserRepository) CancelDeletion(id primitive.ObjectID) error {
+	_, err := r.collection.UpdateByID(context.Background(), id, bson.M{
+		"$unset": bson.M{"deletion_scheduled_at": "", "deletion_requested_at": "", "deletion_requested_by": ""},
+		"$set":   bson.M{"updated_at": time.Now()},
+	})
+	return err
+}
+
+// GetDueDeletions devuelve los usuarios cuyo periodo de gracia ya terminó
+func (r *MongoUserRepository) GetDueDeletions(now time.Time) ([]models.MongoUser, error) {
+	var users []models.MongoUser
+	cursor, err := r.collection.Find(context.Background(), bson.M{"deletion_scheduled_at": bson.M{"$lte": now}})
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(context.Background(), &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// ClearWelcomePending quita la marca de "bienvenida sin encolar" (ya está en la bandeja de salida)
+func (r *MongoUserRepository) ClearWelcomePending(id primitive.ObjectID) error {
+	_, err := r.collection.UpdateByID(context.Background(), id, bson.M{
+		"$unset": bson.M{"welcome_pending": ""},
+	})
+	return err
+}
+
+// GetPendingWelcomes devuelve los usuarios creados antes de 'createdBefore' con la bienvenida sin encolar
+func (r *MongoUserRepository) GetPendingWelcomes(createdBefore time.Time) ([]models.MongoUser, error) {
+	var users []models.MongoUser
+	cursor, err := r.collection.Find(context.Background(), bson.M{
+		"welcome_pending": true,
+		"created_at":      bson.M{"$lt": createdBefore},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(context.Background(), &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// DeleteUser borra definitivamente el documento del usuario
+func (r *MongoUserRepository) DeleteUser(id primitive.ObjectID) error {
+	_, err := r.collection.DeleteOne(context.Background(), bson.M{"_id": id})
+	return err
+}
+
+// AnonymizeUser reemplaza los datos personales por valores sin significado y deja la cuenta inutilizable
+// (el documento se conserva para no romper referencias, pero ya no identifica a nadie)
+func (r *MongoUserRepository) AnonymizeUser(id primitive.ObjectID, anonymousEmail string) error {
+	_, err := r.collection.UpdateByID(context.Background(), id, bson.M{
+		"$set": bson.M{
+			"email":      anonymousEmail,
+			"password":   "!", // Ningún hash coincide nunca con "!"
+			"updated_at": time.Now(),
+		},
+		"$unset": bson.M{
+			"profile_image_path":       "",
+			"password_history":         "",
+			"deletion_scheduled_at":    "",
+			"deletion_requested_at":    "",
+			"deletion_requested_by":    "",
+			"email_suppressed_at":      "",
+			"email_suppression_reason": "",
+		},
+	})
+	return err
+}
+
+// SetEmailSuppression marca a los usuarios con ese email (sin distinguir mayúsculas) como "no enviar emails".
+// Con 'suppressedAt' nil se quita la marca.
+func (r *MongoUserRepository) SetEmailSuppression(email string, suppressedAt *time.Time, reason string) error {
+	update := bson.M{
+		"$set": bson.M{"email_suppressed_at": suppressedAt, "email_suppression_reason": reason, "updated_at": time.Now()},
+	}
+	if suppressedAt == nil {
+		update = bson.M{
+			"$unset": bson.M{"email_suppressed_at": "", "email_suppression_reason": ""},
+			"$set":   bson.M{"updated_at": time.Now()},
+		}
+	}
+
+	// Collation con "strength" 2: compara sin distinguir mayúsculas (los emails se guardan tal cual se registraron)
+	opts := options.Update().SetCollation(&options.Collation{Locale: "en", Strength: 2})
+	_, err := r.collection.UpdateMany(context.Background(), bson.M{"email": email}, update, opts)
+	return err
+}
@@ -4,6 +4,8 @@ import (
 	"go-aprendizaje/config"
 	"go-aprendizaje/controllers"
 	"go-aprendizaje/middleware"
+	"go-aprendizaje/models"
+	"go-aprendizaje/ratelimit"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,16 +14,35 @@ import (
 // (Debe ser Pública, con 'S' mayúscula En go las funciones publicas empiezan con mayúscula)
 func SetupRoutes(router *gin.Engine) {
 
-	// Obtener la ruta de archivos estáticos desde la configuración o usar el valor por defecto
-	uploadDir := config.GetEnv("UPLOAD_PATH", "./uploads")
-
 	// Servir archivos estáticos.
-	// Cualquier petición GET a /static/[nombre-archivo]
-	// servirá el archivo desde la carpeta ./uploads
+	// Cualquier petición GET a /static/[clave-del-archivo]
+	// servirá el archivo desde el backend de almacenamiento (disco local o S3)
 	// Es un GET
-	router.Static("/static", uploadDir)
+	router.GET("/static/*key", controllers.ServeFile)
+	router.HEAD("/static/*key", controllers.ServeFile)
+
+	// Cuotas de rate limiting (configurables con variables de entorno, formato "<peticiones>/<periodo>[/<ráfaga>]")
+	globalLimit := ratelimit.LimitFromEnv("RATE_LIMIT_GLOBAL", "300/1m/60")
+	authLimit := ratelimit.LimitFromEnv("RATE_LIMIT_AUTH", "10/1m")
+	registerLimit := ratelimit.LimitFromEnv("RATE_LIMIT_REGISTER", "5/1h")
+	uploadLimit := ratelimit.LimitFromEnv("RATE_LIMIT_UPLOAD", "10/1h")
+	exportLimit := ratelimit.LimitFromEnv("RATE_LIMIT_DATA_EXPORT", "3/24h")
+	magicLimit := ratelimit.LimitFromEnv("RATE_LIMIT_MAGIC_LINK", "5/15m")
+	oauthTokenLimit := ratelimit.LimitFromEnv("RATE_LIMIT_OAUTH_TOKEN", "60/1m")
+	apiKeyLimit := ratelimit.LimitFromEnv("RATE_LIMIT_API_KEY", "300/1m/60")
+
+	// Cuota de las rutas que aceptan API keys, después de AuthMiddleware: cada key validada tiene la suya
+	// (aunque se use desde muchas IPs) y las peticiones con token cuentan por usuario
+	apiKeyLimiter := middleware.RateLimitMiddleware("api-key", apiKeyLimit, middleware.KeyByAPIKey)
+
+	// Metadatos del servidor OAuth / OpenID Connect (en la raíz, donde los buscan las librerías cliente)
+	router.GET("/.well-known/openid-configuration", controllers.OpenIDConfiguration)
+	router.GET("/.well-known/oauth-authorization-server", controllers.OpenIDConfiguration)
 
 	api := router.Group("/api")
+	// Límite general para toda la API, por IP. Va antes de la autenticación: no podemos fiarnos
+	// todavía de la API key ni del token que se envíen (cada uno inventado tendría su propia cuota)
+	api.Use(middleware.RateLimitMiddleware("global", globalLimit, middleware.KeyByIP))
 	{
 
 		api.GET("/ping", controllers.Ping)
@@ -30,34 +51,241 @@ func SetupRoutes(router *gin.Engine) {
 		userRoutes := api.Group("/users")
 		{
 			// Ruta para registrar un nuevo usuario
-			userRoutes.POST("/register", controllers.RegisterUser)
+			// (Límite más estricto por IP para evitar registros masivos)
+			userRoutes.POST("/register",
+				middleware.RateLimitMiddleware("register", registerLimit, middleware.KeyByIP),
+				controllers.RegisterUser,
+			)
 
 			// Ruta para iniciar sesión
-			userRoutes.POST("/login", controllers.Login)
+			userRoutes.POST("/login",
+				middleware.RateLimitMiddleware("login", authLimit, middleware.KeyByIP),
+				controllers.Login,
+			)
+
+			// Inicio de sesión sin contraseña (Postgres y Mongo): pedir el enlace/código y canjearlo por el token
+			userRoutes.POST("/login/magic",
+				middleware.RateLimitMiddleware("magic", magicLimit, middleware.KeyByIP),
+				controllers.RequestMagicLogin,
+			)
+			userRoutes.POST("/login/magic/verify",
+				middleware.RateLimitMiddleware("login", authLimit, middleware.KeyByIP),
+				controllers.VerifyMagicLogin,
+			)
 
 			// Ruta protegida para obtener el perfil del usuario
 			// Se añade el middleware de autenticación
 			// Es como una cadena ejecución, primero el middleware y luego el controlador
-			userRoutes.GET("/profile", middleware.AuthMiddleware(), controllers.GetProfile)
+			// (las rutas con permisos, ej. models.ScopeProfileRead, también aceptan API keys que los tengan)
+			userRoutes.GET("/profile", middleware.AuthMiddleware(models.ScopeProfileRead), apiKeyLimiter, controllers.GetProfile)
+
+			// API keys del usuario para sus scripts (solo con sesión: una API key no puede crear otras)
+			userRoutes.POST("/api-keys", middleware.AuthMiddleware(), controllers.CreateAPIKey)
+			userRoutes.GET("/api-keys", middleware.AuthMiddleware(), controllers.ListAPIKeys)
+			userRoutes.DELETE("/api-keys/:keyID", middleware.AuthMiddleware(), controllers.RevokeAPIKey)
+
+			// Cerrar la sesión actual, y renovar el token de acceso en modo cookie (ver authcookies)
+			userRoutes.POST("/logout", middleware.AuthMiddleware(), controllers.Logout)
+			userRoutes.POST("/token/refresh",
+				middleware.RateLimitMiddleware("refresh", authLimit, middleware.KeyByIP),
+				controllers.RefreshToken,
+			)
+
+			// Sesiones abiertas del usuario (dispositivos) y cerrarlas (Postgres y Mongo)
+			userRoutes.GET("/sessions", middleware.AuthMiddleware(), controllers.ListSessions)
+			userRoutes.DELETE("/sessions", middleware.AuthMiddleware(), controllers.RevokeOtherSessions)
+			userRoutes.DELETE("/sessions/:sessionID", middleware.AuthMiddleware(), controllers.RevokeSession)
+
+			// Historial de inicios de sesión de la cuenta (correctos y fallidos)
+			userRoutes.GET("/login-history", middleware.AuthMiddleware(), controllers.GetLoginHistory)
+
+			// Aplicaciones (clientes OAuth) con acceso a la cuenta, y quitarles el acceso
+			userRoutes.GET("/oauth/consents", middleware.AuthMiddleware(), controllers.ListOAuthConsents)
+			userRoutes.DELETE("/oauth/consents/:clientID", middleware.AuthMiddleware(), controllers.RevokeOAuthConsent)
 
 			// Rutas para usuario en MongoDB
-			userRoutes.POST("/mongo/register", controllers.MongoRegister)
-			userRoutes.POST("/mongo/login", controllers.MongoLogin)
+			userRoutes.POST("/mongo/register",
+				middleware.RateLimitMiddleware("register", registerLimit, middleware.KeyByIP),
+				controllers.MongoRegister,
+			)
+			userRoutes.POST("/mongo/login",
+				middleware.RateLimitMiddleware("login", authLimit, middleware.KeyByIP),
+				controllers.MongoLogin,
+			)
+
+			// Rutas para cambiar la contraseña (requieren la contraseña actual)
+			userRoutes.PUT("/password",
+				middleware.AuthMiddleware(),
+				middleware.RateLimitMiddleware("login", authLimit, middleware.KeyByUser),
+				controllers.ChangePassword,
+			)
+			userRoutes.PUT("/mongo/password",
+				middleware.AuthMiddleware(),
+				middleware.RateLimitMiddleware("login", authLimit, middleware.KeyByUser),
+				controllers.MongoChangePassword,
+			)
+
+			// Exportación de datos personales (RGPD): se pide con el token y se descarga con el enlace del email
+			userRoutes.POST("/me/export",
+				middleware.AuthMiddleware(),
+				middleware.RateLimitMiddleware("export", exportLimit, middleware.KeyByUser),
+				controllers.RequestDataExport,
+			)
+			userRoutes.GET("/exports/:id/download", controllers.DownloadDataExport)
+
+			// Borrar la propia cuenta (con periodo de gracia) y cancelar el borrado
+			userRoutes.DELETE("/me",
+				middleware.AuthMiddleware(),
+				middleware.RateLimitMiddleware("login", authLimit, middleware.KeyByUser),
+				controllers.DeleteMyAccount,
+			)
+			userRoutes.DELETE("/me/deletion", middleware.AuthMiddleware(), controllers.CancelMyAccountDeletion)
 
 			// Ruta para subir foto de perfil
+			// (El límite va después de AuthMiddleware para poder limitar por usuario)
 			userRoutes.POST("/profile/picture",
-				middleware.AuthMiddleware(),
+				middleware.AuthMiddleware(models.ScopeFilesWrite),
+				apiKeyLimiter,
+				middleware.RateLimitMiddleware("upload", uploadLimit, middleware.KeyByUser),
 				controllers.PgUploadProfilePicture,
 			)
 
+			// Archivos privados del usuario (documentos): nunca se sirven desde /static
+			userRoutes.POST("/me/files",
+				middleware.AuthMiddleware(models.ScopeFilesWrite),
+				apiKeyLimiter,
+				middleware.RateLimitMiddleware("upload", uploadLimit, middleware.KeyByUser),
+				controllers.UploadPrivateFile,
+			)
+			userRoutes.GET("/me/files", middleware.AuthMiddleware(models.ScopeFilesRead), apiKeyLimiter, controllers.ListPrivateFiles)
+			userRoutes.GET("/me/files/:id", middleware.AuthMiddleware(models.ScopeFilesRead), apiKeyLimiter, controllers.DownloadPrivateFile)
+			userRoutes.DELETE("/me/files/:id", middleware.AuthMiddleware(models.ScopeFilesWrite), apiKeyLimiter, controllers.DeletePrivateFile)
+
 		}
 
+		// Subidas reanudables (protocolo tus): foto de perfil o archivo privado en trozos.
+		// El límite de subidas solo se aplica al crear la subida, no a cada trozo.
+		tusRoutes := api.Group("/uploads/tus")
+		{
+			tusRoutes.OPTIONS("", controllers.TusOptions)
+			tusRoutes.OPTIONS("/:id", controllers.TusOptions)
+			tusRoutes.POST("",
+				middleware.AuthMiddleware(models.ScopeFilesWrite),
+				apiKeyLimiter,
+				middleware.RateLimitMiddleware("upload", uploadLimit, middleware.KeyByUser),
+				controllers.TusCreate,
+			)
+			tusRoutes.HEAD("/:id", middleware.AuthMiddleware(models.ScopeFilesWrite), apiKeyLimiter, controllers.TusHead)
+			tusRoutes.PATCH("/:id", middleware.AuthMiddleware(models.ScopeFilesWrite), apiKeyLimiter, controllers.TusPatch)
+			tusRoutes.DELETE("/:id", middleware.AuthMiddleware(models.ScopeFilesWrite), apiKeyLimiter, controllers.TusDelete)
+		}
+
+		// Servidor OAuth 2.1 / OpenID Connect ("Iniciar sesión con..." para otras aplicaciones).
+		// Los endpoints de la especificación autentican al cliente, no al usuario; la pantalla de
+		// consentimiento del frontend usa la sesión del usuario (solo personas: sin API keys)
+		oauthRoutes := api.Group("/oauth")
+		{
+			oauthRoutes.GET("/jwks", controllers.OAuthJWKS)
+			oauthRoutes.GET("/authorize", controllers.OAuthAuthorize)
+			oauthRoutes.POST("/token",
+				middleware.RateLimitMiddleware("oauth-token", oauthTokenLimit, middleware.KeyByIP),
+				controllers.OAuthToken,
+			)
+			oauthRoutes.GET("/userinfo", controllers.OAuthUserInfo)
+			oauthRoutes.POST("/userinfo", controllers.OAuthUserInfo)
+			oauthRoutes.POST("/introspect", controllers.OAuthIntrospect)
+			oauthRoutes.POST("/revoke", controllers.OAuthRevoke)
+
+			oauthRoutes.GET("/requests/:id", middleware.AuthMiddleware(), controllers.GetOAuthAuthorizationRequest)
+			oauthRoutes.POST("/requests/:id/decision", middleware.AuthMiddleware(), controllers.DecideOAuthAuthorizationRequest)
+		}
+
+		// Rutas de desarrollo (ej: ver los emails capturados). Nunca activarlas en producción
+		if config.GetEnvBool("DEV_ENDPOINTS", false) {
+			devRoutes := api.Group("/dev")
+			{
+				devRoutes.GET("/mail", controllers.DevListMail)
+				devRoutes.GET("/mail/:id", controllers.DevGetMail)
+				devRoutes.DELETE("/mail", controllers.DevClearMail)
+			}
+		}
+
+		// Webhooks del proveedor de correo (rebotes y quejas de spam), protegidos con EMAIL_WEBHOOK_SECRET
+		webhookRoutes := api.Group("/webhooks/email")
+		{
+			webhookRoutes.POST("/events", controllers.EmailEventsWebhook)
+			webhookRoutes.POST("/dsn", controllers.EmailDSNWebhook)
+		}
+
+		// Descarga de archivos privados con URL firmada (sin token, la firma caduca en minutos)
+		api.GET("/files/:id", controllers.ServeSignedFile)
+		api.HEAD("/files/:id", controllers.ServeSignedFile)
+
 		// Rutas para admin
 		adminRoutes := api.Group("/admin")
-		adminRoutes.Use(middleware.AuthMiddleware(), middleware.RoleMiddleware("admin"))
+		adminRoutes.Use(middleware.AuthMiddleware(models.ScopeAdmin), middleware.RoleMiddleware("admin"), apiKeyLimiter)
 		{
 			// Ruta protegida para obtener usuarios (solo accesible por admin)
 			adminRoutes.GET("/users", controllers.GetAllUsers)
+
+			// Importar usuarios de sistemas antiguos (CSV/JSONL con hashes de otros formatos)
+			adminRoutes.POST("/users/import", controllers.ImportUsers)
+
+			// Exportar usuarios (CSV, JSONL o XLSX) con columnas y filtros seleccionables
+			adminRoutes.GET("/users/export", controllers.ExportUsers)
+
+			// Borrar una cuenta ("?immediate=true" para saltarse el periodo de gracia) y cancelar un borrado programado
+			adminRoutes.DELETE("/users/:store/:id", controllers.AdminDeleteUser)
+			adminRoutes.DELETE("/users/:store/:id/deletion", controllers.AdminCancelUserDeletion)
+
+			// Ver y cerrar las sesiones de un usuario
+			adminRoutes.GET("/users/:store/:id/sessions", controllers.AdminListUserSessions)
+			adminRoutes.DELETE("/users/:store/:id/sessions", controllers.AdminRevokeUserSessions)
+			adminRoutes.DELETE("/users/:store/:id/sessions/:sessionID", controllers.AdminRevokeUserSession)
+
+			// Historial de inicios de sesión de un usuario, y búsqueda en todo el historial (por email, IP...)
+			adminRoutes.GET("/users/:store/:id/login-history", controllers.AdminGetUserLoginHistory)
+			adminRoutes.GET("/login-history", controllers.AdminSearchLoginHistory)
+
+			// API keys de cualquier usuario (se crean en adminSessionRoutes)
+			adminRoutes.GET("/users/:store/:id/api-keys", controllers.AdminListUserAPIKeys)
+			adminRoutes.DELETE("/users/:store/:id/api-keys/:keyID", controllers.AdminRevokeUserAPIKey)
+
+			// Cuentas de servicio (usuarios sin contraseña para scripts, que solo usan API keys; se crean en adminSessionRoutes)
+			adminRoutes.GET("/service-accounts", controllers.AdminListServiceAccounts)
+
+			// Aplicaciones cliente de OAuth: registrarlas, borrarlas y rotar su secreto
+			adminRoutes.POST("/oauth/clients", controllers.AdminCreateOAuthClient)
+			adminRoutes.GET("/oauth/clients", controllers.AdminListOAuthClients)
+			adminRoutes.DELETE("/oauth/clients/:clientID", controllers.AdminDeleteOAuthClient)
+			adminRoutes.POST("/oauth/clients/:clientID/secret", controllers.AdminRotateOAuthClientSecret)
+
+			// Ver los archivos privados de un usuario (ej: para revisar documentos)
+			adminRoutes.GET("/users/:store/:id/files", controllers.AdminListUserFiles)
+
+			// Bandeja de salida de emails: ver los pendientes/fallidos y reenviarlos
+			adminRoutes.GET("/emails", controllers.AdminListEmails)
+			adminRoutes.GET("/emails/:id", controllers.AdminGetEmail)
+			adminRoutes.POST("/emails/:id/resend", controllers.AdminResendEmail)
+
+			// Lista de supresión: direcciones a las que no se envían emails (rebotes, quejas o a mano)
+			adminRoutes.GET("/email-suppressions", controllers.AdminListSuppressions)
+			adminRoutes.POST("/email-suppressions", controllers.AdminAddSuppression)
+			adminRoutes.DELETE("/email-suppressions/:id", controllers.AdminRemoveSuppression)
+
+			// Plantillas de email: listarlas y ver cómo quedan (con datos de ejemplo) en cada idioma
+			adminRoutes.GET("/email-templates", controllers.AdminListEmailTemplates)
+			adminRoutes.GET("/email-templates/:name/preview", controllers.AdminPreviewEmailTemplate)
+		}
+
+		// Rutas de admin que crean credenciales: solo con sesión, nunca con una API key (igual que /users/api-keys).
+		// Si no, una key con el permiso "admin" podría crearse otras nuevas (para su dueño o para cualquiera)
+		// y seguir con acceso aunque caduque o se anule.
+		adminSessionRoutes := api.Group("/admin")
+		adminSessionRoutes.Use(middleware.AuthMiddleware(), middleware.RoleMiddleware("admin"))
+		{
+			adminSessionRoutes.POST("/users/:store/:id/api-keys", controllers.AdminCreateUserAPIKey)
+			adminSessionRoutes.POST("/service-accounts", controllers.AdminCreateServiceAccount)
 		}
 
 	}
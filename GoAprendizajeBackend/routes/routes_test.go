@@ -0,0 +1,40 @@
+package routes
+
+import (
+	"go-aprendizaje/core"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/ratelimit"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Las rutas de admin que crean credenciales no aceptan API keys (ni siquiera con el permiso "admin"):
+// se rechazan antes de mirar la key
+func TestAdminCredentialRoutesRejectAPIKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logging.Log = logrus.New()
+	logging.Log.SetOutput(io.Discard)
+	core.RateLimitStore = ratelimit.NewMemoryStore(time.Minute)
+
+	router := gin.New()
+	SetupRoutes(router)
+
+	for _, path := range []string{"/api/admin/users/postgres/1/api-keys", "/api/admin/service-accounts"} {
+		request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"name":"script"}`))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("X-API-Key", "ak_clave-de-admin")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		if recorder.Code != http.StatusForbidden || !strings.Contains(recorder.Body.String(), "no admite API keys") {
+			t.Fatalf("POST %s con API key = %d %s; se esperaba 403", path, recorder.Code, recorder.Body.String())
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// ErrSizeLimit se devuelve si el archivo supera el StreamMaxLength configurado en clamd
+var ErrSizeLimit = errors.New("clamd: el archivo supera el tamaño máximo que acepta el antivirus")
+
+// clamdChunkSize es el tamaño de cada trozo que se envía con INSTREAM
+const clamdChunkSize = 64 * 1024
+
+// ClamdScanner habla con el demonio de ClamAV (clamd) por TCP o por socket Unix,
+// usando el comando INSTREAM: el archivo se envía por la conexión, así clamd no necesita acceso a nuestro disco.
+type ClamdScanner struct {
+	network string // "tcp" o "unix"
+	address string
+	timeout time.Duration
+}
+
+// NewClamdScanner crea el cliente. 'address' puede ser "tcp://host:3310", "unix:///run/clamav/clamd.ctl" o "host:3310".
+func NewClamdScanner(address string, timeout time.Duration) (*ClamdScanner, error) {
+	scanner := &ClamdScanner{network: "tcp", address: address, timeout: timeout}
+	switch {
+	case strings.HasPrefix(address, "tcp://"):
+		scanner.address = strings.TrimPrefix(address, "tcp://")
+	case strings.HasPrefix(address, "unix://"):
+		scanner.network, scanner.address = "unix", strings.TrimPrefix(address, "unix://")
+	case strings.Contains(address, "://"):
+		return nil, fmt.Errorf("dirección de clamd no soportada: %s", address)
+	}
+	if scanner.address == "" {
+		return nil, errors.New("falta la dirección de clamd (CLAMD_ADDRESS)")
+	}
+	return scanner, nil
+}
+
+func (s *ClamdScanner) Name() string { return "clamd" }
+
+// Ping comprueba que clamd responde (útil al arrancar y en los health checks)
+func (s *ClamdScanner) Ping(ctx context.Context) error {
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("zPING\x00")); err != nil {
+		return err
+	}
+	reply, err := readReply(conn)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("clamd: respuesta inesperada a PING: %q", reply)
+	}
+	return nil
+}
+
+// Scan envía el archivo con INSTREAM: "zINSTREAM\0", trozos de <4 bytes de tamaño big-endian><datos>
+// y un trozo de tamaño 0 para terminar. clamd responde "stream: OK" o "stream: <firma> FOUND".
+func (s *ClamdScanner) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// 1. Enviar el archivo (si clamd corta a mitad, p.ej. por el límite de tamaño, igualmente leemos su respuesta)
+	writeErr := sendStream(conn, r)
+
+	// 2. Leer la respuesta
+	reply, err := readReply(conn)
+	if err != nil {
+		if writeErr != nil {
+			return nil, writeErr
+		}
+		return nil, err
+	}
+	return parseReply(reply)
+}
+
+// dial abre la conexión con un plazo máximo (el del contexto o el timeout configurado, el que llegue antes)
+func (s *ClamdScanner) dial(ctx context.Context) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return nil, fmt.Errorf("clamd: no se pudo conectar: %w", err)
+	}
+
+	deadline := time.Now().Add(s.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.SetDeadline(deadline)
+	return conn, nil
+}
+
+func sendStream(conn net.Conn, r io.Reader) error {
+	writer := bufio.NewWriterSize(conn, clamdChunkSize+4)
+	if _, err := writer.WriteString("zINSTREAM\x00"); err != nil {
+		return err
+	}
+
+	buffer := make([]byte, clamdChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, err := r.Read(buffer)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, werr := writer.Write(size); werr != nil {
+				return werr
+			}
+			if _, werr := writer.Write(buffer[:n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Trozo de tamaño 0: fin del archivo
+	if _, err := writer.Write([]byte{0, 0, 0, 0}); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// readReply lee la respuesta de clamd (termina en '\0' con los comandos "z...")
+func readReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && (err != io.EOF || len(reply) == 0) {
+		return "", fmt.Errorf("clamd: no se pudo leer la respuesta: %w", err)
+	}
+	return string(bytes.TrimRight(reply, "\x00\n")), nil
+}
+
+// parseReply interpreta "stream: OK", "stream: <firma> FOUND" o "<mensaje> ERROR"
+func parseReply(reply string) (*Result, error) {
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return &Result{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return &Result{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	case strings.HasPrefix(reply, "INSTREAM size limit exceeded"):
+		return nil, ErrSizeLimit
+	default:
+		return nil, fmt.Errorf("clamd: %s", reply)
+	}
+}
@@ -0,0 +1,129 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClamd es un clamd de mentira: lee el INSTREAM y responde lo que diga 'handle'
+// (que recibe los bytes que llegaron y decide cuándo contestar)
+func fakeClamd(t *testing.T, handle func(conn net.Conn, reader *bufio.Reader)) *ClamdScanner {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		command, err := reader.ReadString(0)
+		if err != nil || command != "zINSTREAM\x00" {
+			conn.Write([]byte("UNKNOWN COMMAND\x00"))
+			return
+		}
+		handle(conn, reader)
+	}()
+
+	scanner, err := NewClamdScanner("tcp://"+listener.Addr().String(), 2*time.Second)
+	if err != nil {
+		t.Fatalf("NewClamdScanner: %v", err)
+	}
+	return scanner
+}
+
+// readStream lee los trozos del INSTREAM hasta el de tamaño 0 (o hasta 'limit' bytes) y devuelve los datos
+func readStream(reader *bufio.Reader, limit int) ([]byte, bool) {
+	var data []byte
+	size := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(reader, size); err != nil {
+			return data, false
+		}
+		n := binary.BigEndian.Uint32(size)
+		if n == 0 {
+			return data, true
+		}
+		chunk := make([]byte, n)
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			return data, false
+		}
+		data = append(data, chunk...)
+		if limit > 0 && len(data) > limit {
+			return data, false
+		}
+	}
+}
+
+func TestClamdScan(t *testing.T) {
+	file := bytes.Repeat([]byte("contenido del archivo "), 10000) // Varios trozos de 64 KB
+
+	t.Run("OK", func(t *testing.T) {
+		received := make(chan []byte, 1)
+		scanner := fakeClamd(t, func(conn net.Conn, reader *bufio.Reader) {
+			data, _ := readStream(reader, 0)
+			received <- data
+			conn.Write([]byte("stream: OK\x00"))
+		})
+
+		result, err := scanner.Scan(context.Background(), bytes.NewReader(file))
+		if err != nil || result.Infected {
+			t.Fatalf("Scan = %+v, %v; se esperaba limpio", result, err)
+		}
+		if data := <-received; !bytes.Equal(data, file) {
+			t.Fatalf("clamd recibió %d bytes distintos de los %d del archivo", len(data), len(file))
+		}
+	})
+
+	t.Run("FOUND", func(t *testing.T) {
+		scanner := fakeClamd(t, func(conn net.Conn, reader *bufio.Reader) {
+			readStream(reader, 0)
+			conn.Write([]byte("stream: Win.Test.EICAR_HDB-1 FOUND\x00"))
+		})
+
+		result, err := scanner.Scan(context.Background(), strings.NewReader("X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR"))
+		if err != nil || !result.Infected || result.Signature != "Win.Test.EICAR_HDB-1" {
+			t.Fatalf("Scan = %+v, %v; se esperaba infectado con Win.Test.EICAR_HDB-1", result, err)
+		}
+	})
+
+	t.Run("INSTREAM size limit exceeded", func(t *testing.T) {
+		// clamd contesta en cuanto pasa de su StreamMaxLength, sin esperar al final del archivo
+		scanner := fakeClamd(t, func(conn net.Conn, reader *bufio.Reader) {
+			readStream(reader, 1024)
+			conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+			conn.(*net.TCPConn).CloseWrite()
+			io.Copy(io.Discard, reader) // Lo que siga enviando el cliente se descarta
+		})
+
+		result, err := scanner.Scan(context.Background(), bytes.NewReader(file))
+		if !errors.Is(err, ErrSizeLimit) {
+			t.Fatalf("Scan = %+v, %v; se esperaba ErrSizeLimit", result, err)
+		}
+	})
+
+	t.Run("conexión cortada a mitad del envío", func(t *testing.T) {
+		scanner := fakeClamd(t, func(conn net.Conn, reader *bufio.Reader) {
+			readStream(reader, 1024)
+			// Cierra sin responder (ej: clamd se reinicia)
+		})
+
+		result, err := scanner.Scan(context.Background(), bytes.NewReader(file))
+		if err == nil || result != nil {
+			t.Fatalf("Scan = %+v, %v; se esperaba un error (nunca \"limpio\")", result, err)
+		}
+	})
+}
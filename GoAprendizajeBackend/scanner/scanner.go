@@ -0,0 +1,41 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"go-aprendizaje/config"
+	"io"
+	"time"
+)
+
+// Result es el resultado de analizar un archivo
+type Result struct {
+	Infected  bool
+	Signature string // Nombre de la firma detectada (ej: "Win.Test.EICAR_HDB-1"), vacío si está limpio
+}
+
+// Scanner es la interfaz común a los antivirus.
+// Los archivos subidos se analizan antes de que nadie pueda descargarlos (ver media/quarantine.go).
+type Scanner interface {
+	// Name es el identificador del antivirus ("clamd")
+	Name() string
+	// Scan analiza el contenido de 'r'. Un error significa "no se pudo analizar", nunca "limpio".
+	Scan(ctx context.Context, r io.Reader) (*Result, error)
+}
+
+// New crea el antivirus indicado en SCANNER_BACKEND.
+// "none" devuelve nil: los archivos no se analizan (ni pasan por cuarentena).
+func New(backend string) (Scanner, error) {
+	switch backend {
+	case "none", "":
+		return nil, nil
+	case "clamd":
+		timeout, err := time.ParseDuration(config.GetEnv("CLAMD_TIMEOUT", "60s"))
+		if err != nil {
+			return nil, fmt.Errorf("CLAMD_TIMEOUT inválido: %w", err)
+		}
+		return NewClamdScanner(config.GetEnv("CLAMD_ADDRESS", "tcp://localhost:3310"), timeout)
+	default:
+		return nil, fmt.Errorf("antivirus desconocido: %s", backend)
+	}
+}
@@ -0,0 +1,132 @@
+package security
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// breachedFile es el fichero de hashes filtrados (formato de "Have I Been Pwned"):
+// una línea por contraseña con "<SHA1 EN MAYÚSCULAS>:<veces vista>", ORDENADO por hash.
+// No se carga en memoria: se busca con búsqueda binaria directamente sobre el disco.
+var breachedFile *os.File
+
+// breachedPrefixLength es el tamaño del prefijo usado en la consulta (igual que la API k-anonymity de HIBP)
+const breachedPrefixLength = 5
+
+// OpenBreachedPasswords abre el fichero de contraseñas filtradas
+func OpenBreachedPasswords(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	breachedFile = file
+	return nil
+}
+
+// IsBreachedPassword calcula el SHA-1 de la contraseña y lo busca en el fichero.
+// Igual que el modelo k-anonymity, pedimos el rango de un prefijo de 5 caracteres
+// y comparamos el resto del hash con los sufijos devueltos.
+func IsBreachedPassword(password string) (bool, error) {
+	if breachedFile == nil {
+		return false, errors.New("fichero de contraseñas filtradas no configurado")
+	}
+
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:breachedPrefixLength], hash[breachedPrefixLength:]
+
+	suffixes, err := breachedRange(prefix)
+	if err != nil {
+		return false, err
+	}
+
+	for _, candidate := range suffixes {
+		if candidate == suffix {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// breachedRange devuelve los sufijos de todos los hashes que empiezan por 'prefix'
+func breachedRange(prefix string) ([]string, error) {
+	info, err := breachedFile.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+
+	// Búsqueda binaria sobre posiciones del fichero: buscamos la primera posición
+	// cuya línea siguiente sea >= prefijo
+	low, high := int64(0), size
+	for low < high {
+		mid := (low + high) / 2
+		line, _, err := lineAt(mid, size)
+		if err != nil {
+			return nil, err
+		}
+		if line == "" || line >= prefix {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+
+	// Recorremos las líneas desde ahí mientras sigan empezando por el prefijo
+	_, start, err := lineAt(low, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var suffixes []string
+	scanner := bufio.NewScanner(io.NewSectionReader(breachedFile, start, size-start))
+	for scanner.Scan() {
+		line := strings.ToUpper(strings.TrimSpace(scanner.Text()))
+		if !strings.HasPrefix(line, prefix) {
+			break
+		}
+		hash, _, _ := strings.Cut(line, ":")
+		suffixes = append(suffixes, hash[breachedPrefixLength:])
+	}
+
+	return suffixes, scanner.Err()
+}
+
+// lineAt devuelve la primera línea que empieza en 'offset' o después, y la posición donde empieza.
+// Devuelve "" si no quedan líneas.
+func lineAt(offset int64, size int64) (string, int64, error) {
+	// Una línea (hash SHA-1 + contador) nunca pasa de 64 bytes, así que con leer
+	// 2 líneas desde 'offset - 1' siempre encontramos el inicio de la siguiente y la línea completa
+	start := offset
+	if offset > 0 {
+		start = offset - 1
+	}
+	buf := make([]byte, 128)
+	n, err := breachedFile.ReadAt(buf, start)
+	if err != nil && err != io.EOF {
+		return "", size, err
+	}
+	buf = buf[:n]
+
+	// Si no estamos al principio del fichero, saltamos hasta después del siguiente salto de línea
+	if offset > 0 {
+		newline := bytes.IndexByte(buf, '\n')
+		if newline < 0 {
+			return "", size, nil
+		}
+		buf = buf[newline+1:]
+		start += int64(newline + 1)
+	}
+	if start >= size {
+		return "", size, nil
+	}
+
+	line, _, _ := bytes.Cut(buf, []byte("\n"))
+	return strings.ToUpper(strings.TrimSpace(string(line))), start, nil
+}
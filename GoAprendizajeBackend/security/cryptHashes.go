@@ -0,0 +1,214 @@
+package security
+
+import (
+	"crypto/md5"
+	"crypto/sha512"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Implementación de los formatos "crypt" de Unix/PHP que aparecen en sistemas antiguos:
+// - MD5-crypt:     $1$<salt>$<hash>
+// - SHA-512-crypt: $6$[rounds=N$]<salt>$<hash>
+// La biblioteca estándar de Go no los incluye, así que los implementamos siguiendo
+// la especificación original (solo para VERIFICAR, nunca generamos hashes nuevos con ellos).
+
+// cryptAlphabet es el "base64" propio de crypt (distinto orden que el estándar)
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// cryptEncode24 codifica 3 bytes en 'n' caracteres del alfabeto de crypt
+func cryptEncode24(b *strings.Builder, b2, b1, b0 byte, n int) {
+	w := uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+	for i := 0; i < n; i++ {
+		b.WriteByte(cryptAlphabet[w&0x3f])
+		w >>= 6
+	}
+}
+
+// md5Crypt calcula el hash MD5-crypt completo ("$1$salt$hash") para comparar
+func md5Crypt(password []byte, salt string) string {
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+	magic := "$1$"
+
+	alt := md5.New()
+	alt.Write(password)
+	alt.Write([]byte(salt))
+	alt.Write(password)
+	altSum := alt.Sum(nil)
+
+	ctx := md5.New()
+	ctx.Write(password)
+	ctx.Write([]byte(magic))
+	ctx.Write([]byte(salt))
+	for i := len(password); i > 0; i -= 16 {
+		ctx.Write(altSum[:min(i, 16)])
+	}
+	for i := len(password); i > 0; i >>= 1 {
+		if i&1 == 1 {
+			ctx.Write([]byte{0})
+		} else {
+			ctx.Write(password[:1])
+		}
+	}
+	final := ctx.Sum(nil)
+
+	// 1000 rondas para hacerlo más lento
+	for i := 0; i < 1000; i++ {
+		round := md5.New()
+		if i&1 == 1 {
+			round.Write(password)
+		} else {
+			round.Write(final)
+		}
+		if i%3 != 0 {
+			round.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			round.Write(password)
+		}
+		if i&1 == 1 {
+			round.Write(final)
+		} else {
+			round.Write(password)
+		}
+		final = round.Sum(nil)
+	}
+
+	var b strings.Builder
+	b.WriteString(magic + salt + "$")
+	cryptEncode24(&b, final[0], final[6], final[12], 4)
+	cryptEncode24(&b, final[1], final[7], final[13], 4)
+	cryptEncode24(&b, final[2], final[8], final[14], 4)
+	cryptEncode24(&b, final[3], final[9], final[15], 4)
+	cryptEncode24(&b, final[4], final[10], final[5], 4)
+	cryptEncode24(&b, 0, 0, final[11], 2)
+
+	return b.String()
+}
+
+// Límites de rondas de SHA-512-crypt según la especificación
+const (
+	sha512CryptDefaultRounds = 5000
+	sha512CryptMinRounds     = 1000
+	sha512CryptMaxRounds     = 999999999
+)
+
+// sha512CryptOrder es el orden en que se codifican los 64 bytes del resultado
+var sha512CryptOrder = [21][3]int{
+	{0, 21, 42}, {22, 43, 1}, {44, 2, 23}, {3, 24, 45}, {25, 46, 4}, {47, 5, 26}, {6, 27, 48},
+	{28, 49, 7}, {50, 8, 29}, {9, 30, 51}, {31, 52, 10}, {53, 11, 32}, {12, 33, 54}, {34, 55, 13},
+	{56, 14, 35}, {15, 36, 57}, {37, 58, 16}, {59, 17, 38}, {18, 39, 60}, {40, 61, 19}, {62, 20, 41},
+}
+
+// sha512Crypt calcula el hash SHA-512-crypt completo a partir del hash guardado
+// (de él sacamos el salt y, si existe, el número de rondas)
+func sha512Crypt(password []byte, stored string) (string, error) {
+	rest := strings.TrimPrefix(stored, "$6$")
+
+	rounds := sha512CryptDefaultRounds
+	customRounds := false
+	if strings.HasPrefix(rest, "rounds=") {
+		value, after, found := strings.Cut(strings.TrimPrefix(rest, "rounds="), "$")
+		if !found {
+			return "", errors.New("hash SHA-512-crypt inválido")
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return "", errors.New("rondas inválidas en el hash SHA-512-crypt")
+		}
+		rounds = min(max(parsed, sha512CryptMinRounds), sha512CryptMaxRounds)
+		customRounds = true
+		rest = after
+	}
+
+	salt, _, _ := strings.Cut(rest, "$")
+	if len(salt) > 16 {
+		salt = salt[:16]
+	}
+	saltBytes := []byte(salt)
+
+	// Digest B = SHA512(password + salt + password)
+	alt := sha512.New()
+	alt.Write(password)
+	alt.Write(saltBytes)
+	alt.Write(password)
+	altSum := alt.Sum(nil)
+
+	// Digest A
+	ctx := sha512.New()
+	ctx.Write(password)
+	ctx.Write(saltBytes)
+	cnt := len(password)
+	for ; cnt > 64; cnt -= 64 {
+		ctx.Write(altSum)
+	}
+	ctx.Write(altSum[:cnt])
+	for cnt = len(password); cnt > 0; cnt >>= 1 {
+		if cnt&1 == 1 {
+			ctx.Write(altSum)
+		} else {
+			ctx.Write(password)
+		}
+	}
+	final := ctx.Sum(nil)
+
+	// Secuencia P (derivada de la contraseña)
+	dp := sha512.New()
+	for i := 0; i < len(password); i++ {
+		dp.Write(password)
+	}
+	p := repeatBytes(dp.Sum(nil), len(password))
+
+	// Secuencia S (derivada del salt)
+	ds := sha512.New()
+	for i := 0; i < 16+int(final[0]); i++ {
+		ds.Write(saltBytes)
+	}
+	s := repeatBytes(ds.Sum(nil), len(saltBytes))
+
+	for i := 0; i < rounds; i++ {
+		round := sha512.New()
+		if i&1 == 1 {
+			round.Write(p)
+		} else {
+			round.Write(final)
+		}
+		if i%3 != 0 {
+			round.Write(s)
+		}
+		if i%7 != 0 {
+			round.Write(p)
+		}
+		if i&1 == 1 {
+			round.Write(final)
+		} else {
+			round.Write(p)
+		}
+		final = round.Sum(nil)
+	}
+
+	var b strings.Builder
+	b.WriteString("$6$")
+	if customRounds {
+		b.WriteString("rounds=" + strconv.Itoa(rounds) + "$")
+	}
+	b.WriteString(salt + "$")
+	for _, group := range sha512CryptOrder {
+		cryptEncode24(&b, final[group[0]], final[group[1]], final[group[2]], 4)
+	}
+	cryptEncode24(&b, 0, 0, final[63], 2)
+
+	return b.String(), nil
+}
+
+// repeatBytes repite 'digest' hasta llenar 'length' bytes
+func repeatBytes(digest []byte, length int) []byte {
+	out := make([]byte, 0, length)
+	for len(out) < length {
+		out = append(out, digest[:min(len(digest), length-len(out))]...)
+	}
+	return out
+}
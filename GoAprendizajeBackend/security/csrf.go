@@ -0,0 +1,58 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"go-aprendizaje/config"
+	"log"
+	"strings"
+)
+
+// Tokens CSRF del modo cookie ("double submit cookie" firmado): el token va en una cookie legible por el
+// frontend y este lo repite en la cabecera X-CSRF-Token. Otra web puede hacer que el navegador envíe
+// las cookies, pero no puede leerlas para poner la cabecera.
+// Además va firmado y ligado a la sesión: un token fabricado o de otra sesión no vale
+// (evita que un subdominio comprometido plante su propia cookie).
+
+// csrfKey es la clave HMAC de los tokens CSRF (se inicializa con InitCSRF desde 'main.go')
+var csrfKey []byte
+
+// InitCSRF carga la clave de CSRF_SECRET_KEY. Si no está, se deriva de JWT_SECRET_KEY
+// (igual que la de las URLs firmadas, pero distinta de las dos).
+func InitCSRF() {
+	if key := config.GetEnv("CSRF_SECRET_KEY", ""); key != "" {
+		csrfKey = []byte(key)
+	} else {
+		mac := hmac.New(sha256.New, []byte(config.GetEnv("JWT_SECRET_KEY", "fallback_secret")))
+		mac.Write([]byte("csrf-tokens"))
+		csrfKey = mac.Sum(nil)
+	}
+	log.Println("Protección CSRF inicializada")
+}
+
+// NewCSRFToken genera un token CSRF para la sesión: "<aleatorio>.<firma>"
+func NewCSRFToken(sessionID string) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(nonce)
+	return encoded + "." + csrfSignature(sessionID, encoded), nil
+}
+
+// ValidCSRFToken comprueba que el token lo generamos nosotros para esa sesión
+func ValidCSRFToken(token string, sessionID string) bool {
+	nonce, signature, found := strings.Cut(token, ".")
+	if !found || nonce == "" || sessionID == "" {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(csrfSignature(sessionID, nonce)))
+}
+
+func csrfSignature(sessionID string, nonce string) string {
+	mac := hmac.New(sha256.New, csrfKey)
+	mac.Write([]byte(sessionID + "." + nonce))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
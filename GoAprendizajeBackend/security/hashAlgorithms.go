@@ -0,0 +1,205 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Tamaños de salt y de hash (en bytes) para argon2id y scrypt
+const (
+	saltLength = 16
+	keyLength  = 32
+)
+
+// randomSalt genera un salt aleatorio criptográficamente seguro
+func randomSalt() ([]byte, error) {
+	salt := make([]byte, saltLength)
+	_, err := rand.Read(salt)
+	return salt, err
+}
+
+// paramInt lee un parámetro numérico de un hash PHC
+func paramInt(hash *phcHash, key string) (int, error) {
+	value, err := strconv.Atoi(hash.Params[key])
+	if err != nil {
+		return 0, fmt.Errorf("parámetro %q inválido en el hash %s", key, hash.Algorithm)
+	}
+	return value, nil
+}
+
+// decodeSaltAndHash decodifica el salt y el hash (base64 sin padding) de un hash PHC
+func decodeSaltAndHash(hash *phcHash) ([]byte, []byte, error) {
+	salt, err := b64.DecodeString(hash.Salt)
+	if err != nil {
+		return nil, nil, errors.New("salt inválido en el hash " + hash.Algorithm)
+	}
+	key, err := b64.DecodeString(hash.Hash)
+	if err != nil {
+		return nil, nil, errors.New("hash inválido en el hash " + hash.Algorithm)
+	}
+	return salt, key, nil
+}
+
+// --- ARGON2ID (algoritmo por defecto) ---
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+
+// Argon2idHasher usa argon2id, el ganador de la Password Hashing Competition
+type Argon2idHasher struct {
+	Memory      uint32 // En KiB
+	Iterations  uint32
+	Parallelism uint8
+}
+
+func (h *Argon2idHasher) Name() string { return "argon2id" }
+
+func (h *Argon2idHasher) Hash(input []byte, keyID string) (string, error) {
+	salt, err := randomSalt()
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey(input, salt, h.Iterations, h.Memory, h.Parallelism, keyLength)
+
+	params := []phcParam{
+		{Key: "m", Value: strconv.Itoa(int(h.Memory))},
+		{Key: "t", Value: strconv.Itoa(int(h.Iterations))},
+		{Key: "p", Value: strconv.Itoa(int(h.Parallelism))},
+	}
+	return formatPHC(h.Name(), strconv.Itoa(argon2.Version), params, keyID, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+func (h *Argon2idHasher) Verify(input []byte, hash *phcHash) (bool, error) {
+	memory, err := paramInt(hash, "m")
+	if err != nil {
+		return false, err
+	}
+	iterations, err := paramInt(hash, "t")
+	if err != nil {
+		return false, err
+	}
+	parallelism, err := paramInt(hash, "p")
+	if err != nil {
+		return false, err
+	}
+	salt, expected, err := decodeSaltAndHash(hash)
+	if err != nil {
+		return false, err
+	}
+
+	key := argon2.IDKey(input, salt, uint32(iterations), uint32(memory), uint8(parallelism), uint32(len(expected)))
+
+	// Comparación en tiempo constante (no filtra en qué byte fallaron)
+	return subtle.ConstantTimeCompare(key, expected) == 1, nil
+}
+
+func (h *Argon2idHasher) Outdated(hash *phcHash) bool {
+	return hash.Version != strconv.Itoa(argon2.Version) ||
+		hash.Params["m"] != strconv.Itoa(int(h.Memory)) ||
+		hash.Params["t"] != strconv.Itoa(int(h.Iterations)) ||
+		hash.Params["p"] != strconv.Itoa(int(h.Parallelism))
+}
+
+// --- BCRYPT ---
+// $bcrypt$r=12$<salt y hash en el formato propio de bcrypt>
+
+// BcryptHasher envuelve bcrypt en formato PHC (el hash original va en la parte del salt)
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h *BcryptHasher) Name() string { return "bcrypt" }
+
+func (h *BcryptHasher) Hash(input []byte, keyID string) (string, error) {
+	raw, err := bcrypt.GenerateFromPassword(input, h.Cost)
+	if err != nil {
+		return "", err
+	}
+
+	// 'raw' es "$2a$12$<53 caracteres>": nos quedamos con los 53 caracteres finales
+	params := []phcParam{{Key: "r", Value: strconv.Itoa(h.Cost)}}
+	return formatPHC(h.Name(), "", params, keyID, string(raw[7:]), ""), nil
+}
+
+func (h *BcryptHasher) Verify(input []byte, hash *phcHash) (bool, error) {
+	cost, err := paramInt(hash, "r")
+	if err != nil {
+		return false, err
+	}
+
+	raw := fmt.Sprintf("$2a$%02d$%s", cost, hash.Salt)
+	return bcrypt.CompareHashAndPassword([]byte(raw), input) == nil, nil
+}
+
+func (h *BcryptHasher) Outdated(hash *phcHash) bool {
+	return hash.Params["r"] != strconv.Itoa(h.Cost)
+}
+
+// --- SCRYPT ---
+// $scrypt$ln=15,r=8,p=1$<salt>$<hash>
+
+// ScryptHasher usa scrypt (N = 2^LogN)
+type ScryptHasher struct {
+	LogN int
+	R    int
+	P    int
+}
+
+func (h *ScryptHasher) Name() string { return "scrypt" }
+
+func (h *ScryptHasher) Hash(input []byte, keyID string) (string, error) {
+	salt, err := randomSalt()
+	if err != nil {
+		return "", err
+	}
+
+	key, err := scrypt.Key(input, salt, 1<<h.LogN, h.R, h.P, keyLength)
+	if err != nil {
+		return "", err
+	}
+
+	params := []phcParam{
+		{Key: "ln", Value: strconv.Itoa(h.LogN)},
+		{Key: "r", Value: strconv.Itoa(h.R)},
+		{Key: "p", Value: strconv.Itoa(h.P)},
+	}
+	return formatPHC(h.Name(), "", params, keyID, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+func (h *ScryptHasher) Verify(input []byte, hash *phcHash) (bool, error) {
+	logN, err := paramInt(hash, "ln")
+	if err != nil {
+		return false, err
+	}
+	r, err := paramInt(hash, "r")
+	if err != nil {
+		return false, err
+	}
+	p, err := paramInt(hash, "p")
+	if err != nil {
+		return false, err
+	}
+	salt, expected, err := decodeSaltAndHash(hash)
+	if err != nil {
+		return false, err
+	}
+
+	key, err := scrypt.Key(input, salt, 1<<logN, r, p, len(expected))
+	if err != nil {
+		return false, err
+	}
+
+	return subtle.ConstantTimeCompare(key, expected) == 1, nil
+}
+
+func (h *ScryptHasher) Outdated(hash *phcHash) bool {
+	return hash.Params["ln"] != strconv.Itoa(h.LogN) ||
+		hash.Params["r"] != strconv.Itoa(h.R) ||
+		hash.Params["p"] != strconv.Itoa(h.P)
+}
@@ -0,0 +1,210 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"go-aprendizaje/config"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hasher es la interfaz que implementa cada algoritmo de hashing de contraseñas.
+// Todos generan hashes en formato PHC: $<algoritmo>$<parámetros>$<salt>$<hash>
+type Hasher interface {
+	// Name es el identificador del algoritmo en el formato PHC (ej: "argon2id")
+	Name() string
+	// Hash genera el hash de 'input' (la contraseña ya "pimentada"); 'keyID' se guarda en los parámetros
+	Hash(input []byte, keyID string) (string, error)
+	// Verify comprueba 'input' contra un hash ya parseado
+	Verify(input []byte, hash *phcHash) (bool, error)
+	// Outdated indica si el hash se generó con parámetros distintos a los configurados
+	Outdated(hash *phcHash) bool
+}
+
+// PasswordHasher elige el algoritmo actual, aplica el "pepper" y reconoce hashes de cualquier algoritmo soportado
+type PasswordHasher struct {
+	current  Hasher
+	hashers  map[string]Hasher
+	pepper   []byte // Secreto del servidor (NO se guarda en la BD)
+	pepperID string // Identificador del pepper, se guarda en el hash como 'keyid'
+
+	dummyHash     string
+	dummyCost     time.Duration // Lo que tarda verificar el hash falso (el mínimo de cada inicio de sesión)
+	dummyHashOnce sync.Once
+}
+
+// Passwords es el hasher global (se inicializa con InitPasswordHasher desde 'main.go')
+var Passwords *PasswordHasher
+
+// InitPasswordHasher configura los algoritmos desde el .env
+// PASSWORD_HASH_ALGORITHM puede ser "argon2id" (por defecto), "bcrypt" o "scrypt"
+func InitPasswordHasher() {
+	hashers := []Hasher{
+		&Argon2idHasher{
+			Memory:      uint32(config.GetEnvInt("ARGON2_MEMORY_KB", 64*1024)),
+			Iterations:  uint32(config.GetEnvInt("ARGON2_ITERATIONS", 3)),
+			Parallelism: uint8(config.GetEnvInt("ARGON2_PARALLELISM", 2)),
+		},
+		&BcryptHasher{
+			Cost: config.GetEnvInt("BCRYPT_COST", 12),
+		},
+		&ScryptHasher{
+			LogN: config.GetEnvInt("SCRYPT_LOG_N", 15),
+			R:    config.GetEnvInt("SCRYPT_R", 8),
+			P:    config.GetEnvInt("SCRYPT_P", 1),
+		},
+	}
+
+	Passwords = &PasswordHasher{hashers: make(map[string]Hasher)}
+	for _, hasher := range hashers {
+		Passwords.hashers[hasher.Name()] = hasher
+	}
+
+	algorithm := config.GetEnv("PASSWORD_HASH_ALGORITHM", "argon2id")
+	current, ok := Passwords.hashers[algorithm]
+	if !ok {
+		log.Fatal("Error fatal: Algoritmo de hashing desconocido: ", algorithm)
+	}
+	Passwords.current = current
+
+	if pepper := config.GetEnv("PASSWORD_PEPPER", ""); pepper != "" {
+		Passwords.pepper = []byte(pepper)
+		Passwords.pepperID = config.GetEnv("PASSWORD_PEPPER_ID", "1")
+	}
+
+	log.Println("Hasher de contraseñas inicializado con algoritmo: " + algorithm)
+}
+
+// Hash genera el hash de una contraseña con el algoritmo y los parámetros actuales
+func (p *PasswordHasher) Hash(password string) (string, error) {
+	input, err := p.applyPepper(password, p.pepperID)
+	if err != nil {
+		return "", err
+	}
+	return p.current.Hash(input, p.pepperID)
+}
+
+// Verify comprueba una contraseña contra un hash guardado.
+// 'needsRehash' es true si la contraseña es correcta pero el hash está desactualizado
+// (otro algoritmo, otros costos u otro pepper): en ese caso hay que guardar un hash nuevo.
+func (p *PasswordHasher) Verify(password string, encoded string) (ok bool, needsRehash bool, err error) {
+	// Hashes bcrypt "antiguos" (generados con bcrypt.DefaultCost antes de este sistema, sin pepper)
+	if isRawBcrypt(encoded) {
+		if bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)) != nil {
+			return false, false, nil
+		}
+		return true, true, nil
+	}
+
+	// Hashes importados de otros sistemas ("legacy$<tipo>$..."): siempre se re-hashean
+	if strings.HasPrefix(encoded, legacyPrefix) {
+		ok, err := verifyLegacy(password, encoded)
+		if err != nil || !ok {
+			return false, false, err
+		}
+		return true, true, nil
+	}
+
+	hash, err := parsePHC(encoded)
+	if err != nil {
+		return false, false, err
+	}
+
+	hasher, exists := p.hashers[hash.Algorithm]
+	if !exists {
+		return false, false, errors.New("algoritmo de hashing no soportado: " + hash.Algorithm)
+	}
+
+	keyID := hash.Params["keyid"]
+	input, err := p.applyPepper(password, keyID)
+	if err != nil {
+		return false, false, err
+	}
+
+	ok, err = hasher.Verify(input, hash)
+	if err != nil || !ok {
+		return false, false, err
+	}
+
+	needsRehash = hasher != p.current || hasher.Outdated(hash) || keyID != p.pepperID
+	return true, needsRehash, nil
+}
+
+// Matches es un atajo de Verify cuando solo importa si la contraseña coincide
+func (p *PasswordHasher) Matches(password string, encoded string) bool {
+	ok, _, _ := p.Verify(password, encoded)
+	return ok
+}
+
+// VerifyLogin es Verify para el inicio de sesión: tarda siempre al menos lo mismo que CompareDummy.
+// Las cuentas de antes de este sistema (bcrypt de costo 10) y las importadas tienen hashes más baratos
+// que los actuales: sin esto, un email registrado respondería antes que uno que no existe.
+func (p *PasswordHasher) VerifyLogin(password string, encoded string) (ok bool, needsRehash bool, err error) {
+	p.initDummy()
+	defer padUntil(time.Now().Add(p.dummyCost))
+	return p.Verify(password, encoded)
+}
+
+// CompareDummy hace una verificación "de mentira" cuando el usuario no existe.
+// Así el login tarda lo mismo para emails registrados y no registrados, y un atacante
+// no puede averiguar qué cuentas existen midiendo el tiempo de respuesta.
+func (p *PasswordHasher) CompareDummy(password string) {
+	p.initDummy()
+	defer padUntil(time.Now().Add(p.dummyCost))
+
+	// El resultado se ignora a propósito: solo nos interesa gastar el mismo tiempo
+	_, _, _ = p.Verify(password, p.dummyHash)
+}
+
+// initDummy genera el hash falso con los parámetros actuales y mide lo que tarda verificarlo
+// (la más lenta de varias veces, para no quedarse corto)
+func (p *PasswordHasher) initDummy() {
+	p.dummyHashOnce.Do(func() {
+		p.dummyHash, _ = p.Hash("dummy-password-que-nunca-coincide")
+		for i := 0; i < 3; i++ {
+			start := time.Now()
+			_, _, _ = p.Verify("otra-password", p.dummyHash)
+			p.dummyCost = max(p.dummyCost, time.Since(start))
+		}
+	})
+}
+
+// padUntil espera hasta 'deadline' si todavía no ha llegado
+func padUntil(deadline time.Time) {
+	time.Sleep(time.Until(deadline))
+}
+
+// MaxPasswordBytes es la longitud máxima de contraseña que el algoritmo actual tiene en cuenta entera.
+// Con pepper, bcrypt recibe el HMAC en base64 (44 bytes): tampoco trunca nada.
+func (p *PasswordHasher) MaxPasswordBytes() int {
+	if p.current.Name() == "bcrypt" && p.pepperID == "" {
+		return bcryptMaxBytes
+	}
+	return passwordMaxBytes
+}
+
+// applyPepper mezcla la contraseña con el pepper (HMAC-SHA256) si el hash lo usa.
+// El resultado va en base64, así además nunca supera los 72 bytes de bcrypt.
+func (p *PasswordHasher) applyPepper(password string, keyID string) ([]byte, error) {
+	if keyID == "" {
+		return []byte(password), nil
+	}
+	if keyID != p.pepperID {
+		return nil, errors.New("el hash usa un pepper que no está configurado: " + keyID)
+	}
+
+	mac := hmac.New(sha256.New, p.pepper)
+	mac.Write([]byte(password))
+	return []byte(base64.StdEncoding.EncodeToString(mac.Sum(nil))), nil
+}
+
+// isRawBcrypt detecta un hash bcrypt en su formato original ($2a$, $2b$, $2y$)
+func isRawBcrypt(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
@@ -0,0 +1,63 @@
+package security
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fastest devuelve lo que tarda 'run' en la más rápida de varias veces (la menos afectada por el ruido)
+func fastest(run func()) time.Duration {
+	best := time.Duration(1<<63 - 1)
+	for i := 0; i < 3; i++ {
+		start := time.Now()
+		run()
+		best = min(best, time.Since(start))
+	}
+	return best
+}
+
+// Un email que no existe tarda lo mismo que una contraseña incorrecta en una cuenta con un hash antiguo
+// más barato que los actuales (ej: bcrypt de antes de este sistema o importado con un costo bajo)
+func TestVerifyLoginTimingMatchesDummy(t *testing.T) {
+	t.Setenv("PASSWORD_HASH_ALGORITHM", "argon2id")
+	t.Setenv("ARGON2_MEMORY_KB", "32768")
+	t.Setenv("ARGON2_ITERATIONS", "4")
+	t.Setenv("ARGON2_PARALLELISM", "1")
+	t.Setenv("PASSWORD_PEPPER", "")
+	InitPasswordHasher()
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("Clave-Antigua-2019"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyCost := fastest(func() { bcrypt.CompareHashAndPassword(legacy, []byte("incorrecta")) })
+
+	unknown := fastest(func() { Passwords.CompareDummy("incorrecta") })
+	known := fastest(func() { Passwords.VerifyLogin("incorrecta", string(legacy)) })
+
+	// Sin igualar, la cuenta antigua respondería en lo que tarda bcrypt: mucho antes que el hash falso
+	if legacyCost > unknown/4 {
+		t.Fatalf("el hash antiguo (%v) debería ser mucho más barato que el falso (%v)", legacyCost, unknown)
+	}
+	if known < unknown*8/10 || unknown < known*8/10 {
+		t.Fatalf("los tiempos se distinguen: usuario inexistente %v, cuenta con hash antiguo %v (bcrypt solo: %v)", unknown, known, legacyCost)
+	}
+}
+
+// Igualar el tiempo no cambia el resultado de la verificación
+func TestVerifyLoginResult(t *testing.T) {
+	t.Setenv("PASSWORD_HASH_ALGORITHM", "bcrypt")
+	t.Setenv("BCRYPT_COST", "4")
+	t.Setenv("PASSWORD_PEPPER", "")
+	InitPasswordHasher()
+
+	legacy, _ := bcrypt.GenerateFromPassword([]byte("Clave-Antigua-2019"), 4)
+	if ok, needsRehash, err := Passwords.VerifyLogin("Clave-Antigua-2019", string(legacy)); !ok || !needsRehash || err != nil {
+		t.Fatalf("VerifyLogin = %v, %v, %v; se esperaba correcta y con rehash", ok, needsRehash, err)
+	}
+	if ok, _, _ := Passwords.VerifyLogin("incorrecta", string(legacy)); ok {
+		t.Fatal("VerifyLogin aceptó una contraseña incorrecta")
+	}
+}
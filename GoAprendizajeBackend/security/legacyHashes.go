@@ -0,0 +1,113 @@
+package security
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Tipos de hash "heredados" de otros sistemas (PHP, Django...) que aceptamos al importar usuarios.
+// Solo se usan para verificar: en el primer login correcto se re-hashean con el algoritmo actual.
+const (
+	LegacyDjangoPBKDF2 = "django_pbkdf2_sha256" // pbkdf2_sha256$<iteraciones>$<salt>$<hash base64>
+	LegacyMD5Crypt     = "md5_crypt"            // $1$<salt>$<hash>
+	LegacySHA512Crypt  = "sha512_crypt"         // $6$[rounds=N$]<salt>$<hash>
+	LegacyBcrypt       = "bcrypt"               // $2a$/$2b$/$2y$ con cualquier costo
+)
+
+// legacyPrefix marca los hashes importados: "legacy$<tipo>$<hash original>"
+// Así el tipo viaja junto al hash y no hace falta una columna extra en Postgres ni en Mongo.
+const legacyPrefix = "legacy$"
+
+// DetectHashType intenta adivinar el tipo de un hash importado a partir de su formato.
+// Devuelve "" si es un hash PHC que ya generamos nosotros (argon2id, bcrypt, scrypt).
+func DetectHashType(hash string) (string, error) {
+	switch {
+	case strings.HasPrefix(hash, "pbkdf2_sha256$"):
+		return LegacyDjangoPBKDF2, nil
+	case strings.HasPrefix(hash, "$1$"):
+		return LegacyMD5Crypt, nil
+	case strings.HasPrefix(hash, "$6$"):
+		return LegacySHA512Crypt, nil
+	case isRawBcrypt(hash):
+		return LegacyBcrypt, nil
+	}
+
+	if phc, err := parsePHC(hash); err == nil {
+		if _, ok := Passwords.hashers[phc.Algorithm]; ok {
+			return "", nil
+		}
+	}
+	return "", errors.New("formato de hash no reconocido")
+}
+
+// IsLegacyHashType indica si 'hashType' es uno de los tipos heredados soportados
+func IsLegacyHashType(hashType string) bool {
+	switch hashType {
+	case LegacyDjangoPBKDF2, LegacyMD5Crypt, LegacySHA512Crypt, LegacyBcrypt:
+		return true
+	}
+	return false
+}
+
+// MarkLegacyHash añade el marcador de tipo al hash original para guardarlo en la BD
+func MarkLegacyHash(hashType string, hash string) string {
+	return legacyPrefix + hashType + "$" + hash
+}
+
+// verifyLegacy comprueba una contraseña contra un hash marcado con "legacy$<tipo>$"
+func verifyLegacy(password string, stored string) (bool, error) {
+	hashType, hash, found := strings.Cut(strings.TrimPrefix(stored, legacyPrefix), "$")
+	if !found {
+		return false, errors.New("hash heredado sin tipo")
+	}
+
+	switch hashType {
+	case LegacyDjangoPBKDF2:
+		return verifyDjangoPBKDF2(password, hash)
+	case LegacyMD5Crypt:
+		computed := md5Crypt([]byte(password), strings.SplitN(strings.TrimPrefix(hash, "$1$"), "$", 2)[0])
+		return subtle.ConstantTimeCompare([]byte(computed), []byte(hash)) == 1, nil
+	case LegacySHA512Crypt:
+		computed, err := sha512Crypt([]byte(password), hash)
+		if err != nil {
+			return false, err
+		}
+		return subtle.ConstantTimeCompare([]byte(computed), []byte(hash)) == 1, nil
+	case LegacyBcrypt:
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil, nil
+	}
+
+	return false, errors.New("tipo de hash heredado no soportado: " + hashType)
+}
+
+// verifyDjangoPBKDF2 verifica el formato por defecto de Django: pbkdf2_sha256$<iteraciones>$<salt>$<hash>
+func verifyDjangoPBKDF2(password string, hash string) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 {
+		return false, errors.New("hash de Django inválido")
+	}
+
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false, errors.New("iteraciones inválidas en el hash de Django")
+	}
+
+	expected, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false, errors.New("hash de Django con base64 inválido")
+	}
+
+	key, err := pbkdf2.Key(sha256.New, password, []byte(parts[2]), iterations, len(expected))
+	if err != nil {
+		return false, err
+	}
+
+	return subtle.ConstantTimeCompare(key, expected) == 1, nil
+}
@@ -0,0 +1,77 @@
+package security
+
+import "testing"
+
+// Vectores generados con implementaciones de referencia:
+//   - MD5-crypt y SHA-512-crypt: "openssl passwd -1" / "openssl passwd -6" (los de SHA-512 son los de la
+//     especificación de Ulrich Drepper, con el salt recortado a 16 caracteres)
+//   - Django: hashlib.pbkdf2_hmac("sha256", ...) de Python, el mismo cálculo que hace Django
+//   - bcrypt: vector de prueba de OpenBSD (costo 5, distinto del actual)
+var legacyVectors = []struct {
+	name     string
+	hashType string
+	password string
+	hash     string
+}{
+	{"md5-crypt", LegacyMD5Crypt, "Contraseña-Vieja1", "$1$saltsalt$hROkKz/aMOEMMFewWffO71"},
+	{"sha512-crypt rondas por defecto", LegacySHA512Crypt, "Hello world!",
+		"$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1"},
+	{"sha512-crypt rounds=10000", LegacySHA512Crypt, "Hello world!",
+		"$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v."},
+	{"django pbkdf2_sha256", LegacyDjangoPBKDF2, "Clave-Django-2020",
+		"pbkdf2_sha256$260000$DjangoSalt123$x51qMlW9Gbun5CcipwDHVyM+DsAz0+er6vDVYo+97ns="},
+	{"bcrypt costo 5", LegacyBcrypt, "U*U", "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW"},
+}
+
+func TestVerifyLegacyVectors(t *testing.T) {
+	for _, vector := range legacyVectors {
+		t.Run(vector.name, func(t *testing.T) {
+			detected, err := DetectHashType(vector.hash)
+			if err != nil || detected != vector.hashType {
+				t.Fatalf("DetectHashType = %q, %v; se esperaba %q", detected, err, vector.hashType)
+			}
+
+			stored := MarkLegacyHash(vector.hashType, vector.hash)
+			if ok, err := verifyLegacy(vector.password, stored); !ok || err != nil {
+				t.Fatalf("la contraseña correcta no coincide (err = %v)", err)
+			}
+			if ok, err := verifyLegacy(vector.password+"x", stored); ok || err != nil {
+				t.Fatalf("una contraseña incorrecta coincide (err = %v)", err)
+			}
+		})
+	}
+}
+
+// El hash calculado es exactamente el de la referencia (no solo "coincide")
+func TestCryptHashesMatchReference(t *testing.T) {
+	if got := md5Crypt([]byte("Contraseña-Vieja1"), "saltsalt"); got != legacyVectors[0].hash {
+		t.Fatalf("md5Crypt = %q; se esperaba %q", got, legacyVectors[0].hash)
+	}
+	for _, vector := range legacyVectors[1:3] {
+		got, err := sha512Crypt([]byte(vector.password), vector.hash)
+		if err != nil || got != vector.hash {
+			t.Fatalf("sha512Crypt = %q, %v; se esperaba %q", got, err, vector.hash)
+		}
+	}
+}
+
+// Un usuario importado entra con su contraseña de siempre y el hash se marca para re-hashear
+func TestVerifyLegacyNeedsRehash(t *testing.T) {
+	t.Setenv("PASSWORD_HASH_ALGORITHM", "bcrypt")
+	t.Setenv("BCRYPT_COST", "4")
+	t.Setenv("PASSWORD_PEPPER", "")
+	InitPasswordHasher()
+
+	for _, vector := range legacyVectors {
+		t.Run(vector.name, func(t *testing.T) {
+			stored := MarkLegacyHash(vector.hashType, vector.hash)
+			ok, needsRehash, err := Passwords.Verify(vector.password, stored)
+			if !ok || !needsRehash || err != nil {
+				t.Fatalf("Verify = %v, %v, %v; se esperaba correcta y con rehash", ok, needsRehash, err)
+			}
+			if ok, needsRehash, _ := Passwords.Verify("incorrecta", stored); ok || needsRehash {
+				t.Fatalf("Verify con contraseña incorrecta = %v, %v", ok, needsRehash)
+			}
+		})
+	}
+}
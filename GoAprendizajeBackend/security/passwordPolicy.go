@@ -0,0 +1,161 @@
+package security
+
+import (
+	"go-aprendizaje/config"
+	"log"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// bcryptMaxBytes es el máximo que bcrypt tiene en cuenta: a partir del byte 72 ignora el resto
+const bcryptMaxBytes = 72
+
+// passwordMaxBytes es el máximo con argon2id y scrypt: no truncan nada, pero no hashearemos textos enormes
+const passwordMaxBytes = 256
+
+// PasswordPolicy define las reglas que debe cumplir una contraseña
+type PasswordPolicy struct {
+	MinLength     int  // Mínimo de caracteres
+	MaxBytes      int  // Máximo de bytes (depende del algoritmo: bcrypt solo usa los primeros 72)
+	RequireUpper  bool // Al menos una mayúscula
+	RequireLower  bool // Al menos una minúscula
+	RequireDigit  bool // Al menos un número
+	RequireSymbol bool // Al menos un símbolo
+	DisallowEmail bool // No puede contener el email (ni la parte antes de la @)
+	HistorySize   int  // Cuántas contraseñas anteriores no se pueden reutilizar
+	CheckBreached bool // Comprobar contra el fichero de contraseñas filtradas
+}
+
+// Policy es la política global (se inicializa con InitPasswordPolicy desde 'main.go')
+var Policy *PasswordPolicy
+
+// InitPasswordPolicy lee la política desde el .env (con valores por defecto razonables)
+// y abre el fichero de contraseñas filtradas si está configurado
+func InitPasswordPolicy() {
+	// El máximo lo marca el algoritmo activo (InitPasswordHasher va antes)
+	maxBytes := bcryptMaxBytes
+	if Passwords != nil {
+		maxBytes = Passwords.MaxPasswordBytes()
+	}
+
+	Policy = &PasswordPolicy{
+		MinLength:     config.GetEnvInt("PASSWORD_MIN_LENGTH", 8),
+		MaxBytes:      config.GetEnvInt("PASSWORD_MAX_BYTES", maxBytes),
+		RequireUpper:  config.GetEnvBool("PASSWORD_REQUIRE_UPPER", true),
+		RequireLower:  config.GetEnvBool("PASSWORD_REQUIRE_LOWER", true),
+		RequireDigit:  config.GetEnvBool("PASSWORD_REQUIRE_DIGIT", true),
+		RequireSymbol: config.GetEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+		DisallowEmail: config.GetEnvBool("PASSWORD_DISALLOW_EMAIL", true),
+		HistorySize:   config.GetEnvInt("PASSWORD_HISTORY_SIZE", 5),
+	}
+
+	// Nunca permitimos más de lo que usa el algoritmo: bcrypt truncaría la contraseña en silencio
+	if Policy.MaxBytes <= 0 || Policy.MaxBytes > maxBytes {
+		Policy.MaxBytes = maxBytes
+	}
+	// Un historial negativo no tiene sentido (y rompería IsReused): 0 = sin historial
+	if Policy.HistorySize < 0 {
+		Policy.HistorySize = 0
+	}
+
+	breachedPath := config.GetEnv("BREACHED_PASSWORDS_FILE", "")
+	if breachedPath != "" {
+		if err := OpenBreachedPasswords(breachedPath); err != nil {
+			log.Printf("No se pudo abrir el fichero de contraseñas filtradas (%s): %v", breachedPath, err)
+		} else {
+			Policy.CheckBreached = true
+		}
+	}
+
+	log.Println("Política de contraseñas inicializada.")
+}
+
+// Validate comprueba la contraseña contra todas las reglas y devuelve
+// un mensaje por cada regla incumplida (vacío si la contraseña es válida)
+func (p *PasswordPolicy) Validate(password string, email string) []string {
+	var violations []string
+
+	if len([]rune(password)) < p.MinLength {
+		violations = append(violations, "La contraseña debe tener al menos "+strconv.Itoa(p.MinLength)+" caracteres")
+	}
+	if len(password) > p.MaxBytes {
+		violations = append(violations, "La contraseña no puede superar los "+strconv.Itoa(p.MaxBytes)+" bytes")
+	}
+
+	// Recorremos la contraseña una vez para saber qué tipos de caracteres tiene
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	if p.RequireUpper && !hasUpper {
+		violations = append(violations, "La contraseña debe contener al menos una letra mayúscula")
+	}
+	if p.RequireLower && !hasLower {
+		violations = append(violations, "La contraseña debe contener al menos una letra minúscula")
+	}
+	if p.RequireDigit && !hasDigit {
+		violations = append(violations, "La contraseña debe contener al menos un número")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		violations = append(violations, "La contraseña debe contener al menos un símbolo")
+	}
+
+	if p.DisallowEmail && containsEmail(password, email) {
+		violations = append(violations, "La contraseña no puede contener tu email")
+	}
+
+	if p.CheckBreached {
+		breached, err := IsBreachedPassword(password)
+		if err != nil {
+			// Si el fichero falla no bloqueamos al usuario, solo lo registramos
+			log.Printf("Error al consultar contraseñas filtradas: %v", err)
+		} else if breached {
+			violations = append(violations, "Esta contraseña aparece en filtraciones de datos conocidas, elige otra")
+		}
+	}
+
+	return violations
+}
+
+// IsReused indica si la contraseña es la actual o alguna de las anteriores
+// (de 'previousHashes' solo se miran los últimos 'HistorySize')
+func (p *PasswordPolicy) IsReused(password string, currentHash string, previousHashes []string) bool {
+	if len(previousHashes) > p.HistorySize {
+		previousHashes = previousHashes[len(previousHashes)-p.HistorySize:]
+	}
+
+	for _, hash := range append([]string{currentHash}, previousHashes...) {
+		if Passwords.Matches(password, hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsEmail comprueba si la contraseña contiene el email o su parte local (lo que va antes de la @)
+func containsEmail(password string, email string) bool {
+	if email == "" {
+		return false
+	}
+
+	lowerPassword := strings.ToLower(password)
+	lowerEmail := strings.ToLower(email)
+	if strings.Contains(lowerPassword, lowerEmail) {
+		return true
+	}
+
+	// Partes locales muy cortas (ej: "a@x.com") darían demasiados falsos positivos
+	localPart, _, _ := strings.Cut(lowerEmail, "@")
+	return len(localPart) >= 3 && strings.Contains(lowerPassword, localPart)
+}
@@ -0,0 +1,69 @@
+package security
+
+import "testing"
+
+// El máximo de bytes de la política sale del algoritmo activo: solo bcrypt sin pepper trunca a 72
+func TestPolicyMaxBytesFollowsHasher(t *testing.T) {
+	cases := []struct {
+		algorithm string
+		pepper    string
+		expected  int
+	}{
+		{"argon2id", "", passwordMaxBytes},
+		{"scrypt", "", passwordMaxBytes},
+		{"bcrypt", "", bcryptMaxBytes},
+		{"bcrypt", "secreto", passwordMaxBytes},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.algorithm+"/"+tc.pepper, func(t *testing.T) {
+			t.Setenv("PASSWORD_HASH_ALGORITHM", tc.algorithm)
+			t.Setenv("PASSWORD_PEPPER", tc.pepper)
+			t.Setenv("PASSWORD_MAX_BYTES", "")
+			InitPasswordHasher()
+			InitPasswordPolicy()
+
+			if Policy.MaxBytes != tc.expected {
+				t.Fatalf("MaxBytes = %d, se esperaba %d", Policy.MaxBytes, tc.expected)
+			}
+		})
+	}
+}
+
+// Aunque se configure un máximo mayor, nunca se pasa del que usa el algoritmo
+func TestPolicyMaxBytesIsCapped(t *testing.T) {
+	t.Setenv("PASSWORD_HASH_ALGORITHM", "bcrypt")
+	t.Setenv("PASSWORD_PEPPER", "")
+	t.Setenv("PASSWORD_MAX_BYTES", "1000")
+	InitPasswordHasher()
+	InitPasswordPolicy()
+
+	if Policy.MaxBytes != bcryptMaxBytes {
+		t.Fatalf("MaxBytes = %d, se esperaba %d", Policy.MaxBytes, bcryptMaxBytes)
+	}
+	if violations := Policy.Validate("Aa1"+string(make([]byte, 80)), ""); len(violations) == 0 {
+		t.Fatal("una contraseña de más de 72 bytes debería incumplir la política con bcrypt")
+	}
+}
+
+// Un PASSWORD_HISTORY_SIZE negativo se trata como 0: solo se compara con la contraseña actual
+func TestPolicyNegativeHistorySize(t *testing.T) {
+	t.Setenv("PASSWORD_HASH_ALGORITHM", "bcrypt")
+	t.Setenv("BCRYPT_COST", "4")
+	t.Setenv("PASSWORD_PEPPER", "")
+	t.Setenv("PASSWORD_HISTORY_SIZE", "-3")
+	InitPasswordHasher()
+	InitPasswordPolicy()
+
+	if Policy.HistorySize != 0 {
+		t.Fatalf("HistorySize = %d, se esperaba 0", Policy.HistorySize)
+	}
+	current, _ := Passwords.Hash("Actual-2024")
+	previous, _ := Passwords.Hash("Anterior-2023")
+	if !Policy.IsReused("Actual-2024", current, []string{previous}) {
+		t.Fatal("la contraseña actual debería contar como reutilizada")
+	}
+	if Policy.IsReused("Anterior-2023", current, []string{previous}) {
+		t.Fatal("sin historial, una contraseña anterior no cuenta como reutilizada")
+	}
+}
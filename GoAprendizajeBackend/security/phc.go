@@ -0,0 +1,96 @@
+package security
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// phcHash es un hash en formato PHC ya separado en sus partes:
+// $<algoritmo>[$v=<versión>][$<param>=<valor>,...][$<salt>[$<hash>]]
+type phcHash struct {
+	Algorithm string
+	Version   string
+	Params    map[string]string
+	Salt      string // Tal cual aparece en el string (normalmente base64 sin padding)
+	Hash      string
+}
+
+// phcParam es un parámetro con orden fijo (el formato PHC exige un orden estable)
+type phcParam struct {
+	Key   string
+	Value string
+}
+
+// b64 es la codificación que usa PHC: base64 estándar sin '=' al final
+var b64 = base64.RawStdEncoding
+
+// parsePHC separa un hash PHC en sus partes
+func parsePHC(encoded string) (*phcHash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) < 2 || parts[0] != "" || parts[1] == "" {
+		return nil, errors.New("hash con formato PHC inválido")
+	}
+
+	hash := &phcHash{Algorithm: parts[1], Params: make(map[string]string)}
+	rest := parts[2:]
+
+	if len(rest) > 0 && strings.HasPrefix(rest[0], "v=") {
+		hash.Version = strings.TrimPrefix(rest[0], "v=")
+		rest = rest[1:]
+	}
+
+	if len(rest) > 0 && strings.Contains(rest[0], "=") {
+		for _, pair := range strings.Split(rest[0], ",") {
+			key, value, found := strings.Cut(pair, "=")
+			if !found {
+				return nil, errors.New("parámetro PHC inválido: " + pair)
+			}
+			hash.Params[key] = value
+		}
+		rest = rest[1:]
+	}
+
+	if len(rest) > 0 {
+		hash.Salt = rest[0]
+	}
+	if len(rest) > 1 {
+		hash.Hash = rest[1]
+	}
+	if len(rest) > 2 {
+		return nil, errors.New("hash con formato PHC inválido: demasiadas partes")
+	}
+
+	return hash, nil
+}
+
+// formatPHC construye el string PHC a partir de sus partes
+// (se omiten las partes vacías; 'keyid' solo se añade si hay pepper)
+func formatPHC(algorithm string, version string, params []phcParam, keyID string, salt string, hash string) string {
+	var b strings.Builder
+	b.WriteString("$" + algorithm)
+
+	if version != "" {
+		b.WriteString("$v=" + version)
+	}
+
+	if keyID != "" {
+		params = append(params, phcParam{Key: "keyid", Value: keyID})
+	}
+	if len(params) > 0 {
+		pairs := make([]string, 0, len(params))
+		for _, param := range params {
+			pairs = append(pairs, param.Key+"="+param.Value)
+		}
+		b.WriteString("$" + strings.Join(pairs, ","))
+	}
+
+	if salt != "" {
+		b.WriteString("$" + salt)
+	}
+	if hash != "" {
+		b.WriteString("$" + hash)
+	}
+
+	return b.String()
+}
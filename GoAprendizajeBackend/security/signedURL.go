@@ -0,0 +1,71 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"go-aprendizaje/config"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// urlSigningKey es la clave HMAC de las URLs firmadas (se inicializa con InitURLSigner desde 'main.go')
+var urlSigningKey []byte
+
+// InitURLSigner carga la clave de FILE_URL_SIGNING_KEY.
+// Si no está, se deriva de JWT_SECRET_KEY (así funciona sin configurar nada, y todas las réplicas usan la misma),
+// pero con una clave distinta a la de los tokens.
+func InitURLSigner() {
+	if key := config.GetEnv("FILE_URL_SIGNING_KEY", ""); key != "" {
+		urlSigningKey = []byte(key)
+	} else {
+		mac := hmac.New(sha256.New, []byte(config.GetEnv("JWT_SECRET_KEY", "fallback_secret")))
+		mac.Write([]byte("signed-file-urls"))
+		urlSigningKey = mac.Sum(nil)
+	}
+	log.Println("Firmador de URLs inicializado")
+}
+
+// SignURL devuelve 'path' con los parámetros 'params' más "expires" y "signature".
+// Cualquier cambio en la ruta o en los parámetros invalida la firma.
+func SignURL(path string, params url.Values, ttl time.Duration) string {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("expires", strconv.FormatInt(time.Now().Add(ttl).Unix(), 10))
+	params.Del("signature")
+	params.Set("signature", urlSignature(path, params))
+	return path + "?" + params.Encode()
+}
+
+// VerifySignedURL comprueba la firma y la caducidad de una URL generada con SignURL.
+// Devuelve también cuándo caduca (para las cabeceras de caché).
+func VerifySignedURL(path string, query url.Values) (time.Time, bool) {
+	expiresUnix, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	expires := time.Unix(expiresUnix, 0)
+	if time.Now().After(expires) {
+		return time.Time{}, false
+	}
+
+	params := url.Values{}
+	for key, values := range query {
+		params[key] = values
+	}
+	signature := params.Get("signature")
+	params.Del("signature")
+
+	expected := urlSignature(path, params)
+	return expires, hmac.Equal([]byte(signature), []byte(expected))
+}
+
+// urlSignature es el HMAC-SHA256 de la ruta y los parámetros (url.Values.Encode los ordena por clave)
+func urlSignature(path string, params url.Values) string {
+	mac := hmac.New(sha256.New, urlSigningKey)
+	mac.Write([]byte(path + "?" + params.Encode()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,61 @@
+package security
+
+import (
+	"go-aprendizaje/database"
+	"go-aprendizaje/models"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// UserRef traduce el "userID" de los claims del token a la BD del usuario y su ID como string.
+// Los tokens de Postgres llevan el ID como número (float64 en JWT) y los de Mongo como string hexadecimal.
+func UserRef(userID interface{}) (store string, id string, ok bool) {
+	switch value := userID.(type) {
+	case float64:
+		return "postgres", strconv.FormatUint(uint64(value), 10), true
+	case uint:
+		return "postgres", strconv.FormatUint(uint64(value), 10), true
+	case string:
+		return "mongo", value, true
+	}
+	return "", "", false
+}
+
+// RevokeUserTokens invalida todos los tokens emitidos hasta ahora para el usuario.
+// El "iat" de los tokens va en segundos: la fecha se guarda también en segundos para que un token
+// emitido justo después (en el mismo segundo) siga valiendo. Los emitidos antes en ese mismo segundo
+// dejan de valer igualmente porque su sesión se cierra aquí.
+func RevokeUserTokens(store string, userID string) error {
+	revocation := models.TokenRevocation{
+		UserStore:     store,
+		UserID:        userID,
+		RevokedBefore: time.Now().Truncate(time.Second),
+	}
+
+	// "Upsert": si ya había una revocación para el usuario, solo se mueve la fecha
+	err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_store"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_before"}),
+	}).Create(&revocation).Error
+	if err != nil {
+		return err
+	}
+
+	// Sus sesiones también aparecen como cerradas
+	return database.DB.Model(&models.Session{}).
+		Where("user_store = ? AND user_id = ? AND revoked_at IS NULL", store, userID).
+		Updates(map[string]interface{}{"revoked_at": revocation.RevokedBefore, "revoked_by": "system"}).Error
+}
+
+// IsTokenRevoked indica si un token emitido en 'issuedAt' fue revocado después
+// (se compara en segundos, la precisión del "iat")
+func IsTokenRevoked(store string, userID string, issuedAt time.Time) bool {
+	var revocation models.TokenRevocation
+	err := database.DB.Where("user_store = ? AND user_id = ?", store, userID).First(&revocation).Error
+	if err != nil {
+		return false
+	}
+	return issuedAt.Truncate(time.Second).Before(revocation.RevokedBefore.Truncate(time.Second))
+}
@@ -0,0 +1,48 @@
+package security
+
+import (
+	"go-aprendizaje/database"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// El "iat" va en segundos: un token emitido en el mismo segundo que la revocación (pero después) sigue valiendo
+func TestIsTokenRevokedComparesSeconds(t *testing.T) {
+	revokedAt := time.Date(2026, 3, 1, 12, 0, 0, 500_000_000, time.UTC) // 12:00:00.5 (filas guardadas sin truncar)
+
+	cases := []struct {
+		name     string
+		issuedAt time.Time
+		revoked  bool
+	}{
+		{"segundo anterior", time.Date(2026, 3, 1, 11, 59, 59, 0, time.UTC), true},
+		{"mismo segundo", time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), false},
+		{"segundo siguiente", time.Date(2026, 3, 1, 12, 0, 1, 0, time.UTC), false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sqlDB, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sqlDB.Close()
+			database.DB, err = gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			mock.ExpectQuery(`SELECT`).WillReturnRows(sqlmock.NewRows([]string{"user_store", "user_id", "revoked_before"}).
+				AddRow("postgres", "1", revokedAt))
+
+			if revoked := IsTokenRevoked("postgres", "1", tc.issuedAt); revoked != tc.revoked {
+				t.Fatalf("IsTokenRevoked = %v, se esperaba %v", revoked, tc.revoked)
+			}
+		})
+	}
+}
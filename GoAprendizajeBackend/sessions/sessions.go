@@ -0,0 +1,260 @@
+package sessions
+
+import (
+	"archive/zip"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"go-aprendizaje/config"
+	"go-aprendizaje/database"
+	"go-aprendizaje/dataexport"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Sesiones: cada token JWT que se emite se registra con el dispositivo (User-Agent) y la IP.
+// AuthMiddleware comprueba en cada petición que la sesión del token no se haya cerrado.
+
+// ErrNotFound se devuelve si la sesión no existe o no es del usuario
+var ErrNotFound = errors.New("sesión no encontrada")
+
+// ErrRevoked se devuelve si la sesión se cerró o caducó
+var ErrRevoked = errors.New("la sesión se ha cerrado")
+
+// ErrInvalidRefresh se devuelve si el refresh token no existe, no coincide o ya se usó
+var ErrInvalidRefresh = errors.New("refresh token inválido")
+
+// refreshReuseGrace es el tiempo durante el que el refresh token recién rotado todavía puede aparecer sin que
+// se considere robado (otra pestaña lo estaba canjeando a la vez y ya tiene las cookies nuevas)
+const refreshReuseGrace = 30 * time.Second
+
+// Create registra una sesión nueva para el token que se va a emitir
+func Create(store string, userID string, method string, userAgent string, ip string, expiresAt time.Time) (*models.Session, error) {
+	device := ParseUserAgent(userAgent)
+	now := time.Now()
+	session := &models.Session{
+		ID:         uuid.New().String(),
+		UserStore:  store,
+		UserID:     userID,
+		Method:     method,
+		UserAgent:  truncate(userAgent, 512),
+		Browser:    device.Browser,
+		OS:         device.OS,
+		DeviceType: device.Type,
+		FirstIP:    ip,
+		LastIP:     ip,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  expiresAt,
+	}
+	if err := database.DB.Create(session).Error; err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Check comprueba que la sesión siga abierta y apunta cuándo y desde qué IP se usó por última vez
+// (como mucho una escritura cada SESSION_TOUCH_INTERVAL, para no escribir en cada petición)
+func Check(id string, ip string) error {
+	var session models.Session
+	if err := database.DB.Where("id = ?", id).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRevoked
+		}
+		return err
+	}
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return ErrRevoked
+	}
+
+	if time.Since(session.LastSeenAt) > touchInterval() || session.LastIP != ip {
+		database.DB.Model(&session).Updates(map[string]interface{}{"last_seen_at": time.Now(), "last_ip": ip})
+	}
+	return nil
+}
+
+// AuthTime devuelve cuándo se inició la sesión (el inicio de sesión del usuario, aunque el token se haya renovado).
+// Si no se encuentra (ej: un token sin sesión), devuelve la hora actual.
+func AuthTime(id string) time.Time {
+	var session models.Session
+	if id == "" || database.DB.Select("created_at").Where("id = ?", id).First(&session).Error != nil {
+		return time.Now()
+	}
+	return session.CreatedAt
+}
+
+// NewRefreshToken genera el refresh token de la sesión ("<id de sesión>.<secreto>") y guarda su hash.
+// Sustituye al anterior: cada refresh token solo sirve una vez.
+func NewRefreshToken(session *models.Session) (string, error) {
+	secret, hash, err := newRefreshSecret()
+	if err != nil {
+		return "", err
+	}
+	if err := database.DB.Model(session).Update("refresh_hash", hash).Error; err != nil {
+		return "", err
+	}
+	session.RefreshHash = hash
+	return session.ID + "." + secret, nil
+}
+
+// Refresh canjea un refresh token: comprueba que la sesión siga abierta y lo rota (devuelve el nuevo).
+// Si dos peticiones usan el mismo token a la vez solo una lo consigue (la otra recibe ErrInvalidRefresh).
+// Un token ya rotado que vuelve a aparecer (salvo el anterior, justo después de rotarlo) es que alguien
+// lo copió: se cierra la sesión ("system") y se devuelve ErrRevoked.
+func Refresh(token string, ip string) (*models.Session, string, error) {
+	sessionID, secret, found := strings.Cut(token, ".")
+	if !found || sessionID == "" || secret == "" {
+		return nil, "", ErrInvalidRefresh
+	}
+
+	var session models.Session
+	if err := database.DB.Where("id = ?", sessionID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", ErrInvalidRefresh
+		}
+		return nil, "", err
+	}
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return nil, "", ErrRevoked
+	}
+	presented := hashRefreshSecret(secret)
+	if session.RefreshHash == "" {
+		return nil, "", ErrInvalidRefresh
+	}
+	if !hmac.Equal([]byte(presented), []byte(session.RefreshHash)) {
+		justRotated := session.RefreshRotatedAt != nil && time.Since(*session.RefreshRotatedAt) < refreshReuseGrace
+		if justRotated && hmac.Equal([]byte(presented), []byte(session.PreviousRefreshHash)) {
+			return nil, "", ErrInvalidRefresh
+		}
+		if err := Revoke(session.UserStore, session.UserID, session.ID, "system"); err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, "", err
+		}
+		logging.Log.Warnf("Refresh token reutilizado en la sesión %s: sesión cerrada", session.ID)
+		return nil, "", ErrRevoked
+	}
+
+	// Rotar: el UPDATE solo funciona si el hash sigue siendo el que se presentó
+	newSecret, newHash, err := newRefreshSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	result := database.DB.Model(&models.Session{}).
+		Where("id = ? AND refresh_hash = ?", session.ID, presented).
+		Updates(map[string]interface{}{
+			"refresh_hash":          newHash,
+			"previous_refresh_hash": presented,
+			"refresh_rotated_at":    time.Now(),
+			"last_seen_at":          time.Now(),
+			"last_ip":               ip,
+		})
+	if result.Error != nil {
+		return nil, "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, "", ErrInvalidRefresh
+	}
+	session.RefreshHash = newHash
+	return &session, session.ID + "." + newSecret, nil
+}
+
+// List devuelve las sesiones abiertas de un usuario (la usada más recientemente primero)
+func List(store string, userID string) ([]models.Session, error) {
+	var list []models.Session
+	err := database.DB.Where("user_store = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", store, userID, time.Now()).
+		Order("last_seen_at desc").Find(&list).Error
+	return list, err
+}
+
+// Revoke cierra una sesión del usuario. 'revokedBy' es "self" o "admin:<id>".
+func Revoke(store string, userID string, id string, revokedBy string) error {
+	result := database.DB.Model(&models.Session{}).
+		Where("id = ? AND user_store = ? AND user_id = ? AND revoked_at IS NULL", id, store, userID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_by": revokedBy})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RevokeOthers cierra todas las sesiones del usuario salvo 'keepID' ("cerrar sesión en los demás dispositivos")
+func RevokeOthers(store string, userID string, keepID string, revokedBy string) (int64, error) {
+	result := database.DB.Model(&models.Session{}).
+		Where("user_store = ? AND user_id = ? AND id <> ? AND revoked_at IS NULL", store, userID, keepID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_by": revokedBy})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteUserSessions borra las sesiones de un usuario (al borrar su cuenta: tienen su IP y su dispositivo)
+func DeleteUserSessions(store string, userID string) error {
+	return database.DB.Where("user_store = ? AND user_id = ?", store, userID).Delete(&models.Session{}).Error
+}
+
+// StartJanitor arranca en segundo plano el borrado de las sesiones caducadas o cerradas
+// hace más de SESSION_RETENTION (se guardan un tiempo para poder investigar accesos)
+func StartJanitor(every time.Duration) {
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for range ticker.C {
+			retention, err := time.ParseDuration(config.GetEnv("SESSION_RETENTION", "720h"))
+			if err != nil || retention <= 0 {
+				retention = 30 * 24 * time.Hour
+			}
+			cutoff := time.Now().Add(-retention)
+			result := database.DB.Where("expires_at < ? OR revoked_at < ?", cutoff, cutoff).Delete(&models.Session{})
+			if result.RowsAffected > 0 {
+				logging.Log.Infof("Sesiones antiguas borradas: %d", result.RowsAffected)
+			}
+		}
+	}()
+}
+
+// ExportSection añade las sesiones del usuario a su exportación de datos personales (RGPD)
+func ExportSection(zipWriter *zip.Writer, export *models.DataExport) error {
+	var list []models.Session
+	database.DB.Where("user_store = ? AND user_id = ?", export.UserStore, export.UserID).Order("created_at").Find(&list)
+	return dataexport.WriteJSON(zipWriter, "sessions.json", map[string]interface{}{"sessions": list})
+}
+
+// newRefreshSecret genera un secreto aleatorio y su hash (en la BD solo se guarda el hash)
+func newRefreshSecret() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(buf)
+	return secret, hashRefreshSecret(secret), nil
+}
+
+func hashRefreshSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// touchInterval es cada cuánto se actualiza "visto por última vez" de una sesión
+func touchInterval() time.Duration {
+	interval, err := time.ParseDuration(config.GetEnv("SESSION_TOUCH_INTERVAL", "1m"))
+	if err != nil || interval < 0 {
+		return time.Minute
+	}
+	return interval
+}
+
+// truncate corta 'value' a 'max' bytes sin dejar un carácter a medias (Postgres rechaza UTF-8 inválido)
+func truncate(value string, max int) string {
+	if len(value) > max {
+		value = value[:max]
+	}
+	return strings.ToValidUTF8(value, "")
+}
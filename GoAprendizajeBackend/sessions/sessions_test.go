@@ -0,0 +1,104 @@
+package sessions
+
+import (
+	"errors"
+	"go-aprendizaje/database"
+	"go-aprendizaje/logging"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/sirupsen/logrus"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupSessionsTest(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	logging.Log = logrus.New()
+	logging.Log.SetOutput(io.Discard)
+
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	database.DB, err = gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mock
+}
+
+// expectSession devuelve una sesión abierta cuyo refresh token actual es 'current' (y el anterior 'previous',
+// rotado en 'rotatedAt')
+func expectSession(mock sqlmock.Sqlmock, current string, previous string, rotatedAt time.Time) {
+	mock.ExpectQuery(`SELECT \* FROM "sessions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_store", "user_id", "expires_at", "refresh_hash", "previous_refresh_hash", "refresh_rotated_at"}).
+			AddRow("s1", "postgres", "1", time.Now().Add(time.Hour), hashRefreshSecret(current), hashRefreshSecret(previous), rotatedAt))
+}
+
+func TestRefreshReuse(t *testing.T) {
+	t.Run("el token actual se rota", func(t *testing.T) {
+		mock := setupSessionsTest(t)
+		expectSession(mock, "actual", "anterior", time.Now().Add(-time.Hour))
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "sessions" SET .*"refresh_hash"`).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		session, token, err := Refresh("s1.actual", "203.0.113.7")
+		if err != nil || session == nil || token == "" || token == "s1.actual" {
+			t.Fatalf("Refresh = %v, %q, %v; se esperaba un token nuevo", session, token, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("un token ya rotado cierra la sesión", func(t *testing.T) {
+		mock := setupSessionsTest(t)
+		expectSession(mock, "actual", "anterior", time.Now().Add(-time.Hour))
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "sessions" SET .*"revoked_at"`).
+			WithArgs(sqlmock.AnyArg(), "system", "s1", "postgres", "1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if _, _, err := Refresh("s1.anterior", "198.51.100.9"); !errors.Is(err, ErrRevoked) {
+			t.Fatalf("Refresh = %v; se esperaba ErrRevoked", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("un token más antiguo también la cierra", func(t *testing.T) {
+		mock := setupSessionsTest(t)
+		expectSession(mock, "actual", "anterior", time.Now())
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "sessions" SET .*"revoked_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if _, _, err := Refresh("s1.mucho-mas-antiguo", "198.51.100.9"); !errors.Is(err, ErrRevoked) {
+			t.Fatalf("Refresh = %v; se esperaba ErrRevoked", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("el anterior justo después de rotarlo es otra pestaña", func(t *testing.T) {
+		mock := setupSessionsTest(t)
+		expectSession(mock, "actual", "anterior", time.Now().Add(-time.Second))
+
+		// Sin ningún UPDATE: la sesión sigue abierta
+		if _, _, err := Refresh("s1.anterior", "203.0.113.7"); !errors.Is(err, ErrInvalidRefresh) {
+			t.Fatalf("Refresh = %v; se esperaba ErrInvalidRefresh", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
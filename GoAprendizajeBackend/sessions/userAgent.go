@@ -0,0 +1,87 @@
+package sessions
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Device es lo que sabemos del dispositivo a partir del User-Agent (aproximado: el User-Agent lo elige el cliente)
+type Device struct {
+	Browser string // Ej: "Chrome 129", "Firefox 131", "curl 8.5"
+	OS      string // Ej: "Windows", "macOS", "Android 14", "iOS 17.5"
+	Type    string // "desktop", "mobile", "tablet", "bot" u "other"
+}
+
+// browserPatterns se prueban en orden: varios navegadores dicen ser otros (Edge y Opera incluyen "Chrome",
+// Chrome incluye "Safari"...), así que los más específicos van primero
+var browserPatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"Edge", regexp.MustCompile(`Edg(?:e|A|iOS)?/(\d+)`)},
+	{"Opera", regexp.MustCompile(`(?:OPR|Opera)/(\d+)`)},
+	{"Samsung Internet", regexp.MustCompile(`SamsungBrowser/(\d+)`)},
+	{"Firefox", regexp.MustCompile(`(?:Firefox|FxiOS)/(\d+)`)},
+	{"Chrome", regexp.MustCompile(`(?:Chrome|CriOS)/(\d+)`)},
+	{"Safari", regexp.MustCompile(`Version/(\d+(?:\.\d+)?).*Safari/`)},
+	{"curl", regexp.MustCompile(`^curl/(\d+\.\d+)`)},
+	{"Postman", regexp.MustCompile(`^PostmanRuntime/(\d+\.\d+)`)},
+	{"okhttp", regexp.MustCompile(`^okhttp/(\d+\.\d+)`)},
+	{"Go", regexp.MustCompile(`^Go-http-client/(\d+\.\d+)`)},
+	{"Python", regexp.MustCompile(`^python-(?:requests|httpx)/(\d+\.\d+)`)},
+}
+
+var (
+	windowsPattern = regexp.MustCompile(`Windows NT`)
+	iosPattern     = regexp.MustCompile(`(?:iPhone|iPad|iPod).*? OS (\d+)(?:_(\d+))?`)
+	androidPattern = regexp.MustCompile(`Android (\d+(?:\.\d+)?)`)
+	macPattern     = regexp.MustCompile(`Mac OS X`)
+	botPattern     = regexp.MustCompile(`(?i)bot|crawler|spider|slurp|headless`)
+)
+
+// ParseUserAgent saca el navegador, el sistema operativo y el tipo de dispositivo de un User-Agent
+func ParseUserAgent(userAgent string) Device {
+	device := Device{Browser: "Desconocido", OS: "Desconocido", Type: "other"}
+	if userAgent == "" {
+		return device
+	}
+
+	for _, browser := range browserPatterns {
+		if match := browser.pattern.FindStringSubmatch(userAgent); match != nil {
+			device.Browser = browser.name + " " + match[1]
+			break
+		}
+	}
+
+	switch {
+	case iosPattern.MatchString(userAgent):
+		match := iosPattern.FindStringSubmatch(userAgent)
+		device.OS = "iOS " + match[1]
+		if match[2] != "" {
+			device.OS += "." + match[2]
+		}
+	case androidPattern.MatchString(userAgent):
+		device.OS = "Android " + androidPattern.FindStringSubmatch(userAgent)[1]
+	case windowsPattern.MatchString(userAgent):
+		device.OS = "Windows"
+	case strings.Contains(userAgent, "CrOS"):
+		device.OS = "ChromeOS"
+	case macPattern.MatchString(userAgent):
+		device.OS = "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		device.OS = "Linux"
+	}
+
+	switch {
+	case botPattern.MatchString(userAgent):
+		device.Type = "bot"
+	case strings.Contains(userAgent, "iPad") || strings.Contains(userAgent, "Tablet") ||
+		(strings.Contains(userAgent, "Android") && !strings.Contains(userAgent, "Mobile")):
+		device.Type = "tablet"
+	case strings.Contains(userAgent, "Mobile") || strings.Contains(userAgent, "iPhone"):
+		device.Type = "mobile"
+	case device.OS != "Desconocido":
+		device.Type = "desktop"
+	}
+	return device
+}
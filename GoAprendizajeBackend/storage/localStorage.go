@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tempPrefix es el prefijo de los archivos a medio escribir (se ignoran al recorrer)
+const tempPrefix = ".tmp-"
+
+// LocalStorage guarda los archivos en una carpeta del disco.
+// Solo sirve con una réplica (o con un volumen compartido entre réplicas).
+type LocalStorage struct {
+	dir string
+}
+
+// NewLocalStorage crea el backend sobre la carpeta 'dir'
+func NewLocalStorage(dir string) *LocalStorage {
+	return &LocalStorage{dir: dir}
+}
+
+func (s *LocalStorage) Name() string {
+	return "local"
+}
+
+// path traduce una clave a la ruta del archivo en el disco (y devuelve también la clave ya limpia)
+func (s *LocalStorage) path(key string) (string, string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), key, nil
+}
+
+// Put escribe en un archivo temporal y lo renombra al final,
+// así nadie puede leer un archivo a medio escribir
+func (s *LocalStorage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	destination, _, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(destination), 0755); err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(destination), tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name()) // No hace nada si el rename funcionó
+
+	if _, err := io.Copy(temp, body); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(temp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), destination)
+}
+
+func (s *LocalStorage) Open(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	filePath, key, err := s.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil || stat.IsDir() {
+		file.Close()
+		return nil, nil, ErrNotFound
+	}
+
+	// Devolvemos el *os.File: implementa io.Seeker y se puede servir con rangos
+	return file, s.info(key, stat), nil
+}
+
+func (s *LocalStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	filePath, key, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := os.Stat(filePath)
+	if os.IsNotExist(err) || (err == nil && stat.IsDir()) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.info(key, stat), nil
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	filePath, _, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStorage) Walk(ctx context.Context, prefix string, fn func(info ObjectInfo) error) error {
+	err := filepath.WalkDir(s.dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), tempPrefix) {
+			return nil
+		}
+
+		relative, err := filepath.Rel(s.dir, filePath)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relative)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		stat, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		return fn(*s.info(key, stat))
+	})
+
+	// Si la carpeta aún no existe, simplemente no hay archivos
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// info construye los metadatos (en disco no guardamos el Content-Type, se deduce de la extensión)
+func (s *LocalStorage) info(key string, stat fs.FileInfo) *ObjectInfo {
+	contentType := mime.TypeByExtension(filepath.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return &ObjectInfo{
+		Key:         key,
+		Size:        stat.Size(),
+		ContentType: contentType,
+		ModTime:     stat.ModTime(),
+	}
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+// MigrateReport es el resumen de una migración entre backends
+type MigrateReport struct {
+	Copied  int      `json:"copied"`
+	Skipped int      `json:"skipped"` // Ya existían en el destino con el mismo tamaño
+	Failed  []string `json:"failed"`  // Claves que no se pudieron copiar
+}
+
+// Migrate copia todos los archivos de 'from' a 'to' (por ejemplo, del disco local a S3).
+// Es idempotente: se puede relanzar y solo copia lo que falte.
+// Con 'dryRun' solo cuenta lo que haría; con 'deleteSource' borra cada archivo del origen tras copiarlo.
+func Migrate(ctx context.Context, from Storage, to Storage, dryRun bool, deleteSource bool) (*MigrateReport, error) {
+	report := &MigrateReport{Failed: []string{}}
+
+	err := from.Walk(ctx, "", func(info ObjectInfo) error {
+		// 1. Saltar los que ya están copiados
+		existing, err := to.Stat(ctx, info.Key)
+		switch {
+		case err == nil && existing.Size == info.Size:
+			report.Skipped++
+		case err != nil && !errors.Is(err, ErrNotFound):
+			report.Failed = append(report.Failed, info.Key)
+			return nil
+		case dryRun:
+			report.Copied++
+			return nil
+		default:
+			// 2. Copiar (en streaming, sin cargar el archivo en memoria)
+			if err := copyObject(ctx, from, to, info.Key); err != nil {
+				report.Failed = append(report.Failed, info.Key)
+				return nil
+			}
+			report.Copied++
+		}
+
+		// 3. Borrar del origen solo si se copió bien
+		if deleteSource && !dryRun {
+			if err := from.Delete(ctx, info.Key); err != nil {
+				report.Failed = append(report.Failed, info.Key)
+			}
+		}
+		return nil
+	})
+
+	return report, err
+}
+
+// copyObject copia un archivo de un backend a otro
+func copyObject(ctx context.Context, from Storage, to Storage, key string) error {
+	source, info, err := from.Open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	return to.Put(ctx, key, source, info.Size, info.ContentType)
+}
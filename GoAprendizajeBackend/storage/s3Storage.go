@@ -0,0 +1,460 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Config es la configuración de un backend compatible con S3 (AWS, MinIO, etc.)
+type S3Config struct {
+	Endpoint  string // Ej: "https://s3.amazonaws.com" o "http://localhost:9000" (MinIO)
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool // true = "<endpoint>/<bucket>/<clave>"; false = "<bucket>.<host>/<clave>"
+}
+
+// S3Storage habla directamente con la API REST de S3 (firmando las peticiones con AWS Signature V4).
+// Todas las réplicas ven los mismos archivos, a diferencia del disco local.
+type S3Storage struct {
+	config   S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// unsignedPayload indica que el cuerpo no se incluye en la firma (así se puede enviar en streaming)
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// emptyPayloadHash es el SHA-256 de un cuerpo vacío
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// NewS3Storage valida la configuración y crea el backend
+func NewS3Storage(config S3Config) (*S3Storage, error) {
+	if config.Bucket == "" || config.AccessKey == "" || config.SecretKey == "" {
+		return nil, errors.New("faltan S3_BUCKET, S3_ACCESS_KEY o S3_SECRET_KEY")
+	}
+
+	endpoint, err := url.Parse(strings.TrimSuffix(config.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, errors.New("S3_ENDPOINT inválido: " + config.Endpoint)
+	}
+
+	return &S3Storage{
+		config:   config,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (s *S3Storage) Name() string {
+	return "s3"
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	// S3 necesita saber el tamaño de antemano: si no lo sabemos, lo leemos entero en memoria
+	if size < 0 {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+		size = int64(len(data))
+	}
+
+	req, err := s.newRequest(ctx, http.MethodPut, key, nil, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(req, unsignedPayload)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Storage) Open(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := s.newRequest(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info := objectInfo(key, resp)
+	return &s3Object{storage: s, ctx: ctx, key: key, size: info.Size, body: resp.Body}, info, nil
+}
+
+// s3Object es un archivo de S3 abierto. Implementa io.Seeker para poder servir rangos (Range: bytes=...):
+// al leer desde otra posición se hace un GET nuevo con la cabecera Range.
+type s3Object struct {
+	storage    *S3Storage
+	ctx        context.Context
+	key        string
+	size       int64
+	offset     int64         // Posición de lectura pedida
+	body       io.ReadCloser // Respuesta abierta (nil si hay que pedir otra)
+	bodyOffset int64         // Posición en la que está 'body'
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+
+	// Si se movió la posición, la respuesta abierta ya no sirve: pedimos desde el nuevo punto
+	if o.body != nil && o.bodyOffset != o.offset {
+		o.body.Close()
+		o.body = nil
+	}
+	if o.body == nil {
+		req, err := o.storage.newRequest(o.ctx, http.MethodGet, o.key, nil, nil)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(o.offset, 10)+"-")
+		resp, err := o.storage.do(req, emptyPayloadHash)
+		if err != nil {
+			return 0, err
+		}
+		o.body = resp.Body
+		o.bodyOffset = o.offset
+	}
+
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	o.bodyOffset += int64(n)
+	return n, err
+}
+
+// Seek solo cambia la posición: la petición a S3 se hace en el siguiente Read
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += o.offset
+	case io.SeekEnd:
+		offset += o.size
+	default:
+		return 0, errors.New("whence inválido")
+	}
+	if offset < 0 {
+		return 0, errors.New("posición negativa")
+	}
+	o.offset = offset
+	return offset, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+	return o.body.Close()
+}
+
+func (s *S3Storage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := s.newRequest(ctx, http.MethodHead, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return objectInfo(key, resp), nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, emptyPayloadHash)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// listBucketResult es la respuesta XML de ListObjectsV2
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// Walk pagina ListObjectsV2 (1000 claves por página)
+func (s *S3Storage) Walk(ctx context.Context, prefix string, fn func(info ObjectInfo) error) error {
+	continuationToken := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		if prefix != "" {
+			query.Set("prefix", prefix)
+		}
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+
+		req, err := s.newRequest(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := s.do(req, emptyPayloadHash)
+		if err != nil {
+			return err
+		}
+
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, object := range result.Contents {
+			err := fn(ObjectInfo{Key: object.Key, Size: object.Size, ModTime: object.LastModified})
+			if err != nil {
+				return err
+			}
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		continuationToken = result.NextContinuationToken
+	}
+}
+
+// newRequest construye la URL del objeto (o del bucket si 'key' está vacía)
+func (s *S3Storage) newRequest(ctx context.Context, method string, key string, query url.Values, body io.Reader) (*http.Request, error) {
+	target := *s.endpoint
+	objectPath := "/" + key
+	if s.config.PathStyle {
+		objectPath = "/" + s.config.Bucket + objectPath
+	} else {
+		target.Host = s.config.Bucket + "." + target.Host
+	}
+
+	// Fijamos Path y RawPath: la ruta que firmamos tiene que ser exactamente la que se envía
+	target.Path = objectPath
+	target.RawPath = encodePath(objectPath)
+	target.RawQuery = encodeQuery(query)
+
+	return http.NewRequestWithContext(ctx, method, target.String(), body)
+}
+
+// do firma la petición, la envía y traduce los errores de S3
+func (s *S3Storage) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	s.sign(req, payloadHash, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, fmt.Errorf("S3 respondió %d a %s %s: %s", resp.StatusCode, req.Method, req.URL.Path, strings.TrimSpace(string(message)))
+}
+
+// sign añade la cabecera Authorization (AWS Signature Version 4)
+func (s *S3Storage) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// Cabeceras firmadas (en minúsculas)
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		headers["content-type"] = contentType
+	}
+
+	credentials := sigV4Credentials{AccessKey: s.config.AccessKey, SecretKey: s.config.SecretKey, Region: s.config.Region, Service: "s3"}
+	req.Header.Set("Authorization", credentials.authorization(req, headers, payloadHash, now))
+}
+
+// sigV4Credentials son las claves y el ámbito ("scope") con los que se firma una petición
+type sigV4Credentials struct {
+	AccessKey string
+	SecretKey string
+	Region    string
+	Service   string // "s3" en nuestro caso
+}
+
+// authorization calcula la cabecera Authorization de AWS Signature Version 4.
+// 'headers' son las cabeceras que se firman (nombre en minúsculas → valor).
+func (k sigV4Credentials) authorization(req *http.Request, headers map[string]string, payloadHash string, now time.Time) string {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := now.UTC().Format("20060102")
+
+	// 1. Cabeceras firmadas (ordenadas)
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	// 2. Petición canónica (la query ordenada por clave, como pide SigV4)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		encodeQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	// 3. Texto a firmar
+	scope := date + "/" + k.Region + "/" + k.Service + "/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	// 4. Clave de firma derivada y firma
+	signingKey := hmacSHA256([]byte("AWS4"+k.SecretKey), date)
+	signingKey = hmacSHA256(signingKey, k.Region)
+	signingKey = hmacSHA256(signingKey, k.Service)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	return "AWS4-HMAC-SHA256 Credential=" + k.AccessKey + "/" + scope + ", SignedHeaders=" + signedHeaders + ", Signature=" + signature
+}
+
+// objectInfo lee los metadatos de las cabeceras de la respuesta
+func objectInfo(key string, resp *http.Response) *ObjectInfo {
+	size, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		size = resp.ContentLength
+	}
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+
+	return &ObjectInfo{
+		Key:         key,
+		Size:        size,
+		ContentType: resp.Header.Get("Content-Type"),
+		ModTime:     modTime,
+	}
+}
+
+// encodePath codifica la ruta como pide S3: todo salvo los caracteres "no reservados" y '/'
+func encodePath(path string) string {
+	return uriEncode(path, false)
+}
+
+// encodeQuery codifica la query ordenada por clave (formato canónico de SigV4)
+func encodeQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range query[key] {
+			parts = append(parts, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode es la codificación de SigV4 (RFC 3986, con hexadecimal en mayúsculas)
+func uriEncode(value string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+)
+
+// Prueba de integración contra un MinIO (o cualquier S3) real. Solo se ejecuta con S3_INTEGRATION_ENDPOINT:
+//
+//	docker compose --profile minio up -d minio
+//	S3_INTEGRATION_ENDPOINT=http://localhost:9000 go test ./storage/ -run MinIO
+//
+// Usa su propio bucket (S3_INTEGRATION_BUCKET, por defecto "go-aprendizaje-test") y lo crea si no existe.
+func TestS3StorageMinIO(t *testing.T) {
+	endpoint := os.Getenv("S3_INTEGRATION_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_INTEGRATION_ENDPOINT no está definido: se omite la prueba contra MinIO")
+	}
+	s3, err := NewS3Storage(S3Config{
+		Endpoint:  endpoint,
+		Region:    envOr("S3_INTEGRATION_REGION", "us-east-1"),
+		Bucket:    envOr("S3_INTEGRATION_BUCKET", "go-aprendizaje-test"),
+		AccessKey: envOr("S3_INTEGRATION_ACCESS_KEY", "minioadmin"),
+		SecretKey: envOr("S3_INTEGRATION_SECRET_KEY", "minioadmin"),
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// 1. Crear el bucket (si ya existe S3 responde 409: no es un error para la prueba)
+	req, err := s3.newRequest(ctx, http.MethodPut, "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := s3.do(req, emptyPayloadHash); err == nil {
+		resp.Body.Close()
+	}
+
+	// 2. Subir, con caracteres que hay que codificar en la ruta y la firma
+	key := "integration/ñandú con espacios+símbolos (1).txt"
+	content := []byte("0123456789abcdefghij")
+	if err := s3.Put(ctx, key, bytes.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	t.Cleanup(func() { s3.Delete(context.Background(), key) })
+
+	// 3. Metadatos
+	info, err := s3.Stat(ctx, key)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Size != int64(len(content)) || info.ContentType != "text/plain" {
+		t.Fatalf("metadatos inesperados: %+v", info)
+	}
+
+	// 4. Leer entero y a partir de una posición (peticiones con Range)
+	reader, _, err := s3.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer reader.Close()
+	seeker, ok := reader.(io.ReadSeeker)
+	if !ok {
+		t.Fatal("Open debería devolver un io.ReadSeeker")
+	}
+	if _, err := seeker.Seek(10, io.SeekStart); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	rest, err := io.ReadAll(seeker)
+	if err != nil || !bytes.Equal(rest, content[10:]) {
+		t.Fatalf("lectura desde la posición 10: %q, %v", rest, err)
+	}
+
+	// 5. Listar con prefijo (usa la query firmada de ListObjectsV2)
+	found := false
+	err = s3.Walk(ctx, "integration/", func(object ObjectInfo) error {
+		found = found || object.Key == key
+		return nil
+	})
+	if err != nil || !found {
+		t.Fatalf("Walk no encontró %q (error: %v)", key, err)
+	}
+
+	// 6. Borrar: después no existe, y borrar otra vez no es un error
+	if err := s3.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s3.Stat(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Stat después de borrar: se esperaba ErrNotFound, obtenido %v", err)
+	}
+	if err := s3.Delete(ctx, key); err != nil {
+		t.Fatalf("borrar un archivo que ya no existe: %v", err)
+	}
+}
+
+func envOr(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
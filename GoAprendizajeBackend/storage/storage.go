@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"go-aprendizaje/config"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotFound se devuelve cuando el objeto (archivo) no existe en el backend
+var ErrNotFound = errors.New("archivo no encontrado")
+
+// ErrInvalidKey se devuelve si la clave intenta salirse del almacenamiento (ej: "../secreto")
+var ErrInvalidKey = errors.New("clave de archivo inválida")
+
+// ObjectInfo son los metadatos de un archivo guardado
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Storage es la interfaz común a todos los backends de archivos (disco local, S3, MinIO...).
+// Los archivos se identifican por una "clave" relativa (ej: "profile-pictures/<uuid>.png"),
+// que es lo que se guarda en la BD. La URL pública se genera a partir de la clave con URL().
+type Storage interface {
+	// Name es el identificador del backend ("local", "s3")
+	Name() string
+	// Put guarda el contenido de 'body' en 'key' ('size' puede ser -1 si no se conoce)
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Open abre un archivo para leerlo (el llamador debe cerrarlo)
+	Open(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+	// Stat devuelve los metadatos de un archivo (ErrNotFound si no existe)
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Delete borra un archivo (no es error si no existía)
+	Delete(ctx context.Context, key string) error
+	// Walk recorre todos los archivos que empiezan por 'prefix'
+	Walk(ctx context.Context, prefix string, fn func(info ObjectInfo) error) error
+}
+
+// ProfilePicturesPrefix es la "carpeta" donde se guardan las fotos de perfil
+const ProfilePicturesPrefix = "profile-pictures/"
+
+// legacyStaticPrefix es como se guardaban antes las rutas en la BD ("/static/<archivo>")
+const legacyStaticPrefix = "/static/"
+
+// New crea un backend por nombre ("local" o "s3") con la configuración del .env
+func New(backend string) (Storage, error) {
+	switch backend {
+	case "local":
+		// FILE_PATH es donde siempre se guardaron las subidas; UPLOAD_PATH se acepta por compatibilidad
+		dir := config.GetEnv("FILE_PATH", config.GetEnv("UPLOAD_PATH", "./uploads"))
+		return NewLocalStorage(dir), nil
+	case "s3":
+		return NewS3Storage(S3Config{
+			Endpoint:  config.GetEnv("S3_ENDPOINT", "https://s3.amazonaws.com"),
+			Region:    config.GetEnv("S3_REGION", "us-east-1"),
+			Bucket:    config.GetEnv("S3_BUCKET", ""),
+			AccessKey: config.GetEnv("S3_ACCESS_KEY", ""),
+			SecretKey: config.GetEnv("S3_SECRET_KEY", ""),
+			PathStyle: config.GetEnvBool("S3_PATH_STYLE", true), // MinIO necesita "path style"
+		})
+	}
+	return nil, errors.New("backend de almacenamiento desconocido (usa 'local' o 's3'): " + backend)
+}
+
+// CleanKey valida y normaliza una clave. Acepta también las rutas antiguas "/static/<archivo>".
+func CleanKey(key string) (string, error) {
+	key = strings.TrimPrefix(key, legacyStaticPrefix)
+	key = strings.TrimPrefix(key, "/")
+	if key == "" {
+		return "", ErrInvalidKey
+	}
+
+	cleaned := path.Clean(key)
+	if cleaned != key || cleaned == "." || strings.HasPrefix(cleaned, "../") || cleaned == ".." || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+	return cleaned, nil
+}
+
+// URL genera la URL pública de un archivo a partir de lo guardado en la BD.
+//   - Con STORAGE_PUBLIC_URL (ej: un CDN o el bucket público) → "<STORAGE_PUBLIC_URL>/<clave>"
+//   - Sin ella → "/static/<clave>", servido por la propia API desde cualquier backend
+//
+// Como en la BD solo está la clave, cambiar de backend o de CDN no obliga a tocar los datos.
+func URL(stored string) string {
+	if stored == "" {
+		return ""
+	}
+	// Valores que ya son URLs absolutas se devuelven tal cual
+	if strings.HasPrefix(stored, "http://") || strings.HasPrefix(stored, "https://") {
+		return stored
+	}
+
+	key, err := CleanKey(stored)
+	if err != nil {
+		return ""
+	}
+
+	if base := strings.TrimSuffix(config.GetEnv("STORAGE_PUBLIC_URL", ""), "/"); base != "" {
+		return base + "/" + key
+	}
+	return legacyStaticPrefix + key
+}
@@ -0,0 +1,135 @@
+package suppression
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// ErrNotDSN se devuelve si el mensaje no es un informe de entrega (RFC 3464)
+var ErrNotDSN = errors.New("el mensaje no es un informe de entrega (DSN)")
+
+// Bounce es un destinatario de un informe de entrega (DSN)
+type Bounce struct {
+	Recipient  string // Ej: "user@example.com"
+	Action     string // "failed", "delayed", "delivered", "relayed" o "expanded"
+	Status     string // Código de estado, ej: "5.1.1"
+	Diagnostic string // Ej: "smtp; 550 5.1.1 User unknown"
+}
+
+// Permanent indica si es un rebote permanente (la entrega falló con un código 5.x.x)
+func (b Bounce) Permanent() bool {
+	return b.Action == "failed" && strings.HasPrefix(b.Status, "5")
+}
+
+// Event convierte el destinatario en un evento para Apply (rebote "hard" o "soft")
+func (b Bounce) Event() Event {
+	bounceType := "soft"
+	if b.Permanent() {
+		bounceType = "hard"
+	}
+	detail := strings.TrimSpace(b.Status + " " + b.Diagnostic)
+	return Event{Type: EventBounce, Email: b.Recipient, BounceType: bounceType, Detail: detail}
+}
+
+// ParseDSN lee un email de rebote en formato RFC 3464: un "multipart/report; report-type=delivery-status"
+// con una parte "message/delivery-status" que tiene un bloque de campos del mensaje
+// y un bloque por destinatario (Final-Recipient, Action, Status, Diagnostic-Code...).
+func ParseDSN(r io.Reader) ([]Bounce, error) {
+	message, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. Debe ser un informe de entrega
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" || !strings.EqualFold(params["report-type"], "delivery-status") {
+		return nil, ErrNotDSN
+	}
+
+	// 2. Buscar la parte con el estado de la entrega (la legible y el mensaje original no nos interesan)
+	parts := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			return nil, ErrNotDSN
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if partType != "message/delivery-status" && partType != "message/global-delivery-status" {
+			continue
+		}
+
+		var body io.Reader = part // multipart ya decodifica quoted-printable
+		if strings.EqualFold(part.Header.Get("Content-Transfer-Encoding"), "base64") {
+			body = base64.NewDecoder(base64.StdEncoding, part)
+		}
+		return parseDeliveryStatus(body)
+	}
+}
+
+// parseDeliveryStatus lee los bloques de campos (separados por líneas en blanco).
+// El primero es del mensaje (Reporting-MTA...) y los siguientes, uno por destinatario.
+func parseDeliveryStatus(r io.Reader) ([]Bounce, error) {
+	reader := textproto.NewReader(bufio.NewReader(r))
+
+	var bounces []Bounce
+	first := true
+	for {
+		fields, err := reader.ReadMIMEHeader()
+		if len(fields) > 0 {
+			if first {
+				first = false
+			} else if bounce, ok := recipientBounce(fields); ok {
+				bounces = append(bounces, bounce)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(bounces) == 0 {
+		return nil, ErrNotDSN
+	}
+	return bounces, nil
+}
+
+// recipientBounce convierte un bloque de campos de un destinatario
+func recipientBounce(fields textproto.MIMEHeader) (Bounce, bool) {
+	recipient := addressField(fields.Get("Final-Recipient"))
+	if recipient == "" {
+		recipient = addressField(fields.Get("Original-Recipient"))
+	}
+	if recipient == "" {
+		return Bounce{}, false
+	}
+
+	return Bounce{
+		Recipient:  recipient,
+		Action:     strings.ToLower(strings.TrimSpace(fields.Get("Action"))),
+		Status:     strings.TrimSpace(fields.Get("Status")),
+		Diagnostic: strings.TrimSpace(fields.Get("Diagnostic-Code")),
+	}, true
+}
+
+// addressField saca la dirección de un campo "tipo; dirección" (ej: "rfc822; <user@example.com>")
+func addressField(value string) string {
+	addressType, address, found := strings.Cut(value, ";")
+	if !found {
+		address = addressType
+	}
+	return strings.Trim(strings.TrimSpace(address), "<>")
+}
@@ -0,0 +1,104 @@
+package suppression
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// parseFixture lee un rebote de testdata/ (copias de los que envían Postfix y Exchange Online)
+func parseFixture(t *testing.T, name string) ([]Bounce, error) {
+	t.Helper()
+	file, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	return ParseDSN(file)
+}
+
+// Un 5.x.x (el buzón no existe) es un rebote permanente: se suprime la dirección
+func TestParseDSNHardBounce(t *testing.T) {
+	bounces, err := parseFixture(t, "postfix-hard.eml")
+	if err != nil {
+		t.Fatalf("ParseDSN: %v", err)
+	}
+	if len(bounces) != 1 {
+		t.Fatalf("se esperaba 1 destinatario, hay %d", len(bounces))
+	}
+
+	bounce := bounces[0]
+	if bounce.Recipient != "no-existe@example.com" || bounce.Action != "failed" || bounce.Status != "5.1.1" {
+		t.Fatalf("Bounce = %+v", bounce)
+	}
+	if !bounce.Permanent() {
+		t.Fatal("un 5.1.1 debería ser permanente")
+	}
+	event := bounce.Event()
+	if event.Type != EventBounce || event.Email != "no-existe@example.com" || event.BounceType != "hard" {
+		t.Fatalf("Event = %+v", event)
+	}
+	want := "5.1.1 smtp; 550 5.1.1 <no-existe@example.com>: Recipient address rejected: User unknown in virtual mailbox table"
+	if event.Detail != want {
+		t.Fatalf("Detail = %q; se esperaba %q", event.Detail, want)
+	}
+}
+
+// Un 4.x.x (buzón lleno, se sigue reintentando) no debe suprimir la dirección
+func TestParseDSNSoftBounce(t *testing.T) {
+	bounces, err := parseFixture(t, "postfix-delayed.eml")
+	if err != nil {
+		t.Fatalf("ParseDSN: %v", err)
+	}
+	if len(bounces) != 1 {
+		t.Fatalf("se esperaba 1 destinatario, hay %d", len(bounces))
+	}
+
+	bounce := bounces[0]
+	if bounce.Recipient != "lleno@example.org" || bounce.Action != "delayed" || bounce.Status != "4.2.2" {
+		t.Fatalf("Bounce = %+v", bounce)
+	}
+	if bounce.Permanent() || bounce.Event().BounceType != "soft" {
+		t.Fatalf("un aviso de retraso no es un rebote permanente: %+v", bounce.Event())
+	}
+}
+
+// Un informe con varios destinatarios (y la parte en base64, como la manda Exchange):
+// cada uno se clasifica por separado
+func TestParseDSNMultipleRecipients(t *testing.T) {
+	bounces, err := parseFixture(t, "exchange-multiple.eml")
+	if err != nil {
+		t.Fatalf("ParseDSN: %v", err)
+	}
+
+	want := []struct {
+		recipient string
+		status    string
+		permanent bool
+	}{
+		{"baja@contoso.example", "5.1.10", true},
+		{"temporal@contoso.example", "4.4.7", false}, // "failed" pero con 4.x.x: no es permanente
+		{"alias@contoso.example", "5.2.1", true},     // solo con Original-Recipient
+	}
+	if len(bounces) != len(want) {
+		t.Fatalf("se esperaban %d destinatarios, hay %d: %+v", len(want), len(bounces), bounces)
+	}
+	for i, expected := range want {
+		bounce := bounces[i]
+		if bounce.Recipient != expected.recipient || bounce.Status != expected.status || bounce.Permanent() != expected.permanent {
+			t.Errorf("destinatario %d = %+v (permanente: %v); se esperaba %+v", i, bounce, bounce.Permanent(), expected)
+		}
+	}
+}
+
+// Las respuestas automáticas y los acuses de lectura llegan al mismo buzón pero no son rebotes
+func TestParseDSNNotDSN(t *testing.T) {
+	for _, name := range []string{"autoreply.eml", "read-receipt.eml"} {
+		t.Run(name, func(t *testing.T) {
+			if bounces, err := parseFixture(t, name); !errors.Is(err, ErrNotDSN) {
+				t.Fatalf("ParseDSN = %+v, %v; se esperaba ErrNotDSN", bounces, err)
+			}
+		})
+	}
+}
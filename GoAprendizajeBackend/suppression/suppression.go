@@ -0,0 +1,150 @@
+package suppression
+
+import (
+	"errors"
+	"go-aprendizaje/core"
+	"go-aprendizaje/database"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/models"
+	"net/mail"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Lista de supresión: direcciones a las que la bandeja de salida ya no envía emails.
+// Se alimenta con los rebotes permanentes y las quejas de spam que notifica el proveedor de correo
+// (webhook JSON o los emails de rebote RFC 3464 que llegan al buzón) y con las que añaden los admins.
+
+// Tipos de evento que llegan del proveedor
+const (
+	EventBounce    = "bounce"
+	EventComplaint = "complaint"
+)
+
+// ErrInvalidEvent se devuelve si un evento no trae un email válido o su tipo no es conocido
+var ErrInvalidEvent = errors.New("evento de email inválido")
+
+// Event es una notificación de rebote o queja sobre una dirección
+type Event struct {
+	Type       string `json:"type"`        // "bounce" o "complaint"
+	Email      string `json:"email"`       // Destinatario afectado
+	BounceType string `json:"bounce_type"` // "hard" (permanente, por defecto) o "soft" (temporal: buzón lleno, servidor caído...)
+	Detail     string `json:"detail"`      // Opcional: motivo o código de diagnóstico
+}
+
+// Apply procesa un evento. Los rebotes temporales no suprimen la dirección (solo se loguean).
+// Devuelve true si la dirección ha quedado suprimida.
+func Apply(event Event, source string) (bool, error) {
+	email := Normalize(event.Email)
+	if email == "" {
+		return false, ErrInvalidEvent
+	}
+
+	switch strings.ToLower(event.Type) {
+	case EventBounce:
+		if isSoft(event.BounceType) {
+			logging.Log.Infof("Rebote temporal de %s (%s): %s", email, source, event.Detail)
+			return false, nil
+		}
+		return true, Add(email, models.SuppressionBounce, event.Detail, source)
+	case EventComplaint:
+		return true, Add(email, models.SuppressionComplaint, event.Detail, source)
+	default:
+		return false, ErrInvalidEvent
+	}
+}
+
+// Add suprime una dirección (o actualiza el motivo si ya lo estaba) y marca a sus usuarios
+func Add(email string, reason string, detail string, source string) error {
+	email = Normalize(email)
+	if email == "" {
+		return ErrInvalidEvent
+	}
+
+	entry := models.EmailSuppression{Email: email, Reason: reason, Detail: detail, Source: source, Events: 1}
+	err := database.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "email"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"reason":     reason,
+			"detail":     detail,
+			"source":     source,
+			"events":     gorm.Expr("email_suppressions.events + 1"),
+			"updated_at": time.Now(),
+		}),
+	}).Create(&entry).Error
+	if err != nil {
+		return err
+	}
+
+	logging.Log.Warnf("Email %s añadido a la lista de supresión (%s, %s): %s", email, reason, source, detail)
+	now := time.Now()
+	flagUsers(email, &now, reason)
+	return nil
+}
+
+// Remove quita una dirección de la lista (ej: el usuario arregló su buzón) y desmarca a sus usuarios
+func Remove(id uint) (*models.EmailSuppression, error) {
+	var entry models.EmailSuppression
+	if err := database.DB.First(&entry, id).Error; err != nil {
+		return nil, err
+	}
+	if err := database.DB.Delete(&entry).Error; err != nil {
+		return nil, err
+	}
+
+	logging.Log.Infof("Email %s quitado de la lista de supresión", entry.Email)
+	flagUsers(entry.Email, nil, "")
+	return &entry, nil
+}
+
+// Check indica si una dirección está suprimida y por qué
+func Check(email string) (string, bool) {
+	if strings.HasSuffix(Normalize(email), ".invalid") {
+		return models.SuppressionReservedDomain, true
+	}
+	var entry models.EmailSuppression
+	if err := database.DB.Select("reason").Where("email = ?", Normalize(email)).First(&entry).Error; err != nil {
+		return "", false
+	}
+	return entry.Reason, true
+}
+
+// Normalize deja una dirección en la forma con la que se guarda en la lista ("Ana <Ana@X.com>" -> "ana@x.com").
+// Devuelve "" si no es una dirección válida.
+func Normalize(email string) string {
+	address, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(address.Address)
+}
+
+// isSoft indica si un tipo de rebote es temporal (cada proveedor los llama de una forma)
+func isSoft(bounceType string) bool {
+	switch strings.ToLower(bounceType) {
+	case "soft", "transient", "temporary", "delayed":
+		return true
+	}
+	return false
+}
+
+// flagUsers marca (o desmarca) a los usuarios con ese email en las dos BD.
+// Si falla solo se loguea: lo que decide si se envía es la lista, la marca es informativa.
+func flagUsers(email string, suppressedAt *time.Time, reason string) {
+	err := database.DB.Model(&models.User{}).Where("lower(email) = ?", email).Updates(map[string]interface{}{
+		"email_suppressed_at":      suppressedAt,
+		"email_suppression_reason": reason,
+	}).Error
+	if err != nil {
+		logging.Log.Errorf("No se pudo marcar la supresión de %s en Postgres: %v", email, err)
+	}
+
+	if core.MongoUserRepo != nil {
+		if err := core.MongoUserRepo.SetEmailSuppression(email, suppressedAt, reason); err != nil {
+			logging.Log.Errorf("No se pudo marcar la supresión de %s en Mongo: %v", email, err)
+		}
+	}
+}
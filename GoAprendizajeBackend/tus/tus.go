@@ -0,0 +1,338 @@
+package tus
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"go-aprendizaje/config"
+	"go-aprendizaje/database"
+	"go-aprendizaje/logging"
+	"go-aprendizaje/models"
+	"go-aprendizaje/storage"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Version es la versión del protocolo tus que implementamos (https://tus.io/protocols/resumable-upload)
+const Version = "1.0.0"
+
+// Extensions son las extensiones del protocolo que soportamos
+const Extensions = "creation,termination,expiration"
+
+// chunksPrefix es la "carpeta" del almacenamiento donde se guardan los trozos: tus/<id>/<offset>
+const chunksPrefix = "tus/"
+
+// Errores del protocolo (el controlador los traduce a códigos HTTP)
+var (
+	ErrNotFound       = errors.New("subida no encontrada")
+	ErrExpired        = errors.New("la subida ha caducado")
+	ErrOffsetMismatch = errors.New("el Upload-Offset no coincide con lo recibido")
+	ErrTooLarge       = errors.New("se han enviado más bytes que el Upload-Length")
+	ErrIncomplete     = errors.New("faltan trozos de la subida")
+	ErrInvalidMeta    = errors.New("Upload-Metadata inválido")
+)
+
+// Expiration es cuánto tiempo se guarda una subida sin recibir trozos nuevos
+func Expiration() time.Duration {
+	expiration, err := time.ParseDuration(config.GetEnv("TUS_UPLOAD_EXPIRATION", "24h"))
+	if err != nil || expiration <= 0 {
+		return 24 * time.Hour
+	}
+	return expiration
+}
+
+// Create registra una subida nueva de 'length' bytes
+func Create(store string, userID string, purpose string, length int64, metadata string) (*models.TusUpload, error) {
+	values, err := ParseMetadata(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	upload := &models.TusUpload{
+		ID:        uuid.New().String(),
+		UserStore: store,
+		UserID:    userID,
+		Purpose:   purpose,
+		FileName:  values["filename"],
+		Length:    length,
+		Metadata:  metadata,
+		ExpiresAt: time.Now().Add(Expiration()),
+	}
+	if err := database.DB.Create(upload).Error; err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+// Get busca una subida. Si caducó devuelve la subida y ErrExpired (el janitor aún no la ha borrado).
+func Get(id string) (*models.TusUpload, error) {
+	var upload models.TusUpload
+	if err := database.DB.Where("id = ?", id).First(&upload).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if time.Now().After(upload.ExpiresAt) {
+		return &upload, ErrExpired
+	}
+	return &upload, nil
+}
+
+// Pending cuenta las subidas sin terminar (y sin caducar) de un usuario
+func Pending(store string, userID string) int64 {
+	var count int64
+	database.DB.Model(&models.TusUpload{}).
+		Where("user_store = ? AND user_id = ? AND expires_at > ?", store, userID, time.Now()).
+		Count(&count)
+	return count
+}
+
+// WriteChunk guarda un trozo que empieza en 'offset' y devuelve el nuevo offset.
+// El trozo pasa directamente del cuerpo de la petición al almacenamiento ('size' es su Content-Length, o -1).
+// Si la conexión se corta a mitad, se guarda lo que haya llegado: así el cliente reanuda desde ahí
+// (es la gracia del protocolo) en lugar de repetir el trozo entero.
+func WriteChunk(ctx context.Context, store storage.Storage, upload *models.TusUpload, offset int64, body io.Reader, size int64) (int64, error) {
+	if offset != upload.Offset {
+		return upload.Offset, ErrOffsetMismatch
+	}
+
+	// 1. Como máximo se guarda lo que falta (si el cliente ya anuncia más, ni lo leemos)
+	remaining := upload.Length - upload.Offset
+	if size > remaining {
+		return upload.Offset, ErrTooLarge
+	}
+
+	// 2. Guardar el trozo con su offset en el nombre (con ceros delante para que se ordenen bien)
+	key := chunkKey(upload.ID, offset)
+	chunk := &chunkBody{reader: io.LimitReader(body, remaining)}
+	if err := store.Put(ctx, key, chunk, size, "application/octet-stream"); err != nil {
+		store.Delete(ctx, key)
+		if chunk.err != nil {
+			return upload.Offset, chunk.err
+		}
+		return upload.Offset, err
+	}
+
+	// Si después de lo que faltaba aún quedan bytes, el cliente envía de más
+	if chunk.err == nil {
+		var extra [1]byte
+		if n, _ := io.ReadFull(body, extra[:]); n > 0 {
+			store.Delete(ctx, key)
+			return upload.Offset, ErrTooLarge
+		}
+	}
+	if chunk.read == 0 {
+		store.Delete(ctx, key)
+		return upload.Offset, chunk.err
+	}
+
+	// 3. Avanzar el offset solo si nadie lo ha movido mientras tanto (dos PATCH a la vez)
+	newOffset := offset + chunk.read
+	expiresAt := time.Now().Add(Expiration())
+	result := database.DB.Model(&models.TusUpload{}).
+		Where("id = ? AND upload_offset = ?", upload.ID, offset).
+		Updates(map[string]interface{}{"upload_offset": newOffset, "expires_at": expiresAt})
+	if result.Error != nil || result.RowsAffected == 0 {
+		store.Delete(ctx, key)
+		if result.Error != nil {
+			return upload.Offset, result.Error
+		}
+		return upload.Offset, ErrOffsetMismatch
+	}
+
+	upload.Offset = newOffset
+	upload.ExpiresAt = expiresAt
+	return newOffset, nil
+}
+
+// chunkBody cuenta los bytes que se leen del cuerpo de la petición.
+// Si la conexión se corta, lo trata como el final del trozo (para guardar lo recibido) y guarda el error.
+type chunkBody struct {
+	reader io.Reader
+	read   int64
+	err    error
+}
+
+func (b *chunkBody) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	b.read += int64(n)
+	if err != nil && err != io.EOF {
+		b.err = err
+		return n, io.EOF
+	}
+	return n, err
+}
+
+// Open devuelve el archivo completo (los trozos uno detrás de otro)
+func Open(ctx context.Context, store storage.Storage, upload *models.TusUpload) (io.ReadCloser, error) {
+	keys, err := chunkKeys(ctx, store, upload.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Comprobar que los trozos son consecutivos y llegan justo hasta el final
+	var expected int64
+	for _, key := range keys {
+		info, err := store.Stat(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if chunkOffset(key) != expected {
+			return nil, ErrIncomplete
+		}
+		expected += info.Size
+	}
+	if expected != upload.Length {
+		return nil, ErrIncomplete
+	}
+
+	return &chunkReader{ctx: ctx, store: store, keys: keys}, nil
+}
+
+// Terminate borra una subida (sus trozos y su registro)
+func Terminate(ctx context.Context, store storage.Storage, upload *models.TusUpload) error {
+	keys, err := chunkKeys(ctx, store, upload.ID)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := store.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return database.DB.Where("id = ?", upload.ID).Delete(&models.TusUpload{}).Error
+}
+
+// StartJanitor arranca en segundo plano el borrado periódico de las subidas caducadas
+func StartJanitor(store storage.Storage, every time.Duration) {
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for range ticker.C {
+			if removed := ExpireDue(context.Background(), store); removed > 0 {
+				logging.Log.Infof("Subidas reanudables caducadas borradas: %d", removed)
+			}
+		}
+	}()
+}
+
+// ExpireDue borra las subidas que llevan más de Expiration() sin recibir trozos
+func ExpireDue(ctx context.Context, store storage.Storage) int {
+	var expired []models.TusUpload
+	database.DB.Where("expires_at < ?", time.Now()).Find(&expired)
+
+	removed := 0
+	for i := range expired {
+		if err := Terminate(ctx, store, &expired[i]); err != nil {
+			logging.Log.Errorf("No se pudo borrar la subida caducada %s: %v", expired[i].ID, err)
+			continue
+		}
+		removed++
+	}
+	return removed
+}
+
+// ParseMetadata decodifica la cabecera "Upload-Metadata": pares "clave valor-en-base64" separados por comas
+// (el valor es opcional, ej: "filename d29ybGQuanBn,is_confidential")
+func ParseMetadata(header string) (map[string]string, error) {
+	values := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return values, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.Fields(pair)
+		if len(parts) == 0 || len(parts) > 2 {
+			return nil, ErrInvalidMeta
+		}
+		if _, repeated := values[parts[0]]; repeated {
+			return nil, ErrInvalidMeta
+		}
+
+		value := ""
+		if len(parts) == 2 {
+			decoded, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				return nil, ErrInvalidMeta
+			}
+			value = string(decoded)
+		}
+		values[parts[0]] = value
+	}
+	return values, nil
+}
+
+// chunkKey es la clave de un trozo: tus/<id>/<offset con 20 dígitos>
+func chunkKey(id string, offset int64) string {
+	return fmt.Sprintf("%s%s/%020d", chunksPrefix, id, offset)
+}
+
+// chunkOffset saca el offset del nombre de un trozo (-1 si no es un trozo válido)
+func chunkOffset(key string) int64 {
+	offset, err := strconv.ParseInt(key[strings.LastIndex(key, "/")+1:], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return offset
+}
+
+// chunkKeys devuelve las claves de los trozos de una subida, ordenadas por offset
+func chunkKeys(ctx context.Context, store storage.Storage, id string) ([]string, error) {
+	var keys []string
+	err := store.Walk(ctx, chunksPrefix+id+"/", func(info storage.ObjectInfo) error {
+		keys = append(keys, info.Key)
+		return nil
+	})
+	sort.Strings(keys)
+	return keys, err
+}
+
+// chunkReader lee los trozos en orden, abriendo cada uno solo cuando hace falta
+type chunkReader struct {
+	ctx     context.Context
+	store   storage.Storage
+	keys    []string
+	current io.ReadCloser
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.keys) == 0 {
+				return 0, io.EOF
+			}
+			file, _, err := r.store.Open(r.ctx, r.keys[0])
+			if err != nil {
+				return 0, err
+			}
+			r.current = file
+			r.keys = r.keys[1:]
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *chunkReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package tus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go-aprendizaje/database"
+	"go-aprendizaje/models"
+	"go-aprendizaje/storage"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupTusTest(t *testing.T) (sqlmock.Sqlmock, storage.Storage) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	database.DB, err = gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("gorm: %v", err)
+	}
+	return mock, storage.NewLocalStorage(t.TempDir())
+}
+
+func expectOffsetUpdate(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "tus_uploads" SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func readChunk(t *testing.T, store storage.Storage, id string, offset int64) string {
+	t.Helper()
+	file, _, err := store.Open(context.Background(), chunkKey(id, offset))
+	if err != nil {
+		t.Fatalf("no se guardó el trozo %d: %v", offset, err)
+	}
+	defer file.Close()
+	data, _ := io.ReadAll(file)
+	return string(data)
+}
+
+// droppedConnection entrega unos bytes y luego falla, como un cliente que corta a mitad de trozo
+type droppedConnection struct {
+	data io.Reader
+}
+
+func (d *droppedConnection) Read(p []byte) (int, error) {
+	n, err := d.data.Read(p)
+	if err == io.EOF {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func TestWriteChunk(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("guarda el trozo y avanza el offset", func(t *testing.T) {
+		mock, store := setupTusTest(t)
+		upload := &models.TusUpload{ID: "a", Length: 10, Offset: 4}
+		expectOffsetUpdate(mock)
+
+		offset, err := WriteChunk(ctx, store, upload, 4, strings.NewReader("123456"), 6)
+		if err != nil || offset != 10 || upload.Offset != 10 {
+			t.Fatalf("WriteChunk = %d, %v (upload.Offset = %d); se esperaba 10", offset, err, upload.Offset)
+		}
+		if got := readChunk(t, store, "a", 4); got != "123456" {
+			t.Fatalf("trozo guardado = %q", got)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("rechaza un Content-Length mayor que lo que falta", func(t *testing.T) {
+		_, store := setupTusTest(t)
+		upload := &models.TusUpload{ID: "b", Length: 10, Offset: 4}
+
+		offset, err := WriteChunk(ctx, store, upload, 4, strings.NewReader("1234567"), 7)
+		if !errors.Is(err, ErrTooLarge) || offset != 4 {
+			t.Fatalf("WriteChunk = %d, %v; se esperaba ErrTooLarge", offset, err)
+		}
+	})
+
+	t.Run("rechaza un cuerpo sin Content-Length que se pasa", func(t *testing.T) {
+		_, store := setupTusTest(t)
+		upload := &models.TusUpload{ID: "c", Length: 10, Offset: 4}
+
+		offset, err := WriteChunk(ctx, store, upload, 4, strings.NewReader("1234567"), -1)
+		if !errors.Is(err, ErrTooLarge) || offset != 4 {
+			t.Fatalf("WriteChunk = %d, %v; se esperaba ErrTooLarge", offset, err)
+		}
+		if _, _, err := store.Open(ctx, chunkKey("c", 4)); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("el trozo rechazado no se borró (err = %v)", err)
+		}
+	})
+
+	t.Run("si se corta la conexión guarda lo recibido", func(t *testing.T) {
+		mock, store := setupTusTest(t)
+		upload := &models.TusUpload{ID: "d", Length: 100, Offset: 0}
+		expectOffsetUpdate(mock)
+
+		body := &droppedConnection{data: bytes.NewReader([]byte("hola"))}
+		offset, err := WriteChunk(ctx, store, upload, 0, body, -1)
+		if err != nil || offset != 4 {
+			t.Fatalf("WriteChunk = %d, %v; se esperaba 4", offset, err)
+		}
+		if got := readChunk(t, store, "d", 0); got != "hola" {
+			t.Fatalf("trozo guardado = %q", got)
+		}
+	})
+
+	t.Run("si no llega nada no cambia nada", func(t *testing.T) {
+		_, store := setupTusTest(t)
+		upload := &models.TusUpload{ID: "e", Length: 10, Offset: 0}
+
+		offset, err := WriteChunk(ctx, store, upload, 0, &droppedConnection{data: strings.NewReader("")}, -1)
+		if !errors.Is(err, io.ErrUnexpectedEOF) || offset != 0 {
+			t.Fatalf("WriteChunk = %d, %v; se esperaba el error de la conexión", offset, err)
+		}
+		if _, _, err := store.Open(ctx, chunkKey("e", 0)); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("se guardó un trozo vacío (err = %v)", err)
+		}
+	})
+}
@@ -1,47 +1,124 @@
 package utils
 
 import (
-	"go-aprendizaje/config"
-	"log"
-	"strconv"
+	"go-aprendizaje/emails"
+	"go-aprendizaje/logging"
+	"time"
+)
+
+// Email es un correo ya preparado (asunto, cuerpo HTML y cuerpo en texto plano).
+// No se envía directamente: se guarda en la bandeja de salida (paquete outbox), que lo envía y reintenta.
+type Email struct {
+	To        string
+	Kind      string // Solo para los logs y el panel de admin (ej: "bienvenida")
+	Locale    string // Idioma en el que se generó
+	Subject   string
+	Body      string // HTML
+	Text      string // Texto plano (para los clientes de correo que no muestran HTML)
+	Sensitive bool   // Lleva un enlace/código secreto: no se muestra a los admins ni se guarda tras enviarse
+}
 
-	"gopkg.in/gomail.v2"
+// Eventos de seguridad que se avisan con SecurityAlertEmail
+const (
+	SecurityEventPasswordChanged = "password_changed"
+	SecurityEventNewDevice       = "new_device"
+	SecurityEventUnusualLocation = "unusual_location"
 )
 
-// SendWelcomeEmail envía un correo de bienvenida al nuevo usuario
-// Si falla solo lo loguea
-func SendWelcomeEmail(toEmail string) {
-	// 1. Leer la configuración del .env
-	host := config.GetEnv("EMAIL_HOST", "smtp.gmail.com")
-	portStr := config.GetEnv("EMAIL_PORT", "587")
-	user := config.GetEnv("EMAIL_USER", "")
-	password := config.GetEnv("EMAIL_PASSWORD", "")
-
-	// 2. Convertir el puerto de string a int
-	port, err := strconv.Atoi(portStr)
+// newEmail genera el email con la plantilla 'template' (ver el paquete emails) en el idioma del usuario.
+// 'locale' vacío = el idioma por defecto (DEFAULT_LOCALE).
+func newEmail(locale string, toEmail string, kind string, template string, data map[string]any) Email {
+	rendered, err := emails.Render(template, locale, data)
 	if err != nil {
-		log.Printf("Error al convertir el puerto de email a int: %v", err)
-		return // No podemos continuar si el puerto es inválido
+		// Las plantillas se prueban al arrancar: solo puede pasar si el código pasa datos de otro tipo
+		logging.Log.Errorf("No se pudo generar el email %s para %s: %v", template, toEmail, err)
 	}
-
-	// 3. Crear el mensaje (el correo)
-	m := gomail.NewMessage()
-	m.SetHeader("From", user)  // De: tu-correo@gmail.com
-	m.SetHeader("To", toEmail) // Para: el-nuevo-usuario@dominio.com
-	m.SetHeader("Subject", "¡Bienvenido a Mi API con Go!")
-	m.SetBody("text/html", "¡Hola! <br><br>Gracias por registrarte en nuestra plataforma. Estamos felices de tenerte.<br><br>Saludos,<br>El equipo de Mi API")
-
-	// 4. Configurar el "Dialer" (el que se conecta al servidor SMTP)
-	// (host, puerto, usuario, contraseña)
-	d := gomail.NewDialer(host, port, user, password)
-
-	// 5. Enviar el correo
-	log.Printf("Intentando enviar email de bienvenida a: %s", toEmail)
-	if err := d.DialAndSend(m); err != nil {
-		// Si hay un error, solo lo logueamos.
-		// No queremos que esto detenga la ejecución principal.
-		log.Printf("Error al enviar el email de bienvenida a %s: %v", toEmail, err)
-	} else {
-		log.Printf("Email de bienvenida enviado exitosamente a: %s", toEmail)
+	return Email{
+		To:      toEmail,
+		Kind:    kind,
+		Locale:  rendered.Locale,
+		Subject: rendered.Subject,
+		Body:    rendered.HTML,
+		Text:    rendered.Text,
 	}
 }
+
+// WelcomeEmail es el correo de bienvenida al nuevo usuario
+func WelcomeEmail(locale string, toEmail string) Email {
+	return newEmail(locale, toEmail, "bienvenida", "welcome", nil)
+}
+
+// AccountExistsEmail avisa al dueño de una cuenta de que alguien intentó registrarse con su email.
+// Se usa en lugar de responder "El usuario ya existe", que permitiría enumerar cuentas.
+func AccountExistsEmail(locale string, toEmail string) Email {
+	return newEmail(locale, toEmail, "cuenta existente", "account_exists", nil)
+}
+
+// VerificationEmail lleva el enlace para confirmar la dirección de email
+func VerificationEmail(locale string, toEmail string, link string, expiresAt time.Time) Email {
+	email := newEmail(locale, toEmail, "verificación", "verification", map[string]any{"Link": link, "ExpiresAt": expiresAt})
+	email.Sensitive = true
+	return email
+}
+
+// MagicLinkEmail lleva el enlace (o, si 'link' está vacío, el código) para iniciar sesión sin contraseña
+func MagicLinkEmail(locale string, toEmail string, link string, code string, expiresAt time.Time) Email {
+	email := newEmail(locale, toEmail, "inicio de sesión sin contraseña", "magic_link", map[string]any{
+		"Link":      link,
+		"Code":      code,
+		"ExpiresAt": expiresAt,
+	})
+	email.Sensitive = true
+	return email
+}
+
+// LockoutEmail avisa de que la cuenta se ha bloqueado por intentos fallidos hasta 'until'
+func LockoutEmail(locale string, toEmail string, until time.Time) Email {
+	return newEmail(locale, toEmail, "bloqueo", "lockout", map[string]any{"Until": until})
+}
+
+// SecurityAlertEmail avisa de un cambio importante en la cuenta (ej: SecurityEventPasswordChanged).
+// 'ip' y 'device' son opcionales.
+func SecurityAlertEmail(locale string, toEmail string, event string, occurredAt time.Time, ip string, device string) Email {
+	return newEmail(locale, toEmail, "alerta de seguridad", "security_alert", map[string]any{
+		"Event":      event,
+		"OccurredAt": occurredAt,
+		"IP":         ip,
+		"Device":     device,
+	})
+}
+
+// LoginAlertEmail avisa de un inicio de sesión desde un dispositivo nuevo (SecurityEventNewDevice)
+// o desde un sitio lejos de los habituales (SecurityEventUnusualLocation). 'location' es opcional.
+func LoginAlertEmail(locale string, toEmail string, event string, occurredAt time.Time, ip string, device string, location string) Email {
+	return newEmail(locale, toEmail, "alerta de inicio de sesión", "security_alert", map[string]any{
+		"Event":      event,
+		"OccurredAt": occurredAt,
+		"IP":         ip,
+		"Device":     device,
+		"Location":   location,
+	})
+}
+
+// DataExportEmail lleva el enlace para descargar la exportación de datos personales.
+// Es "sensible": el enlace da acceso a los datos, así que no se guarda una vez enviado.
+func DataExportEmail(locale string, toEmail string, link string, expiresAt time.Time) Email {
+	email := newEmail(locale, toEmail, "exportación de datos", "data_export", map[string]any{"Link": link, "ExpiresAt": expiresAt})
+	email.Sensitive = true
+	return email
+}
+
+// AccountDeletionScheduledEmail avisa de que la cuenta se borrará y de cómo cancelarlo
+func AccountDeletionScheduledEmail(locale string, toEmail string, scheduledAt time.Time) Email {
+	return newEmail(locale, toEmail, "borrado programado", "deletion_scheduled", map[string]any{"ScheduledAt": scheduledAt})
+}
+
+// AccountDeletedEmail confirma que la cuenta y sus datos se eliminaron
+func AccountDeletedEmail(locale string, toEmail string) Email {
+	return newEmail(locale, toEmail, "cuenta eliminada", "account_deleted", nil)
+}
+
+// InfectedFileEmail avisa de que un archivo subido contenía malware y se ha eliminado
+func InfectedFileEmail(locale string, toEmail string, fileName string) Email {
+	return newEmail(locale, toEmail, "archivo infectado", "infected_file", map[string]any{"FileName": fileName})
+}
@@ -4,6 +4,8 @@ interface User {
     email: string;
     id?: number;
     profile_image_path?: string;
+    profile_image_variants?: Record<string, string>; // Miniaturas por tamaño (ej: "128")
+    profile_image_webp_variants?: Record<string, string>; // Las mismas en WebP (solo si la original es PNG)
 }
 
 export const useAuth = () => {